@@ -0,0 +1,126 @@
+package listing
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/all-in-one/internal/auth"
+	"github.com/all-in-one/internal/config"
+	"github.com/all-in-one/internal/listing/pkg/model"
+	"github.com/all-in-one/internal/listing/pkg/repository"
+	"github.com/sirupsen/logrus"
+)
+
+// openKeyStorage opens the configured storage for managing API keys
+func openKeyStorage(cfg *config.Config) (repository.Storage, error) {
+	if cfg.Storage.Type == "memory" {
+		logrus.Warn("In-memory storage only lives as long as this process; API keys managed here are lost when it exits")
+	}
+
+	return openStorage(cfg)
+}
+
+// CreateAPIKey creates an API key with the given scopes, which name roles
+// of the configured policy, and prints it. The key can't be shown again
+// later.
+func CreateAPIKey(name string, scopes []string) error {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+
+	policy, err := newPolicy(cfg)
+	if err != nil {
+		return err
+	}
+	scopes, err = policy.ParseRoles(scopes)
+	if err != nil {
+		return err
+	}
+
+	token, prefix, hash, err := auth.NewAPIKey()
+	if err != nil {
+		return err
+	}
+
+	store, err := openKeyStorage(cfg)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	key, err := store.APIKeys().Create(model.APIKey{
+		Name:   name,
+		Prefix: prefix,
+		Hash:   hash,
+		Scopes: scopes,
+	})
+	if err != nil {
+		return fmt.Errorf("creating API key: %w", err)
+	}
+
+	fmt.Printf("🔑 Created API key %d (%s) with scopes %s\n", key.ID, key.Name, strings.Join(key.Scopes, ","))
+	fmt.Println()
+	fmt.Printf("   %s\n", token)
+	fmt.Println()
+	fmt.Println("   Store it now; it can't be shown again")
+	return nil
+}
+
+// ListAPIKeys prints all API keys, without their secrets
+func ListAPIKeys() error {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+
+	store, err := openKeyStorage(cfg)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	keys, err := store.APIKeys().List()
+	if err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		fmt.Println("No API keys. Create one with: all-in-one apikey create --name <name>")
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tPREFIX\tSCOPES\tCREATED\tSTATUS")
+	for _, key := range keys {
+		status := "active"
+		if key.Revoked() {
+			status = "revoked " + key.RevokedAt.Format("2006-01-02 15:04")
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\n", key.ID, key.Name, key.Prefix,
+			strings.Join(key.Scopes, ","), key.CreatedAt.Format("2006-01-02 15:04"), status)
+	}
+	return tw.Flush()
+}
+
+// RevokeAPIKey revokes an API key; requests using it are rejected from then on
+func RevokeAPIKey(id int) error {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+
+	store, err := openKeyStorage(cfg)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	if err := store.APIKeys().Revoke(id); err != nil {
+		return fmt.Errorf("revoking API key %d: %w", id, err)
+	}
+
+	fmt.Printf("🚫 Revoked API key %d\n", id)
+	return nil
+}
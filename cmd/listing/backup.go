@@ -0,0 +1,83 @@
+package listing
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/all-in-one/internal/config"
+	"github.com/sirupsen/logrus"
+)
+
+// Backup writes a backup archive of the configured storage to outPath
+func Backup(outPath string) error {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+
+	if cfg.Storage.Type == "memory" {
+		logrus.Warn("Backing up in-memory storage from a separate process yields an empty archive; use GET /api/v1/admin/backup on the running server instead")
+	}
+
+	listingService, err := newListingService(cfg, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer listingService.Close()
+
+	// Write next to the destination and rename, so a failed backup never
+	// leaves a truncated archive in place of a good one
+	tmp, err := os.CreateTemp(filepath.Dir(outPath), ".listing-backup-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	header, err := listingService.Backup(tmp)
+	if err != nil {
+		tmp.Close()
+		return fmt.Errorf("backup failed: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), outPath); err != nil {
+		return err
+	}
+
+	fmt.Printf("💾 Backed up %d items from %s storage to %s (%s)\n", header.ItemCount, cfg.Storage.Type, outPath, header.Checksum)
+	return nil
+}
+
+// Restore replaces the contents of the configured storage with the backup archive at inPath
+func Restore(inPath string) error {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+
+	if cfg.Storage.Type == "memory" {
+		logrus.Warn("Restoring into in-memory storage from a separate process has no lasting effect; use POST /api/v1/admin/restore on the running server instead")
+	}
+
+	f, err := os.Open(inPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	listingService, err := newListingService(cfg, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer listingService.Close()
+
+	header, err := listingService.Restore(f)
+	if err != nil {
+		return fmt.Errorf("restore failed: %w", err)
+	}
+
+	fmt.Printf("♻️  Restored %d items into %s storage from %s (created %s)\n", header.ItemCount, cfg.Storage.Type, inPath, header.CreatedAt.Format("2006-01-02 15:04:05 MST"))
+	return nil
+}
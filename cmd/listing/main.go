@@ -1,61 +1,224 @@
 package listing
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"net/http"
-	"time"
+	"os"
+	"os/signal"
+	"syscall"
 
+	"github.com/all-in-one/internal/auth"
 	"github.com/all-in-one/internal/common"
 	"github.com/all-in-one/internal/config"
 	"github.com/all-in-one/internal/listing"
+	"github.com/all-in-one/internal/listing/pkg/handler"
+	"github.com/all-in-one/internal/listing/pkg/repository"
+	"github.com/all-in-one/internal/listing/pkg/repository/cache"
+	"github.com/all-in-one/internal/listing/pkg/repository/encryption"
+	"github.com/all-in-one/internal/metrics"
+	"github.com/all-in-one/internal/openapi"
+	"github.com/all-in-one/internal/ratelimit"
+	"github.com/all-in-one/internal/server"
+	"github.com/all-in-one/internal/sqlitedb"
+	"github.com/all-in-one/internal/tracing"
 	"github.com/gorilla/mux"
 	"github.com/rs/cors"
 	"github.com/sirupsen/logrus"
 )
 
-// Health check endpoint
-func healthCheck(w http.ResponseWriter, r *http.Request) {
-	logrus.WithFields(logrus.Fields{
-		"method": r.Method,
-		"path":   r.URL.Path,
-		"ip":     r.RemoteAddr,
-	}).Info("Health check requested")
-
-	response := common.Response{
-		Success: true,
-		Message: "Listing API is running",
-		Data: map[string]interface{}{
-			"timestamp": time.Now(),
-			"version":   "1.0.0",
-			"service":   "listing",
-		},
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+// openStorage opens the configured storage backend without any decorators
+func openStorage(cfg *config.Config) (repository.Storage, error) {
+	var opts repository.Options
+
+	switch cfg.Storage.Type {
+	case "sqlite":
+		logrus.WithFields(logrus.Fields{
+			"db_path": cfg.Storage.Path,
+			"driver":  cfg.Storage.Driver,
+		}).Info("Initializing SQLite storage")
+		opts.SQLite = sqlitedb.FromConfig(cfg.Storage)
+	case "bolt":
+		logrus.WithField("db_path", cfg.Storage.Path).Info("Initializing bolt storage")
+	case "memory":
+		logrus.Info("Initializing in-memory storage")
+	default:
+		return nil, fmt.Errorf("unknown storage type %q. Supported types: memory, sqlite, bolt", cfg.Storage.Type)
+	}
+
+	opts.Clock = common.SystemClock{}
+	ids, err := common.NewIDGenerator(cfg.Storage.IDs, opts.Clock)
+	if err != nil {
+		return nil, err
+	}
+	opts.IDs = ids
+
+	return repository.NewStorage(cfg.Storage.Type, cfg.Storage.Path, opts)
 }
 
-// LoggingMiddleware logs all HTTP requests
-func loggingMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		start := time.Now()
+// newListingService creates the listing service for the configured storage
+// backend, with encryption and caching layered on top as configured. With
+// m, the backend's operations are timed, and with tp they're traced.
+func newListingService(cfg *config.Config, m *metrics.Metrics, tp *tracing.Provider) (*listing.Service, error) {
+	store, err := openStorage(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// Time the backend itself, below encryption and the cache
+	if m != nil {
+		timed, err := instrumentStorage(m, cfg.Storage.Type, store)
+		if err != nil {
+			store.Close()
+			return nil, fmt.Errorf("registering storage metrics: %w", err)
+		}
+		store = timed
+	}
+
+	// Encrypt below the cache, so the cache holds plaintext and hits skip
+	// decryption
+	if cfg.Storage.Encryption.Enabled {
+		keys, err := encryption.LoadKeyring(cfg.Storage.Encryption.Keyfile)
+		if err != nil {
+			store.Close()
+			return nil, fmt.Errorf("loading encryption keys: %w", err)
+		}
+		logrus.WithFields(logrus.Fields{
+			"keyfile": cfg.Storage.Encryption.Keyfile,
+			"primary": keys.Primary(),
+			"keys":    len(keys.KeyIDs()),
+		}).Info("Enabling item encryption")
+		store = encryption.New(store, keys)
+	}
 
+	if cfg.Storage.Cache.Enabled {
 		logrus.WithFields(logrus.Fields{
-			"method": r.Method,
-			"path":   r.URL.Path,
-			"ip":     r.RemoteAddr,
-		}).Info("Request started")
+			"size": cfg.Storage.Cache.Size,
+			"ttl":  cfg.Storage.Cache.TTL.String(),
+		}).Info("Enabling item cache")
+		store = cache.New(store, cache.Options{
+			Size: cfg.Storage.Cache.Size,
+			TTL:  cfg.Storage.Cache.TTL,
+		})
+	}
 
-		next.ServeHTTP(w, r)
+	// Trace outermost, as the handlers bind the storage to their request
+	if tp != nil {
+		store = traceStorage(cfg, tp, store)
+	}
 
-		logrus.WithFields(logrus.Fields{
-			"method":   r.Method,
-			"path":     r.URL.Path,
-			"ip":       r.RemoteAddr,
-			"duration": time.Since(start),
-		}).Info("Request completed")
+	return listing.NewService(store), nil
+}
+
+// newAuthenticators returns the configured ways of authenticating API
+// requests: API keys from the storage, plus JWTs if enabled
+func newAuthenticators(cfg *config.Config, store repository.Storage) ([]auth.Authenticator, error) {
+	authenticators := []auth.Authenticator{auth.APIKeyAuthenticator{Keys: repository.AuthKeys(store)}}
+	if !cfg.Auth.JWT.Enabled {
+		return authenticators, nil
+	}
+
+	jwtCfg := cfg.Auth.JWT
+	var keys *auth.KeySet
+	var err error
+	switch {
+	case jwtCfg.KeyFile != "" && jwtCfg.Secret != "":
+		return nil, fmt.Errorf("auth.jwt.key_file and auth.jwt.secret are mutually exclusive")
+	case jwtCfg.KeyFile != "":
+		keys, err = auth.LoadKeySet(jwtCfg.KeyFile)
+	case jwtCfg.Secret != "":
+		keys, err = auth.NewHMACKeySet([]byte(jwtCfg.Secret))
+	default:
+		return nil, fmt.Errorf("JWT validation needs auth.jwt.key_file or auth.jwt.secret")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("loading JWT keys: %w", err)
+	}
+
+	validator, err := auth.NewJWTValidator(auth.JWTOptions{
+		Keys:        keys,
+		Issuer:      jwtCfg.Issuer,
+		Audience:    jwtCfg.Audience,
+		ScopesClaim: jwtCfg.ScopesClaim,
+		Leeway:      jwtCfg.Leeway,
 	})
+	if err != nil {
+		return nil, err
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"issuer":   jwtCfg.Issuer,
+		"audience": jwtCfg.Audience,
+		"keys":     keys.Len(),
+	}).Info("JWT authentication enabled")
+
+	return append(authenticators, validator), nil
+}
+
+// newPolicy returns the role → permissions policy from auth.roles, or the
+// built-in roles if none are configured
+func newPolicy(cfg *config.Config) (*auth.Policy, error) {
+	if len(cfg.Auth.Roles) == 0 {
+		return auth.NewPolicy(auth.DefaultRoles())
+	}
+	return auth.NewPolicy(cfg.Auth.Roles)
+}
+
+// newRateLimiter returns the limiter configured under rate_limit, exiting
+// if the configuration is invalid
+func newRateLimiter(cfg *config.Config) *ratelimit.Limiter {
+	limiter, err := ratelimit.FromConfig(cfg.RateLimit)
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to initialize rate limiting")
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"default_requests": cfg.RateLimit.Default.Requests,
+		"default_period":   cfg.RateLimit.Default.Period.String(),
+		"groups":           len(cfg.RateLimit.Groups),
+	}).Info("Rate limiting enabled")
+	fmt.Println("🚦 Rate limiting enabled")
+	return limiter
+}
+
+// registerRoutes registers the health check, the OpenAPI document and docs
+// page, and the listing API with middleware on r. Permissions are checked
+// with the service's policy at request time.
+func registerRoutes(r *mux.Router, listingService *listing.Service, middleware ...mux.MiddlewareFunc) *openapi.Spec {
+	// Public routes, registered ahead of the API subrouter
+	r.HandleFunc("/api/v1/health", server.HealthCheck("listing", "Listing API is running")).Methods("GET").Name("health")
+	spec := openapi.NewSpec(r, openapi.Options{
+		Title:           "Listing API",
+		Version:         "1.0.0",
+		Description:     "Manage and serve listing items.",
+		SecuritySchemes: handler.SecuritySchemes,
+	}, server.HealthOperations, listingService.Operations())
+	spec.RegisterRoutes(r, "/api/v1")
+
+	// API routes
+	api := r.PathPrefix("/api/v1").Subrouter()
+	api.Use(middleware...)
+	listingService.RegisterRoutes(api)
+
+	// Admin routes
+	admin := api.PathPrefix("/admin").Subrouter()
+	listingService.RegisterAdminRoutes(admin)
+
+	return spec
+}
+
+// printEndpoints prints the routes of spec's router
+func printEndpoints(spec *openapi.Spec) {
+	endpoints, err := spec.Endpoints()
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to list endpoints")
+		return
+	}
+
+	fmt.Println("📋 Available endpoints:")
+	for _, e := range endpoints {
+		fmt.Printf("  %-6s %-26s - %s\n", e.Method, e.Path, e.Summary)
+	}
+	fmt.Println()
 }
 
 // Run starts the listing service
@@ -76,26 +239,24 @@ func Run() {
 	logrus.WithField("storage_type", cfg.Storage.Type).Info("Configuration loaded")
 	fmt.Printf("🔧 Using %s storage\n", cfg.Storage.Type)
 
-	// Initialize listing service based on configuration
-	var listingService *listing.Service
+	var m *metrics.Metrics
+	if cfg.Metrics.Enabled {
+		m = metrics.New()
+	}
 
-	switch cfg.Storage.Type {
-	case "sqlite":
-		logrus.WithField("db_path", cfg.Storage.Path).Info("Initializing SQLite storage")
-		listingService, err = listing.NewSQLiteService(cfg.Storage.Path)
+	var tp *tracing.Provider
+	if cfg.Tracing.Enabled {
+		tp, err = tracing.SetupFromConfig(cfg.Tracing, "listing")
 		if err != nil {
-			logrus.WithError(err).Fatal("Failed to initialize SQLite storage")
+			logrus.WithError(err).Fatal("Failed to initialize tracing")
 		}
-		defer func() {
-			if err := listingService.Close(); err != nil {
-				logrus.WithError(err).Error("Error closing SQLite storage")
-			}
-		}()
-	case "memory":
-		logrus.Info("Initializing in-memory storage")
-		listingService = listing.NewMemoryService()
-	default:
-		logrus.WithField("storage_type", cfg.Storage.Type).Fatal("Unknown storage type. Supported types: memory, sqlite")
+		fmt.Printf("🔭 Tracing enabled (%s exporter)\n", cfg.Tracing.Exporter)
+	}
+
+	// Initialize listing service based on configuration
+	listingService, err := newListingService(cfg, m, tp)
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to initialize storage")
 	}
 
 	// Initialize sample data
@@ -106,40 +267,122 @@ func Run() {
 	// Initialize router
 	r := mux.NewRouter()
 
+	// Trace requests, first so the other middleware runs within the span
+	if tp != nil {
+		r.Use(tp.Middleware)
+	}
+
 	// Add logging middleware
-	r.Use(loggingMiddleware)
+	r.Use(server.LogRequests)
 
-	// API routes
-	api := r.PathPrefix("/api/v1").Subrouter()
+	// Error responses
+	switch cfg.Server.ErrorFormat {
+	case "problem":
+		r.Use(common.ProblemJSON)
+	case "json", "":
+	default:
+		logrus.WithField("error_format", cfg.Server.ErrorFormat).Fatal("Unknown server.error_format. Supported formats: json, problem")
+	}
 
-	// Register listing routes
-	listingService.RegisterRoutes(api)
+	listingService.SetMaxRestoreSize(cfg.Server.MaxRestoreSize)
+
+	// API middleware. With authentication, rejected requests are rate
+	// limited by IP and authenticated ones by key.
+	var middleware []mux.MiddlewareFunc
+	var limiter *ratelimit.Limiter
+	if cfg.RateLimit.Enabled {
+		limiter = newRateLimiter(cfg)
+	}
+	if cfg.Auth.Enabled {
+		authenticators, err := newAuthenticators(cfg, listingService.Storage)
+		if err != nil {
+			logrus.WithError(err).Fatal("Failed to initialize authentication")
+		}
+		policy, err := newPolicy(cfg)
+		if err != nil {
+			logrus.WithError(err).Fatal("Failed to load roles")
+		}
+		if limiter != nil {
+			middleware = append(middleware, limiter.FailedAuthMiddleware)
+		}
+		middleware = append(middleware, auth.Middleware(authenticators...))
+		listingService.SetPolicy(policy)
+		logrus.WithField("roles", policy.Roles()).Info("Authentication enabled")
+		fmt.Println("🔒 Authentication enabled")
+	} else {
+		listingService.SetPolicy(auth.AllowAll())
+		logrus.Warn("Authentication is disabled; anyone who can reach the port can change data")
+	}
+	if limiter != nil {
+		middleware = append(middleware, limiter.Middleware)
+	}
 
-	// Health check
-	api.HandleFunc("/health", healthCheck).Methods("GET")
+	spec := registerRoutes(r, listingService, middleware...)
 
 	// Setup CORS for frontend integration
 	c := cors.New(cors.Options{
 		AllowedOrigins: []string{"*"}, // In production, specify your frontend domain
-		AllowedMethods: []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		AllowedHeaders: []string{"*"},
 	})
 
 	// Wrap router with CORS
-	handler := c.Handler(r)
+	httpHandler := c.Handler(r)
+
+	// Metrics, outermost so that every request is counted
+	var metricsServer *metrics.AdminServer
+	if m != nil {
+		httpHandler, metricsServer = instrumentHandler(cfg, m, listingService, r, httpHandler)
+	}
 
 	// Start server
 	port := cfg.Server.Port
 	logrus.WithField("port", port).Info("Starting HTTP server")
 	fmt.Printf("🚀 Listing Service starting on port %s\n", port)
-	fmt.Println("📋 Available endpoints:")
-	fmt.Println("  GET    /api/v1/health      - Health check")
-	fmt.Println("  GET    /api/v1/items       - Get all items")
-	fmt.Println("  POST   /api/v1/items       - Create new item")
-	fmt.Println("  GET    /api/v1/items/{id}  - Get item by ID")
-	fmt.Println("  PUT    /api/v1/items/{id}  - Update item")
-	fmt.Println("  DELETE /api/v1/items/{id}  - Delete item")
-	fmt.Println()
+	printEndpoints(spec)
 
-	logrus.Fatal(http.ListenAndServe(port, handler))
+	srv, err := server.FromConfig(cfg.Server, port, httpHandler, true)
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to initialize HTTP server")
+	}
+	if srv.TLS() {
+		logrus.WithFields(logrus.Fields{
+			"cert_file":   cfg.Server.TLS.CertFile,
+			"min_version": cfg.Server.TLS.MinVersion,
+			"client_ca":   cfg.Server.TLS.ClientCAFile,
+		}).Info("TLS enabled")
+		fmt.Println("🔐 TLS enabled")
+		if cfg.Server.TLS.ClientCAFile != "" {
+			fmt.Println("🪪 Client certificates verified (mutual TLS)")
+		}
+		if cfg.Server.TLS.RedirectAddr != "" {
+			fmt.Printf("↪️  Redirecting HTTP on %s to HTTPS\n", cfg.Server.TLS.RedirectAddr)
+		}
+	}
+	if metricsServer != nil {
+		srv.Go("metrics", metricsServer.Serve)
+	}
+	// Hooks run last to first, so spans are flushed once everything else
+	// has stopped
+	if tp != nil {
+		srv.OnShutdown("tracing", tp.Shutdown)
+	}
+	srv.OnShutdown("storage", func(context.Context) error {
+		return listingService.Close()
+	})
+
+	// Shut down on SIGINT or SIGTERM. A second signal kills the process
+	// without waiting.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	context.AfterFunc(ctx, func() {
+		stop()
+		fmt.Println("🛑 Shutting down, waiting for in-flight requests...")
+	})
+
+	if err := srv.Run(ctx); err != nil {
+		logrus.WithError(err).Fatal("Listing Service stopped with errors")
+	}
+	logrus.Info("Listing Service stopped")
+	fmt.Println("👋 Listing Service stopped")
 }
@@ -0,0 +1,67 @@
+package listing
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+
+	"github.com/all-in-one/internal/config"
+	"github.com/all-in-one/internal/listing"
+	"github.com/all-in-one/internal/listing/pkg/repository"
+	"github.com/all-in-one/internal/listing/pkg/repository/instrumented"
+	"github.com/all-in-one/internal/metrics"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// instrumentStorage times the operations of the backend store and reports
+// its connection pool, if it has one
+func instrumentStorage(m *metrics.Metrics, backend string, store repository.Storage) (repository.Storage, error) {
+	if _, ok := repository.DBStats(store); ok {
+		pool := metrics.NewDBStatsCollector("listing", func() sql.DBStats {
+			stats, _ := repository.DBStats(store)
+			return stats
+		})
+		if err := m.Register(pool); err != nil {
+			return nil, err
+		}
+	}
+
+	return instrumented.New(store, m.ObserveRepository(backend)), nil
+}
+
+// instrumentHandler counts and times the requests served by handler, whose
+// routes are r, and reports the number of items. The metrics are served on
+// metrics.addr by the returned admin server, to be run alongside the API,
+// or next to the API by the returned handler if the address is empty.
+func instrumentHandler(cfg *config.Config, m *metrics.Metrics, listingService *listing.Service, r *mux.Router, handler http.Handler) (http.Handler, *metrics.AdminServer) {
+	items := metrics.NewCountCollector("listing_items", "Number of listing items.", func() (int, error) {
+		return listingService.Storage.Items().Count()
+	})
+	if err := m.Register(items); err != nil {
+		logrus.WithError(err).Fatal("Failed to register metrics")
+	}
+
+	handler = m.Instrument(r, handler)
+
+	if cfg.Metrics.Addr == "" {
+		mux := http.NewServeMux()
+		mux.Handle("GET "+cfg.Metrics.Path, m.Handler())
+		mux.Handle("/", handler)
+
+		logrus.WithField("path", cfg.Metrics.Path).Info("Serving metrics on the API port")
+		fmt.Printf("📈 Metrics on %s\n", cfg.Metrics.Path)
+		return mux, nil
+	}
+
+	admin, err := m.Listen(cfg.Metrics.Addr, cfg.Metrics.Path)
+	if err != nil {
+		logrus.WithError(err).WithField("addr", cfg.Metrics.Addr).Fatal("Failed to open the metrics port")
+	}
+	logrus.WithFields(logrus.Fields{
+		"addr": admin.Addr().String(),
+		"path": cfg.Metrics.Path,
+	}).Info("Serving metrics on the admin port")
+	fmt.Printf("📈 Metrics on %s%s\n", cfg.Metrics.Addr, cfg.Metrics.Path)
+	return handler, admin
+}
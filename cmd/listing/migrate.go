@@ -0,0 +1,92 @@
+package listing
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/all-in-one/internal/config"
+	"github.com/all-in-one/internal/listing/pkg/migration"
+	"github.com/sirupsen/logrus"
+)
+
+// parseStorageURL splits a storage location such as "memory",
+// "sqlite://./data/listings.db" or "bolt://./data/listings.bolt" into a
+// storage type and path
+func parseStorageURL(location string) (storageType, path string, err error) {
+	if location == "memory" || location == "memory://" {
+		return "memory", "", nil
+	}
+
+	storageType, path, ok := strings.Cut(location, "://")
+	if !ok || path == "" {
+		return "", "", fmt.Errorf("invalid storage location %q (expected memory, sqlite://path or bolt://path)", location)
+	}
+
+	switch storageType {
+	case "sqlite", "bolt":
+		return storageType, path, nil
+	default:
+		return "", "", fmt.Errorf("unsupported storage type %q in %q. Supported types: memory, sqlite, bolt", storageType, location)
+	}
+}
+
+// MigrateStorage copies all items from one storage backend to another
+func MigrateStorage(from, to string, batchSize int, resume bool) error {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+
+	fromType, fromPath, err := parseStorageURL(from)
+	if err != nil {
+		return err
+	}
+	toType, toPath, err := parseStorageURL(to)
+	if err != nil {
+		return err
+	}
+	if fromType == toType && fromPath == toPath {
+		return fmt.Errorf("source and target are the same storage")
+	}
+	if fromType == "memory" || toType == "memory" {
+		logrus.Warn("In-memory storage only lives as long as this process; migrating from or to it is only useful for testing")
+	}
+
+	// Both sides share the configured backend settings (SQLite driver,
+	// pragmas, ...) and differ only in type and location
+	fromCfg, toCfg := *cfg, *cfg
+	fromCfg.Storage.Type, fromCfg.Storage.Path = fromType, fromPath
+	toCfg.Storage.Type, toCfg.Storage.Path = toType, toPath
+
+	source, err := newListingService(&fromCfg, nil, nil)
+	if err != nil {
+		return fmt.Errorf("opening source: %w", err)
+	}
+	defer source.Close()
+
+	target, err := newListingService(&toCfg, nil, nil)
+	if err != nil {
+		return fmt.Errorf("opening target: %w", err)
+	}
+	defer target.Close()
+
+	fmt.Printf("🚚 Migrating items from %s to %s\n", from, to)
+
+	result, err := migration.Run(source.Storage, target.Storage, migration.Options{
+		BatchSize: batchSize,
+		Resume:    resume,
+		Progress: func(p migration.Progress) {
+			fmt.Printf("  … copied %d, skipped %d (up to ID %d)\n", p.Copied, p.Skipped, p.LastID)
+		},
+	})
+	if err != nil {
+		if err == migration.ErrTargetNotEmpty {
+			return fmt.Errorf("%w; pass --resume to continue an interrupted migration", err)
+		}
+		return err
+	}
+
+	fmt.Printf("✅ Migrated %d items (%d already present); source has %d, target has %d\n",
+		result.Copied, result.Skipped, result.SourceCount, result.TargetCount)
+	return nil
+}
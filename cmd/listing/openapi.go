@@ -0,0 +1,20 @@
+package listing
+
+import (
+	"github.com/all-in-one/internal/listing"
+	"github.com/gorilla/mux"
+)
+
+// OpenAPI writes the OpenAPI document of the listing API to outPath, or to
+// stdout if it's empty. The routes are the ones Run registers; storage and
+// configuration don't change them.
+func OpenAPI(outPath string) error {
+	listingService := listing.NewMemoryService()
+	defer listingService.Close()
+
+	doc, err := registerRoutes(mux.NewRouter(), listingService).Document()
+	if err != nil {
+		return err
+	}
+	return doc.WriteFile(outPath)
+}
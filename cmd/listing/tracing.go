@@ -0,0 +1,21 @@
+package listing
+
+import (
+	"path/filepath"
+
+	"github.com/all-in-one/internal/config"
+	"github.com/all-in-one/internal/listing/pkg/repository"
+	"github.com/all-in-one/internal/listing/pkg/repository/traced"
+	"github.com/all-in-one/internal/tracing"
+)
+
+// traceStorage gives each operation on store a span in the trace of the
+// request it serves. The namespace is the database file name, so spans
+// don't carry the server's directory layout.
+func traceStorage(cfg *config.Config, provider *tracing.Provider, store repository.Storage) repository.Storage {
+	opts := traced.Options{Tracer: provider.Tracer(), System: cfg.Storage.Type}
+	if cfg.Storage.Type != "memory" {
+		opts.Namespace = filepath.Base(cfg.Storage.Path)
+	}
+	return traced.New(store, opts)
+}
@@ -0,0 +1,216 @@
+package users
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/all-in-one/internal/common"
+	"github.com/all-in-one/internal/config"
+	"github.com/all-in-one/internal/openapi"
+	"github.com/all-in-one/internal/ratelimit"
+	"github.com/all-in-one/internal/server"
+	"github.com/all-in-one/internal/sqlitedb"
+	"github.com/all-in-one/internal/tracing"
+	"github.com/all-in-one/internal/users"
+	"github.com/all-in-one/internal/users/pkg/handler"
+	"github.com/all-in-one/internal/users/pkg/repository"
+	"github.com/gorilla/mux"
+	"github.com/rs/cors"
+	"github.com/sirupsen/logrus"
+)
+
+// openStorage opens the configured users storage backend
+func openStorage(cfg *config.Config) (repository.Storage, error) {
+	var opts repository.Options
+
+	switch cfg.Users.Storage.Type {
+	case "sqlite":
+		// Both databases keep their schema version in PRAGMA user_version,
+		// so they can't share a file
+		if cfg.Storage.Type == "sqlite" && filepath.Clean(cfg.Users.Storage.Path) == filepath.Clean(cfg.Storage.Path) {
+			return nil, fmt.Errorf("users.storage.path must differ from storage.path (%s)", cfg.Storage.Path)
+		}
+		logrus.WithFields(logrus.Fields{
+			"db_path": cfg.Users.Storage.Path,
+			"driver":  cfg.Storage.Driver,
+		}).Info("Initializing SQLite user storage")
+		opts.SQLite = sqlitedb.FromConfig(cfg.Storage)
+	case "memory":
+		logrus.Info("Initializing in-memory user storage")
+	default:
+		return nil, fmt.Errorf("unknown users storage type %q. Supported types: memory, sqlite", cfg.Users.Storage.Type)
+	}
+
+	opts.Clock = common.SystemClock{}
+	return repository.NewStorage(cfg.Users.Storage.Type, cfg.Users.Storage.Path, opts)
+}
+
+// registerRoutes registers the health check, the OpenAPI document and docs
+// page, and the users API with middleware on r
+func registerRoutes(r *mux.Router, usersService *users.Service, middleware ...mux.MiddlewareFunc) *openapi.Spec {
+	// Public routes, registered ahead of the API subrouter
+	r.HandleFunc("/api/v1/health", server.HealthCheck("users", "Users API is running")).Methods("GET").Name("health")
+	spec := openapi.NewSpec(r, openapi.Options{
+		Title:           "Users API",
+		Version:         "1.0.0",
+		Description:     "Registration, password login and sessions.",
+		SecuritySchemes: handler.SecuritySchemes,
+	}, server.HealthOperations, usersService.Operations())
+	spec.RegisterRoutes(r, "/api/v1")
+
+	// API routes
+	api := r.PathPrefix("/api/v1").Subrouter()
+	api.Use(middleware...)
+	usersService.RegisterRoutes(api)
+
+	return spec
+}
+
+// printEndpoints prints the routes of spec's router
+func printEndpoints(spec *openapi.Spec) {
+	endpoints, err := spec.Endpoints()
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to list endpoints")
+		return
+	}
+
+	fmt.Println("📋 Available endpoints:")
+	for _, e := range endpoints {
+		fmt.Printf("  %-6s %-26s - %s\n", e.Method, e.Path, e.Summary)
+	}
+	fmt.Println()
+}
+
+// Run starts the users service
+func Run() {
+	// Setup logging
+	logrus.SetFormatter(&logrus.JSONFormatter{})
+	logrus.SetLevel(logrus.InfoLevel)
+
+	fmt.Println("👤 Starting Users Service...")
+	logrus.Info("Initializing Users Service")
+
+	// Load configuration
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to load config")
+	}
+
+	logrus.WithField("storage_type", cfg.Users.Storage.Type).Info("Configuration loaded")
+	fmt.Printf("🔧 Using %s storage\n", cfg.Users.Storage.Type)
+
+	store, err := openStorage(cfg)
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to initialize storage")
+	}
+	usersService := users.NewService(store, handler.Options{
+		SessionTTL:    cfg.Users.SessionTTL,
+		SecureCookies: cfg.Users.SecureCookies,
+	})
+	if !cfg.Users.SecureCookies {
+		logrus.Warn("users.secure_cookies is off; session cookies are also sent over plain HTTP")
+	}
+
+	var tp *tracing.Provider
+	if cfg.Tracing.Enabled {
+		tp, err = tracing.SetupFromConfig(cfg.Tracing, "users")
+		if err != nil {
+			logrus.WithError(err).Fatal("Failed to initialize tracing")
+		}
+		fmt.Printf("🔭 Tracing enabled (%s exporter)\n", cfg.Tracing.Exporter)
+	}
+
+	// Initialize router
+	r := mux.NewRouter()
+
+	// Trace requests, first so the other middleware runs within the span
+	if tp != nil {
+		r.Use(tp.Middleware)
+	}
+
+	// Add logging middleware
+	r.Use(server.LogRequests)
+
+	// Error responses
+	switch cfg.Server.ErrorFormat {
+	case "problem":
+		r.Use(common.ProblemJSON)
+	case "json", "":
+	default:
+		logrus.WithField("error_format", cfg.Server.ErrorFormat).Fatal("Unknown server.error_format. Supported formats: json, problem")
+	}
+
+	// API middleware
+	var middleware []mux.MiddlewareFunc
+	if cfg.RateLimit.Enabled {
+		limiter, err := ratelimit.FromConfig(cfg.RateLimit)
+		if err != nil {
+			logrus.WithError(err).Fatal("Failed to initialize rate limiting")
+		}
+		middleware = append(middleware, limiter.Middleware)
+		logrus.Info("Rate limiting enabled")
+		fmt.Println("🚦 Rate limiting enabled")
+	}
+
+	spec := registerRoutes(r, usersService, middleware...)
+
+	// Setup CORS for frontend integration. Session cookies are never sent
+	// cross-origin, as credentials aren't allowed.
+	c := cors.New(cors.Options{
+		AllowedOrigins: []string{"*"}, // In production, specify your frontend domain
+		AllowedMethods: []string{"GET", "POST", "PUT", "OPTIONS"},
+		AllowedHeaders: []string{"*"},
+	})
+
+	// Wrap router with CORS
+	httpHandler := c.Handler(r)
+
+	// Start server
+	port := cfg.Users.Port
+	logrus.WithField("port", port).Info("Starting HTTP server")
+	fmt.Printf("🚀 Users Service starting on port %s\n", port)
+	printEndpoints(spec)
+
+	srv, err := server.FromConfig(cfg.Server, port, httpHandler, false)
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to initialize HTTP server")
+	}
+	if srv.TLS() {
+		logrus.WithFields(logrus.Fields{
+			"cert_file":   cfg.Server.TLS.CertFile,
+			"min_version": cfg.Server.TLS.MinVersion,
+			"client_ca":   cfg.Server.TLS.ClientCAFile,
+		}).Info("TLS enabled")
+		fmt.Println("🔐 TLS enabled")
+		if cfg.Server.TLS.ClientCAFile != "" {
+			fmt.Println("🪪 Client certificates verified (mutual TLS)")
+		}
+	}
+	// Hooks run last to first, so spans are flushed once everything else
+	// has stopped
+	if tp != nil {
+		srv.OnShutdown("tracing", tp.Shutdown)
+	}
+	srv.OnShutdown("storage", func(context.Context) error {
+		return usersService.Close()
+	})
+
+	// Shut down on SIGINT or SIGTERM. A second signal kills the process
+	// without waiting.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	context.AfterFunc(ctx, func() {
+		stop()
+		fmt.Println("🛑 Shutting down, waiting for in-flight requests...")
+	})
+
+	if err := srv.Run(ctx); err != nil {
+		logrus.WithError(err).Fatal("Users Service stopped with errors")
+	}
+	logrus.Info("Users Service stopped")
+	fmt.Println("👋 Users Service stopped")
+}
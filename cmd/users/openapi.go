@@ -0,0 +1,21 @@
+package users
+
+import (
+	"github.com/all-in-one/internal/users"
+	"github.com/all-in-one/internal/users/pkg/handler"
+	"github.com/gorilla/mux"
+)
+
+// OpenAPI writes the OpenAPI document of the users API to outPath, or to
+// stdout if it's empty. The routes are the ones Run registers; storage and
+// configuration don't change them.
+func OpenAPI(outPath string) error {
+	usersService := users.NewMemoryService(handler.Options{})
+	defer usersService.Close()
+
+	doc, err := registerRoutes(mux.NewRouter(), usersService).Document()
+	if err != nil {
+		return err
+	}
+	return doc.WriteFile(outPath)
+}
@@ -0,0 +1,104 @@
+// Package auth authenticates API requests
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/all-in-one/internal/common"
+)
+
+// apiKeyTag starts every API key, so leaked keys are easy to spot
+const apiKeyTag = "aio"
+
+// NewAPIKey generates a random API key of the form aio_<prefix>_<secret>.
+// It returns the key, which is only ever shown to its owner, along with
+// the prefix and hash to store.
+func NewAPIKey() (key, prefix, hash string, err error) {
+	var b [4 + 32]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", "", "", fmt.Errorf("reading random bytes: %w", err)
+	}
+
+	prefix = hex.EncodeToString(b[:4])
+	key = apiKeyTag + "_" + prefix + "_" + hex.EncodeToString(b[4:])
+	return key, prefix, HashAPIKey(key), nil
+}
+
+// HashAPIKey returns the hash stored for key. Keys carry 256 random bits,
+// so a fast hash is enough; there's nothing to brute-force.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// ParseAPIKey returns the prefix of key, or false if key isn't shaped like
+// an API key
+func ParseAPIKey(key string) (string, bool) {
+	tag, rest, ok := strings.Cut(key, "_")
+	if !ok || tag != apiKeyTag {
+		return "", false
+	}
+
+	prefix, secret, ok := strings.Cut(rest, "_")
+	if !ok || prefix == "" || secret == "" {
+		return "", false
+	}
+
+	return prefix, true
+}
+
+// StoredKey is an API key as kept by a KeyStore
+type StoredKey struct {
+	ID      int
+	Name    string
+	Hash    string // HashAPIKey of the whole key
+	Scopes  []string
+	Revoked bool
+}
+
+// KeyStore looks up API keys by prefix, failing with common.ErrNotFound
+// for unknown ones
+type KeyStore interface {
+	LookupAPIKey(prefix string) (StoredKey, error)
+}
+
+// APIKeyAuthenticator authenticates API keys created with NewAPIKey
+type APIKeyAuthenticator struct {
+	Keys KeyStore
+}
+
+// Authenticate returns the principal of an active API key
+func (a APIKeyAuthenticator) Authenticate(token string) (Principal, error) {
+	prefix, ok := ParseAPIKey(token)
+	if !ok {
+		return Principal{}, ErrUnrecognized
+	}
+
+	key, err := a.Keys.LookupAPIKey(prefix)
+	if err != nil {
+		if errors.Is(err, common.ErrNotFound) {
+			return Principal{}, fmt.Errorf("%w: unknown API key", ErrInvalidToken)
+		}
+		return Principal{}, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(HashAPIKey(token)), []byte(key.Hash)) != 1 {
+		return Principal{}, fmt.Errorf("%w: unknown API key", ErrInvalidToken)
+	}
+	if key.Revoked {
+		return Principal{}, fmt.Errorf("%w: API key %d is revoked", ErrInvalidToken, key.ID)
+	}
+
+	return Principal{
+		Subject: fmt.Sprintf("apikey:%d", key.ID),
+		Name:    key.Name,
+		Method:  "apikey",
+		Roles:   key.Scopes,
+	}, nil
+}
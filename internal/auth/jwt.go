@@ -0,0 +1,252 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/all-in-one/internal/common"
+)
+
+// JWTOptions configures a JWTValidator
+type JWTOptions struct {
+	// Keys verify token signatures. Required.
+	Keys *KeySet
+
+	// Issuer is the required iss claim. Required, so that tokens minted by
+	// another issuer sharing the keys are refused.
+	Issuer string
+
+	// Audience must be in the aud claim. Required, so that tokens minted
+	// for another service are refused.
+	Audience string
+
+	// ScopesClaim names the claim holding the granted scopes, which become
+	// the principal's roles. It's either a space-separated string or an
+	// array. Empty means "scope".
+	ScopesClaim string
+
+	// Leeway allows for clock skew when checking exp and nbf
+	Leeway time.Duration
+
+	// Clock tells the time for expiry checks. Nil means the system clock.
+	Clock common.Clock
+}
+
+// JWTValidator authenticates HS256, RS256 and ES256 signed JWTs
+type JWTValidator struct {
+	opts JWTOptions
+}
+
+// NewJWTValidator creates a validator
+func NewJWTValidator(opts JWTOptions) (*JWTValidator, error) {
+	if opts.Keys == nil || opts.Keys.Len() == 0 {
+		return nil, fmt.Errorf("JWT validation needs at least one key")
+	}
+	if opts.Issuer == "" || opts.Audience == "" {
+		return nil, fmt.Errorf("JWT validation needs an issuer and an audience")
+	}
+	if opts.ScopesClaim == "" {
+		opts.ScopesClaim = "scope"
+	}
+	if opts.Clock == nil {
+		opts.Clock = common.SystemClock{}
+	}
+
+	return &JWTValidator{opts: opts}, nil
+}
+
+// jwtHeader is the JOSE header of a JWT
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	Typ string `json:"typ"`
+}
+
+// Authenticate verifies a JWT and returns its principal. Tokens that aren't
+// shaped like a JWT are left for other authenticators.
+func (v *JWTValidator) Authenticate(token string) (Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Principal{}, ErrUnrecognized
+	}
+
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return Principal{}, ErrUnrecognized
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Principal{}, fmt.Errorf("%w: malformed signature", ErrInvalidToken)
+	}
+	if err := v.verify(header, parts[0]+"."+parts[1], signature); err != nil {
+		return Principal{}, err
+	}
+
+	var claims map[string]any
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return Principal{}, fmt.Errorf("%w: malformed claims", ErrInvalidToken)
+	}
+	if err := v.checkClaims(claims); err != nil {
+		return Principal{}, err
+	}
+
+	principal := Principal{
+		Subject: stringClaim(claims, "sub"),
+		Name:    stringClaim(claims, "name"),
+		Method:  "jwt",
+		Roles:   stringsClaim(claims, v.opts.ScopesClaim),
+		Claims:  claims,
+	}
+	if principal.Name == "" {
+		principal.Name = stringClaim(claims, "email")
+	}
+	if principal.Subject == "" {
+		return Principal{}, fmt.Errorf("%w: missing sub claim", ErrInvalidToken)
+	}
+
+	return principal, nil
+}
+
+// verify checks the signature with the keys that may sign header.Alg
+// tokens, narrowed down by kid if the token has one
+func (v *JWTValidator) verify(header jwtHeader, signed string, signature []byte) error {
+	switch header.Alg {
+	case AlgHS256, AlgRS256, AlgES256:
+	default:
+		// Includes "none"
+		return fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidToken, header.Alg)
+	}
+
+	candidates := 0
+	for _, key := range v.opts.Keys.keys {
+		// Matching the algorithm to the key's type stops a public RSA key
+		// being used as an HMAC secret
+		if key.alg != header.Alg || (header.Kid != "" && key.id != "" && key.id != header.Kid) {
+			continue
+		}
+		candidates++
+
+		if verifySignature(key, signed, signature) {
+			return nil
+		}
+	}
+
+	if candidates == 0 {
+		return fmt.Errorf("%w: no %s key with kid %q", ErrInvalidToken, header.Alg, header.Kid)
+	}
+	return fmt.Errorf("%w: bad signature", ErrInvalidToken)
+}
+
+// verifySignature reports whether signature is key's signature of signed
+func verifySignature(key verificationKey, signed string, signature []byte) bool {
+	digest := sha256.Sum256([]byte(signed))
+
+	switch k := key.key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(signed))
+		return hmac.Equal(mac.Sum(nil), signature)
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], signature) == nil
+	case *ecdsa.PublicKey:
+		// JWS uses the fixed-width r || s encoding, not ASN.1
+		if len(signature) != 64 {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		return ecdsa.Verify(k, digest[:], r, s)
+	}
+
+	return false
+}
+
+// checkClaims enforces expiry, not-before, issuer and audience
+func (v *JWTValidator) checkClaims(claims map[string]any) error {
+	now := v.opts.Clock.Now()
+
+	exp, ok := timeClaim(claims, "exp")
+	if !ok {
+		return fmt.Errorf("%w: missing exp claim", ErrInvalidToken)
+	}
+	if !now.Before(exp.Add(v.opts.Leeway)) {
+		return fmt.Errorf("%w: expired at %s", ErrInvalidToken, exp.UTC().Format(time.RFC3339))
+	}
+	if nbf, ok := timeClaim(claims, "nbf"); ok && now.Add(v.opts.Leeway).Before(nbf) {
+		return fmt.Errorf("%w: not valid before %s", ErrInvalidToken, nbf.UTC().Format(time.RFC3339))
+	}
+
+	if stringClaim(claims, "iss") != v.opts.Issuer {
+		return fmt.Errorf("%w: issuer %q not accepted", ErrInvalidToken, stringClaim(claims, "iss"))
+	}
+
+	// aud is a string or an array of strings (RFC 7519 4.1.3)
+	audiences := stringsClaim(claims, "aud")
+	if aud, ok := claims["aud"].(string); ok {
+		audiences = []string{aud}
+	}
+	found := false
+	for _, aud := range audiences {
+		if aud == v.opts.Audience {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return fmt.Errorf("%w: audience %q not in token", ErrInvalidToken, v.opts.Audience)
+	}
+
+	return nil
+}
+
+// decodeSegment decodes a base64url JSON segment of a JWT
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// stringClaim returns a string claim, or "" if it's missing or not a string
+func stringClaim(claims map[string]any, name string) string {
+	s, _ := claims[name].(string)
+	return s
+}
+
+// stringsClaim returns a claim that's either a space-separated string or an
+// array of strings
+func stringsClaim(claims map[string]any, name string) []string {
+	switch v := claims[name].(type) {
+	case string:
+		return strings.Fields(v)
+	case []any:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return []string{}
+}
+
+// timeClaim returns a NumericDate claim
+func timeClaim(claims map[string]any, name string) (time.Time, bool) {
+	seconds, ok := claims[name].(float64)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(0, int64(seconds*float64(time.Second))), true
+}
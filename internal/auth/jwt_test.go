@@ -0,0 +1,326 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/all-in-one/internal/common"
+)
+
+var (
+	testNow    = time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	testSecret = []byte("0123456789abcdef0123456789abcdef")
+)
+
+// testKeys are signing keys generated once per test run
+type testKeys struct {
+	rsa *rsa.PrivateKey
+	ec  *ecdsa.PrivateKey
+}
+
+func newTestKeys(t *testing.T) testKeys {
+	t.Helper()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testKeys{rsa: rsaKey, ec: ecKey}
+}
+
+// jwks returns a JWKS document with the public keys as kids "rsa" and "ec"
+// and the HMAC secret as "hmac"
+func (k testKeys) jwks() []byte {
+	b64 := base64.RawURLEncoding.EncodeToString
+	pad32 := func(b []byte) []byte {
+		return append(make([]byte, 32-len(b)), b...)
+	}
+
+	doc := map[string]any{"keys": []map[string]string{
+		{"kty": "RSA", "kid": "rsa", "use": "sig", "alg": "RS256", "n": b64(k.rsa.N.Bytes()), "e": "AQAB"},
+		{"kty": "EC", "kid": "ec", "crv": "P-256", "x": b64(pad32(k.ec.X.Bytes())), "y": b64(pad32(k.ec.Y.Bytes()))},
+		{"kty": "oct", "kid": "hmac", "k": b64(testSecret)},
+		{"kty": "RSA", "kid": "enc", "use": "enc", "n": "AQAB", "e": "AQAB"},
+		{"kty": "OKP", "kid": "ed", "crv": "Ed25519", "x": "AAAA"},
+	}}
+	data, _ := json.Marshal(doc)
+	return data
+}
+
+// sign builds a JWT with the given header fields and claims
+func (k testKeys) sign(t *testing.T, alg, kid string, claims map[string]any) string {
+	t.Helper()
+
+	header := map[string]string{"alg": alg, "typ": "JWT"}
+	if kid != "" {
+		header["kid"] = kid
+	}
+	h, _ := json.Marshal(header)
+	c, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+	digest := sha256.Sum256([]byte(signed))
+
+	var signature []byte
+	switch alg {
+	case AlgHS256:
+		mac := hmac.New(sha256.New, testSecret)
+		mac.Write([]byte(signed))
+		signature = mac.Sum(nil)
+	case AlgRS256:
+		var err error
+		if signature, err = rsa.SignPKCS1v15(rand.Reader, k.rsa, crypto.SHA256, digest[:]); err != nil {
+			t.Fatal(err)
+		}
+	case AlgES256:
+		r, s, err := ecdsa.Sign(rand.Reader, k.ec, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		signature = make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// validClaims returns claims that pass the test validator's checks
+func validClaims() map[string]any {
+	return map[string]any{
+		"sub":   "user-1",
+		"name":  "Ada",
+		"iss":   "https://id.example.com",
+		"aud":   []string{"other", "all-in-one"},
+		"exp":   testNow.Add(time.Hour).Unix(),
+		"nbf":   testNow.Add(-time.Minute).Unix(),
+		"scope": "read write",
+	}
+}
+
+func newTestValidator(t *testing.T, keys *KeySet) *JWTValidator {
+	t.Helper()
+
+	v, err := NewJWTValidator(JWTOptions{
+		Keys:     keys,
+		Issuer:   "https://id.example.com",
+		Audience: "all-in-one",
+		Leeway:   30 * time.Second,
+		Clock:    common.NewManualClock(testNow),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestJWTValidatorAlgorithms(t *testing.T) {
+	keys := newTestKeys(t)
+	jwks, err := ParseJWKS(keys.jwks())
+	if err != nil {
+		t.Fatalf("ParseJWKS: %v", err)
+	}
+	if jwks.Len() != 3 {
+		t.Errorf("JWKS has %d usable keys, want 3", jwks.Len())
+	}
+	v := newTestValidator(t, jwks)
+
+	for _, tt := range []struct{ alg, kid string }{
+		{AlgHS256, "hmac"},
+		{AlgRS256, "rsa"},
+		{AlgES256, "ec"},
+		{AlgES256, ""}, // no kid: every ES256 key is tried
+	} {
+		principal, err := v.Authenticate(keys.sign(t, tt.alg, tt.kid, validClaims()))
+		if err != nil {
+			t.Errorf("%s/%q: %v", tt.alg, tt.kid, err)
+			continue
+		}
+		if principal.Subject != "user-1" || principal.Name != "Ada" || principal.Method != "jwt" {
+			t.Errorf("%s: principal = %+v", tt.alg, principal)
+		}
+		if !slices.Equal(principal.Roles, []string{"read", "write"}) {
+			t.Errorf("%s: roles = %v, want [read write]", tt.alg, principal.Roles)
+		}
+	}
+}
+
+func TestJWTValidatorRejects(t *testing.T) {
+	keys := newTestKeys(t)
+	jwks, err := ParseJWKS(keys.jwks())
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := newTestValidator(t, jwks)
+
+	with := func(name string, value any) map[string]any {
+		claims := validClaims()
+		if value == nil {
+			delete(claims, name)
+		} else {
+			claims[name] = value
+		}
+		return claims
+	}
+
+	valid := keys.sign(t, AlgRS256, "rsa", validClaims())
+	tampered := valid[:len(valid)-4] + "AAAA"
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"expired", keys.sign(t, AlgRS256, "rsa", with("exp", testNow.Add(-time.Minute).Unix()))},
+		{"no exp", keys.sign(t, AlgRS256, "rsa", with("exp", nil))},
+		{"not yet valid", keys.sign(t, AlgRS256, "rsa", with("nbf", testNow.Add(time.Minute).Unix()))},
+		{"wrong issuer", keys.sign(t, AlgRS256, "rsa", with("iss", "https://evil.example.com"))},
+		{"wrong audience", keys.sign(t, AlgRS256, "rsa", with("aud", "other"))},
+		{"no subject", keys.sign(t, AlgRS256, "rsa", with("sub", nil))},
+		{"unknown kid", keys.sign(t, AlgRS256, "nope", validClaims())},
+		{"kid of another algorithm", keys.sign(t, AlgHS256, "rsa", validClaims())},
+		{"alg none", keys.sign(t, "none", "", validClaims())},
+		{"tampered signature", tampered},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := v.Authenticate(tt.token)
+			if !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("Authenticate error = %v, want ErrInvalidToken", err)
+			}
+		})
+	}
+
+	// Within the leeway, a just-expired token is still accepted
+	if _, err := v.Authenticate(keys.sign(t, AlgRS256, "rsa", with("exp", testNow.Add(-10*time.Second).Unix()))); err != nil {
+		t.Errorf("token expired within the leeway: %v", err)
+	}
+
+	// Other kinds of tokens are left to other authenticators
+	if _, err := v.Authenticate("aio_abcd1234_secret"); !errors.Is(err, ErrUnrecognized) {
+		t.Errorf("API key error = %v, want ErrUnrecognized", err)
+	}
+}
+
+func TestNewJWTValidatorNeedsIssuerAndAudience(t *testing.T) {
+	keys, err := NewHMACKeySet([]byte("0123456789abcdef0123456789abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, opts := range []JWTOptions{
+		{Keys: keys},
+		{Keys: keys, Issuer: "https://id.example.com"},
+		{Keys: keys, Audience: "all-in-one"},
+	} {
+		if _, err := NewJWTValidator(opts); err == nil {
+			t.Errorf("NewJWTValidator(issuer=%q, audience=%q) succeeded, want error", opts.Issuer, opts.Audience)
+		}
+	}
+}
+
+func TestJWTValidatorStaticKeys(t *testing.T) {
+	keys := newTestKeys(t)
+
+	der, err := x509.MarshalPKIXPublicKey(&keys.ec.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "ec.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	pemKeys, err := LoadKeySet(path)
+	if err != nil {
+		t.Fatalf("LoadKeySet: %v", err)
+	}
+	if _, err := newTestValidator(t, pemKeys).Authenticate(keys.sign(t, AlgES256, "any", validClaims())); err != nil {
+		t.Errorf("ES256 with a PEM key: %v", err)
+	}
+
+	hmacKeys, err := NewHMACKeySet(testSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := newTestValidator(t, hmacKeys)
+	if _, err := v.Authenticate(keys.sign(t, AlgHS256, "", validClaims())); err != nil {
+		t.Errorf("HS256 with a static secret: %v", err)
+	}
+	// An RS256 token can't be checked with an HMAC secret, and vice versa
+	if _, err := v.Authenticate(keys.sign(t, AlgRS256, "", validClaims())); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("RS256 token against an HMAC key: error = %v, want ErrInvalidToken", err)
+	}
+
+	if _, err := NewHMACKeySet([]byte("short")); err == nil {
+		t.Error("NewHMACKeySet accepted a 5-byte secret")
+	}
+}
+
+func TestMiddlewareWithJWTAndAPIKeys(t *testing.T) {
+	keys := newTestKeys(t)
+	jwks, err := ParseJWKS(keys.jwks())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	apiKeys := fakeKeys{}
+	apiKey := newTestKey(t, apiKeys, RoleRead)
+
+	var seen Principal
+	handler := Middleware(APIKeyAuthenticator{Keys: apiKeys}, newTestValidator(t, jwks))(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			seen, _ = PrincipalFromContext(r.Context())
+		}))
+
+	readOnly := validClaims()
+	readOnly["scope"] = []string{"read"}
+
+	tests := []struct {
+		name, token string
+		want        int
+		subject     string
+		roles       []string
+	}{
+		{"jwt scope string", keys.sign(t, AlgES256, "ec", validClaims()), http.StatusOK, "user-1", []string{"read", "write"}},
+		{"jwt scope array", keys.sign(t, AlgES256, "ec", readOnly), http.StatusOK, "user-1", []string{"read"}},
+		{"api key", apiKey, http.StatusOK, "apikey:1", []string{"read"}},
+		{"neither", "garbage", http.StatusUnauthorized, "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seen = Principal{}
+			req := httptest.NewRequest("GET", "/api/v1/items", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d (body %s)", rec.Code, tt.want, rec.Body)
+			}
+			if seen.Subject != tt.subject || !slices.Equal(seen.Roles, tt.roles) {
+				t.Errorf("principal = %q %v, want %q %v", seen.Subject, seen.Roles, tt.subject, tt.roles)
+			}
+		})
+	}
+}
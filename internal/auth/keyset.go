@@ -0,0 +1,230 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+)
+
+// Supported JWT signing algorithms
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgES256 = "ES256"
+)
+
+// minRSABits is the smallest RSA key accepted for RS256
+const minRSABits = 2048
+
+// KeySet holds the keys JWT signatures are verified with
+type KeySet struct {
+	keys []verificationKey
+}
+
+// verificationKey is one key of a KeySet
+type verificationKey struct {
+	id  string // JWK kid, empty for static keys
+	alg string // the only algorithm the key may be used with
+	key any    // []byte, *rsa.PublicKey or *ecdsa.PublicKey
+}
+
+// Len returns the number of keys in the set
+func (s *KeySet) Len() int {
+	return len(s.keys)
+}
+
+// NewHMACKeySet returns a key set for HS256 tokens signed with secret
+func NewHMACKeySet(secret []byte) (*KeySet, error) {
+	// RFC 7518 3.2: the key must be at least as long as the hash output
+	if len(secret) < 32 {
+		return nil, fmt.Errorf("HS256 secret must be at least 32 bytes, got %d", len(secret))
+	}
+
+	return &KeySet{keys: []verificationKey{{alg: AlgHS256, key: secret}}}, nil
+}
+
+// LoadKeySet reads a JWKS document or a PEM public key or certificate
+func LoadKeySet(path string) (*KeySet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		return ParseJWKS(data)
+	}
+	return ParsePEM(data)
+}
+
+// ParsePEM parses an RSA or P-256 public key, as a PUBLIC KEY or
+// CERTIFICATE block
+func ParsePEM(data []byte) (*KeySet, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found")
+	}
+
+	var pub any
+	switch block.Type {
+	case "PUBLIC KEY":
+		var err error
+		if pub, err = x509.ParsePKIXPublicKey(block.Bytes); err != nil {
+			return nil, err
+		}
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		pub = cert.PublicKey
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q; want PUBLIC KEY or CERTIFICATE", block.Type)
+	}
+
+	key, err := newPublicKey("", pub)
+	if err != nil {
+		return nil, err
+	}
+	return &KeySet{keys: []verificationKey{key}}, nil
+}
+
+// jwk is a JSON Web Key (RFC 7517) as found in a JWKS document
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`   // RSA modulus
+	E   string `json:"e"`   // RSA exponent
+	Crv string `json:"crv"` // EC curve
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"` // symmetric key
+}
+
+// ParseJWKS parses a JWKS document. Encryption keys and key types other
+// than RSA, EC P-256 and oct are skipped, but at least one key must be
+// usable.
+func ParseJWKS(data []byte) (*KeySet, error) {
+	var doc struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parsing JWKS: %w", err)
+	}
+
+	set := &KeySet{}
+	for i, k := range doc.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		key, err := k.verificationKey()
+		if err != nil {
+			return nil, fmt.Errorf("JWKS key %d (%q): %w", i, k.Kid, err)
+		}
+		if key.alg == "" {
+			continue
+		}
+		if k.Alg != "" && k.Alg != key.alg {
+			// Declared for an algorithm we don't support, such as RS512
+			continue
+		}
+		set.keys = append(set.keys, key)
+	}
+
+	if len(set.keys) == 0 {
+		return nil, fmt.Errorf("JWKS has no usable signing keys; supported: RSA, EC P-256 and oct")
+	}
+	return set, nil
+}
+
+// verificationKey decodes the key. Unsupported key types return a key
+// without an algorithm.
+func (k jwk) verificationKey() (verificationKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return verificationKey{}, fmt.Errorf("n: %w", err)
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return verificationKey{}, fmt.Errorf("e: %w", err)
+		}
+		if !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+			return verificationKey{}, fmt.Errorf("unsupported RSA exponent")
+		}
+		return newPublicKey(k.Kid, &rsa.PublicKey{N: n, E: int(e.Int64())})
+	case "EC":
+		if k.Crv != "P-256" {
+			return verificationKey{}, nil
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return verificationKey{}, fmt.Errorf("x: %w", err)
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return verificationKey{}, fmt.Errorf("y: %w", err)
+		}
+		return newPublicKey(k.Kid, &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y})
+	case "oct":
+		secret, err := base64.RawURLEncoding.DecodeString(k.K)
+		if err != nil {
+			return verificationKey{}, fmt.Errorf("k: %w", err)
+		}
+		set, err := NewHMACKeySet(secret)
+		if err != nil {
+			return verificationKey{}, err
+		}
+		key := set.keys[0]
+		key.id = k.Kid
+		return key, nil
+	}
+
+	return verificationKey{}, nil
+}
+
+// newPublicKey checks an RSA or ECDSA public key and pairs it with its
+// algorithm
+func newPublicKey(id string, pub any) (verificationKey, error) {
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		if pub.N.BitLen() < minRSABits {
+			return verificationKey{}, fmt.Errorf("RSA key is %d bits, want at least %d", pub.N.BitLen(), minRSABits)
+		}
+		return verificationKey{id: id, alg: AlgRS256, key: pub}, nil
+	case *ecdsa.PublicKey:
+		if pub.Curve != elliptic.P256() {
+			return verificationKey{}, fmt.Errorf("EC key is on %s, want P-256", pub.Curve.Params().Name)
+		}
+		// The ECDH conversion rejects points that aren't on the curve
+		if _, err := pub.ECDH(); err != nil {
+			return verificationKey{}, fmt.Errorf("invalid EC key: %w", err)
+		}
+		return verificationKey{id: id, alg: AlgES256, key: pub}, nil
+	}
+
+	return verificationKey{}, fmt.Errorf("unsupported public key type %T; want RSA or EC P-256", pub)
+}
+
+// decodeBigInt decodes a base64url big-endian integer
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, fmt.Errorf("empty value")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,114 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/all-in-one/internal/common"
+	"github.com/sirupsen/logrus"
+)
+
+// Authentication errors
+var (
+	// ErrUnrecognized means a token isn't the kind an Authenticator handles,
+	// so the next one should try
+	ErrUnrecognized = errors.New("unrecognized token")
+
+	// ErrInvalidToken means a token is of the right kind but not valid:
+	// unknown, revoked, expired, badly signed and so on. It's usually
+	// wrapped with the reason.
+	ErrInvalidToken = errors.New("invalid token")
+)
+
+// Authenticator turns a bearer token into a Principal. It returns an error
+// wrapping ErrUnrecognized or ErrInvalidToken for tokens it can't accept;
+// any other error means it couldn't check the token.
+type Authenticator interface {
+	Authenticate(token string) (Principal, error)
+}
+
+// RejectHook is offered each request Middleware turns away for a missing
+// or invalid token, before the 401 is sent. It returns false if it has
+// answered the request itself.
+type RejectHook func(w http.ResponseWriter, r *http.Request) bool
+
+// WithRejectHook returns a copy of ctx in which Middleware calls hook on
+// the requests it rejects, so middleware registered before it can act on
+// failed attempts only
+func WithRejectHook(ctx context.Context, hook RejectHook) context.Context {
+	return context.WithValue(ctx, rejectHookContextKey, hook)
+}
+
+// Middleware authenticates requests by their "Authorization: Bearer" token,
+// offering it to each authenticator in turn. The principal is put in the
+// request context; what it may do is up to the Policy the routes enforce.
+func Middleware(authenticators ...Authenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, ok := bearerToken(r)
+			if !ok {
+				reject(w, r, common.Unauthorized("Missing bearer token").WithCode("missing_token"))
+				return
+			}
+
+			principal, err := authenticate(authenticators, token)
+			if err != nil {
+				if errors.Is(err, ErrInvalidToken) || errors.Is(err, ErrUnrecognized) {
+					logrus.WithFields(logrus.Fields{
+						"path":   r.URL.Path,
+						"ip":     r.RemoteAddr,
+						"reason": err.Error(),
+					}).Warn("Rejected bearer token")
+					reject(w, r, common.Unauthorized("Invalid bearer token").WithCode("invalid_token"))
+					return
+				}
+				common.WriteError(w, r, common.Internal("Failed to authenticate", err))
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), principal)))
+		})
+	}
+}
+
+// authenticate returns the principal of the first authenticator that
+// recognizes token
+func authenticate(authenticators []Authenticator, token string) (Principal, error) {
+	for _, a := range authenticators {
+		principal, err := a.Authenticate(token)
+		if errors.Is(err, ErrUnrecognized) {
+			continue
+		}
+		return principal, err
+	}
+
+	return Principal{}, ErrUnrecognized
+}
+
+// bearerToken extracts the token of an "Authorization: Bearer" header
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// reject sends a 401 response for err, unless the request's RejectHook
+// answers it instead
+func reject(w http.ResponseWriter, r *http.Request, err error) {
+	if hook, ok := r.Context().Value(rejectHookContextKey).(RejectHook); ok && !hook(w, r) {
+		return
+	}
+	sendUnauthorized(w, r, err)
+}
+
+// sendUnauthorized sends a 401 response asking for a bearer token
+func sendUnauthorized(w http.ResponseWriter, r *http.Request, err error) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="all-in-one"`)
+	common.WriteError(w, r, err)
+}
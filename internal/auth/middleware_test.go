@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/all-in-one/internal/common"
+)
+
+// fakeKeys is a KeyStore backed by a map of prefix to key
+type fakeKeys map[string]StoredKey
+
+func (f fakeKeys) LookupAPIKey(prefix string) (StoredKey, error) {
+	if prefix == "broken" {
+		return StoredKey{}, errors.New("storage is down")
+	}
+	key, ok := f[prefix]
+	if !ok {
+		return StoredKey{}, common.ErrNotFound
+	}
+	return key, nil
+}
+
+// newTestKey generates a key with the given scopes and adds it to keys
+func newTestKey(t *testing.T, keys fakeKeys, scopes ...string) string {
+	t.Helper()
+
+	token, prefix, hash, err := NewAPIKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys[prefix] = StoredKey{ID: len(keys) + 1, Name: "key", Hash: hash, Scopes: scopes}
+	return token
+}
+
+func TestMiddleware(t *testing.T) {
+	keys := fakeKeys{}
+	reader := newTestKey(t, keys, RoleRead)
+	writer := newTestKey(t, keys, RoleWrite)
+	revoked := newTestKey(t, keys, RoleAdmin)
+	prefix, _ := ParseAPIKey(revoked)
+	revokedKey := keys[prefix]
+	revokedKey.Revoked = true
+	keys[prefix] = revokedKey
+
+	// A valid-looking key whose secret doesn't match the stored hash
+	forged := "aio_" + prefix + "_00"
+
+	var seen Principal
+	handler := Middleware(APIKeyAuthenticator{Keys: keys})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen, _ = PrincipalFromContext(r.Context())
+	}))
+
+	tests := []struct {
+		name   string
+		header string
+		want   int
+	}{
+		{"no header", "", http.StatusUnauthorized},
+		{"wrong scheme", "Basic " + reader, http.StatusUnauthorized},
+		{"malformed key", "Bearer nonsense", http.StatusUnauthorized},
+		{"unknown prefix", "Bearer aio_ffffffff_00", http.StatusUnauthorized},
+		{"wrong secret", "Bearer " + forged, http.StatusUnauthorized},
+		{"revoked", "Bearer " + revoked, http.StatusUnauthorized},
+		{"storage error", "Bearer aio_broken_00", http.StatusInternalServerError},
+		{"valid", "Bearer " + reader, http.StatusOK},
+		{"lowercase scheme", "bearer " + writer, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/v1/items", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d (body %s)", rec.Code, tt.want, rec.Body)
+			}
+			if rec.Code == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+				t.Error("401 without a WWW-Authenticate header")
+			}
+		})
+	}
+
+	req := httptest.NewRequest("GET", "/api/v1/items", nil)
+	req.Header.Set("Authorization", "Bearer "+writer)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if seen.Subject != "apikey:2" || seen.Method != "apikey" || len(seen.Roles) != 1 || seen.Roles[0] != RoleWrite {
+		t.Errorf("handler saw principal %+v in the context, want apikey:2 with the write role", seen)
+	}
+}
@@ -0,0 +1,176 @@
+package auth
+
+import (
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"sort"
+
+	"github.com/all-in-one/internal/common"
+)
+
+// Permissions checked by the routes
+const (
+	PermItemsRead   = "items:read"
+	PermItemsWrite  = "items:write"
+	PermItemsDelete = "items:delete"
+	PermAdmin       = "admin"
+)
+
+// permissions lists every known permission, in display order
+var permissions = []string{PermItemsRead, PermItemsWrite, PermItemsDelete, PermAdmin}
+
+// AllPermissions returns every known permission
+func AllPermissions() []string {
+	return slices.Clone(permissions)
+}
+
+// Built-in roles, the default API key scopes
+const (
+	RoleRead  = "read"
+	RoleWrite = "write"
+	RoleAdmin = "admin"
+)
+
+// DefaultRoles maps the built-in roles to their permissions. Each includes
+// the one before it.
+func DefaultRoles() map[string][]string {
+	return map[string][]string{
+		RoleRead:  {PermItemsRead},
+		RoleWrite: {PermItemsRead, PermItemsWrite, PermItemsDelete},
+		RoleAdmin: {PermItemsRead, PermItemsWrite, PermItemsDelete, PermAdmin},
+	}
+}
+
+// Policy maps roles to permissions. A principal has the union of the
+// permissions of its roles; unknown roles grant nothing. A nil policy
+// grants nothing.
+type Policy struct {
+	roles    map[string][]string
+	allowAll bool
+}
+
+// AllowAll returns a policy that allows every request, with or without a
+// principal, for when authentication is off
+func AllowAll() *Policy {
+	return &Policy{roles: map[string][]string{}, allowAll: true}
+}
+
+// AllowsAll reports whether p is the AllowAll policy
+func (p *Policy) AllowsAll() bool {
+	return p != nil && p.allowAll
+}
+
+// NewPolicy creates a policy from role → permissions. Every permission
+// must be a known one.
+func NewPolicy(roles map[string][]string) (*Policy, error) {
+	if len(roles) == 0 {
+		return nil, fmt.Errorf("no roles defined")
+	}
+
+	policy := &Policy{roles: make(map[string][]string, len(roles))}
+	for role, perms := range roles {
+		for _, perm := range perms {
+			if !slices.Contains(permissions, perm) {
+				return nil, fmt.Errorf("role %q: unknown permission %q. Supported permissions: items:read, items:write, items:delete, admin", role, perm)
+			}
+		}
+		policy.roles[role] = slices.Clone(perms)
+	}
+
+	return policy, nil
+}
+
+// Roles returns the names of all roles, sorted
+func (p *Policy) Roles() []string {
+	return slices.Sorted(maps.Keys(p.roles))
+}
+
+// HasRole reports whether role is defined
+func (p *Policy) HasRole(role string) bool {
+	_, ok := p.roles[role]
+	return ok
+}
+
+// ParseRoles validates role names, such as the values of a --scopes flag
+func (p *Policy) ParseRoles(names []string) ([]string, error) {
+	if len(names) == 0 {
+		return nil, fmt.Errorf("at least one role is required. Defined roles: %v", p.Roles())
+	}
+
+	for _, name := range names {
+		if !p.HasRole(name) {
+			return nil, fmt.Errorf("unknown role %q. Defined roles: %v", name, p.Roles())
+		}
+	}
+
+	return slices.Clone(names), nil
+}
+
+// Permissions returns the permissions granted by roles, in display order
+func (p *Policy) Permissions(roles []string) []string {
+	if p.AllowsAll() {
+		return AllPermissions()
+	}
+	if p == nil {
+		return []string{}
+	}
+
+	granted := map[string]bool{}
+	for _, role := range roles {
+		for _, perm := range p.roles[role] {
+			granted[perm] = true
+		}
+	}
+
+	perms := make([]string, 0, len(granted))
+	for perm := range granted {
+		perms = append(perms, perm)
+	}
+	sort.Slice(perms, func(i, j int) bool {
+		return slices.Index(permissions, perms[i]) < slices.Index(permissions, perms[j])
+	})
+
+	return perms
+}
+
+// Allows reports whether principal has perm
+func (p *Policy) Allows(principal Principal, perm string) bool {
+	if p == nil {
+		return false
+	}
+	if p.allowAll {
+		return true
+	}
+
+	for _, role := range principal.Roles {
+		if slices.Contains(p.roles[role], perm) {
+			return true
+		}
+	}
+	return false
+}
+
+// Require wraps next so it only runs for principals with perm, or for
+// everyone under AllowAll. A nil policy refuses every request.
+func (p *Policy) Require(perm string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if p.AllowsAll() {
+			next(w, r)
+			return
+		}
+
+		principal, ok := PrincipalFromContext(r.Context())
+		if !ok {
+			sendUnauthorized(w, r, common.Unauthorized("Missing bearer token").WithCode("missing_token"))
+			return
+		}
+		if !p.Allows(principal, perm) {
+			common.WriteError(w, r, common.Forbidden("Permission denied: "+perm+" required").WithCode("permission_denied"))
+			return
+		}
+
+		next(w, r)
+	}
+}
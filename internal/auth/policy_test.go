@@ -0,0 +1,98 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestDefaultPolicy(t *testing.T) {
+	policy, err := NewPolicy(DefaultRoles())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		roles []string
+		want  []string
+	}{
+		{nil, []string{}},
+		{[]string{"unknown"}, []string{}},
+		{[]string{RoleRead}, []string{PermItemsRead}},
+		{[]string{RoleWrite}, []string{PermItemsRead, PermItemsWrite, PermItemsDelete}},
+		{[]string{RoleRead, RoleAdmin}, []string{PermItemsRead, PermItemsWrite, PermItemsDelete, PermAdmin}},
+	}
+
+	for _, tt := range tests {
+		if got := policy.Permissions(tt.roles); !slices.Equal(got, tt.want) {
+			t.Errorf("Permissions(%v) = %v, want %v", tt.roles, got, tt.want)
+		}
+	}
+}
+
+func TestNewPolicy(t *testing.T) {
+	policy, err := NewPolicy(map[string][]string{
+		"editor":  {PermItemsRead, PermItemsWrite},
+		"janitor": {PermItemsDelete},
+	})
+	if err != nil {
+		t.Fatalf("NewPolicy: %v", err)
+	}
+	if !slices.Equal(policy.Roles(), []string{"editor", "janitor"}) {
+		t.Errorf("Roles = %v", policy.Roles())
+	}
+
+	both := Principal{Roles: []string{"editor", "janitor"}}
+	if !policy.Allows(both, PermItemsDelete) || policy.Allows(both, PermAdmin) {
+		t.Errorf("editor+janitor permissions = %v", policy.Permissions(both.Roles))
+	}
+
+	if _, err := policy.ParseRoles([]string{"editor", "read"}); err == nil {
+		t.Error("ParseRoles accepted a role the policy doesn't define")
+	}
+	if _, err := policy.ParseRoles(nil); err == nil {
+		t.Error("ParseRoles accepted no roles")
+	}
+
+	if _, err := NewPolicy(map[string][]string{"root": {"everything"}}); err == nil {
+		t.Error("NewPolicy accepted an unknown permission")
+	}
+}
+
+func TestRequire(t *testing.T) {
+	policy, err := NewPolicy(DefaultRoles())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+
+	tests := []struct {
+		name      string
+		policy    *Policy
+		principal *Principal
+		perm      string
+		want      int
+	}{
+		{"allow all", AllowAll(), nil, PermAdmin, http.StatusOK},
+		{"no policy", nil, &Principal{Roles: []string{RoleAdmin}}, PermItemsRead, http.StatusForbidden},
+		{"no principal", policy, nil, PermItemsRead, http.StatusUnauthorized},
+		{"allowed", policy, &Principal{Roles: []string{RoleWrite}}, PermItemsDelete, http.StatusOK},
+		{"denied", policy, &Principal{Roles: []string{RoleRead}}, PermItemsWrite, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			if tt.principal != nil {
+				req = req.WithContext(WithPrincipal(req.Context(), *tt.principal))
+			}
+			rec := httptest.NewRecorder()
+			tt.policy.Require(tt.perm, ok)(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d (body %s)", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package auth
+
+import "context"
+
+// Principal is who a request was authenticated as, whichever way it was
+// authenticated
+type Principal struct {
+	// Subject identifies the caller: "apikey:<id>" for API keys, the sub
+	// claim for JWTs
+	Subject string `json:"subject"`
+
+	// Name is a human-readable name: the API key's name or, for JWTs, the
+	// name or email claim
+	Name string `json:"name,omitempty"`
+
+	// Method is how the request was authenticated: "apikey" or "jwt"
+	Method string `json:"method"`
+
+	// Roles are the principal's roles in the Policy: the API key's scopes
+	// or the JWT's scopes claim
+	Roles []string `json:"roles"`
+
+	// Claims holds all claims of a JWT, nil for API keys
+	Claims map[string]any `json:"-"`
+}
+
+type contextKey int
+
+const (
+	principalContextKey contextKey = iota
+	rejectHookContextKey
+)
+
+// WithPrincipal returns a copy of ctx carrying p
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalContextKey, p)
+}
+
+// PrincipalFromContext returns the principal that authenticated the request
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalContextKey).(Principal)
+	return p, ok
+}
@@ -1,16 +1,14 @@
 package common
 
-import "errors"
-
-// Common storage errors
-var (
-	ErrNotFound = errors.New("resource not found")
-)
-
 // Response is a standard API response structure
 type Response struct {
 	Success bool        `json:"success"`
 	Message string      `json:"message,omitempty"`
 	Data    interface{} `json:"data,omitempty"`
 	Error   string      `json:"error,omitempty"`
+
+	// Code and Details describe errors for programs: Code is the stable
+	// error code and Details the invalid fields of a validation error
+	Code    string       `json:"code,omitempty"`
+	Details []FieldError `json:"details,omitempty"`
 }
@@ -0,0 +1,195 @@
+package common
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Error kinds. Storage returns them as they are; handlers return *Error
+// values wrapping them. Either way, match them with errors.Is.
+var (
+	ErrNotFound     = errors.New("resource not found")
+	ErrConflict     = errors.New("resource already exists")
+	ErrValidation   = errors.New("validation failed")
+	ErrPrecondition = errors.New("precondition failed")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+	ErrRateLimited  = errors.New("rate limited")
+	ErrTooLarge     = errors.New("request too large")
+	ErrUnavailable  = errors.New("service unavailable")
+)
+
+// Stable error codes of the kinds, for clients to switch on. An *Error may
+// carry a more specific code instead.
+const (
+	CodeNotFound     = "not_found"
+	CodeConflict     = "conflict"
+	CodeValidation   = "validation_failed"
+	CodePrecondition = "precondition_failed"
+	CodeUnauthorized = "unauthorized"
+	CodeForbidden    = "forbidden"
+	CodeRateLimited  = "rate_limited"
+	CodeTooLarge     = "too_large"
+	CodeUnavailable  = "unavailable"
+	CodeInternal     = "internal"
+)
+
+// kinds maps each error kind to its default code and HTTP status
+var kinds = []struct {
+	err    error
+	code   string
+	status int
+}{
+	{ErrNotFound, CodeNotFound, http.StatusNotFound},
+	{ErrConflict, CodeConflict, http.StatusConflict},
+	{ErrValidation, CodeValidation, http.StatusBadRequest},
+	{ErrPrecondition, CodePrecondition, http.StatusPreconditionFailed},
+	{ErrUnauthorized, CodeUnauthorized, http.StatusUnauthorized},
+	{ErrForbidden, CodeForbidden, http.StatusForbidden},
+	{ErrRateLimited, CodeRateLimited, http.StatusTooManyRequests},
+	{ErrTooLarge, CodeTooLarge, http.StatusRequestEntityTooLarge},
+	{ErrUnavailable, CodeUnavailable, http.StatusServiceUnavailable},
+}
+
+// FieldError says what's wrong with one field of a request
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"` // e.g. "required", "too_long"
+	Message string `json:"message"`
+}
+
+// Error is an error meant for API clients: a kind, a stable code and a
+// message that's safe to show, plus the cause, which isn't shown
+type Error struct {
+	Kind    error        // one of the Err* kinds; nil for internal errors
+	Code    string       // stable machine-readable code
+	Message string       // human-readable, safe to show to clients
+	Fields  []FieldError // invalid fields of a validation error
+	Err     error        // underlying cause, for logs only
+}
+
+// Error returns the message, followed by the cause if there is one
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+// Unwrap returns the kind and the cause, so errors.Is matches either
+func (e *Error) Unwrap() []error {
+	errs := make([]error, 0, 2)
+	if e.Kind != nil {
+		errs = append(errs, e.Kind)
+	}
+	if e.Err != nil {
+		errs = append(errs, e.Err)
+	}
+	return errs
+}
+
+// Status returns the HTTP status for the error
+func (e *Error) Status() int {
+	for _, k := range kinds {
+		if e.Kind == k.err {
+			return k.status
+		}
+	}
+	return http.StatusInternalServerError
+}
+
+// WithCode returns a copy of e with a more specific code
+func (e *Error) WithCode(code string) *Error {
+	c := *e
+	c.Code = code
+	return &c
+}
+
+// Wrap returns a copy of e with cause as its underlying error
+func (e *Error) Wrap(cause error) *Error {
+	c := *e
+	c.Err = cause
+	return &c
+}
+
+// newError creates an *Error of kind with the kind's default code
+func newError(kind error, message string) *Error {
+	e := &Error{Kind: kind, Message: message}
+	for _, k := range kinds {
+		if kind == k.err {
+			e.Code = k.code
+		}
+	}
+	return e
+}
+
+// NotFound returns a not-found error
+func NotFound(message string) *Error {
+	return newError(ErrNotFound, message)
+}
+
+// Conflict returns an error for a request that clashes with existing data
+func Conflict(message string) *Error {
+	return newError(ErrConflict, message)
+}
+
+// Validation returns an error for an invalid request, listing the invalid
+// fields if there are any
+func Validation(message string, fields ...FieldError) *Error {
+	e := newError(ErrValidation, message)
+	e.Fields = fields
+	return e
+}
+
+// PreconditionFailed returns an error for a request whose precondition,
+// such as If-Match, doesn't hold
+func PreconditionFailed(message string) *Error {
+	return newError(ErrPrecondition, message)
+}
+
+// Unauthorized returns an error for a request without valid credentials
+func Unauthorized(message string) *Error {
+	return newError(ErrUnauthorized, message)
+}
+
+// Forbidden returns an error for a request the caller isn't allowed to make
+func Forbidden(message string) *Error {
+	return newError(ErrForbidden, message)
+}
+
+// RateLimited returns an error for a client that has made too many requests
+func RateLimited(message string) *Error {
+	return newError(ErrRateLimited, message)
+}
+
+// TooLarge returns an error for a request body over the size limit
+func TooLarge(message string) *Error {
+	return newError(ErrTooLarge, message)
+}
+
+// Unavailable returns an error for a request the service is too busy to
+// serve right now
+func Unavailable(message string) *Error {
+	return newError(ErrUnavailable, message)
+}
+
+// Internal returns an error for a failure that isn't the client's fault.
+// message is shown to the client; cause is only logged.
+func Internal(message string, cause error) *Error {
+	return &Error{Code: CodeInternal, Message: message, Err: cause}
+}
+
+// AsError returns err as an *Error. Bare kinds get their default code and
+// message; anything else is an internal error.
+func AsError(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	for _, k := range kinds {
+		if errors.Is(err, k.err) {
+			return newError(k.err, k.err.Error()).Wrap(err)
+		}
+	}
+	return Internal("Internal server error", err)
+}
@@ -0,0 +1,79 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestErrorMatchesKindAndCause(t *testing.T) {
+	cause := errors.New("disk on fire")
+	err := fmt.Errorf("handler: %w", NotFound("Item not found").WithCode("item_not_found").Wrap(cause))
+
+	if !errors.Is(err, ErrNotFound) {
+		t.Error("errors.Is(err, ErrNotFound) = false")
+	}
+	if !errors.Is(err, cause) {
+		t.Error("errors.Is(err, cause) = false")
+	}
+	if errors.Is(err, ErrConflict) {
+		t.Error("errors.Is(err, ErrConflict) = true")
+	}
+
+	var e *Error
+	if !errors.As(err, &e) {
+		t.Fatal("errors.As(err, *Error) = false")
+	}
+	if e.Code != "item_not_found" || e.Status() != http.StatusNotFound {
+		t.Errorf("code %q, status %d; want item_not_found, 404", e.Code, e.Status())
+	}
+	if got := e.Error(); got != "Item not found: disk on fire" {
+		t.Errorf("Error() = %q", got)
+	}
+}
+
+func TestWithCodeAndWrapCopy(t *testing.T) {
+	base := Unauthorized("Not logged in")
+	coded := base.WithCode("not_logged_in").Wrap(errors.New("cause"))
+
+	if base.Code != CodeUnauthorized || base.Err != nil {
+		t.Errorf("base changed to %+v", base)
+	}
+	if coded.Code != "not_logged_in" || coded.Err == nil {
+		t.Errorf("copy = %+v, want the new code and cause", coded)
+	}
+}
+
+func TestAsError(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		code   string
+		status int
+	}{
+		{"bare kind", ErrConflict, CodeConflict, http.StatusConflict},
+		{"wrapped kind", fmt.Errorf("create: %w", ErrNotFound), CodeNotFound, http.StatusNotFound},
+		{"validation", Validation("Bad", FieldError{Field: "title", Code: "required"}), CodeValidation, http.StatusBadRequest},
+		{"precondition", PreconditionFailed("Stale"), CodePrecondition, http.StatusPreconditionFailed},
+		{"forbidden", Forbidden("No"), CodeForbidden, http.StatusForbidden},
+		{"rate limited", RateLimited("Slow down"), CodeRateLimited, http.StatusTooManyRequests},
+		{"too large", TooLarge("Too big"), CodeTooLarge, http.StatusRequestEntityTooLarge},
+		{"unavailable", Unavailable("Busy"), CodeUnavailable, http.StatusServiceUnavailable},
+		{"internal", Internal("Failed", errors.New("boom")), CodeInternal, http.StatusInternalServerError},
+		{"unknown", errors.New("boom"), CodeInternal, http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := AsError(tt.err)
+			if e.Code != tt.code || e.Status() != tt.status {
+				t.Errorf("code %q, status %d; want %q, %d", e.Code, e.Status(), tt.code, tt.status)
+			}
+		})
+	}
+
+	if e := AsError(errors.New("secret detail")); e.Message != "Internal server error" {
+		t.Errorf("unknown error message = %q, want the generic one", e.Message)
+	}
+}
@@ -0,0 +1,207 @@
+package common
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+// IDGenerator creates the string identifiers storage backends assign to new
+// records
+type IDGenerator interface {
+	NewID() string
+}
+
+// IDSeeder is implemented by generators that must be told which IDs are
+// already taken, such as SequentialIDs, whose sequence would otherwise
+// restart with the process
+type IDSeeder interface {
+	// Seed makes sure id is never generated
+	Seed(id string)
+}
+
+// SeedIDs tells ids about taken IDs, if it needs to know. Storage backends
+// call it with the stored UIDs when they open, and with imported ones.
+func SeedIDs(ids IDGenerator, taken ...string) {
+	seeder, ok := ids.(IDSeeder)
+	if !ok {
+		return
+	}
+	for _, id := range taken {
+		seeder.Seed(id)
+	}
+}
+
+// ID generator names accepted by NewIDGenerator
+const (
+	IDGeneratorInt    = "int"
+	IDGeneratorUUIDv7 = "uuidv7"
+	IDGeneratorULID   = "ulid"
+)
+
+// NewIDGenerator returns the generator with the given name, using clock for
+// the time-based ones
+func NewIDGenerator(name string, clock Clock) (IDGenerator, error) {
+	switch name {
+	case IDGeneratorInt:
+		return NewSequentialIDs(0), nil
+	case IDGeneratorUUIDv7:
+		return NewUUIDv7s(clock), nil
+	case IDGeneratorULID, "":
+		return NewULIDs(clock), nil
+	default:
+		return nil, fmt.Errorf("unknown ID generator %q. Supported generators: int, uuidv7, ulid", name)
+	}
+}
+
+// SequentialIDs generates "1", "2", "3", ... It's deterministic, which makes
+// it handy in tests. Its sequence restarts with the process, so storages
+// seed it with the UIDs they already hold.
+type SequentialIDs struct {
+	mutex sync.Mutex
+	last  int
+}
+
+// NewSequentialIDs creates a generator whose first ID is last+1
+func NewSequentialIDs(last int) *SequentialIDs {
+	return &SequentialIDs{last: last}
+}
+
+// NewID returns the next number in the sequence
+func (g *SequentialIDs) NewID() string {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	g.last++
+	return strconv.Itoa(g.last)
+}
+
+// Seed moves the sequence past id if it's a number at or beyond the last
+// one generated
+func (g *SequentialIDs) Seed(id string) {
+	n, err := strconv.Atoi(id)
+	if err != nil {
+		return
+	}
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if n > g.last {
+		g.last = n
+	}
+}
+
+// timeRandom holds the state shared by the time-ordered generators: a 48-bit
+// millisecond timestamp followed by random bits. Within one millisecond the
+// random part is incremented instead of redrawn, so IDs from one generator
+// sort in creation order.
+type timeRandom struct {
+	clock Clock
+	mutex sync.Mutex
+	ms    uint64
+	rand  [10]byte
+}
+
+// next returns the timestamp and random bits of the next ID
+func (g *timeRandom) next() (uint64, [10]byte) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	ms := uint64(g.clock.Now().UnixMilli())
+	if ms > g.ms {
+		g.ms = ms
+		if _, err := rand.Read(g.rand[:]); err != nil {
+			panic(fmt.Sprintf("common: reading random bytes: %v", err))
+		}
+		// Leave headroom so incrementing within a millisecond never overflows
+		g.rand[0] &= 0x7f
+	} else {
+		// Same millisecond, or the clock went backwards: stay monotonic
+		for i := len(g.rand) - 1; i >= 0; i-- {
+			g.rand[i]++
+			if g.rand[i] != 0 {
+				break
+			}
+		}
+	}
+
+	return g.ms, g.rand
+}
+
+// UUIDv7s generates RFC 9562 version 7 UUIDs
+type UUIDv7s struct {
+	timeRandom
+}
+
+// NewUUIDv7s creates a UUIDv7 generator that takes timestamps from clock
+func NewUUIDv7s(clock Clock) *UUIDv7s {
+	return &UUIDv7s{timeRandom{clock: clock}}
+}
+
+// NewID returns a new UUIDv7 such as "01928c7e-3b9a-7c3d-9f1e-5a6b7c8d9e0f"
+func (g *UUIDv7s) NewID() string {
+	ms, random := g.next()
+
+	var u [16]byte
+	binary.BigEndian.PutUint64(u[:8], ms<<16)
+	copy(u[6:], random[:])
+	u[6] = 0x70 | u[6]&0x0f // version 7
+	u[8] = 0x80 | u[8]&0x3f // RFC 9562 variant
+
+	var buf [36]byte
+	hex.Encode(buf[0:8], u[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], u[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], u[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], u[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], u[10:])
+
+	return string(buf[:])
+}
+
+// crockford is the ULID alphabet: Crockford's base32 without I, L, O and U
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ULIDs generates ULIDs (https://github.com/ulid/spec)
+type ULIDs struct {
+	timeRandom
+}
+
+// NewULIDs creates a ULID generator that takes timestamps from clock
+func NewULIDs(clock Clock) *ULIDs {
+	return &ULIDs{timeRandom{clock: clock}}
+}
+
+// NewID returns a new 26-character ULID such as "01J9ZQ6X3M8K4T2B7N5R1C0V9D"
+func (g *ULIDs) NewID() string {
+	ms, random := g.next()
+
+	var id [16]byte
+	binary.BigEndian.PutUint64(id[:8], ms<<16)
+	copy(id[6:], random[:])
+
+	return encodeULID(id)
+}
+
+// encodeULID encodes 128 bits as 26 base32 characters, the first of which
+// only carries 3 bits
+func encodeULID(id [16]byte) string {
+	hi := binary.BigEndian.Uint64(id[:8])
+	lo := binary.BigEndian.Uint64(id[8:])
+
+	var buf [26]byte
+	for i := 25; i >= 0; i-- {
+		buf[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+
+	return string(buf[:])
+}
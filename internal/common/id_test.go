@@ -0,0 +1,97 @@
+package common
+
+import (
+	"regexp"
+	"sort"
+	"testing"
+	"time"
+)
+
+var (
+	uuidv7Pattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	ulidPattern   = regexp.MustCompile(`^[0-7][0-9A-HJKMNP-TV-Z]{25}$`)
+)
+
+func TestSequentialIDs(t *testing.T) {
+	ids := NewSequentialIDs(41)
+	for _, want := range []string{"42", "43", "44"} {
+		if got := ids.NewID(); got != want {
+			t.Errorf("NewID() = %q, want %q", got, want)
+		}
+	}
+}
+
+func TestSeedIDs(t *testing.T) {
+	ids := NewSequentialIDs(0)
+	SeedIDs(ids, "7", "01J9ZQ6X3M8K4T2B7N5R1C0V9D", "3")
+	if got := ids.NewID(); got != "8" {
+		t.Errorf("NewID() after seeding 7 = %q, want 8", got)
+	}
+
+	// Time-ordered generators don't need seeding
+	SeedIDs(NewULIDs(SystemClock{}), "7")
+}
+
+func TestTimeOrderedIDs(t *testing.T) {
+	clock := NewManualClock(time.Date(2024, 10, 1, 12, 0, 0, 0, time.UTC))
+
+	tests := []struct {
+		name    string
+		ids     IDGenerator
+		pattern *regexp.Regexp
+	}{
+		{"uuidv7", NewUUIDv7s(clock), uuidv7Pattern},
+		{"ulid", NewULIDs(clock), ulidPattern},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Several IDs per millisecond, across a few milliseconds and one
+			// step backwards in time, must still be unique and sorted
+			var generated []string
+			for i := 0; i < 30; i++ {
+				switch i {
+				case 10, 20:
+					clock.Advance(time.Millisecond)
+				case 25:
+					clock.Advance(-time.Second)
+				}
+				generated = append(generated, tt.ids.NewID())
+			}
+
+			seen := map[string]bool{}
+			for _, id := range generated {
+				if !tt.pattern.MatchString(id) {
+					t.Errorf("malformed ID %q", id)
+				}
+				if seen[id] {
+					t.Errorf("duplicate ID %q", id)
+				}
+				seen[id] = true
+			}
+			if !sort.StringsAreSorted(generated) {
+				t.Errorf("IDs not in creation order: %v", generated)
+			}
+		})
+	}
+}
+
+func TestULIDEncodesTimestamp(t *testing.T) {
+	// The first 10 characters encode the millisecond timestamp; the spec's
+	// example 1469918176385 encodes as "01ARYZ6S41"
+	clock := NewManualClock(time.UnixMilli(1469918176385))
+	if got := NewULIDs(clock).NewID()[:10]; got != "01ARYZ6S41" {
+		t.Errorf("ULID timestamp = %q, want %q", got, "01ARYZ6S41")
+	}
+}
+
+func TestNewIDGenerator(t *testing.T) {
+	for _, name := range []string{"int", "uuidv7", "ulid", ""} {
+		if _, err := NewIDGenerator(name, SystemClock{}); err != nil {
+			t.Errorf("NewIDGenerator(%q): %v", name, err)
+		}
+	}
+	if _, err := NewIDGenerator("snowflake", SystemClock{}); err == nil {
+		t.Error("NewIDGenerator accepted an unknown generator")
+	}
+}
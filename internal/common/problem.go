@@ -0,0 +1,92 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ProblemContentType is the media type of RFC 7807 problem details
+const ProblemContentType = "application/problem+json"
+
+// ProblemTypePrefix prefixes the error code to form a problem's type URI
+const ProblemTypePrefix = "urn:all-in-one:problem:"
+
+// Problem is an RFC 7807 problem details object, extended with the error
+// code and the invalid fields
+type Problem struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Code     string       `json:"code"`
+	Errors   []FieldError `json:"errors,omitempty"`
+}
+
+type problemContextKey struct{}
+
+// ProblemJSON makes WriteError answer every request below it with
+// application/problem+json, whatever the client accepts
+func ProblemJSON(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), problemContextKey{}, true)))
+	})
+}
+
+// WriteError sends err to the client. It's an RFC 7807 problem if the
+// client accepts application/problem+json or ProblemJSON is in effect, and
+// the usual Response otherwise. Internal errors are logged with their cause.
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	e := AsError(err)
+	status := e.Status()
+	if status >= http.StatusInternalServerError {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"method": r.Method,
+			"path":   r.URL.Path,
+		}).Error(e.Message)
+	}
+
+	if wantsProblem(r) {
+		w.Header().Set("Content-Type", ProblemContentType)
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(Problem{
+			Type:     ProblemTypePrefix + e.Code,
+			Title:    http.StatusText(status),
+			Status:   status,
+			Detail:   e.Message,
+			Instance: r.URL.Path,
+			Code:     e.Code,
+			Errors:   e.Fields,
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(Response{
+		Success: false,
+		Error:   e.Message,
+		Code:    e.Code,
+		Details: e.Fields,
+	})
+}
+
+// wantsProblem reports whether r should get a problem details response
+func wantsProblem(r *http.Request) bool {
+	if on, _ := r.Context().Value(problemContextKey{}).(bool); on {
+		return true
+	}
+
+	for _, accept := range strings.Split(strings.Join(r.Header.Values("Accept"), ","), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accept))
+		if err == nil && mediaType == ProblemContentType {
+			return true
+		}
+	}
+	return false
+}
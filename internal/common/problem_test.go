@@ -0,0 +1,73 @@
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWriteErrorEnvelope(t *testing.T) {
+	req := httptest.NewRequest("POST", "/api/v1/items", nil)
+	rec := httptest.NewRecorder()
+	WriteError(rec, req, Validation("Title is required", FieldError{Field: "title", Code: "required", Message: "Title is required"}))
+
+	if rec.Code != http.StatusBadRequest || rec.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("status %d, Content-Type %q; want 400, application/json", rec.Code, rec.Header().Get("Content-Type"))
+	}
+
+	var resp Response
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decoding: %v", err)
+	}
+	if resp.Success || resp.Error != "Title is required" || resp.Code != CodeValidation {
+		t.Errorf("response = %+v", resp)
+	}
+	if len(resp.Details) != 1 || resp.Details[0].Field != "title" {
+		t.Errorf("details = %+v, want the title field", resp.Details)
+	}
+}
+
+func TestWriteErrorProblem(t *testing.T) {
+	check := func(t *testing.T, rec *httptest.ResponseRecorder) {
+		t.Helper()
+
+		if rec.Code != http.StatusNotFound || rec.Header().Get("Content-Type") != ProblemContentType {
+			t.Fatalf("status %d, Content-Type %q; want 404, %s", rec.Code, rec.Header().Get("Content-Type"), ProblemContentType)
+		}
+		var p Problem
+		if err := json.NewDecoder(rec.Body).Decode(&p); err != nil {
+			t.Fatalf("decoding: %v", err)
+		}
+		want := Problem{
+			Type:     ProblemTypePrefix + "item_not_found",
+			Title:    "Not Found",
+			Status:   http.StatusNotFound,
+			Detail:   "Item not found",
+			Instance: "/api/v1/items/7",
+			Code:     "item_not_found",
+		}
+		if p.Type != want.Type || p.Title != want.Title || p.Status != want.Status || p.Detail != want.Detail || p.Instance != want.Instance || p.Code != want.Code {
+			t.Errorf("problem = %+v, want %+v", p, want)
+		}
+	}
+	notFound := NotFound("Item not found").WithCode("item_not_found").Wrap(errors.New("no rows"))
+
+	t.Run("accepted", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/v1/items/7", nil)
+		req.Header.Set("Accept", "application/json, application/problem+json;q=0.9")
+		rec := httptest.NewRecorder()
+		WriteError(rec, req, notFound)
+		check(t, rec)
+	})
+
+	t.Run("forced", func(t *testing.T) {
+		h := ProblemJSON(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			WriteError(w, r, notFound)
+		}))
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/items/7", nil))
+		check(t, rec)
+	})
+}
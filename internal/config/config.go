@@ -3,22 +3,154 @@ package config
 import (
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/spf13/viper"
 )
 
 type Config struct {
-	Server  ServerConfig  `mapstructure:"server"`
-	Storage StorageConfig `mapstructure:"storage"`
+	Server    ServerConfig    `mapstructure:"server"`
+	Storage   StorageConfig   `mapstructure:"storage"`
+	Auth      AuthConfig      `mapstructure:"auth"`
+	Users     UsersConfig     `mapstructure:"users"`
+	RateLimit RateLimitConfig `mapstructure:"rate_limit"`
+	Metrics   MetricsConfig   `mapstructure:"metrics"`
+	Tracing   TracingConfig   `mapstructure:"tracing"`
+}
+
+// TracingConfig controls OpenTelemetry tracing of the listing and users services
+type TracingConfig struct {
+	Enabled     bool              `mapstructure:"enabled"`
+	Exporter    string            `mapstructure:"exporter"`     // "otlp" (OTLP/HTTP), "stdout" or "file"
+	Endpoint    string            `mapstructure:"endpoint"`     // OTLP collector URL; empty uses the OTEL_EXPORTER_OTLP_* variables or http://localhost:4318
+	Headers     map[string]string `mapstructure:"headers"`      // sent with every OTLP export, e.g. for authentication
+	File        string            `mapstructure:"file"`         // JSON lines file of the file exporter
+	SampleRatio float64           `mapstructure:"sample_ratio"` // fraction of new traces recorded; incoming sampled traces always are
+}
+
+// MetricsConfig controls the Prometheus metrics of the listing service
+type MetricsConfig struct {
+	Enabled bool   `mapstructure:"enabled"`
+	Addr    string `mapstructure:"addr"` // admin address serving only the metrics; empty serves them on the API port
+	Path    string `mapstructure:"path"`
+}
+
+// RateLimitConfig controls per-client rate limiting of the API routes
+type RateLimitConfig struct {
+	Enabled        bool                      `mapstructure:"enabled"`
+	TrustedProxies []string                  `mapstructure:"trusted_proxies"` // CIDRs or addresses whose X-Forwarded-For is believed
+	Default        RateLimit                 `mapstructure:"default"`         // for routes in no group; zero requests means unlimited
+	Groups         map[string]RateLimitGroup `mapstructure:"groups"`
+}
+
+// RateLimit allows Requests per Period, in bursts of up to Burst
+type RateLimit struct {
+	Requests int           `mapstructure:"requests"`
+	Period   time.Duration `mapstructure:"period"`
+	Burst    int           `mapstructure:"burst"` // 0 means the same as requests
+}
+
+// RateLimitGroup is a limit for the routes under a path prefix
+type RateLimitGroup struct {
+	Prefix    string `mapstructure:"prefix"` // e.g. "/api/v1/admin"; the longest matching prefix wins
+	RateLimit `mapstructure:",squash"`
+}
+
+// UsersConfig controls the users service. Its SQLite database uses the
+// driver and connection settings under storage.
+type UsersConfig struct {
+	Port          string             `mapstructure:"port"`
+	Storage       UsersStorageConfig `mapstructure:"storage"`
+	SessionTTL    time.Duration      `mapstructure:"session_ttl"`    // how long a login lasts
+	SecureCookies bool               `mapstructure:"secure_cookies"` // only send the session cookie over HTTPS
+}
+
+// UsersStorageConfig selects where users and sessions are kept
+type UsersStorageConfig struct {
+	Type string `mapstructure:"type"` // "memory" or "sqlite"
+	Path string `mapstructure:"path"` // SQLite database; must not be the listing database
+}
+
+// AuthConfig controls authentication of the /api/v1 routes
+type AuthConfig struct {
+	Enabled bool                `mapstructure:"enabled"` // require a bearer token on every route but /api/v1/health
+	JWT     JWTConfig           `mapstructure:"jwt"`
+	Roles   map[string][]string `mapstructure:"roles"` // role → permissions; empty means the built-in read, write and admin roles
+}
+
+// JWTConfig controls validation of JWTs from an identity provider, accepted
+// alongside API keys
+type JWTConfig struct {
+	Enabled     bool          `mapstructure:"enabled"`
+	Issuer      string        `mapstructure:"issuer"`       // required iss claim; must be set
+	Audience    string        `mapstructure:"audience"`     // required aud entry; must be set
+	KeyFile     string        `mapstructure:"key_file"`     // JWKS document or PEM public key/certificate, for RS256 and ES256
+	Secret      string        `mapstructure:"secret"`       // shared secret for HS256
+	ScopesClaim string        `mapstructure:"scopes_claim"` // claim holding the granted scopes
+	Leeway      time.Duration `mapstructure:"leeway"`       // allowed clock skew for exp and nbf
 }
 
 type ServerConfig struct {
-	Port string `mapstructure:"port"`
+	Port        string `mapstructure:"port"`
+	ErrorFormat string `mapstructure:"error_format"` // "json" (the usual response, or problem+json if accepted) or "problem" (always problem+json)
+
+	// HTTP server timeouts, shared by the listing and users services; 0 means none
+	ReadTimeout       time.Duration `mapstructure:"read_timeout"`        // reading a whole request, body included
+	ReadHeaderTimeout time.Duration `mapstructure:"read_header_timeout"` // reading request headers
+	WriteTimeout      time.Duration `mapstructure:"write_timeout"`       // from the end of the request headers to the end of the response
+	IdleTimeout       time.Duration `mapstructure:"idle_timeout"`        // keep-alive connections between requests
+	ShutdownTimeout   time.Duration `mapstructure:"shutdown_timeout"`    // drain deadline for in-flight requests on SIGINT/SIGTERM
+
+	MaxRestoreSize int64 `mapstructure:"max_restore_size"` // bytes; largest archive POST /api/v1/admin/restore accepts, as sent and uncompressed
+
+	TLS TLSConfig `mapstructure:"tls"`
+}
+
+// TLSConfig enables HTTPS, and optionally mutual TLS, on the services
+type TLSConfig struct {
+	Enabled        bool          `mapstructure:"enabled"`
+	CertFile       string        `mapstructure:"cert_file"`       // PEM certificate chain
+	KeyFile        string        `mapstructure:"key_file"`        // PEM private key
+	MinVersion     string        `mapstructure:"min_version"`     // "1.2" or "1.3"
+	CipherSuites   []string      `mapstructure:"cipher_suites"`   // TLS 1.2 suites by Go name; empty means Go's defaults
+	ClientCAFile   string        `mapstructure:"client_ca_file"`  // PEM CAs for client certificates; set for mutual TLS
+	ClientAuth     string        `mapstructure:"client_auth"`     // with client_ca_file: "require" (default) or "optional"
+	ReloadInterval time.Duration `mapstructure:"reload_interval"` // how often the files are checked for changes
+	RedirectAddr   string        `mapstructure:"redirect_addr"`   // listing service only: plain HTTP address redirecting to HTTPS
 }
 
 type StorageConfig struct {
-	Type string `mapstructure:"type"` // "memory" or "sqlite"
-	Path string `mapstructure:"path"` // used for sqlite storage
+	Type       string           `mapstructure:"type"`   // "memory", "sqlite" or "bolt"
+	Path       string           `mapstructure:"path"`   // used for sqlite and bolt storage
+	Driver     string           `mapstructure:"driver"` // sqlite driver: "mattn" (cgo) or "modernc" (pure Go); empty picks the default for the build
+	IDs        string           `mapstructure:"ids"`    // generator for item UIDs: "ulid", "uuidv7" or "int"
+	SQLite     SQLiteConfig     `mapstructure:"sqlite"`
+	Cache      CacheConfig      `mapstructure:"cache"`
+	Encryption EncryptionConfig `mapstructure:"encryption"`
+}
+
+// EncryptionConfig controls field-level encryption of item content at rest
+type EncryptionConfig struct {
+	Enabled bool   `mapstructure:"enabled"`
+	Keyfile string `mapstructure:"keyfile"` // JSON keyfile holding the primary key ID and all keys
+}
+
+// CacheConfig controls the read-through item cache in front of the storage backend
+type CacheConfig struct {
+	Enabled bool          `mapstructure:"enabled"`
+	Size    int           `mapstructure:"size"` // max entries in each of the item and list caches
+	TTL     time.Duration `mapstructure:"ttl"`
+}
+
+// SQLiteConfig holds SQLite connection tuning, applied to every pooled connection
+type SQLiteConfig struct {
+	JournalMode     string        `mapstructure:"journal_mode"`       // PRAGMA journal_mode, e.g. "WAL"
+	Synchronous     string        `mapstructure:"synchronous"`        // PRAGMA synchronous: OFF, NORMAL, FULL or EXTRA
+	BusyTimeout     time.Duration `mapstructure:"busy_timeout"`       // wait on a locked database before failing
+	ForeignKeys     bool          `mapstructure:"foreign_keys"`       // PRAGMA foreign_keys
+	MaxOpenConns    int           `mapstructure:"max_open_conns"`     // 0 means unlimited
+	MaxIdleConns    int           `mapstructure:"max_idle_conns"`     // 0 means the database/sql default
+	ConnMaxIdleTime time.Duration `mapstructure:"conn_max_idle_time"` // 0 means idle connections are kept
 }
 
 func LoadConfig() (*Config, error) {
@@ -29,8 +161,51 @@ func LoadConfig() (*Config, error) {
 
 	// Set default values
 	viper.SetDefault("server.port", ":8080")
+	viper.SetDefault("server.error_format", "json")
+	viper.SetDefault("server.read_timeout", "30s")
+	viper.SetDefault("server.read_header_timeout", "5s")
+	viper.SetDefault("server.write_timeout", "60s")
+	viper.SetDefault("server.idle_timeout", "120s")
+	viper.SetDefault("server.shutdown_timeout", "20s")
+	viper.SetDefault("server.max_restore_size", 64<<20)
+	viper.SetDefault("server.tls.enabled", false)
+	viper.SetDefault("server.tls.min_version", "1.2")
+	viper.SetDefault("server.tls.reload_interval", "30s")
 	viper.SetDefault("storage.type", "memory")
 	viper.SetDefault("storage.path", "./data/listings.db")
+	viper.SetDefault("storage.ids", "ulid")
+	viper.SetDefault("storage.sqlite.journal_mode", "WAL")
+	viper.SetDefault("storage.sqlite.synchronous", "NORMAL")
+	viper.SetDefault("storage.sqlite.busy_timeout", "5s")
+	viper.SetDefault("storage.sqlite.foreign_keys", true)
+	viper.SetDefault("storage.sqlite.max_open_conns", 0)
+	viper.SetDefault("storage.sqlite.max_idle_conns", 2)
+	viper.SetDefault("storage.sqlite.conn_max_idle_time", "5m")
+	viper.SetDefault("storage.cache.enabled", false)
+	viper.SetDefault("storage.cache.size", 1000)
+	viper.SetDefault("storage.cache.ttl", "30s")
+	viper.SetDefault("storage.encryption.enabled", false)
+	viper.SetDefault("storage.encryption.keyfile", "./data/keys.json")
+	viper.SetDefault("auth.enabled", false)
+	viper.SetDefault("auth.jwt.enabled", false)
+	viper.SetDefault("auth.jwt.scopes_claim", "scope")
+	viper.SetDefault("auth.jwt.leeway", "30s")
+	viper.SetDefault("users.port", ":8081")
+	viper.SetDefault("users.storage.type", "memory")
+	viper.SetDefault("users.storage.path", "./data/users.db")
+	viper.SetDefault("users.session_ttl", "24h")
+	viper.SetDefault("users.secure_cookies", false)
+	viper.SetDefault("rate_limit.enabled", false)
+	viper.SetDefault("rate_limit.default.requests", 300)
+	viper.SetDefault("rate_limit.default.period", "1m")
+	viper.SetDefault("rate_limit.default.burst", 0)
+	viper.SetDefault("metrics.enabled", false)
+	viper.SetDefault("metrics.addr", ":9090")
+	viper.SetDefault("metrics.path", "/metrics")
+	viper.SetDefault("tracing.enabled", false)
+	viper.SetDefault("tracing.exporter", "otlp")
+	viper.SetDefault("tracing.file", "./data/traces.jsonl")
+	viper.SetDefault("tracing.sample_ratio", 1.0)
 
 	// Enable environment variable support
 	viper.AutomaticEnv()
@@ -39,7 +214,34 @@ func LoadConfig() (*Config, error) {
 	// Allow command-line flags to override config
 	viper.BindEnv("storage.type", "ALLINONE_STORAGE_TYPE")
 	viper.BindEnv("storage.path", "ALLINONE_STORAGE_PATH")
+	viper.BindEnv("storage.driver", "ALLINONE_STORAGE_DRIVER")
+	viper.BindEnv("storage.ids", "ALLINONE_STORAGE_IDS")
+	viper.BindEnv("storage.cache.enabled", "ALLINONE_STORAGE_CACHE_ENABLED")
+	viper.BindEnv("storage.encryption.enabled", "ALLINONE_STORAGE_ENCRYPTION_ENABLED")
+	viper.BindEnv("storage.encryption.keyfile", "ALLINONE_STORAGE_ENCRYPTION_KEYFILE")
+	viper.BindEnv("auth.enabled", "ALLINONE_AUTH_ENABLED")
+	viper.BindEnv("auth.jwt.enabled", "ALLINONE_AUTH_JWT_ENABLED")
+	viper.BindEnv("auth.jwt.issuer", "ALLINONE_AUTH_JWT_ISSUER")
+	viper.BindEnv("auth.jwt.audience", "ALLINONE_AUTH_JWT_AUDIENCE")
+	viper.BindEnv("auth.jwt.key_file", "ALLINONE_AUTH_JWT_KEY_FILE")
+	viper.BindEnv("auth.jwt.secret", "ALLINONE_AUTH_JWT_SECRET")
 	viper.BindEnv("server.port", "ALLINONE_SERVER_PORT")
+	viper.BindEnv("server.error_format", "ALLINONE_SERVER_ERROR_FORMAT")
+	viper.BindEnv("server.shutdown_timeout", "ALLINONE_SERVER_SHUTDOWN_TIMEOUT")
+	viper.BindEnv("server.max_restore_size", "ALLINONE_SERVER_MAX_RESTORE_SIZE")
+	viper.BindEnv("server.tls.enabled", "ALLINONE_SERVER_TLS_ENABLED")
+	viper.BindEnv("server.tls.cert_file", "ALLINONE_SERVER_TLS_CERT_FILE")
+	viper.BindEnv("server.tls.key_file", "ALLINONE_SERVER_TLS_KEY_FILE")
+	viper.BindEnv("users.port", "ALLINONE_USERS_PORT")
+	viper.BindEnv("users.storage.type", "ALLINONE_USERS_STORAGE_TYPE")
+	viper.BindEnv("users.storage.path", "ALLINONE_USERS_STORAGE_PATH")
+	viper.BindEnv("users.secure_cookies", "ALLINONE_USERS_SECURE_COOKIES")
+	viper.BindEnv("rate_limit.enabled", "ALLINONE_RATE_LIMIT_ENABLED")
+	viper.BindEnv("metrics.enabled", "ALLINONE_METRICS_ENABLED")
+	viper.BindEnv("metrics.addr", "ALLINONE_METRICS_ADDR")
+	viper.BindEnv("tracing.enabled", "ALLINONE_TRACING_ENABLED")
+	viper.BindEnv("tracing.exporter", "ALLINONE_TRACING_EXPORTER")
+	viper.BindEnv("tracing.endpoint", "ALLINONE_TRACING_ENDPOINT")
 
 	// Try to read config file (it's okay if it doesn't exist)
 	if err := viper.ReadInConfig(); err != nil {
@@ -0,0 +1,163 @@
+package backup
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/all-in-one/internal/listing/pkg/model"
+)
+
+// Archive format identifiers
+const (
+	Format  = "all-in-one/listing-backup"
+	Version = 1
+)
+
+// ErrInvalidArchive is returned when an archive can't be read or fails
+// verification
+var ErrInvalidArchive = errors.New("invalid backup archive")
+
+// ErrTooLarge is returned when an archive decompresses to more than the
+// size limit given to Read
+var ErrTooLarge = errors.New("backup archive too large")
+
+// Header describes an archive. It is written alongside the items so that an
+// archive can be inspected and verified without knowing which backend
+// produced it.
+type Header struct {
+	Format    string    `json:"format"`
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	ItemCount int       `json:"item_count"`
+
+	// Checksum is "sha256:<hex>" of the exact bytes of the items array
+	Checksum string `json:"checksum"`
+}
+
+// archive is the on-disk layout: a gzip-compressed JSON document
+type archive struct {
+	Header
+	Items json.RawMessage `json:"items"`
+}
+
+// Write encodes items into a checksummed archive
+func Write(w io.Writer, items []model.Item) (Header, error) {
+	if items == nil {
+		items = []model.Item{}
+	}
+
+	itemsJSON, err := json.Marshal(items)
+	if err != nil {
+		return Header{}, err
+	}
+
+	header := Header{
+		Format:    Format,
+		Version:   Version,
+		CreatedAt: time.Now().UTC(),
+		ItemCount: len(items),
+		Checksum:  checksum(itemsJSON),
+	}
+
+	gz := gzip.NewWriter(w)
+	if err := json.NewEncoder(gz).Encode(archive{Header: header, Items: itemsJSON}); err != nil {
+		return Header{}, err
+	}
+	if err := gz.Close(); err != nil {
+		return Header{}, err
+	}
+
+	return header, nil
+}
+
+// Read decodes an archive, verifying its format, checksum and item count,
+// and that item IDs are usable by every backend. If maxSize is positive,
+// archives decompressing to more than maxSize bytes fail with ErrTooLarge.
+func Read(r io.Reader, maxSize int64) (Header, []model.Item, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return Header{}, nil, fmt.Errorf("%w: %w", ErrInvalidArchive, err)
+	}
+	defer gz.Close()
+
+	var src io.Reader = gz
+	if maxSize > 0 {
+		src = &limitReader{r: gz, remaining: maxSize}
+	}
+
+	var a archive
+	if err := json.NewDecoder(src).Decode(&a); err != nil {
+		if errors.Is(err, ErrTooLarge) {
+			return Header{}, nil, fmt.Errorf("%w: more than %d bytes uncompressed", ErrTooLarge, maxSize)
+		}
+		return Header{}, nil, fmt.Errorf("%w: %w", ErrInvalidArchive, err)
+	}
+
+	if a.Format != Format {
+		return Header{}, nil, fmt.Errorf("%w: unknown format %q", ErrInvalidArchive, a.Format)
+	}
+	if a.Version != Version {
+		return Header{}, nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidArchive, a.Version)
+	}
+	if sum := checksum(a.Items); sum != a.Checksum {
+		return Header{}, nil, fmt.Errorf("%w: checksum mismatch (archive says %s, content is %s)", ErrInvalidArchive, a.Checksum, sum)
+	}
+
+	var items []model.Item
+	if err := json.Unmarshal(a.Items, &items); err != nil {
+		return Header{}, nil, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+	if len(items) != a.ItemCount {
+		return Header{}, nil, fmt.Errorf("%w: header says %d items, found %d", ErrInvalidArchive, a.ItemCount, len(items))
+	}
+
+	seen := make(map[int]bool, len(items))
+	for _, item := range items {
+		if item.ID <= 0 {
+			return Header{}, nil, fmt.Errorf("%w: invalid item ID %d", ErrInvalidArchive, item.ID)
+		}
+		if seen[item.ID] {
+			return Header{}, nil, fmt.Errorf("%w: duplicate item ID %d", ErrInvalidArchive, item.ID)
+		}
+		seen[item.ID] = true
+	}
+
+	return a.Header, items, nil
+}
+
+// limitReader reads from r until more than remaining bytes have been read,
+// then fails with ErrTooLarge, so a small archive can't decompress into an
+// unbounded amount of memory
+type limitReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+// Read implements io.Reader
+func (l *limitReader) Read(p []byte) (int, error) {
+	if l.remaining < 0 {
+		return 0, ErrTooLarge
+	}
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return n, ErrTooLarge
+	}
+	return n, err
+}
+
+// checksum returns the archive checksum of the given bytes
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
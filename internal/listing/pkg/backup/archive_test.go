@@ -0,0 +1,164 @@
+package backup
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/all-in-one/internal/listing/pkg/model"
+)
+
+// testItems returns two items with every field set
+func testItems() []model.Item {
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	return []model.Item{
+		{ID: 1, UID: "01HNAAAAAAAAAAAAAAAAAAAAAA", Title: "Lamp", Description: "Brass desk lamp", CreatedAt: created, UpdatedAt: created},
+		{ID: 7, UID: "01HNBBBBBBBBBBBBBBBBBBBBBB", Title: "Chair", Description: "Oak", CreatedAt: created, UpdatedAt: created.Add(time.Hour)},
+	}
+}
+
+// writeRaw gzips a with no checks, for building broken archives
+func writeRaw(t *testing.T, a archive) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(gz).Encode(a); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// validArchive returns the parts of a valid archive of items, to tamper with
+func validArchive(t *testing.T, items []model.Item) archive {
+	t.Helper()
+
+	itemsJSON, err := json.Marshal(items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return archive{
+		Header: Header{
+			Format:    Format,
+			Version:   Version,
+			CreatedAt: time.Now().UTC(),
+			ItemCount: len(items),
+			Checksum:  checksum(itemsJSON),
+		},
+		Items: itemsJSON,
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for name, items := range map[string][]model.Item{
+		"items": testItems(),
+		"empty": nil,
+	} {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			written, err := Write(&buf, items)
+			if err != nil {
+				t.Fatalf("Write: %v", err)
+			}
+
+			read, got, err := Read(&buf, 0)
+			if err != nil {
+				t.Fatalf("Read: %v", err)
+			}
+			if read.Checksum != written.Checksum || read.ItemCount != len(items) || read.Format != Format || read.Version != Version {
+				t.Errorf("header = %+v, want %+v", read, written)
+			}
+			if len(got) != len(items) || (len(items) > 0 && !reflect.DeepEqual(got, items)) {
+				t.Errorf("items = %+v, want %+v", got, items)
+			}
+		})
+	}
+}
+
+func TestReadRejectsBadArchives(t *testing.T) {
+	var good bytes.Buffer
+	if _, err := Write(&good, testItems()); err != nil {
+		t.Fatal(err)
+	}
+
+	wrongVersion := validArchive(t, testItems())
+	wrongVersion.Version = Version + 1
+
+	wrongFormat := validArchive(t, testItems())
+	wrongFormat.Format = "someone-else/backup"
+
+	badChecksum := validArchive(t, testItems())
+	badChecksum.Checksum = checksum([]byte("[]"))
+
+	wrongCount := validArchive(t, testItems())
+	wrongCount.ItemCount = 3
+
+	duplicate := testItems()
+	duplicate[1].ID = duplicate[0].ID
+
+	zeroID := testItems()
+	zeroID[0].ID = 0
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"not gzip", []byte(`{"format":"all-in-one/listing-backup"}`)},
+		{"truncated", good.Bytes()[:good.Len()/2]},
+		{"not JSON", gzipped(t, "not json")},
+		{"wrong version", writeRaw(t, wrongVersion)},
+		{"wrong format", writeRaw(t, wrongFormat)},
+		{"checksum mismatch", writeRaw(t, badChecksum)},
+		{"item count mismatch", writeRaw(t, wrongCount)},
+		{"duplicate IDs", writeRaw(t, validArchive(t, duplicate))},
+		{"zero ID", writeRaw(t, validArchive(t, zeroID))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := Read(bytes.NewReader(tt.data), 0)
+			if !errors.Is(err, ErrInvalidArchive) {
+				t.Errorf("Read error = %v, want ErrInvalidArchive", err)
+			}
+		})
+	}
+}
+
+func TestReadLimitsUncompressedSize(t *testing.T) {
+	items := []model.Item{{ID: 1, Title: "Big", Description: strings.Repeat("a", 1<<20)}}
+	var buf bytes.Buffer
+	if _, err := Write(&buf, items); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() > 64<<10 {
+		t.Fatalf("archive is %d bytes compressed; the test needs it small", buf.Len())
+	}
+
+	if _, _, err := Read(bytes.NewReader(buf.Bytes()), 1<<20); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Read over the limit error = %v, want ErrTooLarge", err)
+	}
+	if _, _, err := Read(bytes.NewReader(buf.Bytes()), 2<<20); err != nil {
+		t.Errorf("Read under the limit: %v", err)
+	}
+}
+
+// gzipped compresses s
+func gzipped(t *testing.T, s string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write([]byte(s))
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/all-in-one/internal/auth"
+	"github.com/all-in-one/internal/common"
+	"github.com/all-in-one/internal/listing/pkg/backup"
+	"github.com/gorilla/mux"
+)
+
+// DefaultMaxRestoreSize is the largest archive the restore route accepts,
+// uncompressed, unless SetMaxRestoreSize says otherwise
+const DefaultMaxRestoreSize = 64 << 20
+
+// SetMaxRestoreSize limits archives uploaded to the restore route to bytes,
+// both as sent and uncompressed. Zero or less means DefaultMaxRestoreSize.
+func (h *Handler) SetMaxRestoreSize(bytes int64) {
+	if bytes <= 0 {
+		bytes = DefaultMaxRestoreSize
+	}
+	h.maxRestoreSize = bytes
+}
+
+// RegisterAdminRoutes registers the listing admin routes to the given router
+func (h *Handler) RegisterAdminRoutes(router *mux.Router) {
+	router.HandleFunc("/backup", h.require(auth.PermAdmin, h.Backup)).Methods("GET").Name("backup")
+	router.HandleFunc("/restore", h.require(auth.PermAdmin, h.Restore)).Methods("POST").Name("restore")
+	router.HandleFunc("/items/by-id/{id}", h.require(auth.PermAdmin, h.GetItemByID)).Methods("GET").Name("getItemByID")
+}
+
+// GET /admin/items/by-id/{id} - Get item by numeric ID, which only admins
+// get to see
+func (h *Handler) GetItemByID(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		common.WriteError(w, r, common.NotFound("Item not found").WithCode("item_not_found"))
+		return
+	}
+
+	item, err := h.store(r).Items().Get(id)
+	if err != nil {
+		common.WriteError(w, r, storageError(err, "Failed to retrieve item"))
+		return
+	}
+
+	response := common.Response{
+		Success: true,
+		Data:    item,
+	}
+
+	sendJSON(w, response, http.StatusOK)
+}
+
+// GET /admin/backup - Download a backup archive of all items
+func (h *Handler) Backup(w http.ResponseWriter, r *http.Request) {
+	items, err := h.store(r).Snapshot()
+	if err != nil {
+		common.WriteError(w, r, common.Internal("Failed to snapshot items", err))
+		return
+	}
+
+	// Encode into a buffer first so a failure can still produce an error
+	// response instead of a truncated download
+	var buf bytes.Buffer
+	if _, err := backup.Write(&buf, items); err != nil {
+		common.WriteError(w, r, common.Internal("Failed to write backup archive", err))
+		return
+	}
+
+	filename := fmt.Sprintf("listing-backup-%s.json.gz", time.Now().UTC().Format("20060102T150405Z"))
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
+
+// POST /admin/restore - Replace all items with the contents of a backup archive
+func (h *Handler) Restore(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, h.maxRestoreSize)
+	header, items, err := backup.Read(r.Body, h.maxRestoreSize)
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr), errors.Is(err, backup.ErrTooLarge):
+		common.WriteError(w, r, common.TooLarge(fmt.Sprintf("Backup archive is larger than %d bytes", h.maxRestoreSize)))
+		return
+	case err != nil:
+		common.WriteError(w, r, common.Validation(err.Error()).WithCode("invalid_backup"))
+		return
+	}
+
+	if err := h.store(r).Restore(items); err != nil {
+		common.WriteError(w, r, common.Internal("Failed to restore items", err))
+		return
+	}
+
+	response := common.Response{
+		Success: true,
+		Message: fmt.Sprintf("Restored %d items", len(items)),
+		Data:    header,
+	}
+
+	sendJSON(w, response, http.StatusOK)
+}
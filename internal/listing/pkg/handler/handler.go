@@ -2,9 +2,15 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"sync/atomic"
 
+	"github.com/all-in-one/internal/auth"
 	"github.com/all-in-one/internal/common"
 	"github.com/all-in-one/internal/listing/pkg/model"
 	"github.com/all-in-one/internal/listing/pkg/repository"
@@ -14,61 +20,182 @@ import (
 // Handler manages HTTP requests for the listing service
 type Handler struct {
 	storage repository.Storage
+	policy  atomic.Pointer[auth.Policy]
+
+	// maxRestoreSize limits restore uploads
+	maxRestoreSize int64
 }
 
-// NewHandler creates a new listing handler
+// NewHandler creates a new listing handler. It allows every request until
+// SetPolicy is called.
 func NewHandler(storage repository.Storage) *Handler {
-	return &Handler{
-		storage: storage,
+	h := &Handler{storage: storage, maxRestoreSize: DefaultMaxRestoreSize}
+	h.policy.Store(auth.AllowAll())
+	return h
+}
+
+// store returns the storage bound to the context of r
+func (h *Handler) store(r *http.Request) repository.Storage {
+	return repository.WithContext(r.Context(), h.storage)
+}
+
+// SetPolicy makes the routes check permissions with policy, whether they
+// were registered before or after. Use auth.AllowAll() when authentication
+// is off; a nil policy refuses every request.
+func (h *Handler) SetPolicy(policy *auth.Policy) {
+	h.policy.Store(policy)
+}
+
+// require wraps next so it only runs if the policy in effect at request
+// time grants perm
+func (h *Handler) require(perm string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		h.policy.Load().Require(perm, next)(w, r)
 	}
 }
 
 // RegisterRoutes registers the listing routes to the given router
 func (h *Handler) RegisterRoutes(router *mux.Router) {
-	router.HandleFunc("/items", h.GetItems).Methods("GET")
-	router.HandleFunc("/items", h.CreateItem).Methods("POST")
-	router.HandleFunc("/items/{id}", h.GetItem).Methods("GET")
-	router.HandleFunc("/items/{id}", h.UpdateItem).Methods("PUT")
-	router.HandleFunc("/items/{id}", h.DeleteItem).Methods("DELETE")
+	router.HandleFunc("/items", h.require(auth.PermItemsRead, h.GetItems)).Methods("GET").Name("listItems")
+	router.HandleFunc("/items", h.require(auth.PermItemsWrite, h.CreateItem)).Methods("POST").Name("createItem")
+	router.HandleFunc("/items/{id}", h.require(auth.PermItemsRead, h.GetItem)).Methods("GET").Name("getItem")
+	router.HandleFunc("/items/{id}", h.require(auth.PermItemsWrite, h.UpdateItem)).Methods("PUT").Name("updateItem")
+	router.HandleFunc("/items/{id}", h.require(auth.PermItemsWrite, h.PatchItem)).Methods("PATCH").Name("patchItem")
+	router.HandleFunc("/items/{id}", h.require(auth.PermItemsDelete, h.DeleteItem)).Methods("DELETE").Name("deleteItem")
+	router.HandleFunc("/me/permissions", h.GetMyPermissions).Methods("GET").Name("getMyPermissions")
+}
+
+// myPermissions is the body of GET /me/permissions
+type myPermissions struct {
+	Subject     string   `json:"subject"`
+	Name        string   `json:"name"`
+	Method      string   `json:"method"`
+	Roles       []string `json:"roles"`
+	Permissions []string `json:"permissions"`
+}
+
+// GET /me/permissions - Get the caller's roles and permissions
+func (h *Handler) GetMyPermissions(w http.ResponseWriter, r *http.Request) {
+	principal, ok := auth.PrincipalFromContext(r.Context())
+
+	policy := h.policy.Load()
+
+	var permissions []string
+	switch {
+	case policy.AllowsAll():
+		// Authentication is off, so everything is allowed
+		principal = auth.Principal{Subject: "anonymous", Method: "none", Roles: []string{}}
+		permissions = auth.AllPermissions()
+	case !ok:
+		common.WriteError(w, r, common.Unauthorized("Missing bearer token").WithCode("missing_token"))
+		return
+	default:
+		permissions = policy.Permissions(principal.Roles)
+	}
+
+	response := common.Response{
+		Success: true,
+		Data: myPermissions{
+			Subject:     principal.Subject,
+			Name:        principal.Name,
+			Method:      principal.Method,
+			Roles:       principal.Roles,
+			Permissions: permissions,
+		},
+	}
+
+	sendJSON(w, response, http.StatusOK)
 }
 
-// GET /items - Get all items
+// Page sizes of GET /items
+const (
+	DefaultPageSize = 100
+	MaxPageSize     = 1000
+)
+
+// GET /items - Get all items, or a page of them with ?limit= and ?after=
 func (h *Handler) GetItems(w http.ResponseWriter, r *http.Request) {
-	items, err := h.storage.Items().GetAll()
+	query := r.URL.Query()
+	if !query.Has("limit") && !query.Has("after") {
+		items, err := h.store(r).Items().GetAll()
+		if err != nil {
+			common.WriteError(w, r, common.Internal("Failed to retrieve items", err))
+			return
+		}
+
+		response := common.Response{
+			Success: true,
+			Data:    model.PublicItems(items),
+		}
+
+		sendJSON(w, response, http.StatusOK)
+		return
+	}
+
+	afterUID, limit, err := pageParams(query)
+	if err != nil {
+		common.WriteError(w, r, err)
+		return
+	}
+
+	// Pages follow the numeric IDs, which callers never see, so the page
+	// is continued from the ID of the item whose UID they send
+	afterID := 0
+	if afterUID != "" {
+		item, err := h.store(r).Items().GetByUID(afterUID)
+		switch {
+		case errors.Is(err, common.ErrNotFound):
+			common.WriteError(w, r, common.Validation("after must be the UID of an item", common.FieldError{
+				Field:   "after",
+				Code:    "not_found",
+				Message: "after must be the UID of an item",
+			}))
+			return
+		case err != nil:
+			common.WriteError(w, r, common.Internal("Failed to retrieve items", err))
+			return
+		}
+		afterID = item.ID
+	}
+
+	items, err := h.store(r).Items().List(afterID, limit)
 	if err != nil {
-		sendError(w, "Failed to retrieve items", http.StatusInternalServerError)
+		common.WriteError(w, r, common.Internal("Failed to retrieve items", err))
 		return
 	}
 
+	// A full page may be followed by more
+	if len(items) == limit {
+		next := url.Values{}
+		next.Set("after", items[len(items)-1].UID)
+		next.Set("limit", strconv.Itoa(limit))
+		w.Header().Set("Link", fmt.Sprintf("<%s?%s>; rel=\"next\"", r.URL.Path, next.Encode()))
+	}
+
 	response := common.Response{
 		Success: true,
-		Data:    items,
+		Data:    model.PublicItems(items),
 	}
 
 	sendJSON(w, response, http.StatusOK)
 }
 
-// GET /items/{id} - Get item by ID
+// GET /items/{id} - Get item by UID
 func (h *Handler) GetItem(w http.ResponseWriter, r *http.Request) {
-	id, err := getIDFromRequest(r)
-	if err != nil {
-		sendError(w, "Invalid ID", http.StatusBadRequest)
+	id, ok := h.getIDFromRequest(w, r)
+	if !ok {
 		return
 	}
 
-	item, err := h.storage.Items().Get(id)
+	item, err := h.store(r).Items().Get(id)
 	if err != nil {
-		if err == common.ErrNotFound {
-			sendError(w, "Item not found", http.StatusNotFound)
-			return
-		}
-		sendError(w, "Failed to retrieve item", http.StatusInternalServerError)
+		common.WriteError(w, r, storageError(err, "Failed to retrieve item"))
 		return
 	}
 
 	response := common.Response{
 		Success: true,
-		Data:    item,
+		Data:    item.Public(),
 	}
 
 	sendJSON(w, response, http.StatusOK)
@@ -78,26 +205,25 @@ func (h *Handler) GetItem(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) CreateItem(w http.ResponseWriter, r *http.Request) {
 	var newItem model.Item
 	if err := json.NewDecoder(r.Body).Decode(&newItem); err != nil {
-		sendError(w, "Invalid JSON data", http.StatusBadRequest)
+		common.WriteError(w, r, invalidJSON(err))
 		return
 	}
 
-	// Validate required fields
-	if newItem.Title == "" {
-		sendError(w, "Title is required", http.StatusBadRequest)
+	if err := model.ItemSchema.Apply(&newItem); err != nil {
+		common.WriteError(w, r, err)
 		return
 	}
 
-	createdItem, err := h.storage.Items().Create(newItem)
+	createdItem, err := h.store(r).Items().Create(newItem)
 	if err != nil {
-		sendError(w, "Failed to create item", http.StatusInternalServerError)
+		common.WriteError(w, r, common.Internal("Failed to create item", err))
 		return
 	}
 
 	response := common.Response{
 		Success: true,
 		Message: "Item created successfully",
-		Data:    createdItem,
+		Data:    createdItem.Public(),
 	}
 
 	sendJSON(w, response, http.StatusCreated)
@@ -105,38 +231,93 @@ func (h *Handler) CreateItem(w http.ResponseWriter, r *http.Request) {
 
 // PUT /items/{id} - Update an existing item
 func (h *Handler) UpdateItem(w http.ResponseWriter, r *http.Request) {
-	id, err := getIDFromRequest(r)
-	if err != nil {
-		sendError(w, "Invalid ID", http.StatusBadRequest)
+	id, ok := h.getIDFromRequest(w, r)
+	if !ok {
 		return
 	}
 
 	var updatedItem model.Item
 	if err := json.NewDecoder(r.Body).Decode(&updatedItem); err != nil {
-		sendError(w, "Invalid JSON data", http.StatusBadRequest)
+		common.WriteError(w, r, invalidJSON(err))
 		return
 	}
 
-	// Validate required fields
-	if updatedItem.Title == "" {
-		sendError(w, "Title is required", http.StatusBadRequest)
+	if err := model.ItemSchema.Apply(&updatedItem); err != nil {
+		common.WriteError(w, r, err)
 		return
 	}
 
-	result, err := h.storage.Items().Update(id, updatedItem)
+	result, err := h.store(r).Items().Update(id, updatedItem)
 	if err != nil {
-		if err == common.ErrNotFound {
-			sendError(w, "Item not found", http.StatusNotFound)
-			return
+		common.WriteError(w, r, storageError(err, "Failed to update item"))
+		return
+	}
+
+	response := common.Response{
+		Success: true,
+		Message: "Item updated successfully",
+		Data:    result.Public(),
+	}
+
+	sendJSON(w, response, http.StatusOK)
+}
+
+// itemPatch is the body of a PATCH request: fields that are left out or
+// null keep their current value
+type itemPatch struct {
+	Title       *string `json:"title"`
+	Description *string `json:"description"`
+}
+
+// PATCH /items/{id} - Update some fields of an existing item
+func (h *Handler) PatchItem(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.getIDFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var patch itemPatch
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		common.WriteError(w, r, invalidJSON(err))
+		return
+	}
+
+	// Read and write in one transaction, so concurrent patches to other
+	// fields aren't lost
+	var result model.Item
+	err := h.store(r).WithTx(r.Context(), func(tx repository.Storage) error {
+		item, err := tx.Items().Get(id)
+		if err != nil {
+			return storageError(err, "Failed to retrieve item")
+		}
+
+		var fields []string
+		if patch.Title != nil {
+			item.Title = *patch.Title
+			fields = append(fields, "title")
+		}
+		if patch.Description != nil {
+			item.Description = *patch.Description
+			fields = append(fields, "description")
+		}
+		if err := model.ItemSchema.ApplyFields(&item, fields...); err != nil {
+			return err
+		}
+
+		if result, err = tx.Items().Update(id, item); err != nil {
+			return storageError(err, "Failed to update item")
 		}
-		sendError(w, "Failed to update item", http.StatusInternalServerError)
+		return nil
+	})
+	if err != nil {
+		common.WriteError(w, r, err)
 		return
 	}
 
 	response := common.Response{
 		Success: true,
 		Message: "Item updated successfully",
-		Data:    result,
+		Data:    result.Public(),
 	}
 
 	sendJSON(w, response, http.StatusOK)
@@ -144,19 +325,14 @@ func (h *Handler) UpdateItem(w http.ResponseWriter, r *http.Request) {
 
 // DELETE /items/{id} - Delete an item
 func (h *Handler) DeleteItem(w http.ResponseWriter, r *http.Request) {
-	id, err := getIDFromRequest(r)
-	if err != nil {
-		sendError(w, "Invalid ID", http.StatusBadRequest)
+	id, ok := h.getIDFromRequest(w, r)
+	if !ok {
 		return
 	}
 
-	err = h.storage.Items().Delete(id)
+	err := h.store(r).Items().Delete(id)
 	if err != nil {
-		if err == common.ErrNotFound {
-			sendError(w, "Item not found", http.StatusNotFound)
-			return
-		}
-		sendError(w, "Failed to delete item", http.StatusInternalServerError)
+		common.WriteError(w, r, storageError(err, "Failed to delete item"))
 		return
 	}
 
@@ -170,10 +346,60 @@ func (h *Handler) DeleteItem(w http.ResponseWriter, r *http.Request) {
 
 // Helper Functions
 
-// getIDFromRequest extracts the ID from the request URL
-func getIDFromRequest(r *http.Request) (int, error) {
-	vars := mux.Vars(r)
-	return strconv.Atoi(vars["id"])
+// getIDFromRequest resolves the {id} path segment, which is the item's UID,
+// to its numeric ID. If it can't, it sends the error response and returns
+// false.
+func (h *Handler) getIDFromRequest(w http.ResponseWriter, r *http.Request) (int, bool) {
+	item, err := h.store(r).Items().GetByUID(mux.Vars(r)["id"])
+	if err != nil {
+		common.WriteError(w, r, storageError(err, "Failed to retrieve item"))
+		return 0, false
+	}
+
+	return item.ID, true
+}
+
+// pageParams reads the ?after= item UID and ?limit= page size of a paged
+// GET /items
+func pageParams(query url.Values) (after string, limit int, err error) {
+	var fields []common.FieldError
+	limit = DefaultPageSize
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > MaxPageSize {
+			fields = append(fields, common.FieldError{
+				Field:   "limit",
+				Code:    "out_of_range",
+				Message: fmt.Sprintf("limit must be a number from 1 to %d", MaxPageSize),
+			})
+		}
+		limit = n
+	}
+	after = query.Get("after")
+
+	if len(fields) > 0 {
+		messages := make([]string, len(fields))
+		for i, f := range fields {
+			messages[i] = f.Message
+		}
+		return "", 0, common.Validation(strings.Join(messages, "; "), fields...)
+	}
+	return after, limit, nil
+}
+
+// storageError turns an error from item storage into the one sent to the
+// client: not-found stays not-found, anything else is an internal error
+// described by message
+func storageError(err error, message string) error {
+	if errors.Is(err, common.ErrNotFound) {
+		return common.NotFound("Item not found").WithCode("item_not_found").Wrap(err)
+	}
+	return common.Internal(message, err)
+}
+
+// invalidJSON returns the error for a request body that isn't valid JSON
+func invalidJSON(err error) error {
+	return common.Validation("Invalid JSON data").WithCode("invalid_json").Wrap(err)
 }
 
 // sendJSON sends a JSON response
@@ -182,12 +408,3 @@ func sendJSON(w http.ResponseWriter, data interface{}, statusCode int) {
 	w.WriteHeader(statusCode)
 	json.NewEncoder(w).Encode(data)
 }
-
-// sendError sends an error response
-func sendError(w http.ResponseWriter, message string, statusCode int) {
-	response := common.Response{
-		Success: false,
-		Error:   message,
-	}
-	sendJSON(w, response, statusCode)
-}
@@ -0,0 +1,314 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/all-in-one/internal/auth"
+	"github.com/all-in-one/internal/common"
+	"github.com/all-in-one/internal/listing/pkg/backup"
+	"github.com/all-in-one/internal/listing/pkg/model"
+	"github.com/all-in-one/internal/listing/pkg/repository"
+	"github.com/all-in-one/internal/openapi"
+	"github.com/gorilla/mux"
+)
+
+// newTestRouter returns a router serving a handler on memory storage, with
+// one item whose ID is 1 and UID is "101", so the two can't be mixed up
+func newTestRouter(t *testing.T) (*mux.Router, repository.Storage) {
+	t.Helper()
+
+	store, err := repository.NewStorage("memory", "", repository.Options{IDs: common.NewSequentialIDs(100)})
+	if err != nil {
+		t.Fatalf("NewStorage: %v", err)
+	}
+	if _, err := store.Items().Create(model.Item{Title: "Lamp", Description: "Brass desk lamp"}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	router := mux.NewRouter()
+	NewHandler(store).RegisterRoutes(router)
+	return router, store
+}
+
+// send sends a request and decodes the response
+func send(t *testing.T, router http.Handler, method, path, body string) (int, common.Response) {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+
+	var resp common.Response
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("%s %s: decoding response: %v", method, path, err)
+	}
+	return rec.Code, resp
+}
+
+// detailCodes returns the field → code map of a response's details
+func detailCodes(resp common.Response) map[string]string {
+	codes := map[string]string{}
+	for _, d := range resp.Details {
+		codes[d.Field] = d.Code
+	}
+	return codes
+}
+
+func TestPolicySetAfterRegisteringRoutes(t *testing.T) {
+	store, err := repository.NewStorage("memory", "", repository.Options{})
+	if err != nil {
+		t.Fatalf("NewStorage: %v", err)
+	}
+
+	h := NewHandler(store)
+	router := mux.NewRouter()
+	h.RegisterRoutes(router)
+
+	if code, _ := send(t, router, "GET", "/items", ""); code != http.StatusOK {
+		t.Fatalf("GET /items with the default policy = %d, want 200", code)
+	}
+
+	policy, err := auth.NewPolicy(auth.DefaultRoles())
+	if err != nil {
+		t.Fatalf("NewPolicy: %v", err)
+	}
+	h.SetPolicy(policy)
+	if code, _ := send(t, router, "GET", "/items", ""); code != http.StatusUnauthorized {
+		t.Errorf("GET /items without a principal = %d, want 401", code)
+	}
+	if code, _ := send(t, router, "GET", "/me/permissions", ""); code != http.StatusUnauthorized {
+		t.Errorf("GET /me/permissions without a principal = %d, want 401", code)
+	}
+
+	h.SetPolicy(nil)
+	if code, _ := send(t, router, "GET", "/items", ""); code != http.StatusUnauthorized {
+		t.Errorf("GET /items with a nil policy = %d, want 401", code)
+	}
+}
+
+func TestNumericIDsAreAdminOnly(t *testing.T) {
+	store, err := repository.NewStorage("memory", "", repository.Options{IDs: common.NewSequentialIDs(100)})
+	if err != nil {
+		t.Fatalf("NewStorage: %v", err)
+	}
+	if _, err := store.Items().Create(model.Item{Title: "Lamp"}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	policy, err := auth.NewPolicy(auth.DefaultRoles())
+	if err != nil {
+		t.Fatalf("NewPolicy: %v", err)
+	}
+	h := NewHandler(store)
+	h.SetPolicy(policy)
+
+	for _, tt := range []struct {
+		role   string
+		path   string
+		want   int
+		withID bool
+	}{
+		{auth.RoleRead, "/items/101", http.StatusOK, false},
+		{auth.RoleRead, "/items/1", http.StatusNotFound, false},
+		{auth.RoleAdmin, "/items/1", http.StatusNotFound, false},
+		{auth.RoleAdmin, "/items/101", http.StatusOK, false},
+		{auth.RoleRead, "/admin/items/by-id/1", http.StatusForbidden, false},
+		{auth.RoleAdmin, "/admin/items/by-id/1", http.StatusOK, true},
+		{auth.RoleAdmin, "/admin/items/by-id/101", http.StatusNotFound, false},
+	} {
+		router := mux.NewRouter()
+		router.Use(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				principal := auth.Principal{Subject: "test", Roles: []string{tt.role}}
+				next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+			})
+		})
+		h.RegisterRoutes(router)
+		h.RegisterAdminRoutes(router.PathPrefix("/admin").Subrouter())
+
+		code, resp := send(t, router, "GET", tt.path, "")
+		if code != tt.want {
+			t.Errorf("%s: GET %s = %d, want %d", tt.role, tt.path, code, tt.want)
+			continue
+		}
+		if data, ok := resp.Data.(map[string]interface{}); ok {
+			if _, hasID := data["id"]; hasID != tt.withID {
+				t.Errorf("%s: GET %s has id %v, want %v", tt.role, tt.path, hasID, tt.withID)
+			}
+		}
+	}
+}
+
+func TestCreateAndUpdateValidateEveryField(t *testing.T) {
+	router, store := newTestRouter(t)
+
+	for _, req := range []struct{ method, path string }{{"POST", "/items"}, {"PUT", "/items/101"}} {
+		status, resp := send(t, router, req.method, req.path, `{"title": "  ", "description": "`+strings.Repeat("x", model.MaxDescriptionLength+1)+`"}`)
+		if status != http.StatusBadRequest || resp.Code != common.CodeValidation {
+			t.Errorf("%s %s: status %d, code %q; want 400, %s", req.method, req.path, status, resp.Code, common.CodeValidation)
+		}
+		codes := detailCodes(resp)
+		if codes["title"] != "required" || codes["description"] != "too_long" {
+			t.Errorf("%s %s: details %v, want title required and description too_long", req.method, req.path, codes)
+		}
+	}
+
+	status, _ := send(t, router, "POST", "/items", `{"title": "  Chair ", "description": "Oak\nchair"}`)
+	if status != http.StatusCreated {
+		t.Fatalf("valid create: status %d, want 201", status)
+	}
+	item, _ := store.Items().Get(2)
+	if item.Title != "Chair" {
+		t.Errorf("stored title %q, want it trimmed", item.Title)
+	}
+}
+
+func TestPatchItem(t *testing.T) {
+	router, store := newTestRouter(t)
+
+	status, resp := send(t, router, "PATCH", "/items/101", `{"description": "  Green glass lamp  "}`)
+	if status != http.StatusOK {
+		t.Fatalf("patch: status %d, error %q", status, resp.Error)
+	}
+	item, _ := store.Items().Get(1)
+	if item.Title != "Lamp" || item.Description != "Green glass lamp" {
+		t.Errorf("after patch item = %+v, want the title kept and the description trimmed", item)
+	}
+
+	status, resp = send(t, router, "PATCH", "/items/101", `{"title": "bad\u0007title"}`)
+	if status != http.StatusBadRequest || detailCodes(resp)["title"] != "forbidden_character" {
+		t.Errorf("invalid patch: status %d, details %v; want 400 with a title error", status, resp.Details)
+	}
+	if item, _ := store.Items().Get(1); item.Title != "Lamp" {
+		t.Errorf("invalid patch changed the title to %q", item.Title)
+	}
+
+	if status, resp := send(t, router, "PATCH", "/items/99", `{"title": "x"}`); status != http.StatusNotFound || resp.Code != "item_not_found" {
+		t.Errorf("patch of a missing item: status %d, code %q; want 404, item_not_found", status, resp.Code)
+	}
+	if status, resp := send(t, router, "PATCH", "/items/101", `{`); status != http.StatusBadRequest || resp.Code != "invalid_json" {
+		t.Errorf("patch with invalid JSON: status %d, code %q; want 400, invalid_json", status, resp.Code)
+	}
+}
+
+func TestOperationsDescribeEveryRoute(t *testing.T) {
+	store, _ := repository.NewStorage("memory", "", repository.Options{})
+	h := NewHandler(store)
+	router := mux.NewRouter()
+	h.RegisterRoutes(router)
+	h.RegisterAdminRoutes(router.PathPrefix("/admin").Subrouter())
+
+	endpoints, err := openapi.Endpoints(router)
+	if err != nil {
+		t.Fatalf("Endpoints: %v", err)
+	}
+	ops := h.Operations()
+	for _, e := range endpoints {
+		if _, ok := ops[e.Name]; !ok {
+			t.Errorf("%s %s (route %q) has no Operation", e.Method, e.Path, e.Name)
+		}
+	}
+	if len(endpoints) != len(ops) {
+		t.Errorf("%d routes, %d operations", len(endpoints), len(ops))
+	}
+}
+
+func TestGetItemsPages(t *testing.T) {
+	router, store := newTestRouter(t)
+	for _, title := range []string{"Chair", "Desk"} {
+		if _, err := store.Items().Create(model.Item{Title: title, Description: "Oak"}); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/items?limit=2", nil))
+	var resp struct {
+		Data []map[string]interface{} `json:"data"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decoding: %v", err)
+	}
+	if rec.Code != http.StatusOK || len(resp.Data) != 2 || resp.Data[0]["uid"] != "101" {
+		t.Fatalf("first page = %d %+v", rec.Code, resp.Data)
+	}
+	if _, ok := resp.Data[0]["id"]; ok {
+		t.Errorf("first page shows numeric IDs: %+v", resp.Data[0])
+	}
+	if link := rec.Header().Get("Link"); link != `</items?after=102&limit=2>; rel="next"` {
+		t.Errorf("Link = %q", link)
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/items?after=102&limit=2", nil))
+	resp.Data = nil
+	json.NewDecoder(rec.Body).Decode(&resp)
+	if len(resp.Data) != 1 || resp.Data[0]["title"] != "Desk" {
+		t.Errorf("last page = %+v", resp.Data)
+	}
+	if link := rec.Header().Get("Link"); link != "" {
+		t.Errorf("last page has Link %q", link)
+	}
+
+	// An empty page is an empty list, not a missing one
+	status, r := send(t, router, "GET", "/items?after=103", "")
+	if data, ok := r.Data.([]interface{}); status != http.StatusOK || !ok || len(data) != 0 {
+		t.Errorf("empty page = %d %#v", status, r.Data)
+	}
+
+	status, r = send(t, router, "GET", fmt.Sprintf("/items?limit=%d", MaxPageSize+1), "")
+	if codes := detailCodes(r); status != http.StatusBadRequest || codes["limit"] != "out_of_range" {
+		t.Errorf("bad limit = %d %v", status, codes)
+	}
+	status, r = send(t, router, "GET", "/items?after=1", "")
+	if codes := detailCodes(r); status != http.StatusBadRequest || codes["after"] != "not_found" {
+		t.Errorf("after a numeric ID = %d %v", status, codes)
+	}
+}
+
+func TestRestoreSizeLimit(t *testing.T) {
+	store, err := repository.NewStorage("memory", "", repository.Options{})
+	if err != nil {
+		t.Fatalf("NewStorage: %v", err)
+	}
+	h := NewHandler(store)
+	h.SetMaxRestoreSize(32 << 10)
+	router := mux.NewRouter()
+	h.RegisterAdminRoutes(router)
+
+	// Compresses to well under the limit, but not once decompressed
+	var archive bytes.Buffer
+	item := model.Item{ID: 1, Title: "Big", Description: strings.Repeat("a", 64<<10)}
+	if _, err := backup.Write(&archive, []model.Item{item}); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("POST", "/restore", bytes.NewReader(archive.Bytes())))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("restoring a large archive = %d, want 413 (body %s)", rec.Code, rec.Body)
+	}
+
+	// Doesn't compress, so the upload itself is over the limit
+	random := make([]byte, 48<<10)
+	rand.Read(random)
+	archive.Reset()
+	item.Description = hex.EncodeToString(random)
+	if _, err := backup.Write(&archive, []model.Item{item}); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if archive.Len() <= 32<<10 {
+		t.Fatalf("archive is only %d bytes", archive.Len())
+	}
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("POST", "/restore", bytes.NewReader(archive.Bytes())))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("uploading more than the limit = %d, want 413 (body %s)", rec.Code, rec.Body)
+	}
+}
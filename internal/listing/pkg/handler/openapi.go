@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/all-in-one/internal/auth"
+	"github.com/all-in-one/internal/listing/pkg/backup"
+	"github.com/all-in-one/internal/listing/pkg/model"
+	"github.com/all-in-one/internal/openapi"
+)
+
+// BearerAuth is the security scheme of the listing routes: an API key or,
+// if enabled, a JWT
+const BearerAuth = "bearerAuth"
+
+// SecuritySchemes are the security schemes the listing Operations refer to
+var SecuritySchemes = map[string]openapi.SecurityScheme{
+	BearerAuth: {
+		Type:        "http",
+		Scheme:      "bearer",
+		Description: "An API key, or a JWT if auth.jwt is enabled. Only checked when auth.enabled is set.",
+	},
+}
+
+// itemID describes the {id} path variable
+var itemID = map[string]string{"id": "UID of the item"}
+
+// Operations describes the routes registered by RegisterRoutes and
+// RegisterAdminRoutes, by route name
+func (h *Handler) Operations() openapi.Operations {
+	security := []string{BearerAuth}
+	return openapi.Operations{
+		"listItems": {
+			Summary:     "Get all items",
+			Description: "With limit or after, returns one page of items in creation order, and a Link header with rel=\"next\" when there may be more.",
+			Tags:        []string{"items"},
+			Permission:  auth.PermItemsRead,
+			Security:    security,
+			Response:    []model.PublicItem{},
+			Query: []openapi.Parameter{
+				{Name: "limit", Description: fmt.Sprintf("Page size, up to %d; %d if only after is given", MaxPageSize, DefaultPageSize), Schema: openapi.Schema{"type": "integer", "minimum": 1, "maximum": MaxPageSize}},
+				{Name: "after", Description: "UID of the last item already seen; the page starts after it", Schema: openapi.Schema{"type": "string"}},
+			},
+		},
+		"createItem": {
+			Summary:    "Create new item",
+			Tags:       []string{"items"},
+			Permission: auth.PermItemsWrite,
+			Security:   security,
+			Request:    model.PublicItem{},
+			Response:   model.PublicItem{},
+			Status:     http.StatusCreated,
+		},
+		"getItem": {
+			Summary:    "Get item by ID",
+			Tags:       []string{"items"},
+			Permission: auth.PermItemsRead,
+			Security:   security,
+			Response:   model.PublicItem{},
+			Params:     itemID,
+		},
+		"updateItem": {
+			Summary:    "Update item",
+			Tags:       []string{"items"},
+			Permission: auth.PermItemsWrite,
+			Security:   security,
+			Request:    model.PublicItem{},
+			Response:   model.PublicItem{},
+			Params:     itemID,
+		},
+		"patchItem": {
+			Summary:     "Update some fields of an item",
+			Description: "Fields that are left out or null keep their current value.",
+			Tags:        []string{"items"},
+			Permission:  auth.PermItemsWrite,
+			Security:    security,
+			Request:     itemPatch{},
+			Response:    model.PublicItem{},
+			Params:      itemID,
+		},
+		"deleteItem": {
+			Summary:    "Delete item",
+			Tags:       []string{"items"},
+			Permission: auth.PermItemsDelete,
+			Security:   security,
+			Params:     itemID,
+		},
+		"getMyPermissions": {
+			Summary:  "Caller's roles and permissions",
+			Tags:     []string{"auth"},
+			Security: security,
+			Response: myPermissions{},
+		},
+		"getItemByID": {
+			Summary:    "Get item by numeric ID",
+			Tags:       []string{"admin"},
+			Permission: auth.PermAdmin,
+			Security:   security,
+			Response:   model.Item{},
+			Params:     map[string]string{"id": "Numeric ID of the item"},
+		},
+		"backup": {
+			Summary:      "Download backup archive",
+			Tags:         []string{"admin"},
+			Permission:   auth.PermAdmin,
+			Security:     security,
+			ResponseType: "application/gzip",
+		},
+		"restore": {
+			Summary:     "Restore from backup archive",
+			Tags:        []string{"admin"},
+			Permission:  auth.PermAdmin,
+			Security:    security,
+			RequestType: "application/gzip",
+			Response:    backup.Header{},
+			Errors:      []int{http.StatusRequestEntityTooLarge},
+		},
+	}
+}
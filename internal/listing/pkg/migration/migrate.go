@@ -0,0 +1,144 @@
+package migration
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/all-in-one/internal/common"
+	"github.com/all-in-one/internal/listing/pkg/model"
+	"github.com/all-in-one/internal/listing/pkg/repository"
+)
+
+// DefaultBatchSize is the number of items read from the source per page
+const DefaultBatchSize = 500
+
+var (
+	// ErrTargetNotEmpty is returned when the target already holds items and
+	// Resume isn't set
+	ErrTargetNotEmpty = errors.New("target storage is not empty")
+
+	// ErrVerification is returned when the target doesn't match the source
+	// after copying
+	ErrVerification = errors.New("migration verification failed")
+)
+
+// Options configures a storage migration
+type Options struct {
+	// BatchSize is the number of items read from the source per page.
+	// Zero means DefaultBatchSize.
+	BatchSize int
+
+	// Resume allows migrating into a non-empty target, e.g. after an
+	// interrupted run. Items the target already has unchanged are skipped.
+	Resume bool
+
+	// Progress, if set, is called after every batch
+	Progress func(Progress)
+}
+
+// Progress reports how far a migration has got
+type Progress struct {
+	Copied  int
+	Skipped int
+	LastID  int
+}
+
+// Result summarizes a finished migration
+type Result struct {
+	Copied      int
+	Skipped     int
+	SourceCount int
+	TargetCount int
+}
+
+// Run streams every item from one storage to another in ID order, keeping
+// IDs and timestamps, then verifies that both hold the same number of items
+func Run(from, to repository.Storage, opts Options) (Result, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+
+	src, dst := from.Items(), to.Items()
+
+	existing, err := dst.List(0, 1)
+	if err != nil {
+		return Result{}, fmt.Errorf("checking target: %w", err)
+	}
+	if len(existing) > 0 && !opts.Resume {
+		return Result{}, ErrTargetNotEmpty
+	}
+
+	var result Result
+	afterID := 0
+	for {
+		batch, err := src.List(afterID, opts.BatchSize)
+		if err != nil {
+			return result, fmt.Errorf("reading source after ID %d: %w", afterID, err)
+		}
+		if len(batch) == 0 {
+			break
+		}
+
+		for _, item := range batch {
+			result.SourceCount++
+
+			if opts.Resume {
+				current, err := dst.Get(item.ID)
+				if err == nil && sameItem(current, item) {
+					result.Skipped++
+					continue
+				}
+				if err != nil && !errors.Is(err, common.ErrNotFound) {
+					return result, fmt.Errorf("checking item %d in target: %w", item.ID, err)
+				}
+			}
+
+			if err := dst.Import(item); err != nil {
+				return result, fmt.Errorf("writing item %d: %w", item.ID, err)
+			}
+			result.Copied++
+		}
+
+		afterID = batch[len(batch)-1].ID
+		if opts.Progress != nil {
+			opts.Progress(Progress{Copied: result.Copied, Skipped: result.Skipped, LastID: afterID})
+		}
+	}
+
+	result.TargetCount, err = count(dst, opts.BatchSize)
+	if err != nil {
+		return result, fmt.Errorf("counting target: %w", err)
+	}
+	if result.TargetCount != result.SourceCount {
+		return result, fmt.Errorf("%w: source has %d items, target has %d", ErrVerification, result.SourceCount, result.TargetCount)
+	}
+
+	return result, nil
+}
+
+// count pages through a repository to count its items
+func count(items repository.ItemRepository, batchSize int) (int, error) {
+	n, afterID := 0, 0
+	for {
+		batch, err := items.List(afterID, batchSize)
+		if err != nil {
+			return 0, err
+		}
+		if len(batch) == 0 {
+			return n, nil
+		}
+		n += len(batch)
+		afterID = batch[len(batch)-1].ID
+	}
+}
+
+// sameItem reports whether two items hold the same data, comparing
+// timestamps by instant since backends differ in the location they return
+func sameItem(a, b model.Item) bool {
+	return a.ID == b.ID &&
+		a.UID == b.UID &&
+		a.Title == b.Title &&
+		a.Description == b.Description &&
+		a.CreatedAt.Equal(b.CreatedAt) &&
+		a.UpdatedAt.Equal(b.UpdatedAt)
+}
@@ -0,0 +1,173 @@
+package migration
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/all-in-one/internal/common"
+	"github.com/all-in-one/internal/listing/pkg/model"
+	"github.com/all-in-one/internal/listing/pkg/repository"
+)
+
+// newMemorySource returns memory storage holding n items with IDs 1..n
+func newMemorySource(t *testing.T, n int) repository.Storage {
+	t.Helper()
+
+	clock := common.NewManualClock(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))
+	store, err := repository.NewStorage("memory", "", repository.Options{Clock: clock})
+	if err != nil {
+		t.Fatalf("NewStorage(memory): %v", err)
+	}
+	for i := 1; i <= n; i++ {
+		clock.Advance(time.Second)
+		if _, err := store.Items().Create(model.Item{Title: fmt.Sprintf("Item %d", i), Description: "from memory"}); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+	return store
+}
+
+// newSQLiteTarget returns empty SQLite storage in a temporary directory
+func newSQLiteTarget(t *testing.T) repository.Storage {
+	t.Helper()
+
+	store, err := repository.NewStorage("sqlite", filepath.Join(t.TempDir(), "target.db"), repository.Options{})
+	if err != nil {
+		t.Fatalf("NewStorage(sqlite): %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+// assertSameItems fails unless from and to hold the same items
+func assertSameItems(t *testing.T, from, to repository.Storage) {
+	t.Helper()
+
+	want, err := from.Items().List(0, 1000)
+	if err != nil {
+		t.Fatalf("List(source): %v", err)
+	}
+	got, err := to.Items().List(0, 1000)
+	if err != nil {
+		t.Fatalf("List(target): %v", err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("target has %d items, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if !sameItem(got[i], want[i]) {
+			t.Errorf("target item %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestRunMemoryToSQLite(t *testing.T) {
+	from, to := newMemorySource(t, 25), newSQLiteTarget(t)
+
+	var batches int
+	result, err := Run(from, to, Options{BatchSize: 10, Progress: func(Progress) { batches++ }})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if result != (Result{Copied: 25, SourceCount: 25, TargetCount: 25}) {
+		t.Errorf("result = %+v", result)
+	}
+	if batches != 3 {
+		t.Errorf("Progress called %d times, want 3", batches)
+	}
+	assertSameItems(t, from, to)
+
+	// New items in the target carry on after the migrated IDs
+	created, err := to.Items().Create(model.Item{Title: "After"})
+	if err != nil || created.ID != 26 {
+		t.Errorf("Create after migrating = %+v, %v, want ID 26", created, err)
+	}
+}
+
+func TestRunRefusesNonEmptyTarget(t *testing.T) {
+	from, to := newMemorySource(t, 3), newSQLiteTarget(t)
+	if _, err := to.Items().Create(model.Item{Title: "Already here"}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	if _, err := Run(from, to, Options{}); !errors.Is(err, ErrTargetNotEmpty) {
+		t.Errorf("Run error = %v, want ErrTargetNotEmpty", err)
+	}
+}
+
+// failingImports fails every Import after the first n
+type failingImports struct {
+	repository.ItemRepository
+	n int
+}
+
+func (f *failingImports) Import(item model.Item) error {
+	if f.n == 0 {
+		return errors.New("connection lost")
+	}
+	f.n--
+	return f.ItemRepository.Import(item)
+}
+
+// interruptedStorage is a storage whose item imports start failing
+type interruptedStorage struct {
+	repository.Storage
+	items *failingImports
+}
+
+func (s *interruptedStorage) Items() repository.ItemRepository {
+	return s.items
+}
+
+func TestRunResumesAfterInterruption(t *testing.T) {
+	from, to := newMemorySource(t, 25), newSQLiteTarget(t)
+
+	interrupted := &interruptedStorage{Storage: to, items: &failingImports{ItemRepository: to.Items(), n: 12}}
+	if _, err := Run(from, interrupted, Options{BatchSize: 10}); err == nil {
+		t.Fatal("interrupted Run succeeded")
+	}
+
+	// Without Resume the half-filled target is refused
+	if _, err := Run(from, to, Options{BatchSize: 10}); !errors.Is(err, ErrTargetNotEmpty) {
+		t.Fatalf("Run without Resume error = %v, want ErrTargetNotEmpty", err)
+	}
+
+	// One of the copied items changed in the source since
+	changed, err := from.Items().Update(5, model.Item{Title: "Changed", Description: "since the first run"})
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	result, err := Run(from, to, Options{BatchSize: 10, Resume: true})
+	if err != nil {
+		t.Fatalf("resumed Run: %v", err)
+	}
+	if result.Skipped != 11 || result.Copied != 14 || result.TargetCount != 25 {
+		t.Errorf("resumed result = %+v, want 11 skipped and 14 copied", result)
+	}
+	assertSameItems(t, from, to)
+
+	if got, err := to.Items().Get(5); err != nil || got.Title != changed.Title {
+		t.Errorf("changed item in target = %+v, %v", got, err)
+	}
+}
+
+func TestRunFailsVerificationOnMismatch(t *testing.T) {
+	from, to := newMemorySource(t, 5), newSQLiteTarget(t)
+
+	// An item the source doesn't have makes the counts differ
+	if err := to.Items().Import(model.Item{ID: 99, Title: "Stray", CreatedAt: time.Now(), UpdatedAt: time.Now()}); err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+
+	result, err := Run(from, to, Options{Resume: true})
+	if !errors.Is(err, ErrVerification) {
+		t.Fatalf("Run error = %v, want ErrVerification", err)
+	}
+	if result.SourceCount != 5 || result.TargetCount != 6 {
+		t.Errorf("result = %+v, want 5 in the source and 6 in the target", result)
+	}
+}
@@ -0,0 +1,20 @@
+package model
+
+import "time"
+
+// APIKey is a credential for the HTTP API. Only a hash of the secret is
+// stored; the key itself is shown once, when it's created.
+type APIKey struct {
+	ID        int        `json:"id"`
+	Name      string     `json:"name"`
+	Prefix    string     `json:"prefix"` // public part of the key, used to look it up
+	Hash      string     `json:"-"`      // hex SHA-256 of the whole key
+	Scopes    []string   `json:"scopes"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// Revoked reports whether the key has been revoked
+func (k APIKey) Revoked() bool {
+	return k.RevokedAt != nil
+}
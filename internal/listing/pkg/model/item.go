@@ -4,9 +4,40 @@ import "time"
 
 // Item represents a listing item
 type Item struct {
-	ID          int       `json:"id"`
+	ID          int       `json:"id" openapi:"readonly"`
+	UID         string    `json:"uid" openapi:"readonly"` // assigned by the storage's IDGenerator
 	Title       string    `json:"title"`
 	Description string    `json:"description"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	CreatedAt   time.Time `json:"created_at" openapi:"readonly"`
+	UpdatedAt   time.Time `json:"updated_at" openapi:"readonly"`
+}
+
+// PublicItem is an item as the API shows it. The numeric ID is left out:
+// it's sequential, so it would tell callers how many items there are.
+type PublicItem struct {
+	UID         string    `json:"uid" openapi:"readonly"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at" openapi:"readonly"`
+	UpdatedAt   time.Time `json:"updated_at" openapi:"readonly"`
+}
+
+// Public returns the item without its numeric ID
+func (i Item) Public() PublicItem {
+	return PublicItem{
+		UID:         i.UID,
+		Title:       i.Title,
+		Description: i.Description,
+		CreatedAt:   i.CreatedAt,
+		UpdatedAt:   i.UpdatedAt,
+	}
+}
+
+// PublicItems returns items without their numeric IDs
+func PublicItems(items []Item) []PublicItem {
+	public := make([]PublicItem, len(items))
+	for i, item := range items {
+		public[i] = item.Public()
+	}
+	return public
 }
@@ -0,0 +1,38 @@
+package model
+
+import "github.com/all-in-one/internal/validation"
+
+// Item field limits
+const (
+	MaxTitleLength       = 200
+	MaxDescriptionLength = 5000
+)
+
+// ItemSchema holds the rules for items sent by clients, applied on create,
+// update and patch. Values are trimmed before they're checked and stored.
+var ItemSchema = validation.Schema[Item]{
+	Fields: []validation.Field[Item]{
+		{
+			Name:  "title",
+			Label: "Title",
+			Value: func(item *Item) *string { return &item.Title },
+			Rules: []validation.Rule{
+				validation.Trim(),
+				validation.Required(),
+				validation.MaxLength(MaxTitleLength),
+				validation.NoControlChars(false),
+			},
+		},
+		{
+			Name:  "description",
+			Label: "Description",
+			Value: func(item *Item) *string { return &item.Description },
+			Rules: []validation.Rule{
+				validation.Trim(),
+				validation.Required(),
+				validation.MaxLength(MaxDescriptionLength),
+				validation.NoControlChars(true),
+			},
+		},
+	},
+}
@@ -0,0 +1,31 @@
+package repository
+
+import (
+	"github.com/all-in-one/internal/auth"
+)
+
+// AuthKeys returns an auth.KeyStore that looks up API keys in storage
+func AuthKeys(storage Storage) auth.KeyStore {
+	return authKeys{storage: storage}
+}
+
+// authKeys implements auth.KeyStore with the API keys of a storage
+type authKeys struct {
+	storage Storage
+}
+
+// LookupAPIKey returns the API key with the given prefix
+func (k authKeys) LookupAPIKey(prefix string) (auth.StoredKey, error) {
+	key, err := k.storage.APIKeys().GetByPrefix(prefix)
+	if err != nil {
+		return auth.StoredKey{}, err
+	}
+
+	return auth.StoredKey{
+		ID:      key.ID,
+		Name:    key.Name,
+		Hash:    key.Hash,
+		Scopes:  key.Scopes,
+		Revoked: key.Revoked(),
+	}, nil
+}
@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/all-in-one/internal/auth"
+	"github.com/all-in-one/internal/common"
+	"github.com/all-in-one/internal/listing/pkg/model"
+)
+
+func TestAuthKeys(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store Storage) {
+		token, prefix, hash, err := auth.NewAPIKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		created, err := store.APIKeys().Create(model.APIKey{Name: "ci", Prefix: prefix, Hash: hash, Scopes: []string{auth.RoleRead}})
+		if err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+
+		authenticator := auth.APIKeyAuthenticator{Keys: AuthKeys(store)}
+		principal, err := authenticator.Authenticate(token)
+		if err != nil {
+			t.Fatalf("Authenticate: %v", err)
+		}
+		if principal.Name != "ci" || len(principal.Roles) != 1 || principal.Roles[0] != auth.RoleRead {
+			t.Errorf("principal = %+v, want key ci with role %s", principal, auth.RoleRead)
+		}
+
+		if _, err := AuthKeys(store).LookupAPIKey("unknown"); !errors.Is(err, common.ErrNotFound) {
+			t.Errorf("LookupAPIKey of an unknown prefix: error = %v, want ErrNotFound", err)
+		}
+
+		if err := store.APIKeys().Revoke(created.ID); err != nil {
+			t.Fatalf("Revoke: %v", err)
+		}
+		if _, err := authenticator.Authenticate(token); !errors.Is(err, auth.ErrInvalidToken) {
+			t.Errorf("Authenticate with a revoked key: error = %v, want ErrInvalidToken", err)
+		}
+	})
+}
@@ -0,0 +1,178 @@
+package bolt
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/all-in-one/internal/common"
+	"github.com/all-in-one/internal/listing/pkg/model"
+	"go.etcd.io/bbolt"
+)
+
+// apiKeyRecord is how an API key is stored. model.APIKey leaves the hash
+// out of its JSON so it never reaches API responses; the record puts it
+// back.
+type apiKeyRecord struct {
+	model.APIKey
+	Hash string `json:"hash"`
+}
+
+// apiKeyRepository implements the API key repository with bbolt. Keys are
+// stored as JSON keyed by big-endian ID, with a prefix → ID index bucket.
+type apiKeyRepository struct {
+	db    *bbolt.DB
+	tx    *bbolt.Tx
+	clock common.Clock
+}
+
+// newAPIKeyRepository creates a new bolt-based API key repository
+func newAPIKeyRepository(db *bbolt.DB, clock common.Clock) *apiKeyRepository {
+	return &apiKeyRepository{db: db, clock: clock}
+}
+
+// withTx returns a copy of the repository bound to an open read-write
+// transaction
+func (r *apiKeyRepository) withTx(tx *bbolt.Tx) *apiKeyRepository {
+	return &apiKeyRepository{db: r.db, tx: tx, clock: r.clock}
+}
+
+// Create stores a new API key
+func (r *apiKeyRepository) Create(key model.APIKey) (model.APIKey, error) {
+	key.CreatedAt = r.clock.Now()
+	key.RevokedAt = nil
+
+	err := r.update(func(tx *bbolt.Tx) error {
+		prefixes := tx.Bucket(apiKeysByPrefixBucket)
+		if existing := prefixes.Get([]byte(key.Prefix)); existing != nil {
+			return fmt.Errorf("API key prefix %q is already used", key.Prefix)
+		}
+
+		keys := tx.Bucket(apiKeysBucket)
+		seq, err := keys.NextSequence()
+		if err != nil {
+			return err
+		}
+		key.ID = int(seq)
+
+		if err := putAPIKey(tx, key); err != nil {
+			return err
+		}
+		return prefixes.Put([]byte(key.Prefix), itob(key.ID))
+	})
+	if err != nil {
+		return model.APIKey{}, err
+	}
+
+	return key, nil
+}
+
+// GetByPrefix returns the API key with the given prefix
+func (r *apiKeyRepository) GetByPrefix(prefix string) (model.APIKey, error) {
+	var key model.APIKey
+
+	err := r.view(func(tx *bbolt.Tx) error {
+		id := tx.Bucket(apiKeysByPrefixBucket).Get([]byte(prefix))
+		if id == nil {
+			return common.ErrNotFound
+		}
+
+		var err error
+		key, err = getAPIKey(tx, id)
+		return err
+	})
+	if err != nil {
+		return model.APIKey{}, err
+	}
+
+	return key, nil
+}
+
+// List returns all API keys, ordered by ID
+func (r *apiKeyRepository) List() ([]model.APIKey, error) {
+	keys := []model.APIKey{}
+
+	err := r.view(func(tx *bbolt.Tx) error {
+		return tx.Bucket(apiKeysBucket).ForEach(func(_, v []byte) error {
+			key, err := decodeAPIKey(v)
+			if err != nil {
+				return err
+			}
+			keys = append(keys, key)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// Revoke marks an API key as revoked
+func (r *apiKeyRepository) Revoke(id int) error {
+	return r.update(func(tx *bbolt.Tx) error {
+		key, err := getAPIKey(tx, itob(id))
+		if err != nil {
+			return err
+		}
+
+		if key.RevokedAt != nil {
+			return nil
+		}
+		now := r.clock.Now()
+		key.RevokedAt = &now
+		return putAPIKey(tx, key)
+	})
+}
+
+// view runs fn in the bound transaction or a new read-only one
+func (r *apiKeyRepository) view(fn func(tx *bbolt.Tx) error) error {
+	if r.tx != nil {
+		return fn(r.tx)
+	}
+	return r.db.View(fn)
+}
+
+// update runs fn in the bound transaction or a new read-write one
+func (r *apiKeyRepository) update(fn func(tx *bbolt.Tx) error) error {
+	if r.tx != nil {
+		return fn(r.tx)
+	}
+	return r.db.Update(fn)
+}
+
+// getAPIKey loads an API key by its encoded ID within a transaction
+func getAPIKey(tx *bbolt.Tx, id []byte) (model.APIKey, error) {
+	v := tx.Bucket(apiKeysBucket).Get(id)
+	if v == nil {
+		return model.APIKey{}, common.ErrNotFound
+	}
+
+	return decodeAPIKey(v)
+}
+
+// putAPIKey stores an API key within a transaction
+func putAPIKey(tx *bbolt.Tx, key model.APIKey) error {
+	data, err := json.Marshal(apiKeyRecord{APIKey: key, Hash: key.Hash})
+	if err != nil {
+		return err
+	}
+
+	return tx.Bucket(apiKeysBucket).Put(itob(key.ID), data)
+}
+
+// decodeAPIKey decodes a stored API key
+func decodeAPIKey(data []byte) (model.APIKey, error) {
+	var record apiKeyRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return model.APIKey{}, err
+	}
+
+	key := record.APIKey
+	key.Hash = record.Hash
+	if key.Scopes == nil {
+		key.Scopes = []string{}
+	}
+
+	return key, nil
+}
@@ -0,0 +1,44 @@
+package bolt_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/all-in-one/internal/listing/pkg/repository"
+	"github.com/all-in-one/internal/listing/pkg/repository/bolt"
+	"github.com/all-in-one/internal/listing/pkg/repository/repotest"
+)
+
+func TestConformance(t *testing.T) {
+	repotest.RunItemRepository(t, func(t *testing.T, deps repotest.Deps) repository.ItemRepository {
+		store, err := bolt.NewStorage(filepath.Join(t.TempDir(), "test.bolt"), bolt.Options{Clock: deps.Clock, IDs: deps.IDs})
+		if err != nil {
+			t.Fatalf("NewStorage: %v", err)
+		}
+		t.Cleanup(func() { store.Close() })
+
+		return store.Items()
+	})
+}
+
+func TestAPIKeyConformance(t *testing.T) {
+	repotest.RunAPIKeyRepository(t, func(t *testing.T, deps repotest.Deps) repository.APIKeyRepository {
+		store, err := bolt.NewStorage(filepath.Join(t.TempDir(), "test.bolt"), bolt.Options{Clock: deps.Clock, IDs: deps.IDs})
+		if err != nil {
+			t.Fatalf("NewStorage: %v", err)
+		}
+		t.Cleanup(func() { store.Close() })
+
+		return store.APIKeys()
+	})
+}
+
+func TestPersistence(t *testing.T) {
+	repotest.RunPersistence(t, func(t *testing.T, path string, deps repotest.Deps) repository.Storage {
+		store, err := repository.NewStorage("bolt", path, repository.Options{Clock: deps.Clock, IDs: deps.IDs})
+		if err != nil {
+			t.Fatalf("NewStorage: %v", err)
+		}
+		return store
+	})
+}
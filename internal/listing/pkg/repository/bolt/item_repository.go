@@ -0,0 +1,408 @@
+package bolt
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/all-in-one/internal/common"
+	"github.com/all-in-one/internal/listing/pkg/model"
+	bbolt "go.etcd.io/bbolt"
+)
+
+// itemRepository implements the item repository with bolt storage.
+//
+// Items are stored as JSON in the items bucket keyed by their big-endian ID,
+// so iterating the bucket yields items in ID order. The created_at and
+// updated_at index buckets are keyed by timestamp followed by the item ID,
+// which keeps them sorted chronologically for ListByCreatedAt and
+// ListByUpdatedAt. The UID index bucket maps each UID to the item's
+// big-endian ID.
+//
+// A repository bound to a transaction (tx != nil) runs every operation in
+// that transaction instead of opening its own.
+type itemRepository struct {
+	db    *bbolt.DB
+	tx    *bbolt.Tx
+	clock common.Clock
+	ids   common.IDGenerator
+}
+
+// newItemRepository creates a new bolt-based item repository
+func newItemRepository(db *bbolt.DB, clock common.Clock, ids common.IDGenerator) *itemRepository {
+	return &itemRepository{db: db, clock: clock, ids: ids}
+}
+
+// withTx returns a copy of the repository bound to an open read-write
+// transaction
+func (r *itemRepository) withTx(tx *bbolt.Tx) *itemRepository {
+	return &itemRepository{db: r.db, tx: tx, clock: r.clock, ids: r.ids}
+}
+
+// GetAll returns all items
+func (r *itemRepository) GetAll() ([]model.Item, error) {
+	items := []model.Item{}
+
+	err := r.view(func(tx *bbolt.Tx) error {
+		return tx.Bucket(itemsBucket).ForEach(func(_, v []byte) error {
+			var item model.Item
+			if err := json.Unmarshal(v, &item); err != nil {
+				return err
+			}
+			items = append(items, item)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// Get returns an item by ID
+func (r *itemRepository) Get(id int) (model.Item, error) {
+	var item model.Item
+
+	err := r.view(func(tx *bbolt.Tx) error {
+		var err error
+		item, err = getItem(tx, id)
+		return err
+	})
+	if err != nil {
+		return model.Item{}, err
+	}
+
+	return item, nil
+}
+
+// GetByUID returns an item by its UID
+func (r *itemRepository) GetByUID(uid string) (model.Item, error) {
+	var item model.Item
+
+	err := r.view(func(tx *bbolt.Tx) error {
+		id := tx.Bucket(itemsByUIDBucket).Get([]byte(uid))
+		if id == nil {
+			return common.ErrNotFound
+		}
+
+		var err error
+		item, err = getItem(tx, int(binary.BigEndian.Uint64(id)))
+		return err
+	})
+	if err != nil {
+		return model.Item{}, err
+	}
+
+	return item, nil
+}
+
+// List returns up to limit items with IDs greater than afterID, ordered by ID
+func (r *itemRepository) List(afterID, limit int) ([]model.Item, error) {
+	items := []model.Item{}
+
+	err := r.view(func(tx *bbolt.Tx) error {
+		c := tx.Bucket(itemsBucket).Cursor()
+		for k, v := c.Seek(itob(afterID + 1)); k != nil && len(items) < limit; k, v = c.Next() {
+			var item model.Item
+			if err := json.Unmarshal(v, &item); err != nil {
+				return err
+			}
+			items = append(items, item)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// TimeOrderedItems lists items in timestamp order. The item repository of a
+// bolt storage implements it with its index buckets; decorators don't pass
+// it on.
+type TimeOrderedItems interface {
+	// ListByCreatedAt returns up to limit items created at or after since,
+	// oldest first
+	ListByCreatedAt(since time.Time, limit int) ([]model.Item, error)
+
+	// ListByUpdatedAt returns up to limit items last updated at or after
+	// since, least recently updated first
+	ListByUpdatedAt(since time.Time, limit int) ([]model.Item, error)
+}
+
+// ListByCreatedAt returns up to limit items created at or after since
+func (r *itemRepository) ListByCreatedAt(since time.Time, limit int) ([]model.Item, error) {
+	return r.listByTime(itemsByCreatedBucket, since, limit)
+}
+
+// ListByUpdatedAt returns up to limit items updated at or after since
+func (r *itemRepository) ListByUpdatedAt(since time.Time, limit int) ([]model.Item, error) {
+	return r.listByTime(itemsByUpdatedBucket, since, limit)
+}
+
+// listByTime scans a timestamp index bucket from since, loading up to limit
+// items
+func (r *itemRepository) listByTime(index []byte, since time.Time, limit int) ([]model.Item, error) {
+	items := []model.Item{}
+
+	err := r.view(func(tx *bbolt.Tx) error {
+		c := tx.Bucket(index).Cursor()
+		for k, _ := c.Seek(indexKey(since, 0)); k != nil && len(items) < limit; k, _ = c.Next() {
+			item, err := getItem(tx, int(binary.BigEndian.Uint64(k[8:])))
+			if err != nil {
+				return err
+			}
+			items = append(items, item)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// Count returns the number of items
+func (r *itemRepository) Count() (int, error) {
+	var count int
+	err := r.view(func(tx *bbolt.Tx) error {
+		count = tx.Bucket(itemsBucket).Stats().KeyN
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// Create adds a new item
+func (r *itemRepository) Create(item model.Item) (model.Item, error) {
+	err := r.update(func(tx *bbolt.Tx) error {
+		id, err := tx.Bucket(itemsBucket).NextSequence()
+		if err != nil {
+			return err
+		}
+
+		// Assign IDs and timestamps
+		now := r.clock.Now()
+		item.ID = int(id)
+		item.UID = r.ids.NewID()
+		item.CreatedAt = now
+		item.UpdatedAt = now
+
+		return putItem(tx, item)
+	})
+	if err != nil {
+		return model.Item{}, err
+	}
+
+	return item, nil
+}
+
+// Update modifies an existing item
+func (r *itemRepository) Update(id int, item model.Item) (model.Item, error) {
+	err := r.update(func(tx *bbolt.Tx) error {
+		existingItem, err := getItem(tx, id)
+		if err != nil {
+			return err
+		}
+
+		// Update item while preserving IDs and CreatedAt
+		item.ID = id
+		item.UID = existingItem.UID
+		item.CreatedAt = existingItem.CreatedAt
+		item.UpdatedAt = r.clock.Now()
+
+		if err := deleteIndexes(tx, existingItem); err != nil {
+			return err
+		}
+		return putItem(tx, item)
+	})
+	if err != nil {
+		return model.Item{}, err
+	}
+
+	return item, nil
+}
+
+// Delete removes an item
+func (r *itemRepository) Delete(id int) error {
+	return r.update(func(tx *bbolt.Tx) error {
+		existingItem, err := getItem(tx, id)
+		if err != nil {
+			return err
+		}
+
+		if err := deleteIndexes(tx, existingItem); err != nil {
+			return err
+		}
+		return tx.Bucket(itemsBucket).Delete(itob(id))
+	})
+}
+
+// Import stores an item as-is, keeping its IDs and timestamps
+func (r *itemRepository) Import(item model.Item) error {
+	if item.UID == "" {
+		item.UID = r.ids.NewID()
+	} else {
+		common.SeedIDs(r.ids, item.UID)
+	}
+
+	return r.update(func(tx *bbolt.Tx) error {
+		existingItem, err := getItem(tx, item.ID)
+		switch err {
+		case nil:
+			if err := deleteIndexes(tx, existingItem); err != nil {
+				return err
+			}
+		case common.ErrNotFound:
+			// Nothing to replace
+		default:
+			return err
+		}
+
+		if err := putItem(tx, item); err != nil {
+			return err
+		}
+
+		// Keep the sequence ahead of imported IDs so Create never reuses them
+		bucket := tx.Bucket(itemsBucket)
+		if uint64(item.ID) > bucket.Sequence() {
+			return bucket.SetSequence(uint64(item.ID))
+		}
+		return nil
+	})
+}
+
+// InitializeSampleData adds sample data to the storage
+func (r *itemRepository) InitializeSampleData() int {
+	// Check if there's already data
+	var count int
+	err := r.view(func(tx *bbolt.Tx) error {
+		count = tx.Bucket(itemsBucket).Stats().KeyN
+		return nil
+	})
+	if err != nil || count > 0 {
+		return 0 // Don't add sample data if there's an error or if data exists
+	}
+
+	sampleItems := []model.Item{
+		{
+			Title:       "Sample Task 1",
+			Description: "This is a sample task for testing",
+		},
+		{
+			Title:       "Sample Task 2",
+			Description: "Another sample task with different content",
+		},
+		{
+			Title:       "Sample Task 3",
+			Description: "Third sample task for demonstration",
+		},
+	}
+
+	for _, item := range sampleItems {
+		_, err := r.Create(item)
+		if err != nil {
+			return 0
+		}
+	}
+
+	return len(sampleItems)
+}
+
+// Helper Functions
+
+// view runs fn in the bound transaction or a new read-only one
+func (r *itemRepository) view(fn func(tx *bbolt.Tx) error) error {
+	if r.tx != nil {
+		return fn(r.tx)
+	}
+	return r.db.View(fn)
+}
+
+// update runs fn in the bound transaction or a new read-write one
+func (r *itemRepository) update(fn func(tx *bbolt.Tx) error) error {
+	if r.tx != nil {
+		return fn(r.tx)
+	}
+	return r.db.Update(fn)
+}
+
+// getItem loads an item by ID within a transaction
+func getItem(tx *bbolt.Tx, id int) (model.Item, error) {
+	v := tx.Bucket(itemsBucket).Get(itob(id))
+	if v == nil {
+		return model.Item{}, common.ErrNotFound
+	}
+
+	var item model.Item
+	if err := json.Unmarshal(v, &item); err != nil {
+		return model.Item{}, err
+	}
+
+	return item, nil
+}
+
+// putItem stores an item and its index entries within a transaction
+func putItem(tx *bbolt.Tx, item model.Item) error {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Bucket(itemsBucket).Put(itob(item.ID), data); err != nil {
+		return err
+	}
+	if err := tx.Bucket(itemsByCreatedBucket).Put(indexKey(item.CreatedAt, item.ID), nil); err != nil {
+		return err
+	}
+	if err := tx.Bucket(itemsByUpdatedBucket).Put(indexKey(item.UpdatedAt, item.ID), nil); err != nil {
+		return err
+	}
+	if item.UID == "" {
+		return nil
+	}
+
+	// UIDs are unique, like the SQLite backend's uid index enforces
+	uids := tx.Bucket(itemsByUIDBucket)
+	if owner := uids.Get([]byte(item.UID)); owner != nil && int(binary.BigEndian.Uint64(owner)) != item.ID {
+		return fmt.Errorf("uid %q is already used by item %d", item.UID, binary.BigEndian.Uint64(owner))
+	}
+	return uids.Put([]byte(item.UID), itob(item.ID))
+}
+
+// deleteIndexes removes the index entries of an item within a transaction
+func deleteIndexes(tx *bbolt.Tx, item model.Item) error {
+	if err := tx.Bucket(itemsByCreatedBucket).Delete(indexKey(item.CreatedAt, item.ID)); err != nil {
+		return err
+	}
+	if err := tx.Bucket(itemsByUpdatedBucket).Delete(indexKey(item.UpdatedAt, item.ID)); err != nil {
+		return err
+	}
+	if item.UID == "" {
+		return nil
+	}
+	return tx.Bucket(itemsByUIDBucket).Delete([]byte(item.UID))
+}
+
+// itob encodes an item ID as a sortable 8-byte big-endian key
+func itob(id int) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(id))
+	return b
+}
+
+// indexKey builds a timestamp index key sorted by time, then by ID. The
+// sign bit is flipped so times before 1970 sort first.
+func indexKey(t time.Time, id int) []byte {
+	b := make([]byte, 16)
+	binary.BigEndian.PutUint64(b[:8], uint64(t.UnixNano())^(1<<63))
+	binary.BigEndian.PutUint64(b[8:], uint64(id))
+	return b
+}
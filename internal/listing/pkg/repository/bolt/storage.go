@@ -0,0 +1,325 @@
+package bolt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/all-in-one/internal/common"
+	"github.com/all-in-one/internal/listing/pkg/model"
+	bbolt "go.etcd.io/bbolt"
+)
+
+// Bucket names used by the bolt storage
+var (
+	itemsBucket          = []byte("listing_items")
+	itemsByCreatedBucket = []byte("listing_items_by_created_at")
+	itemsByUpdatedBucket = []byte("listing_items_by_updated_at")
+	itemsByUIDBucket     = []byte("listing_items_by_uid")
+
+	apiKeysBucket         = []byte("api_keys")
+	apiKeysByPrefixBucket = []byte("api_keys_by_prefix")
+)
+
+// ItemRepository defines the interface for item storage operations (local copy to avoid import cycle)
+type ItemRepository interface {
+	GetAll() ([]model.Item, error)
+	Get(id int) (model.Item, error)
+	GetByUID(uid string) (model.Item, error)
+	List(afterID, limit int) ([]model.Item, error)
+	Count() (int, error)
+	Create(item model.Item) (model.Item, error)
+	Update(id int, item model.Item) (model.Item, error)
+	Delete(id int) error
+	Import(item model.Item) error
+	InitializeSampleData() int
+}
+
+// APIKeyRepository defines the interface for API key storage operations (local copy to avoid import cycle)
+type APIKeyRepository interface {
+	Create(key model.APIKey) (model.APIKey, error)
+	GetByPrefix(prefix string) (model.APIKey, error)
+	List() ([]model.APIKey, error)
+	Revoke(id int) error
+}
+
+// Storage defines the main storage interface (local copy to avoid import cycle)
+type Storage interface {
+	Items() ItemRepository
+	APIKeys() APIKeyRepository
+	Snapshot() ([]model.Item, error)
+	Restore(items []model.Item) error
+	WithTx(ctx context.Context, fn func(tx Storage) error) error
+	Close() error
+}
+
+// errCloseInTx is returned when Close is called on a transaction's storage
+var errCloseInTx = errors.New("bolt: Close called on a transaction; return from WithTx instead")
+
+// Options configures the bolt storage
+type Options struct {
+	// Clock supplies timestamps. Nil means the system clock.
+	Clock common.Clock
+
+	// IDs generates the UID of new items. Nil means ULIDs.
+	IDs common.IDGenerator
+}
+
+// storage implements Storage with a bbolt database file
+type storage struct {
+	db         *bbolt.DB
+	itemRepo   *itemRepository
+	apiKeyRepo *apiKeyRepository
+}
+
+// NewStorage creates a new bolt-based storage
+func NewStorage(dbPath string, opts Options) (Storage, error) {
+	if opts.Clock == nil {
+		opts.Clock = common.SystemClock{}
+	}
+	if opts.IDs == nil {
+		opts.IDs = common.NewULIDs(opts.Clock)
+	}
+
+	// bbolt holds an exclusive file lock, so don't wait forever if another
+	// process already has the database open
+	db, err := bbolt.Open(dbPath, 0600, &bbolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	// Create buckets if they don't exist
+	err = db.Update(func(tx *bbolt.Tx) error {
+		indexUIDs := tx.Bucket(itemsByUIDBucket) == nil
+		indexTimes := tx.Bucket(itemsByCreatedBucket) == nil || tx.Bucket(itemsByUpdatedBucket) == nil
+
+		for _, name := range [][]byte{itemsBucket, itemsByCreatedBucket, itemsByUpdatedBucket, itemsByUIDBucket, apiKeysBucket, apiKeysByPrefixBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+
+		// Files written before the UID index existed need it built, and
+		// items from before UIDs existed need one
+		if indexUIDs {
+			if err := backfillUIDs(tx); err != nil {
+				return err
+			}
+		}
+
+		// Some versions didn't keep the timestamp indexes
+		if indexTimes {
+			if err := rebuildTimeIndexes(tx); err != nil {
+				return err
+			}
+		}
+
+		// A sequential generator carries on from the stored UIDs
+		if _, ok := opts.IDs.(common.IDSeeder); ok {
+			return tx.Bucket(itemsByUIDBucket).ForEach(func(uid, _ []byte) error {
+				common.SeedIDs(opts.IDs, string(uid))
+				return nil
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &storage{
+		db:         db,
+		itemRepo:   newItemRepository(db, opts.Clock, opts.IDs),
+		apiKeyRepo: newAPIKeyRepository(db, opts.Clock),
+	}, nil
+}
+
+// Items returns the item repository
+func (s *storage) Items() ItemRepository {
+	return s.itemRepo
+}
+
+// APIKeys returns the API key repository
+func (s *storage) APIKeys() APIKeyRepository {
+	return s.apiKeyRepo
+}
+
+// Snapshot returns all items as of a single read transaction
+func (s *storage) Snapshot() ([]model.Item, error) {
+	return s.itemRepo.GetAll()
+}
+
+// Restore replaces all items and rebuilds the index buckets in one
+// transaction
+func (s *storage) Restore(items []model.Item) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		return restoreItems(tx, items, s.itemRepo.ids)
+	})
+}
+
+// WithTx runs fn in a single read-write transaction, committing if it
+// returns nil and rolling back otherwise. bbolt allows one writer at a time,
+// so concurrent transactions are serialized.
+func (s *storage) WithTx(ctx context.Context, fn func(tx Storage) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		if err := fn(newTxStorage(tx, s.itemRepo, s.apiKeyRepo)); err != nil {
+			return err
+		}
+		// Don't commit work the caller has given up on
+		return ctx.Err()
+	})
+}
+
+// Close closes the database file
+func (s *storage) Close() error {
+	return s.db.Close()
+}
+
+// txStorage implements Storage on top of an open read-write transaction
+type txStorage struct {
+	tx         *bbolt.Tx
+	itemRepo   *itemRepository
+	apiKeyRepo *apiKeyRepository
+}
+
+// newTxStorage creates a storage whose repositories are copies of items and
+// keys running in tx
+func newTxStorage(tx *bbolt.Tx, items *itemRepository, keys *apiKeyRepository) *txStorage {
+	return &txStorage{
+		tx:         tx,
+		itemRepo:   items.withTx(tx),
+		apiKeyRepo: keys.withTx(tx),
+	}
+}
+
+// Items returns the item repository bound to the transaction
+func (s *txStorage) Items() ItemRepository {
+	return s.itemRepo
+}
+
+// APIKeys returns the API key repository bound to the transaction
+func (s *txStorage) APIKeys() APIKeyRepository {
+	return s.apiKeyRepo
+}
+
+// Snapshot returns all items as seen by the transaction
+func (s *txStorage) Snapshot() ([]model.Item, error) {
+	return s.itemRepo.GetAll()
+}
+
+// Restore replaces all items within the transaction
+func (s *txStorage) Restore(items []model.Item) error {
+	return restoreItems(s.tx, items, s.itemRepo.ids)
+}
+
+// WithTx joins the enclosing transaction; bbolt has no nested transactions
+func (s *txStorage) WithTx(ctx context.Context, fn func(tx Storage) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return fn(s)
+}
+
+// Close is not allowed on a transaction
+func (s *txStorage) Close() error {
+	return errCloseInTx
+}
+
+// restoreItems replaces all items and rebuilds the index buckets within a
+// transaction, giving items without a UID one from ids
+func restoreItems(tx *bbolt.Tx, items []model.Item, ids common.IDGenerator) error {
+	seq := tx.Bucket(itemsBucket).Sequence()
+
+	for _, name := range [][]byte{itemsBucket, itemsByCreatedBucket, itemsByUpdatedBucket, itemsByUIDBucket} {
+		if err := tx.DeleteBucket(name); err != nil {
+			return err
+		}
+		if _, err := tx.CreateBucket(name); err != nil {
+			return err
+		}
+	}
+
+	for _, item := range items {
+		common.SeedIDs(ids, item.UID)
+	}
+	for _, item := range items {
+		if item.UID == "" {
+			item.UID = ids.NewID()
+		}
+		if err := putItem(tx, item); err != nil {
+			return err
+		}
+		if uint64(item.ID) > seq {
+			seq = uint64(item.ID)
+		}
+	}
+
+	// Keep the sequence at or above the highest restored ID so new items
+	// never collide with them
+	return tx.Bucket(itemsBucket).SetSequence(seq)
+}
+
+// rebuildTimeIndexes empties the created_at and updated_at index buckets
+// and indexes every item in them within a transaction
+func rebuildTimeIndexes(tx *bbolt.Tx) error {
+	for _, name := range [][]byte{itemsByCreatedBucket, itemsByUpdatedBucket} {
+		if err := tx.DeleteBucket(name); err != nil {
+			return err
+		}
+		if _, err := tx.CreateBucket(name); err != nil {
+			return err
+		}
+	}
+
+	return tx.Bucket(itemsBucket).ForEach(func(_, v []byte) error {
+		var item model.Item
+		if err := json.Unmarshal(v, &item); err != nil {
+			return err
+		}
+		if err := tx.Bucket(itemsByCreatedBucket).Put(indexKey(item.CreatedAt, item.ID), nil); err != nil {
+			return err
+		}
+		return tx.Bucket(itemsByUpdatedBucket).Put(indexKey(item.UpdatedAt, item.ID), nil)
+	})
+}
+
+// backfillUIDs indexes every item by UID within a transaction. Items without
+// a UID get a ULID whose time part is their creation time.
+func backfillUIDs(tx *bbolt.Tx) error {
+	clock := common.NewManualClock(time.Time{})
+	ulids := common.NewULIDs(clock)
+
+	var missing []model.Item
+	err := tx.Bucket(itemsBucket).ForEach(func(_, v []byte) error {
+		var item model.Item
+		if err := json.Unmarshal(v, &item); err != nil {
+			return err
+		}
+
+		if item.UID == "" {
+			missing = append(missing, item)
+			return nil
+		}
+		return tx.Bucket(itemsByUIDBucket).Put([]byte(item.UID), itob(item.ID))
+	})
+	if err != nil {
+		return err
+	}
+
+	// Rewrite outside ForEach, which doesn't allow modifying its bucket
+	for _, item := range missing {
+		clock.Set(item.CreatedAt)
+		item.UID = ulids.NewID()
+		if err := putItem(tx, item); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
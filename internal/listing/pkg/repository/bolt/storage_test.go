@@ -0,0 +1,243 @@
+package bolt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/all-in-one/internal/common"
+	"github.com/all-in-one/internal/listing/pkg/model"
+	bbolt "go.etcd.io/bbolt"
+)
+
+// openTestStorage opens a bolt file, closing it when the test ends
+func openTestStorage(t *testing.T, dbPath string) Storage {
+	t.Helper()
+
+	store, err := NewStorage(dbPath, Options{})
+	if err != nil {
+		t.Fatalf("NewStorage: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	return store
+}
+
+// writeRawFile creates a bolt file holding the given buckets, with items
+// stored as-is in the items bucket, as an older version would have left it
+func writeRawFile(t *testing.T, dbPath string, buckets [][]byte, items ...model.Item) {
+	t.Helper()
+
+	db, err := bbolt.Open(dbPath, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		for _, name := range buckets {
+			if _, err := tx.CreateBucket(name); err != nil {
+				return err
+			}
+		}
+		for _, item := range items {
+			data, err := json.Marshal(item)
+			if err != nil {
+				return err
+			}
+			if err := tx.Bucket(itemsBucket).Put(itob(item.ID), data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// itemTitles returns the titles of items, in order
+func itemTitles(items []model.Item) []string {
+	titles := make([]string, len(items))
+	for i, item := range items {
+		titles[i] = item.Title
+	}
+	return titles
+}
+
+func TestOpenBackfillsTimeIndexes(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "old.bolt")
+	day := time.Date(2023, 5, 6, 0, 0, 0, 0, time.UTC)
+	writeRawFile(t, dbPath, [][]byte{itemsBucket},
+		model.Item{ID: 1, UID: "a", Title: "Newest", CreatedAt: day.Add(3 * time.Hour), UpdatedAt: day.Add(3 * time.Hour)},
+		model.Item{ID: 2, UID: "b", Title: "Oldest", CreatedAt: day.Add(time.Hour), UpdatedAt: day.Add(4 * time.Hour)},
+		model.Item{ID: 3, UID: "c", Title: "Middle", CreatedAt: day.Add(2 * time.Hour), UpdatedAt: day.Add(2 * time.Hour)},
+	)
+
+	items := openTestStorage(t, dbPath).Items().(TimeOrderedItems)
+
+	created, err := items.ListByCreatedAt(time.Time{}, 10)
+	if err != nil {
+		t.Fatalf("ListByCreatedAt: %v", err)
+	}
+	if got, want := itemTitles(created), []string{"Oldest", "Middle", "Newest"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ListByCreatedAt = %v, want %v", got, want)
+	}
+
+	updated, err := items.ListByUpdatedAt(time.Time{}, 10)
+	if err != nil {
+		t.Fatalf("ListByUpdatedAt: %v", err)
+	}
+	if got, want := itemTitles(updated), []string{"Middle", "Newest", "Oldest"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ListByUpdatedAt = %v, want %v", got, want)
+	}
+}
+
+func TestListByTime(t *testing.T) {
+	clock := common.NewManualClock(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))
+	store, err := NewStorage(filepath.Join(t.TempDir(), "test.bolt"), Options{Clock: clock})
+	if err != nil {
+		t.Fatalf("NewStorage: %v", err)
+	}
+	defer store.Close()
+	items := store.Items().(TimeOrderedItems)
+
+	var created []model.Item
+	for _, title := range []string{"First", "Second", "Third"} {
+		item, err := store.Items().Create(model.Item{Title: title})
+		if err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		created = append(created, item)
+		clock.Advance(time.Minute)
+	}
+
+	// Updating moves an item to the end of the updated_at order only
+	if _, err := store.Items().Update(created[0].ID, model.Item{Title: "First, edited"}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if err := store.Items().Delete(created[1].ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		list  func(time.Time, int) ([]model.Item, error)
+		since time.Time
+		limit int
+		want  []string
+	}{
+		{"created", items.ListByCreatedAt, time.Time{}, 10, []string{"First, edited", "Third"}},
+		{"created since", items.ListByCreatedAt, created[1].CreatedAt, 10, []string{"Third"}},
+		{"updated", items.ListByUpdatedAt, time.Time{}, 10, []string{"Third", "First, edited"}},
+		{"updated limited", items.ListByUpdatedAt, time.Time{}, 1, []string{"Third"}},
+		{"updated since", items.ListByUpdatedAt, clock.Now(), 10, []string{"First, edited"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.list(tt.since, tt.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if titles := itemTitles(got); !reflect.DeepEqual(titles, tt.want) {
+				t.Errorf("got %v, want %v", titles, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenBackfillsUIDs(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "old.bolt")
+	created := time.Date(2023, 5, 6, 7, 8, 9, 0, time.UTC)
+	writeRawFile(t, dbPath, [][]byte{itemsBucket},
+		model.Item{ID: 1, Title: "No UID", CreatedAt: created, UpdatedAt: created},
+		model.Item{ID: 2, UID: "kept", Title: "Has a UID", CreatedAt: created, UpdatedAt: created},
+	)
+
+	items := openTestStorage(t, dbPath).Items()
+
+	backfilled, err := items.Get(1)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	want := common.NewULIDs(common.NewManualClock(created)).NewID()
+	if len(backfilled.UID) != len(want) || backfilled.UID[:10] != want[:10] {
+		t.Errorf("backfilled UID = %q, want a ULID from %v", backfilled.UID, created)
+	}
+	for _, uid := range []string{backfilled.UID, "kept"} {
+		if _, err := items.GetByUID(uid); err != nil {
+			t.Errorf("GetByUID(%q): %v", uid, err)
+		}
+	}
+}
+
+func TestUIDsAreUnique(t *testing.T) {
+	items := openTestStorage(t, filepath.Join(t.TempDir(), "test.bolt")).Items()
+
+	first, err := items.Create(model.Item{Title: "First"})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := items.Import(model.Item{ID: 10, UID: first.UID, Title: "Copy"}); err == nil {
+		t.Error("Import reusing another item's UID succeeded")
+	}
+	if _, err := items.Get(10); !errors.Is(err, common.ErrNotFound) {
+		t.Errorf("Get of the rejected item error = %v, want ErrNotFound", err)
+	}
+
+	// Deleting an item frees its UID
+	if err := items.Delete(first.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := items.Import(model.Item{ID: 10, UID: first.UID, Title: "Copy"}); err != nil {
+		t.Errorf("Import of a freed UID: %v", err)
+	}
+}
+
+func TestTransactions(t *testing.T) {
+	store := openTestStorage(t, filepath.Join(t.TempDir(), "test.bolt"))
+
+	failed := errors.New("give up")
+	err := store.WithTx(context.Background(), func(tx Storage) error {
+		if _, err := tx.Items().Create(model.Item{Title: "Rolled back"}); err != nil {
+			return err
+		}
+		if err := tx.Close(); !errors.Is(err, errCloseInTx) {
+			t.Errorf("Close in a transaction error = %v, want errCloseInTx", err)
+		}
+		return failed
+	})
+	if !errors.Is(err, failed) {
+		t.Fatalf("WithTx error = %v, want %v", err, failed)
+	}
+	if n, _ := store.Items().Count(); n != 0 {
+		t.Errorf("Count after rollback = %d, want 0", n)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	err = store.WithTx(ctx, func(tx Storage) error {
+		_, err := tx.Items().Create(model.Item{Title: "Abandoned"})
+		cancel()
+		return err
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("WithTx with a cancelled context error = %v, want context.Canceled", err)
+	}
+	if n, _ := store.Items().Count(); n != 0 {
+		t.Errorf("Count after a cancelled transaction = %d, want 0", n)
+	}
+}
+
+func TestFileIsLocked(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.bolt")
+	openTestStorage(t, dbPath)
+
+	if store, err := NewStorage(dbPath, Options{}); err == nil {
+		store.Close()
+		t.Error("opening a file that's already open succeeded")
+	}
+}
@@ -0,0 +1,284 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/all-in-one/internal/listing/pkg/model"
+	"github.com/all-in-one/internal/listing/pkg/repository"
+	"github.com/sirupsen/logrus"
+)
+
+// Defaults used for zero Options fields
+const (
+	DefaultSize = 1000
+	DefaultTTL  = 30 * time.Second
+)
+
+// Options configures the cache
+type Options struct {
+	// Size bounds the number of entries in each of the item and list caches
+	Size int
+
+	// TTL is how long an entry is served before it's reloaded
+	TTL time.Duration
+}
+
+// Stats reports cache effectiveness
+type Stats struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+	Entries   int    `json:"entries"`
+}
+
+// Storage decorates a repository.Storage so that its item repository is
+// served through a read-through cache
+type Storage struct {
+	repository.Storage
+	items *itemRepository
+}
+
+// New wraps a storage with a read-through cache
+func New(store repository.Storage, opts Options) *Storage {
+	if opts.Size <= 0 {
+		opts.Size = DefaultSize
+	}
+	if opts.TTL <= 0 {
+		opts.TTL = DefaultTTL
+	}
+
+	return &Storage{
+		Storage: store,
+		items:   newItemRepository(store.Items(), opts),
+	}
+}
+
+// Items returns the cached item repository
+func (s *Storage) Items() repository.ItemRepository {
+	return s.items
+}
+
+// Restore replaces all items and drops everything cached
+func (s *Storage) Restore(items []model.Item) error {
+	defer s.items.invalidate(s.items.lists.purge, s.items.entries.purge)
+	return s.Storage.Restore(items)
+}
+
+// WithTx runs fn in a transaction on the underlying storage. Reads inside
+// the transaction bypass the cache, and everything cached is dropped
+// afterwards since the transaction may have written anything.
+func (s *Storage) WithTx(ctx context.Context, fn func(tx repository.Storage) error) error {
+	defer s.items.invalidate(s.items.lists.purge, s.items.entries.purge)
+	return s.Storage.WithTx(ctx, fn)
+}
+
+// Stats returns the cache hit/miss counters
+func (s *Storage) Stats() Stats {
+	return s.items.stats()
+}
+
+// Close logs the final cache statistics and closes the underlying storage
+func (s *Storage) Close() error {
+	stats := s.Stats()
+	logrus.WithFields(logrus.Fields{
+		"hits":      stats.Hits,
+		"misses":    stats.Misses,
+		"evictions": stats.Evictions,
+	}).Info("Item cache statistics")
+
+	return s.Storage.Close()
+}
+
+// itemRepository implements repository.ItemRepository on top of another
+// repository, caching Get results per ID and list results per query.
+//
+// Writes invalidate precisely: the written item's entry is dropped, along
+// with every cached list since any of them may include it.
+type itemRepository struct {
+	next    repository.ItemRepository
+	entries *lru // single items, keyed by ID
+	lists   *lru // GetAll, List and GetByUID results, keyed by query
+
+	// generation counts writes. A read only stores what it loaded if no
+	// write happened meanwhile, otherwise it could cache a value that the
+	// write already invalidated. The mutex makes check-and-store atomic
+	// with respect to invalidation.
+	mutex      sync.Mutex
+	generation uint64
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+// newItemRepository creates a caching item repository
+func newItemRepository(next repository.ItemRepository, opts Options) *itemRepository {
+	return &itemRepository{
+		next:    next,
+		entries: newLRU(opts.Size, opts.TTL),
+		lists:   newLRU(opts.Size, opts.TTL),
+	}
+}
+
+// GetAll returns all items
+func (r *itemRepository) GetAll() ([]model.Item, error) {
+	return r.cachedList("all", r.next.GetAll)
+}
+
+// Get returns an item by ID
+func (r *itemRepository) Get(id int) (model.Item, error) {
+	key := itemKey(id)
+	if v, ok := r.entries.get(key); ok {
+		r.hits.Add(1)
+		return v.(model.Item), nil
+	}
+	r.misses.Add(1)
+
+	generation := r.currentGeneration()
+	item, err := r.next.Get(id)
+	if err != nil {
+		return model.Item{}, err
+	}
+
+	r.store(r.entries, key, item, generation)
+	return item, nil
+}
+
+// GetByUID returns an item by its UID. Lookups are cached alongside the
+// lists rather than the entries, since every write purges the lists and a
+// write by ID doesn't know which UID it touched.
+func (r *itemRepository) GetByUID(uid string) (model.Item, error) {
+	key := "uid:" + uid
+	if v, ok := r.lists.get(key); ok {
+		r.hits.Add(1)
+		return v.(model.Item), nil
+	}
+	r.misses.Add(1)
+
+	generation := r.currentGeneration()
+	item, err := r.next.GetByUID(uid)
+	if err != nil {
+		return model.Item{}, err
+	}
+
+	r.store(r.lists, key, item, generation)
+	return item, nil
+}
+
+// List returns up to limit items with IDs greater than afterID
+func (r *itemRepository) List(afterID, limit int) ([]model.Item, error) {
+	return r.cachedList(fmt.Sprintf("list:%d:%d", afterID, limit), func() ([]model.Item, error) {
+		return r.next.List(afterID, limit)
+	})
+}
+
+// Count returns the number of items. It isn't cached, since it's cheap in
+// every backend and only asked for now and then.
+func (r *itemRepository) Count() (int, error) {
+	return r.next.Count()
+}
+
+// Create adds a new item
+func (r *itemRepository) Create(item model.Item) (model.Item, error) {
+	defer r.invalidate(r.lists.purge)
+	return r.next.Create(item)
+}
+
+// Update modifies an existing item
+func (r *itemRepository) Update(id int, item model.Item) (model.Item, error) {
+	defer r.invalidate(r.lists.purge, func() { r.entries.remove(itemKey(id)) })
+	return r.next.Update(id, item)
+}
+
+// Delete removes an item
+func (r *itemRepository) Delete(id int) error {
+	defer r.invalidate(r.lists.purge, func() { r.entries.remove(itemKey(id)) })
+	return r.next.Delete(id)
+}
+
+// Import stores an item as-is
+func (r *itemRepository) Import(item model.Item) error {
+	defer r.invalidate(r.lists.purge, func() { r.entries.remove(itemKey(item.ID)) })
+	return r.next.Import(item)
+}
+
+// InitializeSampleData adds sample data to the storage
+func (r *itemRepository) InitializeSampleData() int {
+	defer r.invalidate(r.lists.purge)
+	return r.next.InitializeSampleData()
+}
+
+// Helper Functions
+
+// cachedList serves a list query from the cache or loads it with load
+func (r *itemRepository) cachedList(key string, load func() ([]model.Item, error)) ([]model.Item, error) {
+	if v, ok := r.lists.get(key); ok {
+		r.hits.Add(1)
+		return copyItems(v.([]model.Item)), nil
+	}
+	r.misses.Add(1)
+
+	generation := r.currentGeneration()
+	items, err := load()
+	if err != nil {
+		return nil, err
+	}
+
+	r.store(r.lists, key, copyItems(items), generation)
+	return items, nil
+}
+
+func (r *itemRepository) currentGeneration() uint64 {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.generation
+}
+
+// store caches a loaded value unless a write happened since generation
+func (r *itemRepository) store(c *lru, key string, value any, generation uint64) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.generation == generation {
+		c.set(key, value)
+	}
+}
+
+// invalidate records a write and runs the given invalidations. It runs
+// after the write whether or not it succeeded, since a failed write may
+// still have partially applied.
+func (r *itemRepository) invalidate(invalidations ...func()) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.generation++
+	for _, fn := range invalidations {
+		fn()
+	}
+}
+
+func (r *itemRepository) stats() Stats {
+	return Stats{
+		Hits:      r.hits.Load(),
+		Misses:    r.misses.Load(),
+		Evictions: r.entries.evicted() + r.lists.evicted(),
+		Entries:   r.entries.len() + r.lists.len(),
+	}
+}
+
+// itemKey returns the cache key of a single item
+func itemKey(id int) string {
+	return fmt.Sprintf("item:%d", id)
+}
+
+// copyItems returns a copy of items so callers can't modify cached slices
+func copyItems(items []model.Item) []model.Item {
+	if items == nil {
+		return nil
+	}
+	return append([]model.Item(nil), items...)
+}
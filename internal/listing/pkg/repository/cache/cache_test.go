@@ -0,0 +1,142 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/all-in-one/internal/common"
+	"github.com/all-in-one/internal/listing/pkg/model"
+	"github.com/all-in-one/internal/listing/pkg/repository"
+	"github.com/all-in-one/internal/listing/pkg/repository/repotest"
+)
+
+func newTestStorage(t *testing.T, opts Options) *Storage {
+	t.Helper()
+
+	store, err := repository.NewStorage("memory", "", repository.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cached := New(store, opts)
+	t.Cleanup(func() { cached.Close() })
+
+	return cached
+}
+
+func TestGetIsCachedAndInvalidatedOnWrite(t *testing.T) {
+	store := newTestStorage(t, Options{})
+	items := store.Items()
+
+	created, err := items.Create(model.Item{Title: "original"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	items.Get(created.ID)
+	items.Get(created.ID)
+	if stats := store.Stats(); stats.Hits != 1 || stats.Misses != 1 {
+		t.Errorf("stats after two Gets = %+v, want 1 hit and 1 miss", stats)
+	}
+
+	if _, err := items.Update(created.ID, model.Item{Title: "updated"}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := items.Get(created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Title != "updated" {
+		t.Errorf("Get after Update = %q, want %q", got.Title, "updated")
+	}
+
+	if err := items.Delete(created.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := items.Get(created.ID); !errors.Is(err, common.ErrNotFound) {
+		t.Errorf("Get after Delete error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestListsAreInvalidatedOnCreate(t *testing.T) {
+	store := newTestStorage(t, Options{})
+	items := store.Items()
+
+	items.Create(model.Item{Title: "first"})
+	if all, _ := items.GetAll(); len(all) != 1 {
+		t.Fatalf("GetAll = %d items, want 1", len(all))
+	}
+	if page, _ := items.List(0, 10); len(page) != 1 {
+		t.Fatalf("List = %d items, want 1", len(page))
+	}
+
+	items.Create(model.Item{Title: "second"})
+	if all, _ := items.GetAll(); len(all) != 2 {
+		t.Errorf("GetAll after Create = %d items, want 2", len(all))
+	}
+	if page, _ := items.List(0, 10); len(page) != 2 {
+		t.Errorf("List after Create = %d items, want 2", len(page))
+	}
+}
+
+func TestRestorePurgesCache(t *testing.T) {
+	store := newTestStorage(t, Options{})
+
+	created, _ := store.Items().Create(model.Item{Title: "before restore"})
+	store.Items().Get(created.ID)
+
+	now := time.Now()
+	restored := []model.Item{{ID: created.ID, Title: "restored", CreatedAt: now, UpdatedAt: now}}
+	if err := store.Restore(restored); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := store.Items().Get(created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Title != "restored" {
+		t.Errorf("Get after Restore = %q, want %q", got.Title, "restored")
+	}
+}
+
+func TestEntriesExpireAndEvict(t *testing.T) {
+	store := newTestStorage(t, Options{Size: 2, TTL: time.Minute})
+	clock := time.Now()
+	store.items.entries.now = func() time.Time { return clock }
+
+	var ids []int
+	for _, title := range []string{"a", "b", "c"} {
+		created, _ := store.Items().Create(model.Item{Title: title})
+		store.Items().Get(created.ID)
+		ids = append(ids, created.ID)
+	}
+	if stats := store.Stats(); stats.Evictions != 1 {
+		t.Errorf("evictions = %d, want 1", stats.Evictions)
+	}
+
+	before := store.Stats().Misses
+	store.Items().Get(ids[2])
+	if store.Stats().Misses != before {
+		t.Error("fresh entry missed")
+	}
+
+	clock = clock.Add(2 * time.Minute)
+	store.Items().Get(ids[2])
+	if store.Stats().Misses != before+1 {
+		t.Error("expired entry was served from the cache")
+	}
+}
+
+func TestConformance(t *testing.T) {
+	repotest.RunItemRepository(t, func(t *testing.T, deps repotest.Deps) repository.ItemRepository {
+		store, err := repository.NewStorage("memory", "", repository.Options{Clock: deps.Clock, IDs: deps.IDs})
+		if err != nil {
+			t.Fatal(err)
+		}
+		cached := New(store, Options{})
+		t.Cleanup(func() { cached.Close() })
+
+		return cached.Items()
+	})
+}
@@ -0,0 +1,119 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// entry is a cached value with its expiry time
+type entry struct {
+	key     string
+	value   any
+	expires time.Time
+}
+
+// lru is a size-bounded least-recently-used cache whose entries also expire
+// after a fixed TTL
+type lru struct {
+	mutex     sync.Mutex
+	size      int
+	ttl       time.Duration
+	order     *list.List // front is most recently used
+	entries   map[string]*list.Element
+	evictions uint64
+	now       func() time.Time
+}
+
+// newLRU creates an LRU holding at most size entries for at most ttl each
+func newLRU(size int, ttl time.Duration) *lru {
+	return &lru{
+		size:    size,
+		ttl:     ttl,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+		now:     time.Now,
+	}
+}
+
+// get returns the value for key if present and not expired
+func (c *lru) get(key string) (any, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	e := elem.Value.(*entry)
+	if c.now().After(e.expires) {
+		c.removeElement(elem)
+		return nil, false
+	}
+
+	c.order.MoveToFront(elem)
+	return e.value, true
+}
+
+// set stores value under key, evicting the least recently used entry when
+// the cache is full
+func (c *lru) set(key string, value any) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	expires := c.now().Add(c.ttl)
+	if elem, ok := c.entries[key]; ok {
+		e := elem.Value.(*entry)
+		e.value = value
+		e.expires = expires
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&entry{key: key, value: value, expires: expires})
+	for c.order.Len() > c.size {
+		c.removeElement(c.order.Back())
+		c.evictions++
+	}
+}
+
+// remove drops key from the cache
+func (c *lru) remove(key string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		c.removeElement(elem)
+	}
+}
+
+// purge drops every entry
+func (c *lru) purge() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.order.Init()
+	c.entries = make(map[string]*list.Element)
+}
+
+// len returns the number of entries, including expired ones not yet dropped
+func (c *lru) len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.order.Len()
+}
+
+// evicted returns the number of entries dropped to make room
+func (c *lru) evicted() uint64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.evictions
+}
+
+func (c *lru) removeElement(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.entries, elem.Value.(*entry).key)
+}
@@ -0,0 +1,307 @@
+package encryption
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/all-in-one/internal/listing/pkg/model"
+	"github.com/all-in-one/internal/listing/pkg/repository"
+)
+
+// Field names bound to their ciphertexts
+const (
+	fieldTitle       = "title"
+	fieldDescription = "description"
+)
+
+// DefaultBatchSize is the number of items re-encrypted per transaction by
+// RotateKeys
+const DefaultBatchSize = 500
+
+// Storage decorates a repository.Storage so that item titles and
+// descriptions are encrypted before they reach the backend and decrypted
+// when read back. IDs and timestamps stay in the clear so ordering and
+// paging keep working.
+type Storage struct {
+	repository.Storage
+	keys *Keyring
+}
+
+// New wraps a storage with field-level encryption using keys
+func New(store repository.Storage, keys *Keyring) *Storage {
+	return &Storage{Storage: store, keys: keys}
+}
+
+// Items returns the encrypting item repository
+func (s *Storage) Items() repository.ItemRepository {
+	return &itemRepository{next: s.Storage.Items(), store: s.Storage, keys: s.keys}
+}
+
+// Snapshot returns a decrypted copy of all items
+func (s *Storage) Snapshot() ([]model.Item, error) {
+	items, err := s.Storage.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	return decryptItems(s.keys, items)
+}
+
+// Restore encrypts the given items and replaces all items with them
+func (s *Storage) Restore(items []model.Item) error {
+	encrypted := make([]model.Item, len(items))
+	for i, item := range items {
+		var err error
+		if encrypted[i], err = encryptItem(s.keys, item); err != nil {
+			return err
+		}
+	}
+
+	return s.Storage.Restore(encrypted)
+}
+
+// WithTx runs fn in a transaction whose storage encrypts like s
+func (s *Storage) WithTx(ctx context.Context, fn func(tx repository.Storage) error) error {
+	return s.Storage.WithTx(ctx, func(tx repository.Storage) error {
+		return fn(New(tx, s.keys))
+	})
+}
+
+// RotateResult summarizes a key rotation
+type RotateResult struct {
+	Scanned   int
+	Rotated   int
+	PrimaryID string
+}
+
+// RotateKeys re-encrypts every item that isn't yet encrypted with the
+// primary key, including plaintext rows written before encryption was
+// enabled. Each batch is read and rewritten in one transaction, keeping
+// IDs and timestamps, so it's safe to run against a live database and to
+// rerun after an interruption.
+func (s *Storage) RotateKeys(ctx context.Context, batchSize int) (RotateResult, error) {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+
+	result := RotateResult{PrimaryID: s.keys.Primary()}
+	afterID := 0
+	for {
+		n := 0
+		err := s.Storage.WithTx(ctx, func(tx repository.Storage) error {
+			batch, err := tx.Items().List(afterID, batchSize)
+			if err != nil {
+				return err
+			}
+			n = len(batch)
+
+			for _, item := range batch {
+				afterID = item.ID
+				result.Scanned++
+				if s.keys.current(item.Title) && s.keys.current(item.Description) {
+					continue
+				}
+
+				plain, err := decryptItem(s.keys, item)
+				if err != nil {
+					return err
+				}
+				encrypted, err := encryptItem(s.keys, plain)
+				if err != nil {
+					return fmt.Errorf("item %d: %w", item.ID, err)
+				}
+				if err := tx.Items().Import(encrypted); err != nil {
+					return fmt.Errorf("item %d: %w", item.ID, err)
+				}
+				result.Rotated++
+			}
+			return nil
+		})
+		if err != nil {
+			return result, err
+		}
+		if n == 0 {
+			return result, nil
+		}
+	}
+}
+
+// itemRepository implements repository.ItemRepository on top of another
+// repository, encrypting on the way in and decrypting on the way out
+type itemRepository struct {
+	next  repository.ItemRepository
+	store repository.Storage
+	keys  *Keyring
+}
+
+// GetAll returns all items
+func (r *itemRepository) GetAll() ([]model.Item, error) {
+	items, err := r.next.GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	return decryptItems(r.keys, items)
+}
+
+// Get returns an item by ID
+func (r *itemRepository) Get(id int) (model.Item, error) {
+	item, err := r.next.Get(id)
+	if err != nil {
+		return model.Item{}, err
+	}
+
+	return decryptItem(r.keys, item)
+}
+
+// GetByUID returns an item by its UID
+func (r *itemRepository) GetByUID(uid string) (model.Item, error) {
+	item, err := r.next.GetByUID(uid)
+	if err != nil {
+		return model.Item{}, err
+	}
+
+	return decryptItem(r.keys, item)
+}
+
+// List returns up to limit items with IDs greater than afterID
+func (r *itemRepository) List(afterID, limit int) ([]model.Item, error) {
+	items, err := r.next.List(afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return decryptItems(r.keys, items)
+}
+
+// Count returns the number of items
+func (r *itemRepository) Count() (int, error) {
+	return r.next.Count()
+}
+
+// Create adds a new item. The ciphertext is bound to the ID, which only the
+// backend assigns, so a blank item is created first and filled in within
+// the same transaction.
+func (r *itemRepository) Create(item model.Item) (model.Item, error) {
+	var created model.Item
+	err := r.store.WithTx(context.Background(), func(tx repository.Storage) error {
+		blank, err := tx.Items().Create(model.Item{})
+		if err != nil {
+			return err
+		}
+
+		created = item
+		created.ID, created.UID = blank.ID, blank.UID
+		created.CreatedAt, created.UpdatedAt = blank.CreatedAt, blank.UpdatedAt
+		encrypted, err := encryptItem(r.keys, created)
+		if err != nil {
+			return err
+		}
+		return tx.Items().Import(encrypted)
+	})
+	if err != nil {
+		return model.Item{}, err
+	}
+
+	// Hand back the caller's plaintext with the backend's IDs and timestamps
+	return created, nil
+}
+
+// Update modifies an existing item
+func (r *itemRepository) Update(id int, item model.Item) (model.Item, error) {
+	item.ID = id
+	encrypted, err := encryptItem(r.keys, item)
+	if err != nil {
+		return model.Item{}, err
+	}
+
+	updated, err := r.next.Update(id, encrypted)
+	if err != nil {
+		return model.Item{}, err
+	}
+
+	item.ID, item.UID = updated.ID, updated.UID
+	item.CreatedAt, item.UpdatedAt = updated.CreatedAt, updated.UpdatedAt
+	return item, nil
+}
+
+// Delete removes an item
+func (r *itemRepository) Delete(id int) error {
+	return r.next.Delete(id)
+}
+
+// Import stores an item as-is apart from encrypting it
+func (r *itemRepository) Import(item model.Item) error {
+	encrypted, err := encryptItem(r.keys, item)
+	if err != nil {
+		return err
+	}
+
+	return r.next.Import(encrypted)
+}
+
+// InitializeSampleData adds sample data to the storage. The backend writes
+// the samples in the clear, so they're encrypted right after.
+func (r *itemRepository) InitializeSampleData() int {
+	n := r.next.InitializeSampleData()
+	if n == 0 {
+		return 0
+	}
+
+	afterID := 0
+	for {
+		batch, err := r.next.List(afterID, DefaultBatchSize)
+		if err != nil || len(batch) == 0 {
+			return n
+		}
+		for _, item := range batch {
+			afterID = item.ID
+			if isEncrypted(item.Title) && isEncrypted(item.Description) {
+				continue
+			}
+			if err := r.Import(item); err != nil {
+				return n
+			}
+		}
+	}
+}
+
+// Helper Functions
+
+// encryptItem returns item with its title and description encrypted
+func encryptItem(keys *Keyring, item model.Item) (model.Item, error) {
+	var err error
+	if item.Title, err = keys.encrypt(fieldTitle, item.ID, item.Title); err != nil {
+		return model.Item{}, err
+	}
+	if item.Description, err = keys.encrypt(fieldDescription, item.ID, item.Description); err != nil {
+		return model.Item{}, err
+	}
+
+	return item, nil
+}
+
+// decryptItem returns item with its title and description decrypted
+func decryptItem(keys *Keyring, item model.Item) (model.Item, error) {
+	var err error
+	if item.Title, err = keys.decrypt(fieldTitle, item.ID, item.Title); err != nil {
+		return model.Item{}, fmt.Errorf("item %d: %w", item.ID, err)
+	}
+	if item.Description, err = keys.decrypt(fieldDescription, item.ID, item.Description); err != nil {
+		return model.Item{}, fmt.Errorf("item %d: %w", item.ID, err)
+	}
+
+	return item, nil
+}
+
+// decryptItems decrypts items in place
+func decryptItems(keys *Keyring, items []model.Item) ([]model.Item, error) {
+	for i := range items {
+		var err error
+		if items[i], err = decryptItem(keys, items[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	return items, nil
+}
@@ -0,0 +1,212 @@
+package encryption
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/all-in-one/internal/listing/pkg/model"
+	"github.com/all-in-one/internal/listing/pkg/repository"
+	"github.com/all-in-one/internal/listing/pkg/repository/repotest"
+)
+
+// newTestStorage returns an encrypting storage over a fresh memory backend,
+// along with the backend itself for inspecting what's stored
+func newTestStorage(t *testing.T, keys *Keyring) (*Storage, repository.Storage) {
+	t.Helper()
+
+	raw, err := repository.NewStorage("memory", "", repository.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { raw.Close() })
+
+	return New(raw, keys), raw
+}
+
+func newTestKeyring(t *testing.T, primary string, ids ...string) *Keyring {
+	t.Helper()
+
+	// Derive each key from its ID so the same ID always means the same key
+	keys := make(map[string][]byte)
+	for _, id := range ids {
+		key := sha256.Sum256([]byte(id))
+		keys[id] = key[:]
+	}
+	keyring, err := NewKeyring(primary, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return keyring
+}
+
+func TestFieldsAreEncryptedAtRest(t *testing.T) {
+	store, raw := newTestStorage(t, newTestKeyring(t, "k1", "k1"))
+
+	created, err := store.Items().Create(model.Item{Title: "secret title", Description: "secret description"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.Title != "secret title" {
+		t.Errorf("Create returned title %q, want plaintext", created.Title)
+	}
+
+	stored, err := raw.Items().Get(created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(stored.Title, "enc:v1:k1:") || strings.Contains(stored.Title, "secret") {
+		t.Errorf("stored title = %q, want ciphertext under k1", stored.Title)
+	}
+	if !strings.HasPrefix(stored.Description, "enc:v1:k1:") {
+		t.Errorf("stored description = %q, want ciphertext under k1", stored.Description)
+	}
+
+	got, err := store.Items().Get(created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Title != "secret title" || got.Description != "secret description" {
+		t.Errorf("Get = %+v, want decrypted fields", got)
+	}
+}
+
+func TestPlaintextRowsAreReadable(t *testing.T) {
+	store, raw := newTestStorage(t, newTestKeyring(t, "k1", "k1"))
+
+	legacy, err := raw.Items().Create(model.Item{Title: "written before encryption"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := store.Items().Get(legacy.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Title != "written before encryption" {
+		t.Errorf("Get = %q, want the plaintext title", got.Title)
+	}
+}
+
+func TestRotateKeys(t *testing.T) {
+	oldStore, raw := newTestStorage(t, newTestKeyring(t, "k1", "k1"))
+	for _, title := range []string{"a", "b", "c"} {
+		if _, err := oldStore.Items().Create(model.Item{Title: title}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	legacy, _ := raw.Items().Create(model.Item{Title: "plaintext"})
+
+	store := New(raw, newTestKeyring(t, "k2", "k1", "k2"))
+	result, err := store.RotateKeys(context.Background(), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Scanned != 4 || result.Rotated != 4 {
+		t.Errorf("result = %+v, want 4 scanned and 4 rotated", result)
+	}
+
+	stored, _ := raw.Items().GetAll()
+	for _, item := range stored {
+		if !strings.HasPrefix(item.Title, "enc:v1:k2:") {
+			t.Errorf("item %d title = %q after rotation, want ciphertext under k2", item.ID, item.Title)
+		}
+	}
+
+	// The old key is no longer needed
+	rotated := New(raw, newTestKeyring(t, "k2", "k2"))
+	got, err := rotated.Items().Get(legacy.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Title != "plaintext" {
+		t.Errorf("Get after rotation = %q, want %q", got.Title, "plaintext")
+	}
+
+	// Rerunning is a no-op
+	result, err = store.RotateKeys(context.Background(), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Rotated != 0 {
+		t.Errorf("second rotation rotated %d items, want 0", result.Rotated)
+	}
+}
+
+func TestDecryptFailures(t *testing.T) {
+	store, raw := newTestStorage(t, newTestKeyring(t, "k1", "k1"))
+	created, _ := store.Items().Create(model.Item{Title: "title"})
+
+	other := New(raw, newTestKeyring(t, "k9", "k9"))
+	if _, err := other.Items().Get(created.ID); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Get with missing key error = %v, want ErrUnknownKey", err)
+	}
+
+	// Swapping ciphertexts between fields fails authentication
+	stored, _ := raw.Items().Get(created.ID)
+	stored.Title, stored.Description = stored.Description, stored.Title
+	raw.Items().Import(stored)
+	if _, err := store.Items().Get(created.ID); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("Get of swapped fields error = %v, want ErrDecrypt", err)
+	}
+
+	// So does moving a ciphertext to the same field of another item
+	first, err := store.Items().Create(model.Item{Title: "first"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := store.Items().Create(model.Item{Title: "second"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	source, _ := raw.Items().Get(first.ID)
+	target, _ := raw.Items().Get(second.ID)
+	target.Title = source.Title
+	raw.Items().Import(target)
+	if _, err := store.Items().Get(second.ID); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("Get of a title moved from another item error = %v, want ErrDecrypt", err)
+	}
+}
+
+func TestGenerateAndLoadKeyring(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+
+	first, err := GenerateKey(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := GenerateKey(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == second {
+		t.Fatalf("GenerateKey returned %q twice", first)
+	}
+
+	keys, err := LoadKeyring(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys.Primary() != second {
+		t.Errorf("primary = %q, want the newest key %q", keys.Primary(), second)
+	}
+	if ids := keys.KeyIDs(); len(ids) != 2 {
+		t.Errorf("key IDs = %v, want both keys", ids)
+	}
+}
+
+func TestConformance(t *testing.T) {
+	repotest.RunItemRepository(t, func(t *testing.T, deps repotest.Deps) repository.ItemRepository {
+		raw, err := repository.NewStorage("memory", "", repository.Options{Clock: deps.Clock, IDs: deps.IDs})
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { raw.Close() })
+
+		return New(raw, newTestKeyring(t, "k1", "k1")).Items()
+	})
+}
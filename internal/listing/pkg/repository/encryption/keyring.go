@@ -0,0 +1,251 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// KeySize is the length of an AES-256 key in bytes
+const KeySize = 32
+
+// prefix marks an encrypted field value: prefix + keyID + ":" + base64(nonce || ciphertext)
+const prefix = "enc:v1:"
+
+var (
+	// ErrUnknownKey is returned when a value was encrypted with a key that
+	// isn't in the keyring
+	ErrUnknownKey = errors.New("encryption key not found in keyring")
+
+	// ErrDecrypt is returned when a value can't be decrypted, e.g. because
+	// it was tampered with
+	ErrDecrypt = errors.New("decrypting field failed")
+)
+
+// keyfile is the on-disk format of a keyring
+type keyfile struct {
+	// Primary is the ID of the key new values are encrypted with
+	Primary string `json:"primary"`
+
+	// Keys maps key IDs to base64-encoded 256-bit keys. Retired keys stay
+	// here until rotate-keys has re-encrypted everything with the primary.
+	Keys map[string]string `json:"keys"`
+}
+
+// Keyring holds the keys used to encrypt and decrypt item fields
+type Keyring struct {
+	primary string
+	aeads   map[string]cipher.AEAD
+}
+
+// NewKeyring creates a keyring from raw keys, encrypting with primary
+func NewKeyring(primary string, keys map[string][]byte) (*Keyring, error) {
+	if _, ok := keys[primary]; !ok {
+		return nil, fmt.Errorf("primary key %q is not in the keyring", primary)
+	}
+
+	k := &Keyring{primary: primary, aeads: make(map[string]cipher.AEAD, len(keys))}
+	for id, key := range keys {
+		if id == "" || strings.Contains(id, ":") {
+			return nil, fmt.Errorf("invalid key ID %q: must be non-empty and not contain ':'", id)
+		}
+		if len(key) != KeySize {
+			return nil, fmt.Errorf("key %q is %d bytes, want %d", id, len(key), KeySize)
+		}
+
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		k.aeads[id] = aead
+	}
+
+	return k, nil
+}
+
+// LoadKeyring reads a keyring from a JSON keyfile
+func LoadKeyring(path string) (*Keyring, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var kf keyfile
+	if err := json.Unmarshal(data, &kf); err != nil {
+		return nil, fmt.Errorf("parsing keyfile %s: %w", path, err)
+	}
+
+	keys := make(map[string][]byte, len(kf.Keys))
+	for id, encoded := range kf.Keys {
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("decoding key %q in %s: %w", id, path, err)
+		}
+		keys[id] = key
+	}
+
+	return NewKeyring(kf.Primary, keys)
+}
+
+// GenerateKey adds a new random key to the keyfile at path and makes it the
+// primary, creating the file if needed. It returns the new key's ID.
+func GenerateKey(path string) (string, error) {
+	kf := keyfile{Keys: map[string]string{}}
+
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(data, &kf); err != nil {
+			return "", fmt.Errorf("parsing keyfile %s: %w", path, err)
+		}
+		if kf.Keys == nil {
+			kf.Keys = map[string]string{}
+		}
+	case errors.Is(err, os.ErrNotExist):
+		// Start a new keyfile
+	default:
+		return "", err
+	}
+
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+
+	// Time-based IDs sort in creation order; suffix them in the unlikely
+	// case of two keys within a second
+	base := time.Now().UTC().Format("20060102T150405Z")
+	id := base
+	for n := 2; kf.Keys[id] != ""; n++ {
+		id = fmt.Sprintf("%s-%d", base, n)
+	}
+
+	kf.Keys[id] = base64.StdEncoding.EncodeToString(key)
+	kf.Primary = id
+
+	data, err = json.MarshalIndent(kf, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	// Write next to the keyfile and rename so a crash never leaves a
+	// truncated keyfile, which would make the data unreadable
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".keyfile-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+// Primary returns the ID of the key new values are encrypted with
+func (k *Keyring) Primary() string {
+	return k.primary
+}
+
+// KeyIDs returns the IDs of all keys in the keyring, sorted
+func (k *Keyring) KeyIDs() []string {
+	ids := make([]string, 0, len(k.aeads))
+	for id := range k.aeads {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	return ids
+}
+
+// encrypt seals a field value of item id with the primary key. The field
+// name and item ID are bound as additional data, so a title can't be
+// swapped into a description, nor a value into another item.
+func (k *Keyring) encrypt(field string, id int, plaintext string) (string, error) {
+	aead := k.aeads[k.primary]
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), additionalData(field, id))
+
+	return prefix + k.primary + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// decrypt opens a field value of item id. Values without the encryption
+// prefix were written before encryption was enabled and are returned
+// unchanged.
+func (k *Keyring) decrypt(field string, id int, value string) (string, error) {
+	keyID, sealed, ok := parse(value)
+	if !ok {
+		return value, nil
+	}
+
+	aead, found := k.aeads[keyID]
+	if !found {
+		return "", fmt.Errorf("%w: %q", ErrUnknownKey, keyID)
+	}
+
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil || len(data) < aead.NonceSize() {
+		return "", fmt.Errorf("%w: malformed %s", ErrDecrypt, field)
+	}
+
+	plaintext, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], additionalData(field, id))
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", ErrDecrypt, field)
+	}
+
+	return string(plaintext), nil
+}
+
+// additionalData is what a field value is bound to: the field name and the
+// ID of its item. The ID rather than the UID, since every backend keeps IDs
+// through imports, restores and migrations, while an item may not have its
+// UID yet when it's written.
+func additionalData(field string, id int) []byte {
+	return []byte(field + ":" + strconv.Itoa(id))
+}
+
+// current reports whether value is encrypted with the primary key
+func (k *Keyring) current(value string) bool {
+	keyID, _, ok := parse(value)
+	return ok && keyID == k.primary
+}
+
+// isEncrypted reports whether value carries the encryption prefix
+func isEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+// parse splits an encrypted value into its key ID and sealed payload
+func parse(value string) (keyID, sealed string, ok bool) {
+	rest, ok := strings.CutPrefix(value, prefix)
+	if !ok {
+		return "", "", false
+	}
+	return strings.Cut(rest, ":")
+}
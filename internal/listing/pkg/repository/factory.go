@@ -1,11 +1,16 @@
 package repository
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
 
+	"github.com/all-in-one/internal/common"
 	"github.com/all-in-one/internal/listing/pkg/model"
+	"github.com/all-in-one/internal/listing/pkg/repository/bolt"
 	"github.com/all-in-one/internal/listing/pkg/repository/memory"
 	"github.com/all-in-one/internal/listing/pkg/repository/sqlite"
+	"github.com/all-in-one/internal/sqlitedb"
 )
 
 // storageWrapper wraps the different storage implementations
@@ -13,14 +18,21 @@ type storageWrapper struct {
 	storageType string
 	memStorage  memory.Storage
 	sqlStorage  sqlite.Storage
+	boltStorage bolt.Storage
 }
 
 func (s *storageWrapper) Items() ItemRepository {
-	if s.storageType == "memory" {
+	switch s.storageType {
+	case "memory":
 		return &itemRepositoryWrapper{
 			storageType: "memory",
 			memRepo:     s.memStorage.Items(),
 		}
+	case "bolt":
+		return &itemRepositoryWrapper{
+			storageType: "bolt",
+			boltRepo:    s.boltStorage.Items(),
+		}
 	}
 	return &itemRepositoryWrapper{
 		storageType: "sqlite",
@@ -28,9 +40,67 @@ func (s *storageWrapper) Items() ItemRepository {
 	}
 }
 
+func (s *storageWrapper) APIKeys() APIKeyRepository {
+	switch s.storageType {
+	case "memory":
+		return &apiKeyRepositoryWrapper{
+			storageType: "memory",
+			memRepo:     s.memStorage.APIKeys(),
+		}
+	case "bolt":
+		return &apiKeyRepositoryWrapper{
+			storageType: "bolt",
+			boltRepo:    s.boltStorage.APIKeys(),
+		}
+	}
+	return &apiKeyRepositoryWrapper{
+		storageType: "sqlite",
+		sqlRepo:     s.sqlStorage.APIKeys(),
+	}
+}
+
+func (s *storageWrapper) Snapshot() ([]model.Item, error) {
+	switch s.storageType {
+	case "memory":
+		return s.memStorage.Snapshot()
+	case "bolt":
+		return s.boltStorage.Snapshot()
+	}
+	return s.sqlStorage.Snapshot()
+}
+
+func (s *storageWrapper) Restore(items []model.Item) error {
+	switch s.storageType {
+	case "memory":
+		return s.memStorage.Restore(items)
+	case "bolt":
+		return s.boltStorage.Restore(items)
+	}
+	return s.sqlStorage.Restore(items)
+}
+
+func (s *storageWrapper) WithTx(ctx context.Context, fn func(tx Storage) error) error {
+	switch s.storageType {
+	case "memory":
+		return s.memStorage.WithTx(ctx, func(tx memory.Storage) error {
+			return fn(&storageWrapper{storageType: "memory", memStorage: tx})
+		})
+	case "bolt":
+		return s.boltStorage.WithTx(ctx, func(tx bolt.Storage) error {
+			return fn(&storageWrapper{storageType: "bolt", boltStorage: tx})
+		})
+	}
+	return s.sqlStorage.WithTx(ctx, func(tx sqlite.Storage) error {
+		return fn(&storageWrapper{storageType: "sqlite", sqlStorage: tx})
+	})
+}
+
 func (s *storageWrapper) Close() error {
-	if s.storageType == "memory" {
+	switch s.storageType {
+	case "memory":
 		return s.memStorage.Close()
+	case "bolt":
+		return s.boltStorage.Close()
 	}
 	return s.sqlStorage.Close()
 }
@@ -40,61 +110,207 @@ type itemRepositoryWrapper struct {
 	storageType string
 	memRepo     memory.ItemRepository
 	sqlRepo     sqlite.ItemRepository
+	boltRepo    bolt.ItemRepository
 }
 
 func (r *itemRepositoryWrapper) GetAll() ([]model.Item, error) {
-	if r.storageType == "memory" {
+	switch r.storageType {
+	case "memory":
 		return r.memRepo.GetAll()
+	case "bolt":
+		return r.boltRepo.GetAll()
 	}
 	return r.sqlRepo.GetAll()
 }
 
 func (r *itemRepositoryWrapper) Get(id int) (model.Item, error) {
-	if r.storageType == "memory" {
+	switch r.storageType {
+	case "memory":
 		return r.memRepo.Get(id)
+	case "bolt":
+		return r.boltRepo.Get(id)
 	}
 	return r.sqlRepo.Get(id)
 }
 
+func (r *itemRepositoryWrapper) GetByUID(uid string) (model.Item, error) {
+	switch r.storageType {
+	case "memory":
+		return r.memRepo.GetByUID(uid)
+	case "bolt":
+		return r.boltRepo.GetByUID(uid)
+	}
+	return r.sqlRepo.GetByUID(uid)
+}
+
+func (r *itemRepositoryWrapper) List(afterID, limit int) ([]model.Item, error) {
+	switch r.storageType {
+	case "memory":
+		return r.memRepo.List(afterID, limit)
+	case "bolt":
+		return r.boltRepo.List(afterID, limit)
+	}
+	return r.sqlRepo.List(afterID, limit)
+}
+
+func (r *itemRepositoryWrapper) Count() (int, error) {
+	switch r.storageType {
+	case "memory":
+		return r.memRepo.Count()
+	case "bolt":
+		return r.boltRepo.Count()
+	}
+	return r.sqlRepo.Count()
+}
+
 func (r *itemRepositoryWrapper) Create(item model.Item) (model.Item, error) {
-	if r.storageType == "memory" {
+	switch r.storageType {
+	case "memory":
 		return r.memRepo.Create(item)
+	case "bolt":
+		return r.boltRepo.Create(item)
 	}
 	return r.sqlRepo.Create(item)
 }
 
 func (r *itemRepositoryWrapper) Update(id int, item model.Item) (model.Item, error) {
-	if r.storageType == "memory" {
+	switch r.storageType {
+	case "memory":
 		return r.memRepo.Update(id, item)
+	case "bolt":
+		return r.boltRepo.Update(id, item)
 	}
 	return r.sqlRepo.Update(id, item)
 }
 
 func (r *itemRepositoryWrapper) Delete(id int) error {
-	if r.storageType == "memory" {
+	switch r.storageType {
+	case "memory":
 		return r.memRepo.Delete(id)
+	case "bolt":
+		return r.boltRepo.Delete(id)
 	}
 	return r.sqlRepo.Delete(id)
 }
 
+func (r *itemRepositoryWrapper) Import(item model.Item) error {
+	switch r.storageType {
+	case "memory":
+		return r.memRepo.Import(item)
+	case "bolt":
+		return r.boltRepo.Import(item)
+	}
+	return r.sqlRepo.Import(item)
+}
+
 func (r *itemRepositoryWrapper) InitializeSampleData() int {
-	if r.storageType == "memory" {
+	switch r.storageType {
+	case "memory":
 		return r.memRepo.InitializeSampleData()
+	case "bolt":
+		return r.boltRepo.InitializeSampleData()
 	}
 	return r.sqlRepo.InitializeSampleData()
 }
 
+// apiKeyRepositoryWrapper wraps the different API key repository implementations
+type apiKeyRepositoryWrapper struct {
+	storageType string
+	memRepo     memory.APIKeyRepository
+	sqlRepo     sqlite.APIKeyRepository
+	boltRepo    bolt.APIKeyRepository
+}
+
+func (r *apiKeyRepositoryWrapper) Create(key model.APIKey) (model.APIKey, error) {
+	switch r.storageType {
+	case "memory":
+		return r.memRepo.Create(key)
+	case "bolt":
+		return r.boltRepo.Create(key)
+	}
+	return r.sqlRepo.Create(key)
+}
+
+func (r *apiKeyRepositoryWrapper) GetByPrefix(prefix string) (model.APIKey, error) {
+	switch r.storageType {
+	case "memory":
+		return r.memRepo.GetByPrefix(prefix)
+	case "bolt":
+		return r.boltRepo.GetByPrefix(prefix)
+	}
+	return r.sqlRepo.GetByPrefix(prefix)
+}
+
+func (r *apiKeyRepositoryWrapper) List() ([]model.APIKey, error) {
+	switch r.storageType {
+	case "memory":
+		return r.memRepo.List()
+	case "bolt":
+		return r.boltRepo.List()
+	}
+	return r.sqlRepo.List()
+}
+
+func (r *apiKeyRepositoryWrapper) Revoke(id int) error {
+	switch r.storageType {
+	case "memory":
+		return r.memRepo.Revoke(id)
+	case "bolt":
+		return r.boltRepo.Revoke(id)
+	}
+	return r.sqlRepo.Revoke(id)
+}
+
+// WithContext returns store bound to ctx if it's a ContextStorage, and store
+// itself otherwise
+func WithContext(ctx context.Context, store Storage) Storage {
+	if cs, ok := store.(ContextStorage); ok {
+		return cs.WithContext(ctx)
+	}
+	return store
+}
+
+// DBStats returns the connection pool statistics of a SQLite storage made by
+// NewStorage. ok is false for other backends, which have no pool.
+func DBStats(store Storage) (stats sql.DBStats, ok bool) {
+	wrapper, isWrapper := store.(*storageWrapper)
+	if !isWrapper || wrapper.storageType != "sqlite" {
+		return sql.DBStats{}, false
+	}
+	pool, hasPool := wrapper.sqlStorage.(interface{ DBStats() sql.DBStats })
+	if !hasPool {
+		return sql.DBStats{}, false
+	}
+	return pool.DBStats(), true
+}
+
+// Options holds storage settings
+type Options struct {
+	// Clock supplies timestamps to every backend. Nil means the system clock.
+	Clock common.Clock
+
+	// IDs generates the UID of new items in every backend. Nil means ULIDs.
+	IDs common.IDGenerator
+
+	// SQLite holds the SQLite driver, pragma and pool settings
+	SQLite sqlitedb.Options
+}
+
 // NewStorage creates a new storage instance based on the storage type
-func NewStorage(storageType, connectionString string) (Storage, error) {
+func NewStorage(storageType, connectionString string, opts Options) (Storage, error) {
 	switch storageType {
 	case "memory":
-		memStorage := memory.NewStorage()
+		memStorage := memory.NewStorage(memory.Options{Clock: opts.Clock, IDs: opts.IDs})
 		return &storageWrapper{
 			storageType: "memory",
 			memStorage:  memStorage,
 		}, nil
 	case "sqlite":
-		sqlStorage, err := sqlite.NewStorage(connectionString)
+		sqlStorage, err := sqlite.NewStorage(connectionString, sqlite.Options{
+			Connection: opts.SQLite,
+			Clock:      opts.Clock,
+			IDs:        opts.IDs,
+		})
 		if err != nil {
 			return nil, err
 		}
@@ -102,6 +318,15 @@ func NewStorage(storageType, connectionString string) (Storage, error) {
 			storageType: "sqlite",
 			sqlStorage:  sqlStorage,
 		}, nil
+	case "bolt":
+		boltStorage, err := bolt.NewStorage(connectionString, bolt.Options{Clock: opts.Clock, IDs: opts.IDs})
+		if err != nil {
+			return nil, err
+		}
+		return &storageWrapper{
+			storageType: "bolt",
+			boltStorage: boltStorage,
+		}, nil
 	default:
 		return nil, fmt.Errorf("unsupported storage type: %s", storageType)
 	}
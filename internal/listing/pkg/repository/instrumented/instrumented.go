@@ -0,0 +1,175 @@
+package instrumented
+
+import (
+	"context"
+	"time"
+
+	"github.com/all-in-one/internal/listing/pkg/model"
+	"github.com/all-in-one/internal/listing/pkg/repository"
+)
+
+// Observer records that an operation took d and failed with err, or
+// succeeded if err is nil
+type Observer func(operation string, d time.Duration, err error)
+
+// Storage decorates a repository.Storage so that every operation on it, its
+// repositories and its transactions is timed and reported to an Observer
+type Storage struct {
+	repository.Storage
+	observe Observer
+}
+
+// New wraps a storage, reporting the duration of each operation to observe
+func New(store repository.Storage, observe Observer) *Storage {
+	return &Storage{Storage: store, observe: observe}
+}
+
+// timed runs fn and reports how long it took as operation
+func timed[T any](observe Observer, operation string, fn func() (T, error)) (T, error) {
+	start := time.Now()
+	v, err := fn()
+	observe(operation, time.Since(start), err)
+	return v, err
+}
+
+// timedErr is timed for operations that only return an error
+func timedErr(observe Observer, operation string, fn func() error) error {
+	_, err := timed(observe, operation, func() (struct{}, error) {
+		return struct{}{}, fn()
+	})
+	return err
+}
+
+// Items returns the timed item repository
+func (s *Storage) Items() repository.ItemRepository {
+	return &itemRepository{next: s.Storage.Items(), observe: s.observe}
+}
+
+// APIKeys returns the timed API key repository
+func (s *Storage) APIKeys() repository.APIKeyRepository {
+	return &apiKeyRepository{next: s.Storage.APIKeys(), observe: s.observe}
+}
+
+// Snapshot returns a copy of all items
+func (s *Storage) Snapshot() ([]model.Item, error) {
+	return timed(s.observe, "snapshot", s.Storage.Snapshot)
+}
+
+// Restore replaces all items
+func (s *Storage) Restore(items []model.Item) error {
+	return timedErr(s.observe, "restore", func() error {
+		return s.Storage.Restore(items)
+	})
+}
+
+// WithTx runs fn in a transaction whose storage is timed like s. The whole
+// transaction is reported as "with_tx", including the time spent in fn.
+func (s *Storage) WithTx(ctx context.Context, fn func(tx repository.Storage) error) error {
+	return timedErr(s.observe, "with_tx", func() error {
+		return s.Storage.WithTx(ctx, func(tx repository.Storage) error {
+			return fn(New(tx, s.observe))
+		})
+	})
+}
+
+// itemRepository times the operations of another item repository
+type itemRepository struct {
+	next    repository.ItemRepository
+	observe Observer
+}
+
+// GetAll returns all items
+func (r *itemRepository) GetAll() ([]model.Item, error) {
+	return timed(r.observe, "items.get_all", r.next.GetAll)
+}
+
+// Get returns an item by ID
+func (r *itemRepository) Get(id int) (model.Item, error) {
+	return timed(r.observe, "items.get", func() (model.Item, error) {
+		return r.next.Get(id)
+	})
+}
+
+// GetByUID returns an item by its UID
+func (r *itemRepository) GetByUID(uid string) (model.Item, error) {
+	return timed(r.observe, "items.get_by_uid", func() (model.Item, error) {
+		return r.next.GetByUID(uid)
+	})
+}
+
+// List returns up to limit items with IDs greater than afterID
+func (r *itemRepository) List(afterID, limit int) ([]model.Item, error) {
+	return timed(r.observe, "items.list", func() ([]model.Item, error) {
+		return r.next.List(afterID, limit)
+	})
+}
+
+// Count returns the number of items
+func (r *itemRepository) Count() (int, error) {
+	return timed(r.observe, "items.count", r.next.Count)
+}
+
+// Create adds a new item
+func (r *itemRepository) Create(item model.Item) (model.Item, error) {
+	return timed(r.observe, "items.create", func() (model.Item, error) {
+		return r.next.Create(item)
+	})
+}
+
+// Update modifies an existing item
+func (r *itemRepository) Update(id int, item model.Item) (model.Item, error) {
+	return timed(r.observe, "items.update", func() (model.Item, error) {
+		return r.next.Update(id, item)
+	})
+}
+
+// Delete removes an item
+func (r *itemRepository) Delete(id int) error {
+	return timedErr(r.observe, "items.delete", func() error {
+		return r.next.Delete(id)
+	})
+}
+
+// Import stores an item as-is
+func (r *itemRepository) Import(item model.Item) error {
+	return timedErr(r.observe, "items.import", func() error {
+		return r.next.Import(item)
+	})
+}
+
+// InitializeSampleData adds sample data, which isn't timed
+func (r *itemRepository) InitializeSampleData() int {
+	return r.next.InitializeSampleData()
+}
+
+// apiKeyRepository times the operations of another API key repository
+type apiKeyRepository struct {
+	next    repository.APIKeyRepository
+	observe Observer
+}
+
+// Create stores a new API key
+func (r *apiKeyRepository) Create(key model.APIKey) (model.APIKey, error) {
+	return timed(r.observe, "api_keys.create", func() (model.APIKey, error) {
+		return r.next.Create(key)
+	})
+}
+
+// GetByPrefix returns the API key with the given prefix
+func (r *apiKeyRepository) GetByPrefix(prefix string) (model.APIKey, error) {
+	return timed(r.observe, "api_keys.get_by_prefix", func() (model.APIKey, error) {
+		return r.next.GetByPrefix(prefix)
+	})
+}
+
+// List returns all API keys
+func (r *apiKeyRepository) List() ([]model.APIKey, error) {
+	return timed(r.observe, "api_keys.list", r.next.List)
+}
+
+// Revoke marks an API key as revoked
+func (r *apiKeyRepository) Revoke(id int) error {
+	return timedErr(r.observe, "api_keys.revoke", func() error {
+		return r.next.Revoke(id)
+	})
+}
@@ -0,0 +1,78 @@
+package instrumented
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/all-in-one/internal/common"
+	"github.com/all-in-one/internal/listing/pkg/model"
+	"github.com/all-in-one/internal/listing/pkg/repository"
+	"github.com/all-in-one/internal/listing/pkg/repository/repotest"
+)
+
+// recorder collects observations
+type recorder struct {
+	mutex sync.Mutex
+	ops   []string
+	errs  []error
+}
+
+func (r *recorder) observe(operation string, d time.Duration, err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.ops = append(r.ops, operation)
+	r.errs = append(r.errs, err)
+}
+
+func newTestStorage(t *testing.T, rec *recorder) *Storage {
+	t.Helper()
+
+	raw, err := repository.NewStorage("memory", "", repository.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { raw.Close() })
+
+	return New(raw, rec.observe)
+}
+
+func TestOperationsAreObserved(t *testing.T) {
+	rec := &recorder{}
+	store := newTestStorage(t, rec)
+
+	created, err := store.Items().Create(model.Item{Title: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Items().Get(created.ID)
+	store.Items().Get(created.ID + 1)
+	store.APIKeys().List()
+	store.WithTx(context.Background(), func(tx repository.Storage) error {
+		_, err := tx.Items().Count()
+		return err
+	})
+
+	want := []string{"items.create", "items.get", "items.get", "api_keys.list", "items.count", "with_tx"}
+	if !slices.Equal(rec.ops, want) {
+		t.Errorf("operations = %v, want %v", rec.ops, want)
+	}
+	if rec.errs[1] != nil || !errors.Is(rec.errs[2], common.ErrNotFound) {
+		t.Errorf("Get errors = %v, %v; want nil, ErrNotFound", rec.errs[1], rec.errs[2])
+	}
+}
+
+func TestConformance(t *testing.T) {
+	repotest.RunItemRepository(t, func(t *testing.T, deps repotest.Deps) repository.ItemRepository {
+		raw, err := repository.NewStorage("memory", "", repository.Options{Clock: deps.Clock, IDs: deps.IDs})
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { raw.Close() })
+
+		return New(raw, (&recorder{}).observe).Items()
+	})
+}
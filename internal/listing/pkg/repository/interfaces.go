@@ -1,6 +1,10 @@
 package repository
 
-import "github.com/all-in-one/internal/listing/pkg/model"
+import (
+	"context"
+
+	"github.com/all-in-one/internal/listing/pkg/model"
+)
 
 // ItemRepository defines the interface for item storage operations
 type ItemRepository interface {
@@ -10,6 +14,16 @@ type ItemRepository interface {
 	// Get returns a listing item by ID
 	Get(id int) (model.Item, error)
 
+	// GetByUID returns a listing item by its public UID
+	GetByUID(uid string) (model.Item, error)
+
+	// List returns up to limit items with IDs greater than afterID, ordered
+	// by ID, for paging through large collections
+	List(afterID, limit int) ([]model.Item, error)
+
+	// Count returns the number of items
+	Count() (int, error)
+
 	// Create adds a new listing item
 	Create(item model.Item) (model.Item, error)
 
@@ -19,15 +33,58 @@ type ItemRepository interface {
 	// Delete removes a listing item
 	Delete(id int) error
 
+	// Import stores an item as-is, keeping its IDs and timestamps and
+	// replacing any existing item with the same ID. Items without a UID
+	// get a new one.
+	Import(item model.Item) error
+
 	// InitializeSampleData adds sample data to the storage
 	InitializeSampleData() int
 }
 
+// APIKeyRepository defines the interface for API key storage operations
+type APIKeyRepository interface {
+	// Create stores a new API key, assigning its ID and creation time
+	Create(key model.APIKey) (model.APIKey, error)
+
+	// GetByPrefix returns the API key with the given prefix, revoked or not
+	GetByPrefix(prefix string) (model.APIKey, error)
+
+	// List returns all API keys, including revoked ones, ordered by ID
+	List() ([]model.APIKey, error)
+
+	// Revoke marks an API key as revoked. Revoking a revoked key keeps the
+	// original revocation time.
+	Revoke(id int) error
+}
+
+// ContextStorage is implemented by storages that make use of the context of
+// the request they serve, such as to trace their operations as part of it
+type ContextStorage interface {
+	// WithContext returns the storage bound to ctx
+	WithContext(ctx context.Context) Storage
+}
+
 // Storage defines the main storage interface that aggregates all repositories
 type Storage interface {
 	// Items returns the item repository
 	Items() ItemRepository
 
+	// APIKeys returns the API key repository
+	APIKeys() APIKeyRepository
+
+	// Snapshot returns a consistent point-in-time copy of all items,
+	// ordered by ID
+	Snapshot() ([]model.Item, error)
+
+	// Restore atomically replaces all items with the given ones, keeping
+	// their IDs and timestamps
+	Restore(items []model.Item) error
+
+	// WithTx runs fn in a transaction that commits if fn returns nil and
+	// rolls back otherwise. fn must only use tx, and not close it.
+	WithTx(ctx context.Context, fn func(tx Storage) error) error
+
 	// Close closes the storage connection
 	Close() error
 }
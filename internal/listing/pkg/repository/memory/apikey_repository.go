@@ -0,0 +1,129 @@
+package memory
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/all-in-one/internal/common"
+	"github.com/all-in-one/internal/listing/pkg/model"
+)
+
+// apiKeyRepository implements the API key repository with in-memory storage
+type apiKeyRepository struct {
+	keys   map[int]model.APIKey
+	lastID int
+	mutex  sync.RWMutex
+	clock  common.Clock
+}
+
+// newAPIKeyRepository creates a new memory-based API key repository
+func newAPIKeyRepository(clock common.Clock) *apiKeyRepository {
+	return &apiKeyRepository{
+		keys:  make(map[int]model.APIKey),
+		clock: clock,
+	}
+}
+
+// Create stores a new API key
+func (r *apiKeyRepository) Create(key model.APIKey) (model.APIKey, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, existing := range r.keys {
+		if existing.Prefix == key.Prefix {
+			return model.APIKey{}, fmt.Errorf("API key prefix %q is already used by key %d", key.Prefix, existing.ID)
+		}
+	}
+
+	r.lastID++
+	key.ID = r.lastID
+	key.CreatedAt = r.clock.Now()
+	key.RevokedAt = nil
+	key.Scopes = slices.Clone(key.Scopes)
+
+	r.keys[key.ID] = key
+	return cloneAPIKey(key), nil
+}
+
+// GetByPrefix returns the API key with the given prefix
+func (r *apiKeyRepository) GetByPrefix(prefix string) (model.APIKey, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for _, key := range r.keys {
+		if key.Prefix == prefix {
+			return cloneAPIKey(key), nil
+		}
+	}
+
+	return model.APIKey{}, common.ErrNotFound
+}
+
+// List returns all API keys, ordered by ID
+func (r *apiKeyRepository) List() ([]model.APIKey, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	keys := make([]model.APIKey, 0, len(r.keys))
+	for _, key := range r.keys {
+		keys = append(keys, cloneAPIKey(key))
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].ID < keys[j].ID
+	})
+
+	return keys, nil
+}
+
+// Revoke marks an API key as revoked
+func (r *apiKeyRepository) Revoke(id int) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	key, exists := r.keys[id]
+	if !exists {
+		return common.ErrNotFound
+	}
+
+	if key.RevokedAt == nil {
+		now := r.clock.Now()
+		key.RevokedAt = &now
+		r.keys[id] = key
+	}
+
+	return nil
+}
+
+// withTx runs fn on a private copy of the repository while holding the
+// write lock, then swaps the copy in if fn succeeds
+func (r *apiKeyRepository) withTx(fn func(tx *apiKeyRepository) error) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	tx := &apiKeyRepository{
+		keys:   maps.Clone(r.keys),
+		lastID: r.lastID,
+		clock:  r.clock,
+	}
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	r.keys = tx.keys
+	r.lastID = tx.lastID
+	return nil
+}
+
+// cloneAPIKey returns a copy of key that shares no memory with it, so
+// callers can't modify stored keys
+func cloneAPIKey(key model.APIKey) model.APIKey {
+	key.Scopes = slices.Clone(key.Scopes)
+	if key.RevokedAt != nil {
+		revokedAt := *key.RevokedAt
+		key.RevokedAt = &revokedAt
+	}
+	return key
+}
@@ -0,0 +1,21 @@
+package memory_test
+
+import (
+	"testing"
+
+	"github.com/all-in-one/internal/listing/pkg/repository"
+	"github.com/all-in-one/internal/listing/pkg/repository/memory"
+	"github.com/all-in-one/internal/listing/pkg/repository/repotest"
+)
+
+func TestConformance(t *testing.T) {
+	repotest.RunItemRepository(t, func(t *testing.T, deps repotest.Deps) repository.ItemRepository {
+		return memory.NewStorage(memory.Options{Clock: deps.Clock, IDs: deps.IDs}).Items()
+	})
+}
+
+func TestAPIKeyConformance(t *testing.T) {
+	repotest.RunAPIKeyRepository(t, func(t *testing.T, deps repotest.Deps) repository.APIKeyRepository {
+		return memory.NewStorage(memory.Options{Clock: deps.Clock, IDs: deps.IDs}).APIKeys()
+	})
+}
@@ -1,8 +1,10 @@
 package memory
 
 import (
+	"fmt"
+	"maps"
+	"sort"
 	"sync"
-	"time"
 
 	"github.com/all-in-one/internal/common"
 	"github.com/all-in-one/internal/listing/pkg/model"
@@ -11,15 +13,21 @@ import (
 // itemRepository implements the item repository with in-memory storage
 type itemRepository struct {
 	items  map[int]model.Item
+	byUID  map[string]int
 	lastID int
 	mutex  sync.RWMutex
+	clock  common.Clock
+	ids    common.IDGenerator
 }
 
 // newItemRepository creates a new memory-based item repository
-func newItemRepository() *itemRepository {
+func newItemRepository(clock common.Clock, ids common.IDGenerator) *itemRepository {
 	return &itemRepository{
 		items:  make(map[int]model.Item),
+		byUID:  make(map[string]int),
 		lastID: 0,
+		clock:  clock,
+		ids:    ids,
 	}
 }
 
@@ -49,19 +57,64 @@ func (r *itemRepository) Get(id int) (model.Item, error) {
 	return item, nil
 }
 
+// GetByUID returns an item by its UID
+func (r *itemRepository) GetByUID(uid string) (model.Item, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	id, exists := r.byUID[uid]
+	if !exists {
+		return model.Item{}, common.ErrNotFound
+	}
+
+	return r.items[id], nil
+}
+
+// List returns up to limit items with IDs greater than afterID, ordered by ID
+func (r *itemRepository) List(afterID, limit int) ([]model.Item, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	items := make([]model.Item, 0)
+	for id, item := range r.items {
+		if id > afterID {
+			items = append(items, item)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].ID < items[j].ID })
+
+	if len(items) > limit {
+		items = items[:limit]
+	}
+
+	return items, nil
+}
+
+// Count returns the number of items
+func (r *itemRepository) Count() (int, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return len(r.items), nil
+}
+
 // Create adds a new item
 func (r *itemRepository) Create(item model.Item) (model.Item, error) {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
-	// Assign ID and timestamps
-	r.lastID++
-	item.ID = r.lastID
-	item.CreatedAt = time.Now()
-	item.UpdatedAt = time.Now()
+	// Assign IDs and timestamps
+	now := r.clock.Now()
+	item.ID = r.lastID + 1
+	item.UID = r.ids.NewID()
+	item.CreatedAt = now
+	item.UpdatedAt = now
 
 	// Store the item
-	r.items[item.ID] = item
+	if err := r.put(item); err != nil {
+		return model.Item{}, err
+	}
+	r.lastID = item.ID
 
 	return item, nil
 }
@@ -76,10 +129,11 @@ func (r *itemRepository) Update(id int, item model.Item) (model.Item, error) {
 		return model.Item{}, common.ErrNotFound
 	}
 
-	// Update item while preserving ID and CreatedAt
+	// Update item while preserving IDs and CreatedAt
 	item.ID = id
+	item.UID = existingItem.UID
 	item.CreatedAt = existingItem.CreatedAt
-	item.UpdatedAt = time.Now()
+	item.UpdatedAt = r.clock.Now()
 
 	r.items[id] = item
 
@@ -91,12 +145,34 @@ func (r *itemRepository) Delete(id int) error {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
-	_, exists := r.items[id]
+	item, exists := r.items[id]
 	if !exists {
 		return common.ErrNotFound
 	}
 
 	delete(r.items, id)
+	delete(r.byUID, item.UID)
+	return nil
+}
+
+// Import stores an item as-is, keeping its IDs and timestamps
+func (r *itemRepository) Import(item model.Item) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if item.UID == "" {
+		item.UID = r.ids.NewID()
+	} else {
+		common.SeedIDs(r.ids, item.UID)
+	}
+
+	if err := r.put(item); err != nil {
+		return err
+	}
+	if item.ID > r.lastID {
+		r.lastID = item.ID
+	}
+
 	return nil
 }
 
@@ -120,13 +196,105 @@ func (r *itemRepository) InitializeSampleData() int {
 		},
 	}
 
+	now := r.clock.Now()
 	for _, item := range sampleItems {
 		r.lastID++
 		item.ID = r.lastID
-		item.CreatedAt = time.Now()
-		item.UpdatedAt = time.Now()
-		r.items[item.ID] = item
+		item.UID = r.ids.NewID()
+		item.CreatedAt = now
+		item.UpdatedAt = now
+		if err := r.put(item); err != nil {
+			return 0
+		}
 	}
 
 	return len(sampleItems)
 }
+
+// put stores item and indexes its UID, dropping the UID of any item it
+// replaces. UIDs are unique, like the other backends enforce. The caller
+// holds the write lock.
+func (r *itemRepository) put(item model.Item) error {
+	if owner, exists := r.byUID[item.UID]; exists && owner != item.ID {
+		return fmt.Errorf("uid %q is already used by item %d", item.UID, owner)
+	}
+
+	if old, exists := r.items[item.ID]; exists {
+		delete(r.byUID, old.UID)
+	}
+	r.items[item.ID] = item
+	r.byUID[item.UID] = item.ID
+	return nil
+}
+
+// snapshot copies all items under a single read lock, ordered by ID
+func (r *itemRepository) snapshot() []model.Item {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	items := make([]model.Item, 0, len(r.items))
+	for _, item := range r.items {
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].ID < items[j].ID })
+
+	return items
+}
+
+// restore swaps in the given items, keeping lastID at or above the highest
+// restored ID so new items never collide with them
+func (r *itemRepository) restore(items []model.Item) error {
+	restored := make(map[int]model.Item, len(items))
+	byUID := make(map[string]int, len(items))
+	for _, item := range items {
+		common.SeedIDs(r.ids, item.UID)
+	}
+	for _, item := range items {
+		if item.UID == "" {
+			item.UID = r.ids.NewID()
+		}
+		restored[item.ID] = item
+	}
+	for id, item := range restored {
+		if owner, exists := byUID[item.UID]; exists {
+			return fmt.Errorf("uid %q is used by items %d and %d", item.UID, owner, id)
+		}
+		byUID[item.UID] = id
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.items = restored
+	r.byUID = byUID
+	for id := range restored {
+		if id > r.lastID {
+			r.lastID = id
+		}
+	}
+	return nil
+}
+
+// withTx runs fn on a private copy of the repository while holding the
+// write lock, then swaps the copy in if fn succeeds. Readers and writers
+// wait for the transaction, and a failed one leaves no trace.
+func (r *itemRepository) withTx(fn func(tx *itemRepository) error) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	tx := &itemRepository{
+		items:  maps.Clone(r.items),
+		byUID:  maps.Clone(r.byUID),
+		lastID: r.lastID,
+		clock:  r.clock,
+		ids:    r.ids,
+	}
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	r.items = tx.items
+	r.byUID = tx.byUID
+	r.lastID = tx.lastID
+	return nil
+}
@@ -1,6 +1,9 @@
 package memory
 
 import (
+	"context"
+
+	"github.com/all-in-one/internal/common"
 	"github.com/all-in-one/internal/listing/pkg/model"
 )
 
@@ -8,27 +11,61 @@ import (
 type ItemRepository interface {
 	GetAll() ([]model.Item, error)
 	Get(id int) (model.Item, error)
+	GetByUID(uid string) (model.Item, error)
+	List(afterID, limit int) ([]model.Item, error)
+	Count() (int, error)
 	Create(item model.Item) (model.Item, error)
 	Update(id int, item model.Item) (model.Item, error)
 	Delete(id int) error
+	Import(item model.Item) error
 	InitializeSampleData() int
 }
 
+// APIKeyRepository defines the interface for API key storage operations (local copy to avoid import cycle)
+type APIKeyRepository interface {
+	Create(key model.APIKey) (model.APIKey, error)
+	GetByPrefix(prefix string) (model.APIKey, error)
+	List() ([]model.APIKey, error)
+	Revoke(id int) error
+}
+
 // Storage defines the main storage interface (local copy to avoid import cycle)
 type Storage interface {
 	Items() ItemRepository
+	APIKeys() APIKeyRepository
+	Snapshot() ([]model.Item, error)
+	Restore(items []model.Item) error
+	WithTx(ctx context.Context, fn func(tx Storage) error) error
 	Close() error
 }
 
+// Options configures the memory storage
+type Options struct {
+	// Clock supplies timestamps. Nil means the system clock.
+	Clock common.Clock
+
+	// IDs generates the UID of new items. Nil means ULIDs.
+	IDs common.IDGenerator
+}
+
 // storage implements Storage with in-memory storage
 type storage struct {
-	itemRepo *itemRepository
+	itemRepo   *itemRepository
+	apiKeyRepo *apiKeyRepository
 }
 
 // NewStorage creates a new memory-based storage
-func NewStorage() Storage {
+func NewStorage(opts Options) Storage {
+	if opts.Clock == nil {
+		opts.Clock = common.SystemClock{}
+	}
+	if opts.IDs == nil {
+		opts.IDs = common.NewULIDs(opts.Clock)
+	}
+
 	return &storage{
-		itemRepo: newItemRepository(),
+		itemRepo:   newItemRepository(opts.Clock, opts.IDs),
+		apiKeyRepo: newAPIKeyRepository(opts.Clock),
 	}
 }
 
@@ -37,6 +74,42 @@ func (s *storage) Items() ItemRepository {
 	return s.itemRepo
 }
 
+// APIKeys returns the API key repository
+func (s *storage) APIKeys() APIKeyRepository {
+	return s.apiKeyRepo
+}
+
+// Snapshot returns a copy of all items, ordered by ID
+func (s *storage) Snapshot() ([]model.Item, error) {
+	return s.itemRepo.snapshot(), nil
+}
+
+// Restore replaces all items with the given ones
+func (s *storage) Restore(items []model.Item) error {
+	return s.itemRepo.restore(items)
+}
+
+// WithTx runs fn against a copy of the storage and publishes its changes
+// only if fn returns nil. fn must use the tx storage it's given; calling
+// back into s would deadlock. Nested transactions get their own copy, so
+// they roll back independently.
+func (s *storage) WithTx(ctx context.Context, fn func(tx Storage) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// Always lock items before API keys, so transactions can't deadlock
+	return s.itemRepo.withTx(func(items *itemRepository) error {
+		return s.apiKeyRepo.withTx(func(keys *apiKeyRepository) error {
+			if err := fn(&storage{itemRepo: items, apiKeyRepo: keys}); err != nil {
+				return err
+			}
+			// Don't commit work the caller has given up on
+			return ctx.Err()
+		})
+	})
+}
+
 // Close closes the storage connection (no-op for memory storage)
 func (s *storage) Close() error {
 	return nil
@@ -0,0 +1,158 @@
+package repotest
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/all-in-one/internal/common"
+	"github.com/all-in-one/internal/listing/pkg/model"
+	"github.com/all-in-one/internal/listing/pkg/repository"
+)
+
+// NewAPIKeyRepository returns an empty API key repository built with deps
+// for a single subtest. It should register any cleanup with t.Cleanup.
+type NewAPIKeyRepository func(t *testing.T, deps Deps) repository.APIKeyRepository
+
+// RunAPIKeyRepository runs the API key conformance suite against the
+// repositories returned by newRepo
+func RunAPIKeyRepository(t *testing.T, newRepo NewAPIKeyRepository) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, repo repository.APIKeyRepository, deps Deps)
+	}{
+		{"CreateAndGetByPrefix", testAPIKeyCreateAndGetByPrefix},
+		{"DuplicatePrefix", testAPIKeyDuplicatePrefix},
+		{"List", testAPIKeyList},
+		{"Revoke", testAPIKeyRevoke},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deps := Deps{
+				Clock: common.NewManualClock(Epoch),
+				IDs:   common.NewSequentialIDs(0),
+			}
+			tt.fn(t, newRepo(t, deps), deps)
+		})
+	}
+}
+
+func testAPIKeyCreateAndGetByPrefix(t *testing.T, repo repository.APIKeyRepository, _ Deps) {
+	created, err := repo.Create(model.APIKey{
+		ID:     99, // ignored
+		Name:   "ci",
+		Prefix: "abcd1234",
+		Hash:   "hash",
+		Scopes: []string{"read", "write"},
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if created.ID != 1 {
+		t.Errorf("Create assigned ID %d, want 1", created.ID)
+	}
+	if !created.CreatedAt.Equal(Epoch) {
+		t.Errorf("Create CreatedAt = %v, want the clock's %v", created.CreatedAt, Epoch)
+	}
+
+	got, err := repo.GetByPrefix("abcd1234")
+	if err != nil {
+		t.Fatalf("GetByPrefix: %v", err)
+	}
+	assertSameAPIKey(t, got, created)
+
+	if _, err := repo.GetByPrefix("missing"); err != common.ErrNotFound {
+		t.Errorf("GetByPrefix of unknown prefix error = %v, want %v", err, common.ErrNotFound)
+	}
+}
+
+func testAPIKeyDuplicatePrefix(t *testing.T, repo repository.APIKeyRepository, _ Deps) {
+	if _, err := repo.Create(model.APIKey{Name: "first", Prefix: "same", Hash: "one", Scopes: []string{"read"}}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := repo.Create(model.APIKey{Name: "second", Prefix: "same", Hash: "two", Scopes: []string{"read"}}); err == nil {
+		t.Error("Create with a used prefix succeeded, want error")
+	}
+
+	if got, _ := repo.GetByPrefix("same"); got.Hash != "one" {
+		t.Errorf("GetByPrefix hash = %q, want the first key's", got.Hash)
+	}
+}
+
+func testAPIKeyList(t *testing.T, repo repository.APIKeyRepository, _ Deps) {
+	keys, err := repo.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(keys) != 0 {
+		t.Fatalf("List on empty repository = %d keys, want 0", len(keys))
+	}
+
+	for _, prefix := range []string{"p1", "p2", "p3"} {
+		if _, err := repo.Create(model.APIKey{Name: prefix, Prefix: prefix, Hash: "h", Scopes: []string{}}); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+
+	keys, err = repo.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	var ids []int
+	for _, key := range keys {
+		ids = append(ids, key.ID)
+	}
+	if !slices.Equal(ids, []int{1, 2, 3}) {
+		t.Errorf("List IDs = %v, want [1 2 3]", ids)
+	}
+	if keys[0].Scopes == nil {
+		t.Error("List returned nil scopes for a key without scopes, want empty")
+	}
+}
+
+func testAPIKeyRevoke(t *testing.T, repo repository.APIKeyRepository, deps Deps) {
+	created, err := repo.Create(model.APIKey{Name: "old", Prefix: "old", Hash: "h", Scopes: []string{"admin"}})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if created.Revoked() {
+		t.Fatal("new key is revoked")
+	}
+
+	deps.Clock.Advance(time.Minute)
+	if err := repo.Revoke(created.ID); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	revokedAt := Epoch.Add(time.Minute)
+
+	got, err := repo.GetByPrefix("old")
+	if err != nil {
+		t.Fatalf("GetByPrefix: %v", err)
+	}
+	if got.RevokedAt == nil || !got.RevokedAt.Equal(revokedAt) {
+		t.Errorf("RevokedAt = %v, want %v", got.RevokedAt, revokedAt)
+	}
+
+	// Revoking again keeps the original time
+	deps.Clock.Advance(time.Minute)
+	if err := repo.Revoke(created.ID); err != nil {
+		t.Fatalf("second Revoke: %v", err)
+	}
+	if got, _ := repo.GetByPrefix("old"); got.RevokedAt == nil || !got.RevokedAt.Equal(revokedAt) {
+		t.Errorf("RevokedAt after second Revoke = %v, want %v", got.RevokedAt, revokedAt)
+	}
+
+	if err := repo.Revoke(42); err != common.ErrNotFound {
+		t.Errorf("Revoke of unknown key error = %v, want %v", err, common.ErrNotFound)
+	}
+}
+
+func assertSameAPIKey(t *testing.T, got, want model.APIKey) {
+	t.Helper()
+
+	if got.ID != want.ID || got.Name != want.Name || got.Prefix != want.Prefix || got.Hash != want.Hash ||
+		!slices.Equal(got.Scopes, want.Scopes) || !got.CreatedAt.Equal(want.CreatedAt) || got.Revoked() != want.Revoked() {
+		t.Errorf("got key %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,100 @@
+package repotest
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/all-in-one/internal/common"
+	"github.com/all-in-one/internal/listing/pkg/model"
+	"github.com/all-in-one/internal/listing/pkg/repository"
+)
+
+// OpenStorage opens the storage kept at path, built with deps. The suite
+// closes it and opens it again to check what survives a restart.
+type OpenStorage func(t *testing.T, path string, deps Deps) repository.Storage
+
+// RunPersistence runs the restart suite against the persistent storages
+// opened by open
+func RunPersistence(t *testing.T, open OpenStorage) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, open func() repository.Storage)
+	}{
+		{"CreateAfterReopen", testCreateAfterReopen},
+		{"CreateAfterRestore", testCreateAfterRestore},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "store")
+			clock := common.NewManualClock(Epoch)
+			// Each open gets a new generator, as a restarted process would
+			tt.fn(t, func() repository.Storage {
+				return open(t, path, Deps{Clock: clock, IDs: common.NewSequentialIDs(0)})
+			})
+		})
+	}
+}
+
+func testCreateAfterReopen(t *testing.T, open func() repository.Storage) {
+	store := open()
+	first := mustCreate(t, store.Items(), "first")
+	second := mustCreate(t, store.Items(), "second")
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	store = open()
+	defer store.Close()
+
+	third, err := store.Items().Create(model.Item{Title: "third"})
+	if err != nil {
+		t.Fatalf("Create after reopening: %v", err)
+	}
+	if third.UID == first.UID || third.UID == second.UID {
+		t.Errorf("UID %q reissued after reopening", third.UID)
+	}
+	if third.ID <= second.ID {
+		t.Errorf("ID %d after reopening, want above %d", third.ID, second.ID)
+	}
+
+	for _, want := range []model.Item{first, second} {
+		got, err := store.Items().GetByUID(want.UID)
+		if err != nil {
+			t.Fatalf("GetByUID(%q) after reopening: %v", want.UID, err)
+		}
+		assertSameItem(t, got, want)
+	}
+}
+
+func testCreateAfterRestore(t *testing.T, open func() repository.Storage) {
+	store := open()
+	defer store.Close()
+
+	if err := store.Restore([]model.Item{
+		{ID: 1, UID: "1", Title: "restored", CreatedAt: Epoch, UpdatedAt: Epoch},
+		{ID: 2, Title: "restored without a UID", CreatedAt: Epoch, UpdatedAt: Epoch},
+	}); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+
+	created, err := store.Items().Create(model.Item{Title: "new"})
+	if err != nil {
+		t.Fatalf("Create after Restore: %v", err)
+	}
+
+	items, err := store.Items().GetAll()
+	if err != nil {
+		t.Fatalf("GetAll: %v", err)
+	}
+	seen := map[string]bool{}
+	for _, item := range items {
+		if seen[item.UID] {
+			t.Errorf("UID %q issued twice", item.UID)
+		}
+		seen[item.UID] = true
+	}
+	if len(items) != 3 || !seen[created.UID] {
+		t.Errorf("GetAll = %+v, want the 2 restored items and %+v", items, created)
+	}
+}
@@ -0,0 +1,543 @@
+// Package repotest provides conformance suites that every ItemRepository and
+// APIKeyRepository implementation runs from its tests, so all backends behave
+// the same. Persistent backends also run RunPersistence, which reopens the
+// store as a restarted process would.
+//
+// A backend wires it up from an external test package, which avoids an
+// import cycle through the repository factory:
+//
+//	func TestConformance(t *testing.T) {
+//		repotest.RunItemRepository(t, func(t *testing.T, deps repotest.Deps) repository.ItemRepository {
+//			return memory.NewStorage(memory.Options{Clock: deps.Clock, IDs: deps.IDs}).Items()
+//		})
+//	}
+package repotest
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/all-in-one/internal/common"
+	"github.com/all-in-one/internal/listing/pkg/model"
+	"github.com/all-in-one/internal/listing/pkg/repository"
+)
+
+// Epoch is the time the suite's clock starts at
+var Epoch = time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC)
+
+// Deps are the dependencies a repository under test must be built with, so
+// the suite can predict the timestamps and UIDs it assigns
+type Deps struct {
+	Clock *common.ManualClock
+	IDs   common.IDGenerator
+}
+
+// NewRepository returns an empty repository built with deps for a single
+// subtest. It should register any cleanup with t.Cleanup.
+type NewRepository func(t *testing.T, deps Deps) repository.ItemRepository
+
+// RunItemRepository runs the conformance suite against the repositories
+// returned by newRepo
+func RunItemRepository(t *testing.T, newRepo NewRepository) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, repo repository.ItemRepository, deps Deps)
+	}{
+		{"CreateAndGet", testCreateAndGet},
+		{"GetAll", testGetAll},
+		{"Update", testUpdate},
+		{"Delete", testDelete},
+		{"NotFound", testNotFound},
+		{"IDAssignment", testIDAssignment},
+		{"UIDAssignment", testUIDAssignment},
+		{"GetByUID", testGetByUID},
+		{"UIDClash", testUIDClash},
+		{"Timestamps", testTimestamps},
+		{"List", testList},
+		{"Count", testCount},
+		{"Import", testImport},
+		{"InitializeSampleData", testInitializeSampleData},
+		{"ConcurrentCreates", testConcurrentCreates},
+		{"ConcurrentUpdates", testConcurrentUpdates},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deps := Deps{
+				Clock: common.NewManualClock(Epoch),
+				IDs:   common.NewSequentialIDs(0),
+			}
+			tt.fn(t, newRepo(t, deps), deps)
+		})
+	}
+}
+
+func testCreateAndGet(t *testing.T, repo repository.ItemRepository, _ Deps) {
+	created, err := repo.Create(model.Item{Title: "Test", Description: "A test item"})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if created.Title != "Test" || created.Description != "A test item" {
+		t.Errorf("Create returned %+v, want the given title and description", created)
+	}
+
+	got, err := repo.Get(created.ID)
+	if err != nil {
+		t.Fatalf("Get(%d): %v", created.ID, err)
+	}
+	assertSameItem(t, got, created)
+
+	// An empty description is kept, not turned into something else
+	bare, err := repo.Create(model.Item{Title: "No description"})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if got, _ := repo.Get(bare.ID); got.Description != "" {
+		t.Errorf("Get description = %q, want empty", got.Description)
+	}
+}
+
+func testGetAll(t *testing.T, repo repository.ItemRepository, _ Deps) {
+	items, err := repo.GetAll()
+	if err != nil {
+		t.Fatalf("GetAll on empty repository: %v", err)
+	}
+	if len(items) != 0 {
+		t.Fatalf("GetAll on empty repository = %d items, want 0", len(items))
+	}
+
+	want := map[int]string{}
+	for _, title := range []string{"a", "b", "c"} {
+		created := mustCreate(t, repo, title)
+		want[created.ID] = title
+	}
+
+	items, err = repo.GetAll()
+	if err != nil {
+		t.Fatalf("GetAll: %v", err)
+	}
+	if len(items) != len(want) {
+		t.Fatalf("GetAll = %d items, want %d", len(items), len(want))
+	}
+	for _, item := range items {
+		if want[item.ID] != item.Title {
+			t.Errorf("GetAll item %d title = %q, want %q", item.ID, item.Title, want[item.ID])
+		}
+	}
+}
+
+func testUpdate(t *testing.T, repo repository.ItemRepository, _ Deps) {
+	created := mustCreate(t, repo, "Original")
+
+	updated, err := repo.Update(created.ID, model.Item{ID: created.ID + 100, Title: "Updated", Description: "Changed"})
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if updated.ID != created.ID {
+		t.Errorf("Update changed ID to %d, want %d", updated.ID, created.ID)
+	}
+	if updated.Title != "Updated" || updated.Description != "Changed" {
+		t.Errorf("Update returned %+v, want the new title and description", updated)
+	}
+
+	got, err := repo.Get(created.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	assertSameItem(t, got, updated)
+
+	if _, err := repo.Get(created.ID + 100); !errors.Is(err, common.ErrNotFound) {
+		t.Errorf("Update created an item under the ID in its argument")
+	}
+}
+
+func testDelete(t *testing.T, repo repository.ItemRepository, _ Deps) {
+	kept := mustCreate(t, repo, "Kept")
+	deleted := mustCreate(t, repo, "Deleted")
+
+	if err := repo.Delete(deleted.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := repo.Get(deleted.ID); !errors.Is(err, common.ErrNotFound) {
+		t.Errorf("Get after Delete error = %v, want ErrNotFound", err)
+	}
+	if _, err := repo.Get(kept.ID); err != nil {
+		t.Errorf("Delete removed another item: %v", err)
+	}
+	if err := repo.Delete(deleted.ID); !errors.Is(err, common.ErrNotFound) {
+		t.Errorf("second Delete error = %v, want ErrNotFound", err)
+	}
+}
+
+func testNotFound(t *testing.T, repo repository.ItemRepository, _ Deps) {
+	if _, err := repo.Get(999); !errors.Is(err, common.ErrNotFound) {
+		t.Errorf("Get error = %v, want ErrNotFound", err)
+	}
+	if _, err := repo.Update(999, model.Item{Title: "x"}); !errors.Is(err, common.ErrNotFound) {
+		t.Errorf("Update error = %v, want ErrNotFound", err)
+	}
+	if err := repo.Delete(999); !errors.Is(err, common.ErrNotFound) {
+		t.Errorf("Delete error = %v, want ErrNotFound", err)
+	}
+}
+
+func testIDAssignment(t *testing.T, repo repository.ItemRepository, _ Deps) {
+	// Callers can't pick IDs through Create
+	first, err := repo.Create(model.Item{ID: 42, Title: "first"})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if first.ID <= 0 {
+		t.Fatalf("Create assigned ID %d, want a positive ID", first.ID)
+	}
+
+	previous := first.ID
+	for i := 0; i < 5; i++ {
+		created := mustCreate(t, repo, fmt.Sprintf("item %d", i))
+		if created.ID <= previous {
+			t.Errorf("Create assigned ID %d after %d, want increasing IDs", created.ID, previous)
+		}
+		previous = created.ID
+	}
+
+	// IDs aren't reused after a delete
+	if err := repo.Delete(previous); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if created := mustCreate(t, repo, "after delete"); created.ID <= previous {
+		t.Errorf("Create after Delete assigned ID %d, want more than %d", created.ID, previous)
+	}
+}
+
+func testUIDAssignment(t *testing.T, repo repository.ItemRepository, _ Deps) {
+	// UIDs come from the generator, not the caller
+	first, err := repo.Create(model.Item{UID: "chosen", Title: "first"})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	second := mustCreate(t, repo, "second")
+	if first.UID != "1" || second.UID != "2" {
+		t.Errorf("Create assigned UIDs %q and %q, want %q and %q from the generator", first.UID, second.UID, "1", "2")
+	}
+
+	if got, _ := repo.Get(first.ID); got.UID != first.UID {
+		t.Errorf("Get UID = %q, want %q", got.UID, first.UID)
+	}
+
+	// Updates keep the UID
+	updated, err := repo.Update(first.ID, model.Item{UID: "changed", Title: "renamed"})
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if updated.UID != first.UID {
+		t.Errorf("Update returned UID %q, want %q", updated.UID, first.UID)
+	}
+	if got, _ := repo.Get(first.ID); got.UID != first.UID {
+		t.Errorf("Get after Update UID = %q, want %q", got.UID, first.UID)
+	}
+}
+
+func testGetByUID(t *testing.T, repo repository.ItemRepository, _ Deps) {
+	created := mustCreate(t, repo, "By UID")
+	mustCreate(t, repo, "Other")
+
+	got, err := repo.GetByUID(created.UID)
+	if err != nil {
+		t.Fatalf("GetByUID: %v", err)
+	}
+	assertSameItem(t, got, created)
+
+	if _, err := repo.GetByUID("missing"); err != common.ErrNotFound {
+		t.Errorf("GetByUID of unknown UID error = %v, want %v", err, common.ErrNotFound)
+	}
+
+	// Imported items without a UID get one from the generator
+	if err := repo.Import(model.Item{ID: 50, Title: "No UID", CreatedAt: Epoch, UpdatedAt: Epoch}); err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	imported, err := repo.Get(50)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if imported.UID == "" {
+		t.Fatal("Import left the UID empty")
+	}
+	if got, err := repo.GetByUID(imported.UID); err != nil || got.ID != 50 {
+		t.Errorf("GetByUID of imported UID = %d, %v, want item 50", got.ID, err)
+	}
+
+	// Deleted items can't be found by UID anymore
+	if err := repo.Delete(created.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := repo.GetByUID(created.UID); err != common.ErrNotFound {
+		t.Errorf("GetByUID after Delete error = %v, want %v", err, common.ErrNotFound)
+	}
+}
+
+func testUIDClash(t *testing.T, repo repository.ItemRepository, _ Deps) {
+	first := mustCreate(t, repo, "First")
+
+	// Importing another item with the same UID fails and changes nothing
+	clash := model.Item{ID: 50, UID: first.UID, Title: "Clash", CreatedAt: Epoch, UpdatedAt: Epoch}
+	if err := repo.Import(clash); err == nil {
+		t.Fatal("Import of a used UID succeeded")
+	}
+	if _, err := repo.Get(50); err != common.ErrNotFound {
+		t.Errorf("Get of the clashing item error = %v, want %v", err, common.ErrNotFound)
+	}
+	if got, err := repo.GetByUID(first.UID); err != nil || got.ID != first.ID {
+		t.Errorf("GetByUID after the clash = %d, %v, want item %d", got.ID, err, first.ID)
+	}
+
+	// Re-importing an item under its own UID is fine
+	first.Title = "Renamed"
+	if err := repo.Import(first); err != nil {
+		t.Errorf("Import of an item's own UID: %v", err)
+	}
+}
+
+func testTimestamps(t *testing.T, repo repository.ItemRepository, deps Deps) {
+	created := mustCreate(t, repo, "Timed")
+	if !created.CreatedAt.Equal(Epoch) || !created.UpdatedAt.Equal(Epoch) {
+		t.Errorf("Create timestamps = %v/%v, want both at the clock's %v", created.CreatedAt, created.UpdatedAt, Epoch)
+	}
+
+	// Timestamps survive a round trip through the backend at full precision
+	got, err := repo.Get(created.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if !got.CreatedAt.Equal(created.CreatedAt) || !got.UpdatedAt.Equal(created.UpdatedAt) {
+		t.Errorf("Get timestamps = %v/%v, want %v/%v", got.CreatedAt, got.UpdatedAt, created.CreatedAt, created.UpdatedAt)
+	}
+
+	// Update keeps CreatedAt, whatever the caller passes, and moves UpdatedAt
+	deps.Clock.Advance(time.Hour)
+	updated, err := repo.Update(created.ID, model.Item{Title: "Retimed", CreatedAt: time.Unix(0, 0)})
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if !updated.CreatedAt.Equal(Epoch) {
+		t.Errorf("Update changed CreatedAt to %v, want %v", updated.CreatedAt, Epoch)
+	}
+	if want := Epoch.Add(time.Hour); !updated.UpdatedAt.Equal(want) {
+		t.Errorf("UpdatedAt = %v after Update, want %v", updated.UpdatedAt, want)
+	}
+	if got, _ := repo.Get(created.ID); !got.UpdatedAt.Equal(updated.UpdatedAt) {
+		t.Errorf("Get UpdatedAt = %v, want %v", got.UpdatedAt, updated.UpdatedAt)
+	}
+}
+
+func testList(t *testing.T, repo repository.ItemRepository, _ Deps) {
+	var ids []int
+	for i := 0; i < 5; i++ {
+		ids = append(ids, mustCreate(t, repo, fmt.Sprintf("item %d", i)).ID)
+	}
+
+	page, err := repo.List(0, 2)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	assertIDs(t, "List(0, 2)", page, ids[:2])
+
+	page, err = repo.List(ids[1], 10)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	assertIDs(t, fmt.Sprintf("List(%d, 10)", ids[1]), page, ids[2:])
+
+	page, err = repo.List(ids[4], 10)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	assertIDs(t, "List past the end", page, nil)
+}
+
+func testCount(t *testing.T, repo repository.ItemRepository, _ Deps) {
+	assertCount := func(want int) {
+		t.Helper()
+		n, err := repo.Count()
+		if err != nil {
+			t.Fatalf("Count: %v", err)
+		}
+		if n != want {
+			t.Errorf("Count = %d, want %d", n, want)
+		}
+	}
+
+	assertCount(0)
+	first := mustCreate(t, repo, "first")
+	mustCreate(t, repo, "second")
+	assertCount(2)
+
+	if err := repo.Delete(first.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	assertCount(1)
+}
+
+func testImport(t *testing.T, repo repository.ItemRepository, _ Deps) {
+	createdAt := time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC)
+	updatedAt := createdAt.Add(time.Hour)
+	imported := model.Item{ID: 50, UID: "imported-uid", Title: "Imported", Description: "As-is", CreatedAt: createdAt, UpdatedAt: updatedAt}
+
+	if err := repo.Import(imported); err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	got, err := repo.Get(50)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	assertSameItem(t, got, imported)
+
+	// Import replaces an existing item
+	imported.Title = "Replaced"
+	if err := repo.Import(imported); err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	if got, _ := repo.Get(50); got.Title != "Replaced" {
+		t.Errorf("Get after second Import title = %q, want %q", got.Title, "Replaced")
+	}
+	if all, _ := repo.GetAll(); len(all) != 1 {
+		t.Errorf("GetAll after re-import = %d items, want 1", len(all))
+	}
+
+	// New items never collide with imported IDs
+	if created := mustCreate(t, repo, "after import"); created.ID <= 50 {
+		t.Errorf("Create after Import assigned ID %d, want more than 50", created.ID)
+	}
+}
+
+func testInitializeSampleData(t *testing.T, repo repository.ItemRepository, _ Deps) {
+	n := repo.InitializeSampleData()
+	if n <= 0 {
+		t.Fatalf("InitializeSampleData on empty repository = %d, want some items", n)
+	}
+
+	items, err := repo.GetAll()
+	if err != nil {
+		t.Fatalf("GetAll: %v", err)
+	}
+	if len(items) != n {
+		t.Errorf("GetAll = %d items, want the %d samples", len(items), n)
+	}
+}
+
+func testConcurrentCreates(t *testing.T, repo repository.ItemRepository, _ Deps) {
+	const workers, perWorker = 8, 10
+
+	var wg sync.WaitGroup
+	ids := make(chan int, workers*perWorker)
+	errs := make(chan error, workers*perWorker)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				created, err := repo.Create(model.Item{Title: fmt.Sprintf("worker %d item %d", w, i)})
+				if err != nil {
+					errs <- err
+					continue
+				}
+				ids <- created.ID
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(ids)
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("concurrent Create: %v", err)
+	}
+
+	seen := map[int]bool{}
+	for id := range ids {
+		if seen[id] {
+			t.Errorf("ID %d assigned twice", id)
+		}
+		seen[id] = true
+	}
+
+	items, err := repo.GetAll()
+	if err != nil {
+		t.Fatalf("GetAll: %v", err)
+	}
+	if len(items) != workers*perWorker {
+		t.Errorf("GetAll = %d items, want %d", len(items), workers*perWorker)
+	}
+}
+
+func testConcurrentUpdates(t *testing.T, repo repository.ItemRepository, _ Deps) {
+	const workers = 8
+
+	created := mustCreate(t, repo, "Contended")
+
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			if _, err := repo.Update(created.ID, model.Item{Title: fmt.Sprintf("worker %d", w)}); err != nil {
+				errs <- err
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("concurrent Update: %v", err)
+	}
+
+	// One of the writes wins outright; nothing is lost or duplicated
+	items, err := repo.GetAll()
+	if err != nil {
+		t.Fatalf("GetAll: %v", err)
+	}
+	if len(items) != 1 || !created.CreatedAt.Equal(items[0].CreatedAt) {
+		t.Errorf("GetAll after concurrent updates = %+v, want the one item with its CreatedAt", items)
+	}
+}
+
+// Helper Functions
+
+func mustCreate(t *testing.T, repo repository.ItemRepository, title string) model.Item {
+	t.Helper()
+
+	created, err := repo.Create(model.Item{Title: title})
+	if err != nil {
+		t.Fatalf("Create(%q): %v", title, err)
+	}
+	return created
+}
+
+// assertSameItem compares items field by field, timestamps by instant since
+// backends differ in the location they return
+func assertSameItem(t *testing.T, got, want model.Item) {
+	t.Helper()
+
+	if got.ID != want.ID || got.UID != want.UID || got.Title != want.Title || got.Description != want.Description ||
+		!got.CreatedAt.Equal(want.CreatedAt) || !got.UpdatedAt.Equal(want.UpdatedAt) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func assertIDs(t *testing.T, what string, items []model.Item, want []int) {
+	t.Helper()
+
+	if len(items) != len(want) {
+		t.Errorf("%s = %d items, want %d", what, len(items), len(want))
+		return
+	}
+	for i, item := range items {
+		if item.ID != want[i] {
+			t.Errorf("%s[%d].ID = %d, want %d", what, i, item.ID, want[i])
+		}
+	}
+}
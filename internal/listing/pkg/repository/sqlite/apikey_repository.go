@@ -0,0 +1,142 @@
+package sqlite
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/all-in-one/internal/common"
+	"github.com/all-in-one/internal/listing/pkg/model"
+)
+
+// apiKeyRepository implements the API key repository with SQLite storage.
+// Scopes are stored as one comma-separated column; scope names never
+// contain commas.
+type apiKeyRepository struct {
+	db    dbtx
+	clock common.Clock
+}
+
+// newAPIKeyRepository creates a new SQLite-based API key repository
+func newAPIKeyRepository(db dbtx, clock common.Clock) *apiKeyRepository {
+	return &apiKeyRepository{db: db, clock: clock}
+}
+
+// withDB returns a copy of the repository running its queries on db
+func (r *apiKeyRepository) withDB(db dbtx) *apiKeyRepository {
+	return &apiKeyRepository{db: db, clock: r.clock}
+}
+
+// Create stores a new API key
+func (r *apiKeyRepository) Create(key model.APIKey) (model.APIKey, error) {
+	key.CreatedAt = r.clock.Now().UTC()
+	key.RevokedAt = nil
+
+	result, err := r.db.Exec(`
+		INSERT INTO api_keys (name, prefix, hash, scopes, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, key.Name, key.Prefix, key.Hash, strings.Join(key.Scopes, ","), key.CreatedAt.UnixNano())
+	if err != nil {
+		return model.APIKey{}, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return model.APIKey{}, err
+	}
+	key.ID = int(id)
+
+	return key, nil
+}
+
+// GetByPrefix returns the API key with the given prefix
+func (r *apiKeyRepository) GetByPrefix(prefix string) (model.APIKey, error) {
+	key, err := scanAPIKey(r.db.QueryRow(`
+		SELECT id, name, prefix, hash, scopes, created_at, revoked_at
+		FROM api_keys
+		WHERE prefix = ?
+	`, prefix))
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return model.APIKey{}, common.ErrNotFound
+		}
+		return model.APIKey{}, err
+	}
+
+	return key, nil
+}
+
+// List returns all API keys, ordered by ID
+func (r *apiKeyRepository) List() ([]model.APIKey, error) {
+	rows, err := r.db.Query(`
+		SELECT id, name, prefix, hash, scopes, created_at, revoked_at
+		FROM api_keys
+		ORDER BY id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []model.APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// Revoke marks an API key as revoked
+func (r *apiKeyRepository) Revoke(id int) error {
+	result, err := r.db.Exec(`
+		UPDATE api_keys
+		SET revoked_at = COALESCE(revoked_at, ?)
+		WHERE id = ?
+	`, r.clock.Now().UnixNano(), id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return common.ErrNotFound
+	}
+
+	return nil
+}
+
+// scanAPIKey reads an API key from a row selected as id, name, prefix,
+// hash, scopes, created_at, revoked_at
+func scanAPIKey(row scanner) (model.APIKey, error) {
+	var key model.APIKey
+	var scopes string
+	var createdAt int64
+	var revokedAt sql.NullInt64
+
+	if err := row.Scan(&key.ID, &key.Name, &key.Prefix, &key.Hash, &scopes, &createdAt, &revokedAt); err != nil {
+		return model.APIKey{}, err
+	}
+
+	key.Scopes = []string{}
+	if scopes != "" {
+		key.Scopes = strings.Split(scopes, ",")
+	}
+	key.CreatedAt = time.Unix(0, createdAt).UTC()
+	if revokedAt.Valid {
+		t := time.Unix(0, revokedAt.Int64).UTC()
+		key.RevokedAt = &t
+	}
+
+	return key, nil
+}
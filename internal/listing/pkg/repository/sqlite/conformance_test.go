@@ -0,0 +1,75 @@
+package sqlite_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/all-in-one/internal/listing/pkg/repository"
+	"github.com/all-in-one/internal/listing/pkg/repository/repotest"
+	"github.com/all-in-one/internal/listing/pkg/repository/sqlite"
+	"github.com/all-in-one/internal/sqlitedb"
+)
+
+func TestConformance(t *testing.T) {
+	for _, driver := range sqlitedb.Drivers() {
+		t.Run(driver, func(t *testing.T) {
+			repotest.RunItemRepository(t, func(t *testing.T, deps repotest.Deps) repository.ItemRepository {
+				store, err := sqlite.NewStorage(filepath.Join(t.TempDir(), "test.db"), sqlite.Options{
+					Connection: sqlitedb.Options{
+						Driver: driver,
+						// Match the configured default; without it modernc
+						// fails concurrent writers immediately
+						BusyTimeout: 5 * time.Second,
+					},
+					Clock: deps.Clock,
+					IDs:   deps.IDs,
+				})
+				if err != nil {
+					t.Fatalf("NewStorage(%s): %v", driver, err)
+				}
+				t.Cleanup(func() { store.Close() })
+
+				return store.Items()
+			})
+		})
+	}
+}
+
+func TestAPIKeyConformance(t *testing.T) {
+	for _, driver := range sqlitedb.Drivers() {
+		t.Run(driver, func(t *testing.T) {
+			repotest.RunAPIKeyRepository(t, func(t *testing.T, deps repotest.Deps) repository.APIKeyRepository {
+				store, err := sqlite.NewStorage(filepath.Join(t.TempDir(), "test.db"), sqlite.Options{
+					Connection: sqlitedb.Options{Driver: driver},
+					Clock:      deps.Clock,
+					IDs:        deps.IDs,
+				})
+				if err != nil {
+					t.Fatalf("NewStorage(%s): %v", driver, err)
+				}
+				t.Cleanup(func() { store.Close() })
+
+				return store.APIKeys()
+			})
+		})
+	}
+}
+
+func TestPersistence(t *testing.T) {
+	for _, driver := range sqlitedb.Drivers() {
+		t.Run(driver, func(t *testing.T) {
+			repotest.RunPersistence(t, func(t *testing.T, path string, deps repotest.Deps) repository.Storage {
+				store, err := repository.NewStorage("sqlite", path, repository.Options{
+					Clock:  deps.Clock,
+					IDs:    deps.IDs,
+					SQLite: sqlitedb.Options{Driver: driver},
+				})
+				if err != nil {
+					t.Fatalf("NewStorage(%s): %v", driver, err)
+				}
+				return store
+			})
+		})
+	}
+}
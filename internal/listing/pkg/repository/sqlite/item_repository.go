@@ -6,23 +6,41 @@ import (
 
 	"github.com/all-in-one/internal/common"
 	"github.com/all-in-one/internal/listing/pkg/model"
-	_ "github.com/mattn/go-sqlite3"
 )
 
-// itemRepository implements the item repository with SQLite storage
+// dbtx is the subset of *sql.DB and *sql.Tx used by the repository, so the
+// same code runs inside and outside a transaction
+type dbtx interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// itemRepository implements the item repository with SQLite storage.
+//
+// Timestamps are stored as INTEGER Unix nanoseconds and always read back in
+// UTC, so they keep full precision and sort numerically.
 type itemRepository struct {
-	db *sql.DB
+	db    dbtx
+	clock common.Clock
+	ids   common.IDGenerator
 }
 
 // newItemRepository creates a new SQLite-based item repository
-func newItemRepository(db *sql.DB) *itemRepository {
-	return &itemRepository{db: db}
+func newItemRepository(db dbtx, clock common.Clock, ids common.IDGenerator) *itemRepository {
+	return &itemRepository{db: db, clock: clock, ids: ids}
+}
+
+// withDB returns a copy of the repository running its queries on db, e.g.
+// a transaction
+func (r *itemRepository) withDB(db dbtx) *itemRepository {
+	return &itemRepository{db: db, clock: r.clock, ids: r.ids}
 }
 
 // GetAll returns all items
 func (r *itemRepository) GetAll() ([]model.Item, error) {
 	rows, err := r.db.Query(`
-		SELECT id, title, description, created_at, updated_at 
+		SELECT id, uid, title, description, created_at, updated_at
 		FROM listing_items
 		ORDER BY id
 	`)
@@ -33,34 +51,26 @@ func (r *itemRepository) GetAll() ([]model.Item, error) {
 
 	var items []model.Item
 	for rows.Next() {
-		var item model.Item
-		var createdAt, updatedAt string
-
-		err := rows.Scan(&item.ID, &item.Title, &item.Description, &createdAt, &updatedAt)
+		item, err := scanItem(rows)
 		if err != nil {
 			return nil, err
 		}
-
-		// Parse timestamps
-		item.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
-		item.UpdatedAt, _ = time.Parse(time.RFC3339, updatedAt)
-
 		items = append(items, item)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
 	return items, nil
 }
 
 // Get returns an item by ID
 func (r *itemRepository) Get(id int) (model.Item, error) {
-	var item model.Item
-	var createdAt, updatedAt string
-
-	err := r.db.QueryRow(`
-		SELECT id, title, description, created_at, updated_at 
+	item, err := scanItem(r.db.QueryRow(`
+		SELECT id, uid, title, description, created_at, updated_at
 		FROM listing_items 
 		WHERE id = ?
-	`, id).Scan(&item.ID, &item.Title, &item.Description, &createdAt, &updatedAt)
+	`, id))
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -69,21 +79,75 @@ func (r *itemRepository) Get(id int) (model.Item, error) {
 		return model.Item{}, err
 	}
 
-	// Parse timestamps
-	item.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
-	item.UpdatedAt, _ = time.Parse(time.RFC3339, updatedAt)
+	return item, nil
+}
+
+// GetByUID returns an item by its UID
+func (r *itemRepository) GetByUID(uid string) (model.Item, error) {
+	item, err := scanItem(r.db.QueryRow(`
+		SELECT id, uid, title, description, created_at, updated_at
+		FROM listing_items
+		WHERE uid = ?
+	`, uid))
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return model.Item{}, common.ErrNotFound
+		}
+		return model.Item{}, err
+	}
 
 	return item, nil
 }
 
+// List returns up to limit items with IDs greater than afterID, ordered by ID
+func (r *itemRepository) List(afterID, limit int) ([]model.Item, error) {
+	rows, err := r.db.Query(`
+		SELECT id, uid, title, description, created_at, updated_at
+		FROM listing_items
+		WHERE id > ?
+		ORDER BY id
+		LIMIT ?
+	`, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []model.Item{}
+	for rows.Next() {
+		item, err := scanItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// Count returns the number of items
+func (r *itemRepository) Count() (int, error) {
+	var count int
+	if err := r.db.QueryRow("SELECT COUNT(*) FROM listing_items").Scan(&count); err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
 // Create adds a new item
 func (r *itemRepository) Create(item model.Item) (model.Item, error) {
-	now := time.Now().Format(time.RFC3339)
+	now := r.clock.Now().UTC()
+	uid := r.ids.NewID()
 
 	result, err := r.db.Exec(`
-		INSERT INTO listing_items (title, description, created_at, updated_at) 
-		VALUES (?, ?, ?, ?)
-	`, item.Title, item.Description, now, now)
+		INSERT INTO listing_items (uid, title, description, created_at, updated_at) 
+		VALUES (?, ?, ?, ?, ?)
+	`, uid, item.Title, item.Description, now.UnixNano(), now.UnixNano())
 
 	if err != nil {
 		return model.Item{}, err
@@ -96,8 +160,9 @@ func (r *itemRepository) Create(item model.Item) (model.Item, error) {
 
 	// Set the returned item with current values
 	item.ID = int(id)
-	item.CreatedAt, _ = time.Parse(time.RFC3339, now)
-	item.UpdatedAt = item.CreatedAt
+	item.UID = uid
+	item.CreatedAt = now
+	item.UpdatedAt = now
 
 	return item, nil
 }
@@ -110,13 +175,13 @@ func (r *itemRepository) Update(id int, item model.Item) (model.Item, error) {
 		return model.Item{}, err
 	}
 
-	now := time.Now().Format(time.RFC3339)
+	now := r.clock.Now().UTC()
 
 	_, err = r.db.Exec(`
 		UPDATE listing_items 
 		SET title = ?, description = ?, updated_at = ? 
 		WHERE id = ?
-	`, item.Title, item.Description, now, id)
+	`, item.Title, item.Description, now.UnixNano(), id)
 
 	if err != nil {
 		return model.Item{}, err
@@ -124,8 +189,9 @@ func (r *itemRepository) Update(id int, item model.Item) (model.Item, error) {
 
 	// Set the returned item with updated values
 	item.ID = id
+	item.UID = existingItem.UID
 	item.CreatedAt = existingItem.CreatedAt
-	item.UpdatedAt, _ = time.Parse(time.RFC3339, now)
+	item.UpdatedAt = now
 
 	return item, nil
 }
@@ -142,6 +208,29 @@ func (r *itemRepository) Delete(id int) error {
 	return err
 }
 
+// Import stores an item as-is, keeping its IDs and timestamps. An upsert
+// rather than INSERT OR REPLACE, so that a UID clash with another item fails
+// instead of silently deleting that item.
+func (r *itemRepository) Import(item model.Item) error {
+	if item.UID == "" {
+		item.UID = r.ids.NewID()
+	} else {
+		common.SeedIDs(r.ids, item.UID)
+	}
+
+	_, err := r.db.Exec(`
+		INSERT INTO listing_items (id, uid, title, description, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			uid = excluded.uid,
+			title = excluded.title,
+			description = excluded.description,
+			created_at = excluded.created_at,
+			updated_at = excluded.updated_at
+	`, item.ID, item.UID, item.Title, item.Description, item.CreatedAt.UnixNano(), item.UpdatedAt.UnixNano())
+	return err
+}
+
 // InitializeSampleData adds sample data to the storage
 func (r *itemRepository) InitializeSampleData() int {
 	// Check if there's already data
@@ -175,3 +264,28 @@ func (r *itemRepository) InitializeSampleData() int {
 
 	return len(sampleItems)
 }
+
+// Helper Functions
+
+// scanner is implemented by *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// scanItem reads an item row, converting the stored Unix nanosecond
+// timestamps back to UTC times
+func scanItem(row scanner) (model.Item, error) {
+	var item model.Item
+	var description sql.NullString
+	var createdAt, updatedAt int64
+
+	if err := row.Scan(&item.ID, &item.UID, &item.Title, &description, &createdAt, &updatedAt); err != nil {
+		return model.Item{}, err
+	}
+
+	item.Description = description.String
+	item.CreatedAt = time.Unix(0, createdAt).UTC()
+	item.UpdatedAt = time.Unix(0, updatedAt).UTC()
+
+	return item, nil
+}
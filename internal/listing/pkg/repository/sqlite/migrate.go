@@ -0,0 +1,263 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/all-in-one/internal/common"
+)
+
+// migrations upgrade the schema one version at a time: migrations[i] moves
+// a database from schema version i to i+1. The current version is kept in
+// PRAGMA user_version, so existing databases only run the steps they lack.
+var migrations = []func(tx *sql.Tx) error{
+	createItemsTable,
+	convertTimestampsToUnixNano,
+	addItemUID,
+	backfillItemUIDs,
+	createAPIKeysTable,
+}
+
+// migrate brings the database schema up to the latest version
+func migrate(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return fmt.Errorf("reading schema version: %w", err)
+	}
+	if version > len(migrations) {
+		return fmt.Errorf("database schema version %d is newer than supported version %d", version, len(migrations))
+	}
+
+	for ; version < len(migrations); version++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+
+		if err := migrations[version](tx); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migrating schema to version %d: %w", version+1, err)
+		}
+
+		// PRAGMA doesn't take bind parameters
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", version+1)); err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// createItemsTable creates the original items table, which stored
+// timestamps as RFC3339 text
+func createItemsTable(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+		CREATE TABLE IF NOT EXISTS listing_items (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			title TEXT NOT NULL,
+			description TEXT,
+			created_at TIMESTAMP,
+			updated_at TIMESTAMP
+		)
+	`)
+	return err
+}
+
+// convertTimestampsToUnixNano rebuilds the items table with created_at and
+// updated_at as INTEGER UTC Unix nanoseconds. Rows whose timestamps can't be
+// parsed abort the migration instead of being silently zeroed.
+func convertTimestampsToUnixNano(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+		CREATE TABLE listing_items_new (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			title TEXT NOT NULL,
+			description TEXT,
+			created_at INTEGER NOT NULL,
+			updated_at INTEGER NOT NULL
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// CAST hides the TIMESTAMP declared type from the driver; go-sqlite3
+	// would otherwise parse the column itself and turn bad values into zero
+	// times before we could reject them
+	rows, err := tx.Query(`
+		SELECT id, title, description, CAST(created_at AS TEXT), CAST(updated_at AS TEXT)
+		FROM listing_items
+		ORDER BY id
+	`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	type legacyRow struct {
+		id                   int
+		title                string
+		description          sql.NullString
+		createdAt, updatedAt int64
+	}
+
+	var converted []legacyRow
+	for rows.Next() {
+		var row legacyRow
+		var createdAt, updatedAt sql.NullString
+
+		if err := rows.Scan(&row.id, &row.title, &row.description, &createdAt, &updatedAt); err != nil {
+			return err
+		}
+
+		created, err := parseLegacyTimestamp(createdAt)
+		if err != nil {
+			return fmt.Errorf("item %d: created_at: %w", row.id, err)
+		}
+		updated, err := parseLegacyTimestamp(updatedAt)
+		if err != nil {
+			return fmt.Errorf("item %d: updated_at: %w", row.id, err)
+		}
+
+		row.createdAt = created.UnixNano()
+		row.updatedAt = updated.UnixNano()
+		converted = append(converted, row)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	for _, row := range converted {
+		_, err := tx.Exec(`
+			INSERT INTO listing_items_new (id, title, description, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?)
+		`, row.id, row.title, row.description, row.createdAt, row.updatedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Carry the AUTOINCREMENT high-water mark over so IDs of deleted items
+	// are never reused
+	var seq sql.NullInt64
+	err = tx.QueryRow("SELECT seq FROM sqlite_sequence WHERE name = 'listing_items'").Scan(&seq)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	if _, err := tx.Exec("DROP TABLE listing_items"); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("ALTER TABLE listing_items_new RENAME TO listing_items"); err != nil {
+		return err
+	}
+
+	if seq.Valid {
+		_, err := tx.Exec("UPDATE sqlite_sequence SET seq = MAX(seq, ?) WHERE name = 'listing_items'", seq.Int64)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`
+			INSERT INTO sqlite_sequence (name, seq)
+			SELECT 'listing_items', ? WHERE NOT EXISTS (SELECT 1 FROM sqlite_sequence WHERE name = 'listing_items')
+		`, seq.Int64)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addItemUID adds the uid column holding each item's generated public
+// identifier. Existing rows start out with an empty uid.
+func addItemUID(tx *sql.Tx) error {
+	_, err := tx.Exec("ALTER TABLE listing_items ADD COLUMN uid TEXT NOT NULL DEFAULT ''")
+	return err
+}
+
+// backfillItemUIDs gives every item without a UID a ULID whose time part is
+// the item's creation time, then makes uid unique so items can be looked up
+// by it
+func backfillItemUIDs(tx *sql.Tx) error {
+	rows, err := tx.Query("SELECT id, created_at FROM listing_items WHERE uid = '' ORDER BY id")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	clock := common.NewManualClock(time.Time{})
+	ulids := common.NewULIDs(clock)
+
+	uids := map[int]string{}
+	for rows.Next() {
+		var id int
+		var createdAt int64
+		if err := rows.Scan(&id, &createdAt); err != nil {
+			return err
+		}
+
+		clock.Set(time.Unix(0, createdAt))
+		uids[id] = ulids.NewID()
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	for id, uid := range uids {
+		if _, err := tx.Exec("UPDATE listing_items SET uid = ? WHERE id = ?", uid, id); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec("CREATE UNIQUE INDEX idx_listing_items_uid ON listing_items (uid)")
+	return err
+}
+
+// createAPIKeysTable creates the table of API keys, looked up by prefix
+func createAPIKeysTable(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+		CREATE TABLE api_keys (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			prefix TEXT NOT NULL UNIQUE,
+			hash TEXT NOT NULL,
+			scopes TEXT NOT NULL,
+			created_at INTEGER NOT NULL,
+			revoked_at INTEGER
+		)
+	`)
+	return err
+}
+
+// legacyTimestampLayouts are the formats a pre-migration timestamp can be
+// stored in: the RFC3339 text we wrote, or SQLite's own datetime formats for
+// rows written by hand
+var legacyTimestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+}
+
+// parseLegacyTimestamp parses a timestamp stored by the text-based schema
+func parseLegacyTimestamp(value sql.NullString) (time.Time, error) {
+	if !value.Valid || value.String == "" {
+		return time.Time{}, fmt.Errorf("missing timestamp")
+	}
+
+	for _, layout := range legacyTimestampLayouts {
+		if t, err := time.Parse(layout, value.String); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("unrecognized timestamp %q", value.String)
+}
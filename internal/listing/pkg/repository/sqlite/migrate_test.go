@@ -0,0 +1,187 @@
+package sqlite
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/all-in-one/internal/listing/pkg/model"
+	"github.com/all-in-one/internal/sqlitedb"
+)
+
+// createLegacyDatabase writes a database in the original text timestamp
+// layout, as created before schema versioning existed
+func createLegacyDatabase(t *testing.T, driver, dbPath string, rows [][]any) {
+	t.Helper()
+
+	db, err := sqlitedb.Open(dbPath, sqlitedb.Options{Driver: driver})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`
+		CREATE TABLE listing_items (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			title TEXT NOT NULL,
+			description TEXT,
+			created_at TIMESTAMP,
+			updated_at TIMESTAMP
+		)
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, row := range rows {
+		_, err := db.Exec("INSERT INTO listing_items (id, title, description, created_at, updated_at) VALUES (?, ?, ?, ?, ?)", row...)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMigrateLegacyDatabase(t *testing.T) {
+	forEachDriver(t, func(t *testing.T, driver string) {
+		dbPath := filepath.Join(t.TempDir(), "legacy.db")
+		createLegacyDatabase(t, driver, dbPath, [][]any{
+			{1, "first", "one", "2024-03-01T10:00:00Z", "2024-03-02T11:30:00+02:00"},
+			{2, "second", nil, "2024-03-05T08:15:00Z", "2024-03-05T08:15:00Z"},
+			{7, "deleted", "", "2024-03-06T00:00:00Z", "2024-03-06T00:00:00Z"},
+		})
+
+		// Leave a gap at the top of the ID range, like a deleted item would
+		db, err := sqlitedb.Open(dbPath, sqlitedb.Options{Driver: driver})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec("DELETE FROM listing_items WHERE id = 7"); err != nil {
+			t.Fatal(err)
+		}
+		db.Close()
+
+		store := openTestStorage(t, driver, dbPath)
+
+		items, err := store.Items().GetAll()
+		if err != nil {
+			t.Fatalf("GetAll: %v", err)
+		}
+		if len(items) != 2 {
+			t.Fatalf("GetAll returned %d items, want 2", len(items))
+		}
+
+		wantCreated := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+		wantUpdated := time.Date(2024, 3, 2, 9, 30, 0, 0, time.UTC)
+		if !items[0].CreatedAt.Equal(wantCreated) || !items[0].UpdatedAt.Equal(wantUpdated) {
+			t.Errorf("item 1 timestamps = %v / %v, want %v / %v", items[0].CreatedAt, items[0].UpdatedAt, wantCreated, wantUpdated)
+		}
+		if items[0].CreatedAt.Location() != time.UTC {
+			t.Errorf("CreatedAt location = %v, want UTC", items[0].CreatedAt.Location())
+		}
+		if items[1].Description != "" {
+			t.Errorf("NULL description read back as %q", items[1].Description)
+		}
+
+		created, err := store.Items().Create(model.Item{Title: "after migration"})
+		if err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		if created.ID != 8 {
+			t.Errorf("new item ID = %d, want 8 (IDs of deleted items must not be reused)", created.ID)
+		}
+
+		var version int
+		if err := store.(*storage).db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+			t.Fatal(err)
+		}
+		if version != len(migrations) {
+			t.Errorf("user_version = %d, want %d", version, len(migrations))
+		}
+	})
+}
+
+func TestMigrateRejectsCorruptTimestamps(t *testing.T) {
+	forEachDriver(t, func(t *testing.T, driver string) {
+		dbPath := filepath.Join(t.TempDir(), "corrupt.db")
+		createLegacyDatabase(t, driver, dbPath, [][]any{
+			{1, "ok", "", "2024-03-01T10:00:00Z", "2024-03-01T10:00:00Z"},
+			{2, "broken", "", "yesterday-ish", "2024-03-01T10:00:00Z"},
+		})
+
+		store, err := NewStorage(dbPath, Options{Connection: sqlitedb.Options{Driver: driver}})
+		if err == nil {
+			store.Close()
+			t.Fatal("NewStorage succeeded on a corrupt timestamp, want error")
+		}
+		if !strings.Contains(err.Error(), "item 2") {
+			t.Errorf("error %q does not identify the corrupt row", err)
+		}
+
+		// The failed migration must leave the database untouched
+		retry, err := NewStorage(dbPath, Options{Connection: sqlitedb.Options{Driver: driver}})
+		if err == nil {
+			retry.Close()
+			t.Error("second NewStorage succeeded, want the same error")
+		}
+	})
+}
+
+func TestTimestampsKeepNanosecondPrecision(t *testing.T) {
+	forEachDriver(t, func(t *testing.T, driver string) {
+		items := openTestStorage(t, driver, filepath.Join(t.TempDir(), "test.db")).Items()
+
+		created, err := items.Create(model.Item{Title: "precise"})
+		if err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+
+		got, err := items.Get(created.ID)
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		if got.CreatedAt != created.CreatedAt || got.UpdatedAt != created.UpdatedAt {
+			t.Errorf("read back %v / %v, want exactly %v / %v", got.CreatedAt, got.UpdatedAt, created.CreatedAt, created.UpdatedAt)
+		}
+		if got.CreatedAt.Location() != time.UTC {
+			t.Errorf("CreatedAt location = %v, want UTC", got.CreatedAt.Location())
+		}
+	})
+}
+
+func TestMigrateBackfillsUIDs(t *testing.T) {
+	forEachDriver(t, func(t *testing.T, driver string) {
+		dbPath := filepath.Join(t.TempDir(), "legacy.db")
+		createLegacyDatabase(t, driver, dbPath, [][]any{
+			{1, "first", "", "2024-03-01T10:00:00Z", "2024-03-01T10:00:00Z"},
+			{2, "second", "", "2024-03-05T08:15:00Z", "2024-03-05T08:15:00Z"},
+		})
+
+		items := openTestStorage(t, driver, dbPath).Items()
+
+		all, err := items.GetAll()
+		if err != nil {
+			t.Fatalf("GetAll: %v", err)
+		}
+		for _, item := range all {
+			if len(item.UID) != 26 {
+				t.Fatalf("item %d UID = %q, want a ULID", item.ID, item.UID)
+			}
+			got, err := items.GetByUID(item.UID)
+			if err != nil || got.ID != item.ID {
+				t.Errorf("GetByUID(%q) = item %d, %v, want item %d", item.UID, got.ID, err, item.ID)
+			}
+		}
+
+		// The ULID time part is the creation time, so UIDs sort like the items
+		if all[0].UID >= all[1].UID {
+			t.Errorf("UIDs %q and %q are not in creation order", all[0].UID, all[1].UID)
+		}
+
+		// The uid column is unique from now on
+		err = items.Import(model.Item{ID: 3, UID: all[0].UID, Title: "clash"})
+		if err == nil {
+			t.Error("Import with another item's UID succeeded, want error")
+		}
+	})
+}
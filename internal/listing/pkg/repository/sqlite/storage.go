@@ -1,67 +1,210 @@
 package sqlite
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
 
+	"github.com/all-in-one/internal/common"
 	"github.com/all-in-one/internal/listing/pkg/model"
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/all-in-one/internal/sqlitedb"
+	"github.com/sirupsen/logrus"
 )
 
 // ItemRepository defines the interface for item storage operations (local copy to avoid import cycle)
 type ItemRepository interface {
 	GetAll() ([]model.Item, error)
 	Get(id int) (model.Item, error)
+	GetByUID(uid string) (model.Item, error)
+	List(afterID, limit int) ([]model.Item, error)
+	Count() (int, error)
 	Create(item model.Item) (model.Item, error)
 	Update(id int, item model.Item) (model.Item, error)
 	Delete(id int) error
+	Import(item model.Item) error
 	InitializeSampleData() int
 }
 
+// APIKeyRepository defines the interface for API key storage operations (local copy to avoid import cycle)
+type APIKeyRepository interface {
+	Create(key model.APIKey) (model.APIKey, error)
+	GetByPrefix(prefix string) (model.APIKey, error)
+	List() ([]model.APIKey, error)
+	Revoke(id int) error
+}
+
 // Storage defines the main storage interface (local copy to avoid import cycle)
 type Storage interface {
 	Items() ItemRepository
+	APIKeys() APIKeyRepository
+	Snapshot() ([]model.Item, error)
+	Restore(items []model.Item) error
+	WithTx(ctx context.Context, fn func(tx Storage) error) error
 	Close() error
 }
 
 // storage implements Storage with SQLite storage
 type storage struct {
-	db       *sql.DB
-	itemRepo *itemRepository
+	db         *sqlitedb.DB
+	itemRepo   *itemRepository
+	apiKeyRepo *apiKeyRepository
+}
+
+// Options configures the SQLite storage
+type Options struct {
+	// Connection holds the driver, pragmas and pool settings
+	Connection sqlitedb.Options
+
+	// Clock supplies timestamps. Nil means the system clock.
+	Clock common.Clock
+
+	// IDs generates the UID of new items. Nil means ULIDs.
+	IDs common.IDGenerator
 }
 
 // NewStorage creates a new SQLite-based storage
-func NewStorage(dbPath string) (Storage, error) {
-	db, err := sql.Open("sqlite3", dbPath)
+func NewStorage(dbPath string, opts Options) (Storage, error) {
+	if opts.Clock == nil {
+		opts.Clock = common.SystemClock{}
+	}
+	if opts.IDs == nil {
+		opts.IDs = common.NewULIDs(opts.Clock)
+	}
+
+	db, err := sqlitedb.Open(dbPath, opts.Connection)
 	if err != nil {
 		return nil, err
 	}
 
-	// Create table if it doesn't exist
-	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS listing_items (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			title TEXT NOT NULL,
-			description TEXT,
-			created_at TIMESTAMP,
-			updated_at TIMESTAMP
-		)
-	`)
+	// Create or upgrade the schema
+	if err := migrate(db.DB); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	if err := seedIDs(db.DB, opts.IDs); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	// Report what SQLite actually applied
+	effective, err := db.EffectivePragmas()
 	if err != nil {
 		db.Close()
 		return nil, err
 	}
+	logrus.WithFields(logrus.Fields{
+		"driver":             db.Driver(),
+		"journal_mode":       effective["journal_mode"],
+		"synchronous":        effective["synchronous"],
+		"busy_timeout_ms":    effective["busy_timeout"],
+		"foreign_keys":       effective["foreign_keys"],
+		"max_open_conns":     db.Stats().MaxOpenConnections,
+		"max_idle_conns":     opts.Connection.MaxIdleConns,
+		"conn_max_idle_time": opts.Connection.ConnMaxIdleTime.String(),
+	}).Info("SQLite storage opened")
 
 	return &storage{
-		db:       db,
-		itemRepo: newItemRepository(db),
+		db:         db,
+		itemRepo:   newItemRepository(db.DB, opts.Clock, opts.IDs),
+		apiKeyRepo: newAPIKeyRepository(db.DB, opts.Clock),
 	}, nil
 }
 
+// seedIDs tells ids about the highest numeric UID stored, so that a
+// sequential generator carries on after a restart instead of reissuing UIDs
+func seedIDs(db *sql.DB, ids common.IDGenerator) error {
+	if _, ok := ids.(common.IDSeeder); !ok {
+		return nil
+	}
+
+	var highest sql.NullInt64
+	err := db.QueryRow(`
+		SELECT MAX(CAST(uid AS INTEGER)) FROM listing_items
+		WHERE uid <> '' AND uid NOT GLOB '*[^0-9]*'
+	`).Scan(&highest)
+	if err != nil {
+		return fmt.Errorf("finding the highest UID: %w", err)
+	}
+	if highest.Valid {
+		common.SeedIDs(ids, strconv.FormatInt(highest.Int64, 10))
+	}
+	return nil
+}
+
 // Items returns the item repository
 func (s *storage) Items() ItemRepository {
 	return s.itemRepo
 }
 
+// APIKeys returns the API key repository
+func (s *storage) APIKeys() APIKeyRepository {
+	return s.apiKeyRepo
+}
+
+// Snapshot copies the database with SQLite's online backup API and reads
+// the items back from the copy, so the result is consistent even while
+// writers are active
+func (s *storage) Snapshot() ([]model.Item, error) {
+	dir, err := os.MkdirTemp("", "listing-snapshot-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	snapshotPath := filepath.Join(dir, "snapshot.db")
+	if err := s.db.Backup(context.Background(), snapshotPath); err != nil {
+		return nil, fmt.Errorf("online backup: %w", err)
+	}
+
+	snapshotDB, err := s.db.OpenFile(snapshotPath)
+	if err != nil {
+		return nil, err
+	}
+	defer snapshotDB.Close()
+
+	items, err := s.itemRepo.withDB(snapshotDB).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	if items == nil {
+		items = []model.Item{}
+	}
+
+	return items, nil
+}
+
+// Restore replaces all items in a single transaction
+func (s *storage) Restore(items []model.Item) error {
+	return s.WithTx(context.Background(), func(tx Storage) error {
+		return tx.Restore(items)
+	})
+}
+
+// WithTx runs fn in a database transaction, committing if it returns nil
+// and rolling back otherwise
+func (s *storage) WithTx(ctx context.Context, fn func(tx Storage) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(newTxStorage(tx, s.itemRepo, s.apiKeyRepo)); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DBStats returns the connection pool statistics
+func (s *storage) DBStats() sql.DBStats {
+	return s.db.Stats()
+}
+
 // Close closes the database connection
 func (s *storage) Close() error {
 	return s.db.Close()
@@ -0,0 +1,262 @@
+package sqlite
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/all-in-one/internal/common"
+	"github.com/all-in-one/internal/listing/pkg/model"
+	"github.com/all-in-one/internal/sqlitedb"
+)
+
+// forEachDriver runs fn as a subtest against every driver compiled into the
+// test binary, so both drivers are held to the same expectations.
+func forEachDriver(t *testing.T, fn func(t *testing.T, driver string)) {
+	for _, driver := range sqlitedb.Drivers() {
+		t.Run(driver, func(t *testing.T) {
+			fn(t, driver)
+		})
+	}
+}
+
+func openTestStorage(t *testing.T, driver, dbPath string) Storage {
+	t.Helper()
+
+	store, err := NewStorage(dbPath, Options{Connection: sqlitedb.Options{Driver: driver}})
+	if err != nil {
+		t.Fatalf("NewStorage(%s): %v", driver, err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	return store
+}
+
+func TestCreateAndGet(t *testing.T) {
+	forEachDriver(t, func(t *testing.T, driver string) {
+		items := openTestStorage(t, driver, filepath.Join(t.TempDir(), "test.db")).Items()
+
+		for i, title := range []string{"first", "second"} {
+			created, err := items.Create(model.Item{Title: title, Description: "desc"})
+			if err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+			if created.ID != i+1 {
+				t.Errorf("ID = %d, want %d", created.ID, i+1)
+			}
+			if created.CreatedAt.IsZero() || !created.CreatedAt.Equal(created.UpdatedAt) {
+				t.Errorf("timestamps = %v / %v, want equal and non-zero", created.CreatedAt, created.UpdatedAt)
+			}
+
+			got, err := items.Get(created.ID)
+			if err != nil {
+				t.Fatalf("Get: %v", err)
+			}
+			if got.Title != title || got.Description != "desc" || !got.CreatedAt.Equal(created.CreatedAt) {
+				t.Errorf("Get = %+v, want %+v", got, created)
+			}
+		}
+
+		all, err := items.GetAll()
+		if err != nil {
+			t.Fatalf("GetAll: %v", err)
+		}
+		if len(all) != 2 || all[0].Title != "first" || all[1].Title != "second" {
+			t.Errorf("GetAll = %+v, want first and second in ID order", all)
+		}
+	})
+}
+
+func TestUpdateAndDelete(t *testing.T) {
+	forEachDriver(t, func(t *testing.T, driver string) {
+		items := openTestStorage(t, driver, filepath.Join(t.TempDir(), "test.db")).Items()
+
+		created, err := items.Create(model.Item{Title: "before"})
+		if err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+
+		updated, err := items.Update(created.ID, model.Item{Title: "after", Description: "changed"})
+		if err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+		if updated.ID != created.ID || !updated.CreatedAt.Equal(created.CreatedAt) {
+			t.Errorf("Update = %+v, want ID and CreatedAt of %+v", updated, created)
+		}
+
+		got, err := items.Get(created.ID)
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		if got.Title != "after" || got.Description != "changed" {
+			t.Errorf("Get after update = %+v", got)
+		}
+
+		if err := items.Delete(created.ID); err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+		if _, err := items.Get(created.ID); !errors.Is(err, common.ErrNotFound) {
+			t.Errorf("Get after delete error = %v, want ErrNotFound", err)
+		}
+	})
+}
+
+func TestNotFound(t *testing.T) {
+	forEachDriver(t, func(t *testing.T, driver string) {
+		items := openTestStorage(t, driver, filepath.Join(t.TempDir(), "test.db")).Items()
+
+		if _, err := items.Get(42); !errors.Is(err, common.ErrNotFound) {
+			t.Errorf("Get error = %v, want ErrNotFound", err)
+		}
+		if _, err := items.Update(42, model.Item{Title: "x"}); !errors.Is(err, common.ErrNotFound) {
+			t.Errorf("Update error = %v, want ErrNotFound", err)
+		}
+		if err := items.Delete(42); !errors.Is(err, common.ErrNotFound) {
+			t.Errorf("Delete error = %v, want ErrNotFound", err)
+		}
+	})
+}
+
+func TestInitializeSampleData(t *testing.T) {
+	forEachDriver(t, func(t *testing.T, driver string) {
+		items := openTestStorage(t, driver, filepath.Join(t.TempDir(), "test.db")).Items()
+
+		if n := items.InitializeSampleData(); n != 3 {
+			t.Errorf("first InitializeSampleData = %d, want 3", n)
+		}
+		if n := items.InitializeSampleData(); n != 0 {
+			t.Errorf("second InitializeSampleData = %d, want 0", n)
+		}
+	})
+}
+
+func TestConcurrentWrites(t *testing.T) {
+	forEachDriver(t, func(t *testing.T, driver string) {
+		store, err := NewStorage(filepath.Join(t.TempDir(), "test.db"), Options{
+			Connection: sqlitedb.Options{
+				Driver:      driver,
+				JournalMode: "WAL",
+				BusyTimeout: 5 * time.Second,
+			},
+		})
+		if err != nil {
+			t.Fatalf("NewStorage: %v", err)
+		}
+		defer store.Close()
+
+		const writers, perWriter = 8, 10
+		var wg sync.WaitGroup
+		errs := make(chan error, writers*perWriter)
+		for w := 0; w < writers; w++ {
+			wg.Add(1)
+			go func(w int) {
+				defer wg.Done()
+				for i := 0; i < perWriter; i++ {
+					if _, err := store.Items().Create(model.Item{Title: fmt.Sprintf("w%d-%d", w, i)}); err != nil {
+						errs <- err
+					}
+				}
+			}(w)
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			t.Errorf("Create: %v", err)
+		}
+
+		all, err := store.Items().GetAll()
+		if err != nil {
+			t.Fatalf("GetAll: %v", err)
+		}
+		if len(all) != writers*perWriter {
+			t.Errorf("GetAll returned %d items, want %d", len(all), writers*perWriter)
+		}
+	})
+}
+
+func TestSnapshotAndRestore(t *testing.T) {
+	forEachDriver(t, func(t *testing.T, driver string) {
+		source := openTestStorage(t, driver, filepath.Join(t.TempDir(), "source.db"))
+		source.Items().InitializeSampleData()
+		if err := source.Items().Delete(2); err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+
+		snapshot, err := source.Snapshot()
+		if err != nil {
+			t.Fatalf("Snapshot: %v", err)
+		}
+		if len(snapshot) != 2 || snapshot[0].ID != 1 || snapshot[1].ID != 3 {
+			t.Fatalf("Snapshot = %+v, want items 1 and 3", snapshot)
+		}
+
+		target := openTestStorage(t, driver, filepath.Join(t.TempDir(), "target.db"))
+		target.Items().Create(model.Item{Title: "replaced by restore"})
+		if err := target.Restore(snapshot); err != nil {
+			t.Fatalf("Restore: %v", err)
+		}
+
+		restored, err := target.Items().GetAll()
+		if err != nil {
+			t.Fatalf("GetAll: %v", err)
+		}
+		if !reflect.DeepEqual(restored, snapshot) {
+			t.Errorf("restored %+v, want %+v", restored, snapshot)
+		}
+
+		created, err := target.Items().Create(model.Item{Title: "after restore"})
+		if err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		if created.ID != 4 {
+			t.Errorf("new item ID = %d, want 4", created.ID)
+		}
+	})
+}
+
+// TestDriversShareFileFormat checks that a database written by one driver
+// reads back identically through every other driver.
+func TestDriversShareFileFormat(t *testing.T) {
+	drivers := sqlitedb.Drivers()
+	if len(drivers) < 2 {
+		t.Skipf("only %v compiled in", drivers)
+	}
+
+	dbPath := filepath.Join(t.TempDir(), "shared.db")
+
+	writer, err := NewStorage(dbPath, Options{Connection: sqlitedb.Options{Driver: drivers[0]}})
+	if err != nil {
+		t.Fatalf("NewStorage(%s): %v", drivers[0], err)
+	}
+	writer.Items().InitializeSampleData()
+	want, err := writer.Items().GetAll()
+	if err != nil {
+		t.Fatalf("GetAll(%s): %v", drivers[0], err)
+	}
+	writer.Close()
+
+	for _, driver := range drivers[1:] {
+		got, err := openTestStorage(t, driver, dbPath).Items().GetAll()
+		if err != nil {
+			t.Fatalf("GetAll(%s): %v", driver, err)
+		}
+		if !reflect.DeepEqual(normalize(got), normalize(want)) {
+			t.Errorf("%s read %+v, %s wrote %+v", driver, got, drivers[0], want)
+		}
+	}
+}
+
+// normalize strips location data so items compare by instant
+func normalize(items []model.Item) []model.Item {
+	out := make([]model.Item, len(items))
+	for i, item := range items {
+		item.CreatedAt = item.CreatedAt.UTC()
+		item.UpdatedAt = item.UpdatedAt.UTC()
+		out[i] = item
+	}
+	return out
+}
@@ -0,0 +1,93 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/all-in-one/internal/common"
+	"github.com/all-in-one/internal/listing/pkg/model"
+)
+
+// errCloseInTx is returned when Close is called on a transaction's storage
+var errCloseInTx = errors.New("sqlite: Close called on a transaction; return from WithTx instead")
+
+// txStorage implements Storage on top of an open transaction
+type txStorage struct {
+	tx         *sql.Tx
+	itemRepo   *itemRepository
+	apiKeyRepo *apiKeyRepository
+}
+
+// newTxStorage creates a storage whose repositories are copies of items and
+// keys running in tx
+func newTxStorage(tx *sql.Tx, items *itemRepository, keys *apiKeyRepository) *txStorage {
+	return &txStorage{
+		tx:         tx,
+		itemRepo:   items.withDB(tx),
+		apiKeyRepo: keys.withDB(tx),
+	}
+}
+
+// Items returns the item repository bound to the transaction
+func (s *txStorage) Items() ItemRepository {
+	return s.itemRepo
+}
+
+// APIKeys returns the API key repository bound to the transaction
+func (s *txStorage) APIKeys() APIKeyRepository {
+	return s.apiKeyRepo
+}
+
+// Snapshot returns all items as seen by the transaction
+func (s *txStorage) Snapshot() ([]model.Item, error) {
+	items, err := s.itemRepo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	if items == nil {
+		items = []model.Item{}
+	}
+
+	return items, nil
+}
+
+// Restore replaces all items within the transaction. AUTOINCREMENT keeps
+// the ID sequence at or above the highest restored ID.
+func (s *txStorage) Restore(items []model.Item) error {
+	if _, err := s.tx.Exec("DELETE FROM listing_items"); err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		common.SeedIDs(s.itemRepo.ids, item.UID)
+	}
+	for _, item := range items {
+		if item.UID == "" {
+			item.UID = s.itemRepo.ids.NewID()
+		}
+
+		_, err := s.tx.Exec(`
+			INSERT INTO listing_items (id, uid, title, description, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?)
+		`, item.ID, item.UID, item.Title, item.Description, item.CreatedAt.UnixNano(), item.UpdatedAt.UnixNano())
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WithTx joins the enclosing transaction; SQLite has no nested transactions
+func (s *txStorage) WithTx(ctx context.Context, fn func(tx Storage) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return fn(s)
+}
+
+// Close is not allowed on a transaction
+func (s *txStorage) Close() error {
+	return errCloseInTx
+}
@@ -0,0 +1,269 @@
+package traced
+
+import (
+	"context"
+	"errors"
+
+	"github.com/all-in-one/internal/common"
+	"github.com/all-in-one/internal/listing/pkg/model"
+	"github.com/all-in-one/internal/listing/pkg/repository"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.41.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Collection names, for the db.collection.name attribute
+const (
+	itemsCollection   = "listing_items"
+	apiKeysCollection = "api_keys"
+)
+
+// Options configures a traced storage
+type Options struct {
+	// Tracer starts the spans
+	Tracer trace.Tracer
+
+	// System is the db.system.name attribute, e.g. "sqlite"
+	System string
+
+	// Namespace is the db.namespace attribute, e.g. the database file.
+	// Empty leaves it out.
+	Namespace string
+}
+
+// Storage decorates a repository.Storage so that each operation on it, its
+// repositories and its transactions gets a span. Spans are only started as
+// children of a span in the bound context, normally a request's, so work
+// outside requests such as startup and metrics scrapes isn't traced.
+type Storage struct {
+	repository.Storage
+	opts Options
+	ctx  context.Context
+}
+
+// New wraps a storage with tracing. Bind it to a request's context with
+// WithContext.
+func New(store repository.Storage, opts Options) *Storage {
+	return &Storage{Storage: store, opts: opts, ctx: context.Background()}
+}
+
+// WithContext returns the storage with its spans started under ctx
+func (s *Storage) WithContext(ctx context.Context) repository.Storage {
+	return &Storage{Storage: s.Storage, opts: s.opts, ctx: ctx}
+}
+
+// Items returns the traced item repository
+func (s *Storage) Items() repository.ItemRepository {
+	return &itemRepository{next: s.Storage.Items(), storage: s}
+}
+
+// APIKeys returns the traced API key repository
+func (s *Storage) APIKeys() repository.APIKeyRepository {
+	return &apiKeyRepository{next: s.Storage.APIKeys(), storage: s}
+}
+
+// Snapshot returns a copy of all items
+func (s *Storage) Snapshot() ([]model.Item, error) {
+	_, span := s.start(s.ctx, "Snapshot", itemsCollection)
+	items, err := s.Storage.Snapshot()
+	span.SetAttributes(semconv.DBResponseReturnedRows(len(items)))
+	finish(span, err)
+	return items, err
+}
+
+// Restore replaces all items
+func (s *Storage) Restore(items []model.Item) error {
+	_, span := s.start(s.ctx, "Restore", itemsCollection, attribute.Int("listing.items", len(items)))
+	err := s.Storage.Restore(items)
+	finish(span, err)
+	return err
+}
+
+// WithTx runs fn in a transaction under a span of its own, with the spans
+// of the operations fn makes as its children
+func (s *Storage) WithTx(ctx context.Context, fn func(tx repository.Storage) error) error {
+	ctx, span := s.start(ctx, "Transaction", "")
+	err := s.Storage.WithTx(ctx, func(tx repository.Storage) error {
+		return fn(&Storage{Storage: tx, opts: s.opts, ctx: ctx})
+	})
+	finish(span, err)
+	return err
+}
+
+// start starts a client span for operation on collection under ctx, if ctx
+// has a span, and returns a span that does nothing otherwise
+func (s *Storage) start(ctx context.Context, operation, collection string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx, trace.SpanFromContext(ctx)
+	}
+
+	name := operation
+	attrs = append(attrs,
+		semconv.DBSystemNameKey.String(s.opts.System),
+		semconv.DBOperationName(operation),
+	)
+	if collection != "" {
+		name += " " + collection
+		attrs = append(attrs, semconv.DBCollectionName(collection))
+	}
+	if s.opts.Namespace != "" {
+		attrs = append(attrs, semconv.DBNamespace(s.opts.Namespace))
+	}
+
+	return s.opts.Tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+}
+
+// finish ends span, marking it failed if err is. Not found isn't a failure
+// of the storage, so it's only noted.
+func finish(span trace.Span, err error) {
+	switch {
+	case err == nil:
+	case errors.Is(err, common.ErrNotFound):
+		span.SetAttributes(attribute.Bool("listing.not_found", true))
+	default:
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		span.SetAttributes(semconv.ErrorType(err))
+	}
+	span.End()
+}
+
+// itemRepository traces the operations of another item repository
+type itemRepository struct {
+	next    repository.ItemRepository
+	storage *Storage
+}
+
+// start starts the span of an item operation
+func (r *itemRepository) start(operation string, attrs ...attribute.KeyValue) trace.Span {
+	_, span := r.storage.start(r.storage.ctx, operation, itemsCollection, attrs...)
+	return span
+}
+
+// GetAll returns all items
+func (r *itemRepository) GetAll() ([]model.Item, error) {
+	span := r.start("GetAll")
+	items, err := r.next.GetAll()
+	span.SetAttributes(semconv.DBResponseReturnedRows(len(items)))
+	finish(span, err)
+	return items, err
+}
+
+// Get returns an item by ID
+func (r *itemRepository) Get(id int) (model.Item, error) {
+	span := r.start("Get", attribute.Int("listing.item.id", id))
+	item, err := r.next.Get(id)
+	finish(span, err)
+	return item, err
+}
+
+// GetByUID returns an item by its UID
+func (r *itemRepository) GetByUID(uid string) (model.Item, error) {
+	span := r.start("GetByUID", attribute.String("listing.item.uid", uid))
+	item, err := r.next.GetByUID(uid)
+	finish(span, err)
+	return item, err
+}
+
+// List returns up to limit items with IDs greater than afterID
+func (r *itemRepository) List(afterID, limit int) ([]model.Item, error) {
+	span := r.start("List", attribute.Int("listing.page.after", afterID), attribute.Int("listing.page.limit", limit))
+	items, err := r.next.List(afterID, limit)
+	span.SetAttributes(semconv.DBResponseReturnedRows(len(items)))
+	finish(span, err)
+	return items, err
+}
+
+// Count returns the number of items
+func (r *itemRepository) Count() (int, error) {
+	span := r.start("Count")
+	n, err := r.next.Count()
+	finish(span, err)
+	return n, err
+}
+
+// Create adds a new item
+func (r *itemRepository) Create(item model.Item) (model.Item, error) {
+	span := r.start("Create")
+	created, err := r.next.Create(item)
+	if err == nil {
+		span.SetAttributes(attribute.Int("listing.item.id", created.ID))
+	}
+	finish(span, err)
+	return created, err
+}
+
+// Update modifies an existing item
+func (r *itemRepository) Update(id int, item model.Item) (model.Item, error) {
+	span := r.start("Update", attribute.Int("listing.item.id", id))
+	updated, err := r.next.Update(id, item)
+	finish(span, err)
+	return updated, err
+}
+
+// Delete removes an item
+func (r *itemRepository) Delete(id int) error {
+	span := r.start("Delete", attribute.Int("listing.item.id", id))
+	err := r.next.Delete(id)
+	finish(span, err)
+	return err
+}
+
+// Import stores an item as-is
+func (r *itemRepository) Import(item model.Item) error {
+	span := r.start("Import", attribute.Int("listing.item.id", item.ID))
+	err := r.next.Import(item)
+	finish(span, err)
+	return err
+}
+
+// InitializeSampleData adds sample data, which isn't traced
+func (r *itemRepository) InitializeSampleData() int {
+	return r.next.InitializeSampleData()
+}
+
+// apiKeyRepository traces the operations of another API key repository
+type apiKeyRepository struct {
+	next    repository.APIKeyRepository
+	storage *Storage
+}
+
+// start starts the span of an API key operation
+func (r *apiKeyRepository) start(operation string, attrs ...attribute.KeyValue) trace.Span {
+	_, span := r.storage.start(r.storage.ctx, operation, apiKeysCollection, attrs...)
+	return span
+}
+
+// Create stores a new API key
+func (r *apiKeyRepository) Create(key model.APIKey) (model.APIKey, error) {
+	span := r.start("Create")
+	created, err := r.next.Create(key)
+	finish(span, err)
+	return created, err
+}
+
+// GetByPrefix returns the API key with the given prefix
+func (r *apiKeyRepository) GetByPrefix(prefix string) (model.APIKey, error) {
+	span := r.start("GetByPrefix")
+	key, err := r.next.GetByPrefix(prefix)
+	finish(span, err)
+	return key, err
+}
+
+// List returns all API keys
+func (r *apiKeyRepository) List() ([]model.APIKey, error) {
+	span := r.start("List")
+	keys, err := r.next.List()
+	span.SetAttributes(semconv.DBResponseReturnedRows(len(keys)))
+	finish(span, err)
+	return keys, err
+}
+
+// Revoke marks an API key as revoked
+func (r *apiKeyRepository) Revoke(id int) error {
+	span := r.start("Revoke", attribute.Int("listing.api_key.id", id))
+	err := r.next.Revoke(id)
+	finish(span, err)
+	return err
+}
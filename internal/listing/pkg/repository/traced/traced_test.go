@@ -0,0 +1,122 @@
+package traced
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/all-in-one/internal/listing/pkg/model"
+	"github.com/all-in-one/internal/listing/pkg/repository"
+	"github.com/all-in-one/internal/listing/pkg/repository/repotest"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func newTestStorage(t *testing.T, exporter *tracetest.InMemoryExporter) (*Storage, trace.Tracer) {
+	t.Helper()
+
+	raw, err := repository.NewStorage("memory", "", repository.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { raw.Close() })
+
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)).Tracer("test")
+	return New(raw, Options{Tracer: tracer, System: "memory", Namespace: "test.db"}), tracer
+}
+
+// attrs returns the attributes of span by key
+func attrs(span tracetest.SpanStub) map[attribute.Key]attribute.Value {
+	m := map[attribute.Key]attribute.Value{}
+	for _, kv := range span.Attributes {
+		m[kv.Key] = kv.Value
+	}
+	return m
+}
+
+func TestSpansNeedAParent(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	store, _ := newTestStorage(t, exporter)
+
+	if _, err := store.Items().Create(model.Item{Title: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	store.WithContext(context.Background()).Items().GetAll()
+
+	if n := len(exporter.GetSpans()); n != 0 {
+		t.Errorf("got %d spans outside a trace, want 0", n)
+	}
+}
+
+func TestOperationsAreTraced(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	store, tracer := newTestStorage(t, exporter)
+
+	ctx, request := tracer.Start(context.Background(), "request")
+	bound := store.WithContext(ctx)
+	created, err := bound.Items().Create(model.Item{Title: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bound.Items().Get(created.ID + 1)
+	bound.WithTx(ctx, func(tx repository.Storage) error {
+		_, err := tx.Items().List(0, 10)
+		return err
+	})
+	request.End()
+
+	spans := exporter.GetSpans()
+	names := make([]string, len(spans))
+	for i, span := range spans {
+		names[i] = span.Name
+	}
+	want := []string{"Create listing_items", "Get listing_items", "List listing_items", "Transaction", "request"}
+	if !slices.Equal(names, want) {
+		t.Fatalf("spans = %v, want %v", names, want)
+	}
+
+	create, get, list, tx := spans[0], spans[1], spans[2], spans[3]
+	for _, span := range []tracetest.SpanStub{create, get, tx} {
+		if span.Parent.SpanID() != request.SpanContext().SpanID() || span.SpanKind != trace.SpanKindClient {
+			t.Errorf("%s isn't a client span under the request", span.Name)
+		}
+	}
+	if list.Parent.SpanID() != tx.SpanContext.SpanID() {
+		t.Error("the operation in the transaction isn't under the transaction span")
+	}
+
+	a := attrs(create)
+	if a["db.system.name"].AsString() != "memory" || a["db.operation.name"].AsString() != "Create" ||
+		a["db.collection.name"].AsString() != "listing_items" || a["db.namespace"].AsString() != "test.db" {
+		t.Errorf("Create attributes = %v", create.Attributes)
+	}
+	if a["listing.item.id"].AsInt64() != int64(created.ID) {
+		t.Errorf("listing.item.id = %d, want %d", a["listing.item.id"].AsInt64(), created.ID)
+	}
+	if attrs(list)["db.response.returned_rows"].AsInt64() != 1 {
+		t.Errorf("db.response.returned_rows = %d, want 1", attrs(list)["db.response.returned_rows"].AsInt64())
+	}
+
+	// Not found is noted, not a failure
+	if get.Status.Code == codes.Error || !attrs(get)["listing.not_found"].AsBool() {
+		t.Errorf("Get of a missing item: status %v, attributes %v", get.Status.Code, get.Attributes)
+	}
+}
+
+func TestConformance(t *testing.T) {
+	repotest.RunItemRepository(t, func(t *testing.T, deps repotest.Deps) repository.ItemRepository {
+		raw, err := repository.NewStorage("memory", "", repository.Options{Clock: deps.Clock, IDs: deps.IDs})
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { raw.Close() })
+
+		tracer := sdktrace.NewTracerProvider().Tracer("test")
+		ctx, span := tracer.Start(context.Background(), "test")
+		t.Cleanup(func() { span.End() })
+		return New(raw, Options{Tracer: tracer, System: "memory"}).WithContext(ctx).Items()
+	})
+}
@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/all-in-one/internal/listing/pkg/model"
+)
+
+// forEachBackend runs fn as a subtest against a fresh storage of every type
+func forEachBackend(t *testing.T, fn func(t *testing.T, store Storage)) {
+	for _, storageType := range []string{"memory", "sqlite", "bolt"} {
+		t.Run(storageType, func(t *testing.T) {
+			store, err := NewStorage(storageType, filepath.Join(t.TempDir(), "listing.db"), Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { store.Close() })
+
+			fn(t, store)
+		})
+	}
+}
+
+func TestWithTxCommits(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store Storage) {
+		var first, second model.Item
+		err := store.WithTx(context.Background(), func(tx Storage) error {
+			var err error
+			if first, err = tx.Items().Create(model.Item{Title: "first"}); err != nil {
+				return err
+			}
+			if second, err = tx.Items().Create(model.Item{Title: "second"}); err != nil {
+				return err
+			}
+
+			// Writes are visible inside the transaction
+			_, err = tx.Items().Get(first.ID)
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, want := range []model.Item{first, second} {
+			got, err := store.Items().Get(want.ID)
+			if err != nil {
+				t.Fatalf("Get(%d) after commit: %v", want.ID, err)
+			}
+			if got.Title != want.Title {
+				t.Errorf("Get(%d) = %q, want %q", want.ID, got.Title, want.Title)
+			}
+		}
+	})
+}
+
+func TestWithTxRollsBack(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store Storage) {
+		kept, err := store.Items().Create(model.Item{Title: "kept"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		errAbort := errors.New("abort")
+		err = store.WithTx(context.Background(), func(tx Storage) error {
+			if _, err := tx.Items().Create(model.Item{Title: "discarded"}); err != nil {
+				return err
+			}
+			if _, err := tx.Items().Update(kept.ID, model.Item{Title: "changed"}); err != nil {
+				return err
+			}
+			return errAbort
+		})
+		if !errors.Is(err, errAbort) {
+			t.Fatalf("WithTx error = %v, want %v", err, errAbort)
+		}
+
+		items, err := store.Items().GetAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(items) != 1 || items[0].Title != "kept" {
+			t.Errorf("items after rollback = %+v, want only %q unchanged", items, "kept")
+		}
+	})
+}
+
+func TestWithTxCanceledContext(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store Storage) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		called := false
+		err := store.WithTx(ctx, func(tx Storage) error {
+			called = true
+			return nil
+		})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("WithTx error = %v, want context.Canceled", err)
+		}
+		if called {
+			t.Error("fn ran despite a canceled context")
+		}
+	})
+}
+
+func TestDBStats(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store Storage) {
+		store.Items().Count()
+
+		stats, ok := DBStats(store)
+		if sqlite := t.Name() == "TestDBStats/sqlite"; ok != sqlite {
+			t.Fatalf("DBStats ok = %v, want %v", ok, sqlite)
+		}
+		if ok && stats.OpenConnections == 0 {
+			t.Error("DBStats reports no open connections after a query")
+		}
+	})
+}
@@ -1,8 +1,14 @@
 package listing
 
 import (
+	"io"
+
+	"github.com/all-in-one/internal/auth"
+	"github.com/all-in-one/internal/listing/pkg/backup"
 	"github.com/all-in-one/internal/listing/pkg/handler"
 	"github.com/all-in-one/internal/listing/pkg/repository"
+	"github.com/all-in-one/internal/openapi"
+	"github.com/all-in-one/internal/sqlitedb"
 	"github.com/gorilla/mux"
 )
 
@@ -12,30 +18,50 @@ type Service struct {
 	Storage repository.Storage
 }
 
-// NewMemoryService creates a new listing service with in-memory storage
-func NewMemoryService() *Service {
-	store, _ := repository.NewStorage("memory", "")
-	h := handler.NewHandler(store)
-
+// NewService creates a new listing service on top of an existing storage
+func NewService(store repository.Storage) *Service {
 	return &Service{
-		Handler: h,
+		Handler: handler.NewHandler(store),
 		Storage: store,
 	}
 }
 
+// NewMemoryService creates a new listing service with in-memory storage
+func NewMemoryService() *Service {
+	store, _ := repository.NewStorage("memory", "", repository.Options{})
+	return NewService(store)
+}
+
 // NewSQLiteService creates a new listing service with SQLite storage
-func NewSQLiteService(dbPath string) (*Service, error) {
-	store, err := repository.NewStorage("sqlite", dbPath)
+func NewSQLiteService(dbPath string, opts sqlitedb.Options) (*Service, error) {
+	store, err := repository.NewStorage("sqlite", dbPath, repository.Options{SQLite: opts})
 	if err != nil {
 		return nil, err
 	}
 
-	h := handler.NewHandler(store)
+	return NewService(store), nil
+}
 
-	return &Service{
-		Handler: h,
-		Storage: store,
-	}, nil
+// NewBoltService creates a new listing service with bolt storage
+func NewBoltService(dbPath string) (*Service, error) {
+	store, err := repository.NewStorage("bolt", dbPath, repository.Options{})
+	if err != nil {
+		return nil, err
+	}
+
+	return NewService(store), nil
+}
+
+// SetPolicy makes the routes check permissions with policy. Use
+// auth.AllowAll() when authentication is off.
+func (s *Service) SetPolicy(policy *auth.Policy) {
+	s.Handler.SetPolicy(policy)
+}
+
+// SetMaxRestoreSize limits the size of archives uploaded to the restore
+// route
+func (s *Service) SetMaxRestoreSize(bytes int64) {
+	s.Handler.SetMaxRestoreSize(bytes)
 }
 
 // RegisterRoutes registers the listing routes to the given router
@@ -43,6 +69,38 @@ func (s *Service) RegisterRoutes(router *mux.Router) {
 	s.Handler.RegisterRoutes(router)
 }
 
+// RegisterAdminRoutes registers the listing admin routes to the given router
+func (s *Service) RegisterAdminRoutes(router *mux.Router) {
+	s.Handler.RegisterAdminRoutes(router)
+}
+
+// Operations describes the listing and admin routes for the OpenAPI document
+func (s *Service) Operations() openapi.Operations {
+	return s.Handler.Operations()
+}
+
+// Backup writes a backup archive of all items to w
+func (s *Service) Backup(w io.Writer) (backup.Header, error) {
+	items, err := s.Storage.Snapshot()
+	if err != nil {
+		return backup.Header{}, err
+	}
+	return backup.Write(w, items)
+}
+
+// Restore replaces all items with the contents of the backup archive in r.
+// It's meant for local files, so the archive size isn't limited.
+func (s *Service) Restore(r io.Reader) (backup.Header, error) {
+	header, items, err := backup.Read(r, 0)
+	if err != nil {
+		return backup.Header{}, err
+	}
+	if err := s.Storage.Restore(items); err != nil {
+		return backup.Header{}, err
+	}
+	return header, nil
+}
+
 // InitializeSampleData adds sample data to the storage
 func (s *Service) InitializeSampleData() int {
 	return s.Storage.Items().InitializeSampleData()
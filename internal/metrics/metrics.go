@@ -0,0 +1,155 @@
+// Package metrics exposes Prometheus metrics: HTTP requests by route
+// template and status, repository operation timings, connection pool
+// statistics, and the Go runtime and process metrics.
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/all-in-one/internal/common"
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// unmatchedRoute is the route label of requests that match no route, so
+// that scanners probing random paths can't create new series
+const unmatchedRoute = "unmatched"
+
+// repositoryBuckets are the repository timing histogram buckets, finer
+// than the HTTP ones since most operations take well under a millisecond
+var repositoryBuckets = []float64{.0001, .00025, .0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1}
+
+// Metrics is a registry of a service's metrics
+type Metrics struct {
+	registry *prometheus.Registry
+
+	requests   *prometheus.CounterVec
+	duration   *prometheus.HistogramVec
+	inFlight   prometheus.Gauge
+	repository *prometheus.HistogramVec
+}
+
+// New creates a registry with the HTTP and repository metrics, plus the Go
+// runtime, process and build info collectors
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "HTTP requests served, by method, route template and status code.",
+		}, []string{"method", "route", "status"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "Time to serve HTTP requests, by method, route template and status code.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "http_requests_in_flight",
+			Help: "HTTP requests being served.",
+		}),
+		repository: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "repository_operation_duration_seconds",
+			Help:    "Time taken by storage operations, by backend, operation and result (ok, not_found or error).",
+			Buckets: repositoryBuckets,
+		}, []string{"backend", "operation", "result"}),
+	}
+
+	m.registry.MustRegister(
+		m.requests,
+		m.duration,
+		m.inFlight,
+		m.repository,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		collectors.NewBuildInfoCollector(),
+	)
+	return m
+}
+
+// Register adds collectors to the registry
+func (m *Metrics) Register(cs ...prometheus.Collector) error {
+	for _, c := range cs {
+		if err := m.registry.Register(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Handler serves the metrics in the Prometheus exposition format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// Instrument counts and times the requests served by next. Each request is
+// matched against routes for its route template, e.g.
+// "/api/v1/items/{id}", so next is usually routes itself wrapped in
+// handlers such as CORS. Wrapping the whole chain means requests turned
+// away by authentication or rate limiting are counted too.
+func (m *Metrics) Instrument(routes *mux.Router, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		m.inFlight.Inc()
+		defer m.inFlight.Dec()
+
+		rec := common.NewStatusRecorder(w)
+		next.ServeHTTP(rec, r)
+
+		labels := prometheus.Labels{
+			"method": methodLabel(r.Method),
+			"route":  routeLabel(routes, r),
+			"status": strconv.Itoa(rec.Status()),
+		}
+		m.requests.With(labels).Inc()
+		m.duration.With(labels).Observe(time.Since(start).Seconds())
+	})
+}
+
+// ObserveRepository returns a function recording the duration of storage
+// operations on backend, for an instrumented storage
+func (m *Metrics) ObserveRepository(backend string) func(operation string, d time.Duration, err error) {
+	timings := m.repository.MustCurryWith(prometheus.Labels{"backend": backend})
+
+	return func(operation string, d time.Duration, err error) {
+		timings.WithLabelValues(operation, resultLabel(err)).Observe(d.Seconds())
+	}
+}
+
+// routeLabel returns the template of the route r matches
+func routeLabel(routes *mux.Router, r *http.Request) string {
+	var match mux.RouteMatch
+	if !routes.Match(r, &match) || match.Route == nil {
+		return unmatchedRoute
+	}
+	template, err := match.Route.GetPathTemplate()
+	if err != nil {
+		return unmatchedRoute
+	}
+	return template
+}
+
+// methodLabel returns the method, or "other" for nonstandard ones
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodOptions, http.MethodConnect, http.MethodTrace:
+		return method
+	}
+	return "other"
+}
+
+// resultLabel classifies the outcome of a storage operation
+func resultLabel(err error) string {
+	switch {
+	case err == nil:
+		return "ok"
+	case errors.Is(err, common.ErrNotFound):
+		return "not_found"
+	}
+	return "error"
+}
@@ -0,0 +1,162 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/all-in-one/internal/common"
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// scrape returns the metrics served by m
+func scrape(t *testing.T, m *Metrics) string {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("scrape = %d", rec.Code)
+	}
+	return rec.Body.String()
+}
+
+// assertContains checks that the scrape has every line in want
+func assertContains(t *testing.T, body string, want ...string) {
+	t.Helper()
+
+	for _, line := range want {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("metrics lack %q", line)
+		}
+	}
+}
+
+func TestInstrumentLabelsByRouteTemplate(t *testing.T) {
+	m := New()
+	r := mux.NewRouter()
+	r.HandleFunc("/api/v1/items/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if mux.Vars(r)["id"] == "404" {
+			http.NotFound(w, r)
+			return
+		}
+		io.WriteString(w, "ok")
+	}).Methods("GET")
+	r.HandleFunc("/api/v1/empty", func(w http.ResponseWriter, r *http.Request) {}).Methods("POST")
+	handler := m.Instrument(r, r)
+
+	for _, path := range []string{"/api/v1/items/1", "/api/v1/items/2", "/api/v1/items/404", "/random/path"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/api/v1/empty", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("BREW", "/api/v1/empty", nil))
+
+	assertContains(t, scrape(t, m),
+		`http_requests_total{method="GET",route="/api/v1/items/{id}",status="200"} 2`,
+		`http_requests_total{method="GET",route="/api/v1/items/{id}",status="404"} 1`,
+		`http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		`http_requests_total{method="POST",route="/api/v1/empty",status="200"} 1`,
+		`http_requests_total{method="other",route="unmatched",status="405"} 1`,
+		`http_request_duration_seconds_count{method="GET",route="/api/v1/items/{id}",status="200"} 2`,
+		`http_requests_in_flight 0`,
+	)
+}
+
+func TestObserveRepository(t *testing.T) {
+	m := New()
+	observe := m.ObserveRepository("sqlite")
+	observe("items.get", time.Millisecond, nil)
+	observe("items.get", time.Millisecond, fmt.Errorf("get: %w", common.ErrNotFound))
+	observe("items.create", time.Millisecond, errors.New("disk full"))
+
+	assertContains(t, scrape(t, m),
+		`repository_operation_duration_seconds_count{backend="sqlite",operation="items.get",result="ok"} 1`,
+		`repository_operation_duration_seconds_count{backend="sqlite",operation="items.get",result="not_found"} 1`,
+		`repository_operation_duration_seconds_count{backend="sqlite",operation="items.create",result="error"} 1`,
+	)
+}
+
+func TestCollectors(t *testing.T) {
+	m := New()
+	count, err := 7, error(nil)
+	if err := m.Register(
+		NewCountCollector("things", "Things.", func() (int, error) { return count, err }),
+		NewDBStatsCollector("test", func() sql.DBStats {
+			return sql.DBStats{MaxOpenConnections: 4, OpenConnections: 3, InUse: 1, Idle: 2, WaitDuration: 1500 * time.Millisecond}
+		}),
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	body := scrape(t, m)
+	assertContains(t, body,
+		`things 7`,
+		`go_sql_max_open_connections{db_name="test"} 4`,
+		`go_sql_in_use_connections{db_name="test"} 1`,
+		`go_sql_idle_connections{db_name="test"} 2`,
+		`go_sql_wait_duration_seconds_total{db_name="test"} 1.5`,
+	)
+	// The runtime metrics are there too
+	if !strings.Contains(body, "\ngo_goroutines ") {
+		t.Error("metrics lack the Go runtime metrics")
+	}
+
+	// A failed count leaves the gauge out rather than failing the scrape
+	err = errors.New("database is locked")
+	if strings.Contains(scrape(t, m), "things ") {
+		t.Error("gauge reported after a failed count")
+	}
+	if n := testutil.CollectAndCount(NewCountCollector("things", "Things.", func() (int, error) { return 0, err })); n != 0 {
+		t.Errorf("collected %d metrics after a failed count, want 0", n)
+	}
+}
+
+func TestAdminServer(t *testing.T) {
+	m := New()
+	admin, err := m.Listen("127.0.0.1:0", "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- admin.Serve(ctx) }()
+
+	base := "http://" + admin.Addr().String()
+	resp, err := http.Get(base + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), "go_goroutines") {
+		t.Errorf("GET /metrics = %d, without runtime metrics", resp.StatusCode)
+	}
+
+	// Only the metrics are served
+	resp, err = http.Get(base + "/api/v1/items")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("GET /api/v1/items on the admin port = %d, want 404", resp.StatusCode)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Serve = %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Serve didn't return after its context was done")
+	}
+}
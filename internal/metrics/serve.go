@@ -0,0 +1,70 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+)
+
+// adminShutdownTimeout is how long scrapes in progress get to finish when
+// the admin server stops
+const adminShutdownTimeout = 5 * time.Second
+
+// AdminServer serves the metrics on their own address, apart from the API,
+// so they can be firewalled separately and scraped without credentials
+type AdminServer struct {
+	http *http.Server
+	ln   net.Listener
+}
+
+// Listen opens addr for serving m's metrics on path. Listening happens now
+// so that a taken port fails startup rather than a background worker.
+func (m *Metrics) Listen(addr, path string) (*AdminServer, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("GET "+path, m.Handler())
+
+	return &AdminServer{
+		http: &http.Server{
+			Handler:           mux,
+			ReadHeaderTimeout: 5 * time.Second,
+		},
+		ln: ln,
+	}, nil
+}
+
+// Addr returns the address the server listens on
+func (s *AdminServer) Addr() net.Addr {
+	return s.ln.Addr()
+}
+
+// Serve serves until ctx is done. It's meant to run as a server worker, so
+// the metrics stay available while API requests drain.
+func (s *AdminServer) Serve(ctx context.Context) error {
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- s.http.Serve(s.ln)
+	}()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), adminShutdownTimeout)
+	defer cancel()
+	if err := s.http.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-serveErr; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,310 @@
+// Package openapi generates OpenAPI 3.1 documents from a mux router: the
+// paths and methods come from the registered routes, and everything else
+// from the Operation each route's name maps to.
+package openapi
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/all-in-one/internal/common"
+	"github.com/gorilla/mux"
+)
+
+// Version is the OpenAPI version of generated documents
+const Version = "3.1.0"
+
+// Operation describes the route with the same name. Only what the route
+// table can't tell is given here.
+type Operation struct {
+	Summary     string
+	Description string
+	Tags        []string
+
+	// Permission is the permission the route requires, if any
+	Permission string
+
+	// Security names the security schemes that authenticate the route.
+	// Empty means it's public.
+	Security []string
+
+	// Request is a value of the JSON request body's type, or nil for no
+	// body. RequestType is the media type of a body that isn't JSON.
+	Request     interface{}
+	RequestType string
+
+	// Response is a value of the type of the Data sent in the success
+	// Response, or nil for none. ResponseType is the media type of a
+	// response that isn't a Response.
+	Response     interface{}
+	ResponseType string
+
+	// Status is the success status. Zero means 200.
+	Status int
+
+	// Errors are statuses the route can fail with besides the ones implied
+	// by the rest of the Operation
+	Errors []int
+
+	// Params describes the path variables, by name
+	Params map[string]string
+
+	// Query are the optional query parameters
+	Query []Parameter
+}
+
+// Operations are Operations by route name
+type Operations map[string]Operation
+
+// SecurityScheme is an OpenAPI security scheme
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Description  string `json:"description,omitempty"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	In           string `json:"in,omitempty"`
+	Name         string `json:"name,omitempty"`
+}
+
+// Options configures the generated document
+type Options struct {
+	Title       string
+	Version     string
+	Description string
+
+	// SecuritySchemes are the schemes Operation.Security refers to
+	SecuritySchemes map[string]SecurityScheme
+}
+
+// Document is an OpenAPI document
+type Document struct {
+	OpenAPI    string                        `json:"openapi"`
+	Info       Info                          `json:"info"`
+	Paths      map[string]map[string]*OpSpec `json:"paths"`
+	Components Components                    `json:"components"`
+}
+
+// Info is the document's metadata
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// Components are the schemas, responses and security schemes operations
+// refer to
+type Components struct {
+	Schemas         map[string]Schema         `json:"schemas"`
+	Responses       map[string]*Response      `json:"responses,omitempty"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// OpSpec is an operation as it appears in the document
+type OpSpec struct {
+	OperationID string                `json:"operationId,omitempty"`
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+// Parameter is an operation parameter
+type Parameter struct {
+	Name        string `json:"name"`
+	In          string `json:"in"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required"`
+	Schema      Schema `json:"schema"`
+}
+
+// RequestBody is an operation's request body
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response is an operation response, or a reference to a shared one
+type Response struct {
+	Ref         string               `json:"$ref,omitempty"`
+	Description string               `json:"description,omitempty"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType is the schema of a body in one media type
+type MediaType struct {
+	Schema Schema `json:"schema"`
+}
+
+// errorResponse is the name of the shared error response
+const errorResponse = "Error"
+
+// pathParamPattern finds the variables in a path template
+var pathParamPattern = regexp.MustCompile(`\{([^}]+)\}`)
+
+// Generate returns the document describing the routes on router. Routes
+// are matched to ops by name; ones without an Operation are still listed.
+func Generate(router *mux.Router, ops Operations, opts Options) (*Document, error) {
+	endpoints, err := Endpoints(router)
+	if err != nil {
+		return nil, fmt.Errorf("walking routes: %w", err)
+	}
+
+	s := newSchemas()
+	doc := &Document{
+		OpenAPI: Version,
+		Info: Info{
+			Title:       opts.Title,
+			Version:     opts.Version,
+			Description: opts.Description,
+		},
+		Paths: map[string]map[string]*OpSpec{},
+		Components: Components{
+			Schemas: s.components,
+			Responses: map[string]*Response{
+				errorResponse: {
+					Description: "Error",
+					Content: map[string]MediaType{
+						"application/json":        {Schema: s.of(common.Response{})},
+						common.ProblemContentType: {Schema: s.of(common.Problem{})},
+					},
+				},
+			},
+			SecuritySchemes: opts.SecuritySchemes,
+		},
+	}
+
+	seen := map[string]bool{}
+	for _, e := range endpoints {
+		op := ops[e.Name]
+		spec := operation(s, e, op)
+		if spec.OperationID != "" && seen[spec.OperationID] {
+			// A route with several methods
+			spec.OperationID += strings.ToUpper(e.Method[:1]) + strings.ToLower(e.Method[1:])
+		}
+		seen[spec.OperationID] = true
+
+		if doc.Paths[e.Path] == nil {
+			doc.Paths[e.Path] = map[string]*OpSpec{}
+		}
+		doc.Paths[e.Path][strings.ToLower(e.Method)] = spec
+	}
+
+	return doc, nil
+}
+
+// operation returns the spec of endpoint e described by op
+func operation(s *schemas, e Endpoint, op Operation) *OpSpec {
+	spec := &OpSpec{
+		OperationID: e.Name,
+		Summary:     op.Summary,
+		Description: op.Description,
+		Tags:        op.Tags,
+		Responses:   map[string]*Response{},
+	}
+	if op.Permission != "" {
+		if spec.Description != "" {
+			spec.Description += "\n\n"
+		}
+		spec.Description += fmt.Sprintf("Requires the `%s` permission.", op.Permission)
+	}
+
+	errors := map[int]bool{http.StatusInternalServerError: true}
+	for _, status := range op.Errors {
+		errors[status] = true
+	}
+
+	for _, m := range pathParamPattern.FindAllStringSubmatch(e.Path, -1) {
+		spec.Parameters = append(spec.Parameters, Parameter{
+			Name:        m[1],
+			In:          "path",
+			Description: op.Params[m[1]],
+			Required:    true,
+			Schema:      Schema{"type": "string"},
+		})
+		errors[http.StatusNotFound] = true
+	}
+
+	for _, p := range op.Query {
+		p.In = "query"
+		spec.Parameters = append(spec.Parameters, p)
+		errors[http.StatusBadRequest] = true
+	}
+
+	switch {
+	case op.RequestType != "":
+		spec.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]MediaType{op.RequestType: {Schema: rawSchema(op.RequestType)}},
+		}
+		errors[http.StatusBadRequest] = true
+	case op.Request != nil:
+		spec.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]MediaType{"application/json": {Schema: s.of(op.Request)}},
+		}
+		errors[http.StatusBadRequest] = true
+	}
+
+	for _, name := range op.Security {
+		spec.Security = append(spec.Security, map[string][]string{name: {}})
+		errors[http.StatusUnauthorized] = true
+	}
+	if op.Permission != "" {
+		errors[http.StatusForbidden] = true
+	}
+
+	status := op.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	spec.Responses[strconv.Itoa(status)] = success(s, op, status)
+
+	statuses := make([]int, 0, len(errors))
+	for status := range errors {
+		statuses = append(statuses, status)
+	}
+	sort.Ints(statuses)
+	for _, status := range statuses {
+		spec.Responses[strconv.Itoa(status)] = &Response{Ref: "#/components/responses/" + errorResponse}
+	}
+
+	return spec
+}
+
+// success returns the success response of op
+func success(s *schemas, op Operation, status int) *Response {
+	response := &Response{Description: http.StatusText(status)}
+
+	switch {
+	case op.ResponseType != "":
+		response.Content = map[string]MediaType{op.ResponseType: {Schema: rawSchema(op.ResponseType)}}
+	case op.Response != nil:
+		// The Response envelope with Data narrowed to the actual type
+		response.Content = map[string]MediaType{"application/json": {Schema: Schema{
+			"allOf": []Schema{
+				s.of(common.Response{}),
+				{"type": "object", "properties": Schema{"data": s.of(op.Response)}},
+			},
+		}}}
+	default:
+		response.Content = map[string]MediaType{"application/json": {Schema: s.of(common.Response{})}}
+	}
+
+	return response
+}
+
+// rawSchema returns the schema of a body in mediaType that isn't described
+// by a Go type
+func rawSchema(mediaType string) Schema {
+	if mediaType == "application/json" || strings.HasSuffix(mediaType, "+json") {
+		return Schema{"type": "object"}
+	}
+	return Schema{"type": "string", "contentMediaType": mediaType}
+}
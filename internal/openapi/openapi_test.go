@@ -0,0 +1,224 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type base struct {
+	ID      int       `json:"id" openapi:"readonly"`
+	Created time.Time `json:"created"`
+}
+
+type thing struct {
+	base
+	Name     string         `json:"name"`
+	Note     *string        `json:"note"`
+	Tags     []string       `json:"tags,omitempty"`
+	Labels   map[string]int `json:"labels,omitempty"`
+	Raw      []byte         `json:"raw,omitempty"`
+	Parent   *thing         `json:"parent,omitempty"`
+	Any      interface{}    `json:"any,omitempty"`
+	Skipped  string         `json:"-"`
+	internal string
+}
+
+func TestSchemaFollowsEncodingJSON(t *testing.T) {
+	s := newSchemas()
+	if got := s.of(thing{}); got["$ref"] != "#/components/schemas/Thing" {
+		t.Fatalf("schema = %v, want a $ref to Thing", got)
+	}
+
+	obj := s.components["Thing"]
+	props := obj["properties"].(Schema)
+
+	var names []string
+	for name := range props {
+		names = append(names, name)
+	}
+	for _, want := range []string{"id", "created", "name", "note", "tags", "labels", "raw", "parent", "any"} {
+		if _, ok := props[want]; !ok {
+			t.Errorf("property %q missing; have %v", want, names)
+		}
+	}
+	if len(props) != 9 {
+		t.Errorf("properties = %v, want 9", names)
+	}
+
+	checks := map[string]Schema{
+		"id":      {"type": "integer", "readOnly": true},
+		"created": {"type": "string", "format": "date-time"},
+		"note":    {"type": []string{"string", "null"}},
+		"tags":    {"type": "array", "items": Schema{"type": "string"}},
+		"labels":  {"type": "object", "additionalProperties": Schema{"type": "integer"}},
+		"raw":     {"type": "string", "contentEncoding": "base64"},
+		"parent":  {"anyOf": []Schema{{"$ref": "#/components/schemas/Thing"}, {"type": "null"}}},
+		"any":     {},
+	}
+	for name, want := range checks {
+		if !reflect.DeepEqual(props[name], want) {
+			t.Errorf("%s = %v, want %v", name, props[name], want)
+		}
+	}
+
+	wantRequired := []string{"id", "created", "name"}
+	if !reflect.DeepEqual(obj["required"], wantRequired) {
+		t.Errorf("required = %v, want %v", obj["required"], wantRequired)
+	}
+}
+
+func testRouter() *mux.Router {
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+	r := mux.NewRouter()
+	r.HandleFunc("/health", ok).Methods("GET").Name("health")
+	api := r.PathPrefix("/api").Subrouter()
+	api.HandleFunc("/things", ok).Methods("POST").Name("createThing")
+	api.HandleFunc("/things/{id:[0-9]+}", ok).Methods("GET").Name("getThing")
+	api.HandleFunc("/things/{id}", ok).Methods("PUT", "PATCH").Name("updateThing")
+	api.HandleFunc("/unnamed", ok).Methods("DELETE")
+	return r
+}
+
+var testOps = Operations{
+	"createThing": {
+		Summary:    "Create a thing",
+		Permission: "things:write",
+		Security:   []string{"bearer"},
+		Request:    thing{},
+		Response:   thing{},
+		Status:     http.StatusCreated,
+		Errors:     []int{http.StatusConflict},
+	},
+	"getThing": {
+		Summary:  "Get a thing",
+		Response: thing{},
+		Params:   map[string]string{"id": "ID of the thing"},
+	},
+}
+
+func TestEndpoints(t *testing.T) {
+	endpoints, err := Endpoints(testRouter())
+	if err != nil {
+		t.Fatalf("Endpoints: %v", err)
+	}
+
+	want := []Endpoint{
+		{Method: "GET", Path: "/health", Name: "health"},
+		{Method: "POST", Path: "/api/things", Name: "createThing"},
+		{Method: "GET", Path: "/api/things/{id}", Name: "getThing"},
+		{Method: "PUT", Path: "/api/things/{id}", Name: "updateThing"},
+		{Method: "PATCH", Path: "/api/things/{id}", Name: "updateThing"},
+		{Method: "DELETE", Path: "/api/unnamed"},
+	}
+	if !reflect.DeepEqual(endpoints, want) {
+		t.Errorf("endpoints = %+v, want %+v", endpoints, want)
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	doc, err := Generate(testRouter(), testOps, Options{Title: "Things", Version: "1"})
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if doc.OpenAPI != Version || doc.Info.Title != "Things" {
+		t.Errorf("header = %q %+v", doc.OpenAPI, doc.Info)
+	}
+
+	create := doc.Paths["/api/things"]["post"]
+	if create == nil {
+		t.Fatalf("POST /api/things missing; paths = %v", doc.Paths)
+	}
+	if create.OperationID != "createThing" || create.Summary != "Create a thing" {
+		t.Errorf("create = %+v", create)
+	}
+	if !strings.Contains(create.Description, "things:write") {
+		t.Errorf("description %q doesn't name the permission", create.Description)
+	}
+	var statuses []string
+	for status := range create.Responses {
+		statuses = append(statuses, status)
+	}
+	for _, want := range []string{"201", "400", "401", "403", "409", "500"} {
+		if create.Responses[want] == nil {
+			t.Errorf("create response %s missing; have %v", want, statuses)
+		}
+	}
+	if create.Responses["200"] != nil || create.Responses["404"] != nil {
+		t.Errorf("create has unexpected responses %v", statuses)
+	}
+	if got := create.RequestBody.Content["application/json"].Schema["$ref"]; got != "#/components/schemas/Thing" {
+		t.Errorf("request schema = %v", got)
+	}
+
+	get := doc.Paths["/api/things/{id}"]["get"]
+	if len(get.Parameters) != 1 || get.Parameters[0].Name != "id" || get.Parameters[0].Description != "ID of the thing" {
+		t.Errorf("get parameters = %+v", get.Parameters)
+	}
+	if get.Responses["404"] == nil || get.Responses["401"] != nil || get.Security != nil {
+		t.Errorf("public get with a path variable: responses %v, security %v", get.Responses, get.Security)
+	}
+
+	// Both methods of one route get distinct operation IDs
+	put, patch := doc.Paths["/api/things/{id}"]["put"], doc.Paths["/api/things/{id}"]["patch"]
+	if put.OperationID != "updateThing" || patch.OperationID != "updateThingPatch" {
+		t.Errorf("operation IDs = %q, %q", put.OperationID, patch.OperationID)
+	}
+
+	// Routes without an Operation are still described
+	if doc.Paths["/api/unnamed"]["delete"] == nil {
+		t.Error("unnamed route missing")
+	}
+
+	for _, name := range []string{"Thing", "Response", "Problem", "FieldError"} {
+		if doc.Components.Schemas[name] == nil {
+			t.Errorf("schema %s missing", name)
+		}
+	}
+
+	if _, err := json.Marshal(doc); err != nil {
+		t.Errorf("Marshal: %v", err)
+	}
+}
+
+func TestSpecRoutes(t *testing.T) {
+	r := testRouter()
+	spec := NewSpec(r, Options{Title: "Things", Version: "1"}, testOps)
+	spec.RegisterRoutes(r, "/api")
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/api/openapi.json", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /api/openapi.json = %d", rec.Code)
+	}
+	var doc Document
+	if err := json.NewDecoder(rec.Body).Decode(&doc); err != nil {
+		t.Fatalf("decoding document: %v", err)
+	}
+	if doc.Paths["/api/openapi.json"]["get"] == nil || doc.Paths["/api/docs"]["get"] == nil {
+		t.Errorf("document doesn't describe its own routes: %v", doc.Paths)
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/api/docs", nil))
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/html") {
+		t.Fatalf("GET /api/docs = %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(rec.Body.String(), `fetch("openapi.json")`) {
+		t.Error("docs page doesn't load the document")
+	}
+
+	endpoints, err := spec.Endpoints()
+	if err != nil {
+		t.Fatalf("Endpoints: %v", err)
+	}
+	if endpoints[1].Summary != "Create a thing" {
+		t.Errorf("endpoint summary = %q", endpoints[1].Summary)
+	}
+}
@@ -0,0 +1,49 @@
+package openapi
+
+import (
+	"regexp"
+
+	"github.com/gorilla/mux"
+)
+
+// Endpoint is one method on one path of a router
+type Endpoint struct {
+	Method string
+	Path   string
+
+	// Name is the mux route name, which Operations are keyed by
+	Name string
+
+	// Summary is the Operation's summary, where known
+	Summary string
+}
+
+// pathVarPattern strips the regexp from mux path variables like {id:[0-9]+}
+var pathVarPattern = regexp.MustCompile(`\{([^}:]+):[^}]*\}`)
+
+// Endpoints returns the endpoints registered on router, in registration
+// order. Subrouter prefixes and routes without methods are left out.
+func Endpoints(router *mux.Router) ([]Endpoint, error) {
+	var endpoints []Endpoint
+	err := router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		if route.GetHandler() == nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			// No method matcher
+			return nil
+		}
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		path = pathVarPattern.ReplaceAllString(path, "{$1}")
+
+		for _, method := range methods {
+			endpoints = append(endpoints, Endpoint{Method: method, Path: path, Name: route.GetName()})
+		}
+		return nil
+	})
+	return endpoints, err
+}
@@ -0,0 +1,194 @@
+package openapi
+
+import (
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Schema is a JSON Schema (2020-12, as used by OpenAPI 3.1) object
+type Schema map[string]interface{}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schemas turns Go types into JSON Schemas. Named structs become components
+// referenced with $ref; everything else is inlined.
+type schemas struct {
+	components map[string]Schema
+	names      map[reflect.Type]string
+}
+
+func newSchemas() *schemas {
+	return &schemas{
+		components: map[string]Schema{},
+		names:      map[reflect.Type]string{},
+	}
+}
+
+// of returns the schema of v's type, or nil if v is nil
+func (s *schemas) of(v interface{}) Schema {
+	if v == nil {
+		return nil
+	}
+	return s.schema(reflect.TypeOf(v))
+}
+
+func (s *schemas) schema(t reflect.Type) Schema {
+	switch {
+	case t == timeType:
+		return Schema{"type": "string", "format": "date-time"}
+	case t.Kind() == reflect.Ptr:
+		return nullable(s.schema(t.Elem()))
+	case t.Kind() == reflect.Struct && t.Name() != "":
+		return Schema{"$ref": "#/components/schemas/" + s.component(t)}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return Schema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return Schema{"type": "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return Schema{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return Schema{"type": "number"}
+	case reflect.String:
+		return Schema{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// encoding/json sends []byte as base64
+			return Schema{"type": "string", "contentEncoding": "base64"}
+		}
+		return Schema{"type": "array", "items": s.schema(t.Elem())}
+	case reflect.Map:
+		return Schema{"type": "object", "additionalProperties": s.schema(t.Elem())}
+	case reflect.Struct:
+		return s.object(t)
+	default:
+		// interface{} and anything else encoding/json can't describe up
+		// front: any value
+		return Schema{}
+	}
+}
+
+// component adds the schema of the named struct t to the components, if
+// it isn't there yet, and returns its name
+func (s *schemas) component(t reflect.Type) string {
+	if name, ok := s.names[t]; ok {
+		return name
+	}
+
+	name := exportedName(t.Name())
+	if _, taken := s.components[name]; taken {
+		// Same name in another package
+		name = exportedName(t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:]) + name
+	}
+
+	// Reserve the name before building, so recursive types refer to it
+	s.names[t] = name
+	s.components[name] = nil
+	s.components[name] = s.object(t)
+	return name
+}
+
+// object returns the schema of struct t, following encoding/json's field
+// rules. Fields without omitempty that aren't pointers are required.
+func (s *schemas) object(t reflect.Type) Schema {
+	properties := Schema{}
+	var required []string
+	s.fields(t, properties, &required)
+
+	schema := Schema{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func (s *schemas) fields(t reflect.Type, properties Schema, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		// Embedded structs without a JSON name are flattened
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				s.fields(ft, properties, required)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		schema := s.schema(f.Type)
+		if hasOption(f.Tag.Get("openapi"), "readonly") {
+			schema = withReadOnly(schema)
+		}
+		properties[name] = schema
+
+		if !hasOption(opts, "omitempty") && f.Type.Kind() != reflect.Ptr {
+			*required = append(*required, name)
+		}
+	}
+}
+
+// nullable returns schema also allowing null
+func nullable(schema Schema) Schema {
+	if typ, ok := schema["type"].(string); ok {
+		out := Schema{}
+		for k, v := range schema {
+			out[k] = v
+		}
+		out["type"] = []string{typ, "null"}
+		return out
+	}
+	return Schema{"anyOf": []Schema{schema, {"type": "null"}}}
+}
+
+// withReadOnly returns schema marked readOnly. A $ref can't take siblings
+// in every tool, so it's wrapped.
+func withReadOnly(schema Schema) Schema {
+	if _, ok := schema["$ref"]; ok {
+		return Schema{"allOf": []Schema{schema}, "readOnly": true}
+	}
+	out := Schema{"readOnly": true}
+	for k, v := range schema {
+		out[k] = v
+	}
+	return out
+}
+
+// hasOption reports whether the comma-separated tag options include opt
+func hasOption(opts, opt string) bool {
+	for _, o := range strings.Split(opts, ",") {
+		if o == opt {
+			return true
+		}
+	}
+	return false
+}
+
+// exportedName upper-cases the first letter of name, so unexported request
+// types get conventional component names
+func exportedName(name string) string {
+	if name == "" {
+		return name
+	}
+	r := []rune(name)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
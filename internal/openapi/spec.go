@@ -0,0 +1,121 @@
+package openapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/all-in-one/internal/common"
+	"github.com/gorilla/mux"
+)
+
+// Route names of the routes Spec.RegisterRoutes adds
+const (
+	RouteSpec = "openapi"
+	RouteDocs = "openapiDocs"
+)
+
+//go:embed docs.html
+var docsPage string
+
+var docsTemplate = template.Must(template.New("docs").Parse(docsPage))
+
+// Spec is the document of a router, generated on first use so that it
+// covers every route registered by then
+type Spec struct {
+	router *mux.Router
+	ops    Operations
+	opts   Options
+
+	once sync.Once
+	doc  *Document
+	err  error
+}
+
+// NewSpec returns the spec of the routes on router, described by ops
+func NewSpec(router *mux.Router, opts Options, ops ...Operations) *Spec {
+	merged := Operations{
+		RouteSpec: {
+			Summary:      "OpenAPI document of this API",
+			Tags:         []string{"docs"},
+			ResponseType: "application/json",
+		},
+		RouteDocs: {
+			Summary:      "API documentation page",
+			Tags:         []string{"docs"},
+			ResponseType: "text/html",
+		},
+	}
+	for _, o := range ops {
+		for name, op := range o {
+			merged[name] = op
+		}
+	}
+
+	return &Spec{router: router, ops: merged, opts: opts}
+}
+
+// Document returns the generated document
+func (s *Spec) Document() (*Document, error) {
+	s.once.Do(func() {
+		s.doc, s.err = Generate(s.router, s.ops, s.opts)
+	})
+	return s.doc, s.err
+}
+
+// Endpoints returns the router's endpoints with their summaries, for
+// printing
+func (s *Spec) Endpoints() ([]Endpoint, error) {
+	endpoints, err := Endpoints(s.router)
+	for i, e := range endpoints {
+		endpoints[i].Summary = s.ops[e.Name].Summary
+	}
+	return endpoints, err
+}
+
+// RegisterRoutes serves the document at prefix/openapi.json and the docs
+// page at prefix/docs
+func (s *Spec) RegisterRoutes(router *mux.Router, prefix string) {
+	router.HandleFunc(prefix+"/openapi.json", s.serveDocument).Methods("GET").Name(RouteSpec)
+	router.HandleFunc(prefix+"/docs", s.serveDocs).Methods("GET").Name(RouteDocs)
+}
+
+// GET /openapi.json - Get the OpenAPI document
+func (s *Spec) serveDocument(w http.ResponseWriter, r *http.Request) {
+	doc, err := s.Document()
+	if err != nil {
+		common.WriteError(w, r, common.Internal("Failed to generate OpenAPI document", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(doc)
+}
+
+// GET /docs - Get the page rendering the OpenAPI document
+func (s *Spec) serveDocs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	docsTemplate.Execute(w, map[string]string{
+		"Title":   s.opts.Title,
+		"SpecURL": "openapi.json",
+	})
+}
+
+// WriteFile writes doc as indented JSON to path, or to stdout if path is
+// empty
+func (d *Document) WriteFile(path string) error {
+	data, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	if path == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
@@ -0,0 +1,56 @@
+package ratelimit
+
+import (
+	"math"
+	"time"
+)
+
+// bucket is a token bucket holding up to burst tokens, refilled at rate
+// tokens per second. It isn't safe for concurrent use; the Limiter locks
+// around it.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// decision is the outcome of taking a token from a bucket
+type decision struct {
+	allowed    bool
+	remaining  int
+	reset      time.Duration // until the bucket is full again
+	retryAfter time.Duration // until the next token, if none was left
+}
+
+// take refills the bucket up to now and takes a token if there is one
+func (b *bucket) take(now time.Time, limit Limit) decision {
+	rate := limit.rate()
+	burst := float64(limit.burst())
+
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(burst, b.tokens+elapsed*rate)
+	}
+	b.last = now
+
+	d := decision{}
+	if b.tokens >= 1 {
+		b.tokens--
+		d.allowed = true
+	} else {
+		d.retryAfter = seconds((1 - b.tokens) / rate)
+	}
+	d.remaining = int(b.tokens)
+	d.reset = seconds((burst - b.tokens) / rate)
+
+	return d
+}
+
+// full reports whether the bucket would be full at now, so dropping it
+// changes nothing
+func (b *bucket) full(now time.Time, limit Limit) bool {
+	return b.tokens+now.Sub(b.last).Seconds()*limit.rate() >= float64(limit.burst())
+}
+
+// seconds converts a float number of seconds to a Duration
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,90 @@
+package ratelimit
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// parsePrefixes parses CIDRs and bare IP addresses
+func parsePrefixes(values []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(values))
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if strings.Contains(v, "/") {
+			prefix, err := netip.ParsePrefix(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %w", v, err)
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+
+		addr, err := netip.ParseAddr(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", v, err)
+		}
+		addr = addr.Unmap()
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+
+	return prefixes, nil
+}
+
+// clientIP returns the address of the client that sent r. X-Forwarded-For
+// and X-Real-IP are only believed when the request comes from a trusted
+// proxy; the forwarded-for chain is walked from the right, skipping other
+// trusted proxies, since anything further left may be made up by the client.
+func (l *Limiter) clientIP(r *http.Request) string {
+	remote := parseIP(r.RemoteAddr)
+	if !remote.IsValid() {
+		return r.RemoteAddr
+	}
+	if !l.trusted(remote) {
+		return remote.String()
+	}
+
+	if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+		hops := strings.Split(strings.Join(forwarded, ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := parseIP(strings.TrimSpace(hops[i]))
+			if !hop.IsValid() {
+				break
+			}
+			if !l.trusted(hop) {
+				return hop.String()
+			}
+		}
+	}
+
+	if realIP := parseIP(r.Header.Get("X-Real-IP")); realIP.IsValid() {
+		return realIP.String()
+	}
+
+	return remote.String()
+}
+
+// trusted reports whether addr is a trusted proxy
+func (l *Limiter) trusted(addr netip.Addr) bool {
+	for _, prefix := range l.trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseIP parses an IP address with or without a port, returning the zero
+// Addr if it can't
+func parseIP(s string) netip.Addr {
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+	addr, err := netip.ParseAddr(strings.Trim(s, "[]"))
+	if err != nil {
+		return netip.Addr{}
+	}
+	return addr.Unmap()
+}
@@ -0,0 +1,48 @@
+package ratelimit
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	limiter, err := New(Options{TrustedProxies: []string{"10.0.0.0/8", "192.0.2.10"}})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  string
+		realIP     string
+		want       string
+	}{
+		{"direct", "203.0.113.5:4000", "", "", "203.0.113.5"},
+		{"untrusted peer's header is ignored", "203.0.113.5:4000", "198.51.100.1", "", "203.0.113.5"},
+		{"trusted proxy", "10.1.2.3:4000", "198.51.100.1", "", "198.51.100.1"},
+		{"chain of proxies", "10.1.2.3:4000", "198.51.100.1, 192.0.2.10, 10.9.9.9", "", "198.51.100.1"},
+		{"spoofed left entries are skipped", "10.1.2.3:4000", "1.1.1.1, 198.51.100.1", "", "198.51.100.1"},
+		{"X-Real-IP", "192.0.2.10:4000", "", "198.51.100.7", "198.51.100.7"},
+		{"trusted proxy without headers", "10.1.2.3:4000", "", "", "10.1.2.3"},
+		{"IPv6", "[2001:db8::1]:4000", "", "", "2001:db8::1"},
+		{"IPv4-mapped", "[::ffff:10.1.2.3]:4000", "198.51.100.1", "", "198.51.100.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.forwarded != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			if tt.realIP != "" {
+				req.Header.Set("X-Real-IP", tt.realIP)
+			}
+
+			if got := limiter.clientIP(req); got != tt.want {
+				t.Errorf("clientIP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
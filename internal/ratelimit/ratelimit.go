@@ -0,0 +1,273 @@
+// Package ratelimit limits how often each client may call the API, with a
+// token bucket per client and route group. Clients are told where they
+// stand in RateLimit-* response headers.
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/all-in-one/internal/auth"
+	"github.com/all-in-one/internal/common"
+	"github.com/all-in-one/internal/config"
+	"github.com/sirupsen/logrus"
+)
+
+// Limit allows Requests per Period on average, and bursts of up to Burst
+// requests
+type Limit struct {
+	Requests int
+	Period   time.Duration
+
+	// Burst is the bucket size. Zero means Requests.
+	Burst int
+}
+
+// rate returns the refill rate in tokens per second
+func (l Limit) rate() float64 {
+	return float64(l.Requests) / l.Period.Seconds()
+}
+
+// burst returns the bucket size
+func (l Limit) burst() int {
+	if l.Burst > 0 {
+		return l.Burst
+	}
+	return l.Requests
+}
+
+// enabled reports whether the limit limits anything
+func (l Limit) enabled() bool {
+	return l.Requests > 0 && l.Period > 0
+}
+
+// Group is a set of routes sharing a limit: those whose path starts with
+// Prefix. Each client has its own bucket in each group.
+type Group struct {
+	Name   string
+	Prefix string
+	Limit  Limit
+}
+
+// Options configures a Limiter
+type Options struct {
+	// Default applies to requests that match no group. A zero limit leaves
+	// them unlimited.
+	Default Limit
+
+	// Groups are matched by longest path prefix
+	Groups []Group
+
+	// TrustedProxies are the CIDRs or addresses of reverse proxies whose
+	// X-Forwarded-For and X-Real-IP headers are believed
+	TrustedProxies []string
+
+	// Clock tells the time. Nil means the system clock.
+	Clock common.Clock
+}
+
+// sweepInterval is how often idle buckets are dropped
+const sweepInterval = time.Minute
+
+// Limiter rate limits requests per client and route group
+type Limiter struct {
+	groups         []Group // longest prefix first, then the default group
+	trustedProxies []netip.Prefix
+	clock          common.Clock
+
+	mutex     sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// New creates a Limiter
+func New(opts Options) (*Limiter, error) {
+	if opts.Clock == nil {
+		opts.Clock = common.SystemClock{}
+	}
+
+	proxies, err := parsePrefixes(opts.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make([]Group, 0, len(opts.Groups)+1)
+	for _, g := range opts.Groups {
+		if g.Prefix == "" || !strings.HasPrefix(g.Prefix, "/") {
+			return nil, fmt.Errorf("rate limit group %q: prefix must start with /", g.Name)
+		}
+		if g.Limit.Requests < 0 || g.Limit.Period < 0 || g.Limit.Burst < 0 {
+			return nil, fmt.Errorf("rate limit group %q: requests, period and burst can't be negative", g.Name)
+		}
+		groups = append(groups, g)
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		return len(groups[i].Prefix) > len(groups[j].Prefix)
+	})
+	groups = append(groups, Group{Name: "default", Prefix: "/", Limit: opts.Default})
+
+	return &Limiter{
+		groups:         groups,
+		trustedProxies: proxies,
+		clock:          opts.Clock,
+		buckets:        make(map[string]*bucket),
+		lastSweep:      opts.Clock.Now(),
+	}, nil
+}
+
+// FromConfig creates a Limiter from the rate_limit section of the config
+func FromConfig(cfg config.RateLimitConfig) (*Limiter, error) {
+	opts := Options{
+		Default:        Limit(cfg.Default),
+		TrustedProxies: cfg.TrustedProxies,
+	}
+	for name, g := range cfg.Groups {
+		opts.Groups = append(opts.Groups, Group{Name: name, Prefix: g.Prefix, Limit: Limit(g.RateLimit)})
+	}
+	// Map order is random; make ties between equal prefixes deterministic
+	sort.Slice(opts.Groups, func(i, j int) bool {
+		return opts.Groups[i].Name < opts.Groups[j].Name
+	})
+
+	return New(opts)
+}
+
+// Middleware limits each client's requests, answering 429 Too Many
+// Requests once its bucket for the route's group is empty. Register it
+// after authentication, so authenticated clients are limited by who they
+// are rather than by address.
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	return l.limit(next, l.clientKey)
+}
+
+// FailedAuthMiddleware limits requests that auth.Middleware rejects, by
+// client IP, answering 429 instead of 401 once the IP's bucket is empty.
+// Register it before authentication. Requests that authenticate never
+// take from these buckets, so clients sharing an address each keep their
+// full quota under Middleware.
+func (l *Limiter) FailedAuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		group := l.group(r.URL.Path)
+		if !group.Limit.enabled() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx := auth.WithRejectHook(r.Context(), func(w http.ResponseWriter, r *http.Request) bool {
+			return l.allow(w, r, group, l.ipKey(r))
+		})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// limit returns a handler limiting requests to next per client, as told
+// apart by clientKey
+func (l *Limiter) limit(next http.Handler, clientKey func(r *http.Request) string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		group := l.group(r.URL.Path)
+		if !group.Limit.enabled() || l.allow(w, r, group, clientKey(r)) {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// allow takes a token from client's bucket in group and sets the
+// RateLimit-* headers. If there was none left it answers 429 and returns
+// false.
+func (l *Limiter) allow(w http.ResponseWriter, r *http.Request, group Group, client string) bool {
+	d := l.take(group, client)
+
+	limit := group.Limit
+	h := w.Header()
+	h.Set("RateLimit-Limit", strconv.Itoa(limit.burst()))
+	h.Set("RateLimit-Remaining", strconv.Itoa(d.remaining))
+	h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(d.reset)))
+	h.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d;burst=%d", limit.Requests, ceilSeconds(limit.Period), limit.burst()))
+
+	if !d.allowed {
+		h.Set("Retry-After", strconv.Itoa(ceilSeconds(d.retryAfter)))
+		logrus.WithFields(logrus.Fields{
+			"path":   r.URL.Path,
+			"group":  group.Name,
+			"client": client,
+		}).Warn("Rate limit exceeded")
+		common.WriteError(w, r, common.RateLimited("Rate limit exceeded"))
+		return false
+	}
+	return true
+}
+
+// group returns the group of the route at path
+func (l *Limiter) group(path string) Group {
+	for _, g := range l.groups {
+		if strings.HasPrefix(path, g.Prefix) {
+			return g
+		}
+	}
+	return l.groups[len(l.groups)-1]
+}
+
+// clientKey identifies who made r: the authenticated principal if there is
+// one, the client IP otherwise
+func (l *Limiter) clientKey(r *http.Request) string {
+	if principal, ok := auth.PrincipalFromContext(r.Context()); ok {
+		return principal.Method + ":" + principal.Subject
+	}
+	return l.ipKey(r)
+}
+
+// ipKey identifies who made r by client IP alone
+func (l *Limiter) ipKey(r *http.Request) string {
+	return "ip:" + l.clientIP(r)
+}
+
+// take takes a token from the client's bucket in group
+func (l *Limiter) take(group Group, client string) decision {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := l.clock.Now()
+	if now.Sub(l.lastSweep) >= sweepInterval {
+		l.sweep(now)
+	}
+
+	key := group.Name + "|" + client
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(group.Limit.burst()), last: now}
+		l.buckets[key] = b
+	}
+
+	return b.take(now, group.Limit)
+}
+
+// sweep drops buckets that have refilled, so memory stays bounded by the
+// number of recently active clients. The caller must hold the mutex.
+func (l *Limiter) sweep(now time.Time) {
+	limits := make(map[string]Limit, len(l.groups))
+	for _, g := range l.groups {
+		limits[g.Name] = g.Limit
+	}
+
+	for key, b := range l.buckets {
+		name, _, _ := strings.Cut(key, "|")
+		if b.full(now, limits[name]) {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+// Helper Functions
+
+// ceilSeconds rounds d up to whole seconds
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
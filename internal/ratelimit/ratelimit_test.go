@@ -0,0 +1,228 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/all-in-one/internal/auth"
+	"github.com/all-in-one/internal/common"
+)
+
+// newTestLimiter returns a limiter on a manual clock and a handler behind it
+func newTestLimiter(t *testing.T, opts Options) (http.Handler, *common.ManualClock) {
+	t.Helper()
+
+	clock := common.NewManualClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	opts.Clock = clock
+	limiter, err := New(opts)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	return limiter.Middleware(ok), clock
+}
+
+// get sends a GET from remoteAddr and returns the recorded response
+func get(h http.Handler, path, remoteAddr string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", path, nil)
+	req.RemoteAddr = remoteAddr
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestBurstThenLimited(t *testing.T) {
+	h, clock := newTestLimiter(t, Options{Default: Limit{Requests: 60, Period: time.Minute, Burst: 3}})
+
+	for i := 2; i >= 0; i-- {
+		rec := get(h, "/api/v1/items", "192.0.2.1:1234")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("request %d: status %d, want 200", 3-i, rec.Code)
+		}
+		if got := rec.Header().Get("RateLimit-Remaining"); got != strconv.Itoa(i) {
+			t.Errorf("RateLimit-Remaining = %s, want %d", got, i)
+		}
+	}
+
+	rec := get(h, "/api/v1/items", "192.0.2.1:1234")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429", rec.Code)
+	}
+	for header, want := range map[string]string{
+		"RateLimit-Limit":     "3",
+		"RateLimit-Remaining": "0",
+		"RateLimit-Reset":     "3",
+		"RateLimit-Policy":    "60;w=60;burst=3",
+		"Retry-After":         "1",
+	} {
+		if got := rec.Header().Get(header); got != want {
+			t.Errorf("%s = %q, want %q", header, got, want)
+		}
+	}
+
+	// One token a second
+	clock.Advance(time.Second)
+	if rec := get(h, "/api/v1/items", "192.0.2.1:1234"); rec.Code != http.StatusOK {
+		t.Errorf("after refill: status %d, want 200", rec.Code)
+	}
+	if rec := get(h, "/api/v1/items", "192.0.2.1:1234"); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("after using the refill: status %d, want 429", rec.Code)
+	}
+}
+
+func TestClientsHaveTheirOwnBuckets(t *testing.T) {
+	h, _ := newTestLimiter(t, Options{Default: Limit{Requests: 1, Period: time.Minute}})
+
+	if rec := get(h, "/", "192.0.2.1:1"); rec.Code != http.StatusOK {
+		t.Errorf("first client: status %d, want 200", rec.Code)
+	}
+	if rec := get(h, "/", "192.0.2.1:2"); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("first client, other port: status %d, want 429", rec.Code)
+	}
+	if rec := get(h, "/", "192.0.2.2:1"); rec.Code != http.StatusOK {
+		t.Errorf("second client: status %d, want 200", rec.Code)
+	}
+}
+
+func TestPrincipalsAreLimitedByIdentity(t *testing.T) {
+	clock := common.NewManualClock(time.Now())
+	limiter, _ := New(Options{Default: Limit{Requests: 1, Period: time.Minute}, Clock: clock})
+	h := limiter.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	send := func(subject, remoteAddr string) int {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = remoteAddr
+		req = req.WithContext(auth.WithPrincipal(req.Context(), auth.Principal{Subject: subject, Method: "apikey"}))
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := send("apikey:1", "192.0.2.1:1"); code != http.StatusOK {
+		t.Errorf("key 1: status %d, want 200", code)
+	}
+	if code := send("apikey:1", "192.0.2.99:1"); code != http.StatusTooManyRequests {
+		t.Errorf("key 1 from another address: status %d, want 429", code)
+	}
+	if code := send("apikey:2", "192.0.2.1:1"); code != http.StatusOK {
+		t.Errorf("key 2 from the same address: status %d, want 200", code)
+	}
+}
+
+// staticAuthenticator accepts the tokens in its map as API keys
+type staticAuthenticator map[string]string
+
+func (a staticAuthenticator) Authenticate(token string) (auth.Principal, error) {
+	subject, ok := a[token]
+	if !ok {
+		return auth.Principal{}, auth.ErrInvalidToken
+	}
+	return auth.Principal{Subject: subject, Method: "apikey"}, nil
+}
+
+func TestFailedAuthIsLimitedByIP(t *testing.T) {
+	limiter, _ := New(Options{Default: Limit{Requests: 2, Period: time.Minute}, Clock: common.NewManualClock(time.Now())})
+
+	// Routed as the listing service does with authentication on
+	var h http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	h = limiter.Middleware(h)
+	h = auth.Middleware(staticAuthenticator{"key-1": "apikey:1", "key-2": "apikey:2"})(h)
+	h = limiter.FailedAuthMiddleware(h)
+
+	send := func(token string) int {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "192.0.2.1:1"
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// Failed attempts from an address share a bucket
+	for _, token := range []string{"wrong", ""} {
+		if code := send(token); code != http.StatusUnauthorized {
+			t.Errorf("token %q: status %d, want 401", token, code)
+		}
+	}
+	if code := send("wrong"); code != http.StatusTooManyRequests {
+		t.Errorf("third failed attempt: status %d, want 429", code)
+	}
+
+	// They don't touch the keys' buckets, and two keys behind that address
+	// each get their full quota
+	for _, token := range []string{"key-1", "key-2"} {
+		for i := 0; i < 2; i++ {
+			if code := send(token); code != http.StatusOK {
+				t.Errorf("%s request %d: status %d, want 200", token, i+1, code)
+			}
+		}
+		if code := send(token); code != http.StatusTooManyRequests {
+			t.Errorf("%s over quota: status %d, want 429", token, code)
+		}
+	}
+}
+
+func TestGroups(t *testing.T) {
+	h, _ := newTestLimiter(t, Options{
+		Groups: []Group{
+			{Name: "api", Prefix: "/api/v1", Limit: Limit{Requests: 2, Period: time.Minute}},
+			{Name: "admin", Prefix: "/api/v1/admin", Limit: Limit{Requests: 1, Period: time.Minute}},
+		},
+	})
+
+	// The longest prefix wins, and each group has its own buckets
+	if rec := get(h, "/api/v1/admin/backup", "192.0.2.1:1"); rec.Code != http.StatusOK {
+		t.Errorf("admin: status %d, want 200", rec.Code)
+	}
+	if rec := get(h, "/api/v1/admin/backup", "192.0.2.1:1"); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("admin again: status %d, want 429", rec.Code)
+	}
+	for i := 0; i < 2; i++ {
+		if rec := get(h, "/api/v1/items", "192.0.2.1:1"); rec.Code != http.StatusOK {
+			t.Errorf("items %d: status %d, want 200", i, rec.Code)
+		}
+	}
+
+	// Without a default limit, other routes aren't limited or annotated
+	for i := 0; i < 5; i++ {
+		rec := get(h, "/health", "192.0.2.1:1")
+		if rec.Code != http.StatusOK || rec.Header().Get("RateLimit-Limit") != "" {
+			t.Fatalf("ungrouped route: status %d, RateLimit-Limit %q; want 200 and none", rec.Code, rec.Header().Get("RateLimit-Limit"))
+		}
+	}
+}
+
+func TestSweepDropsIdleBuckets(t *testing.T) {
+	clock := common.NewManualClock(time.Now())
+	limiter, _ := New(Options{Default: Limit{Requests: 10, Period: time.Second}, Clock: clock})
+
+	limiter.take(limiter.groups[0], "ip:192.0.2.1")
+	limiter.take(limiter.groups[0], "ip:192.0.2.2")
+	clock.Advance(sweepInterval)
+	limiter.take(limiter.groups[0], "ip:192.0.2.3")
+
+	if n := len(limiter.buckets); n != 1 {
+		t.Errorf("%d buckets after sweep, want 1", n)
+	}
+}
+
+func TestNewRejectsBadGroups(t *testing.T) {
+	for _, g := range []Group{
+		{Name: "no prefix", Limit: Limit{Requests: 1, Period: time.Second}},
+		{Name: "relative", Prefix: "api", Limit: Limit{Requests: 1, Period: time.Second}},
+		{Name: "negative", Prefix: "/api", Limit: Limit{Requests: -1, Period: time.Second}},
+	} {
+		if _, err := New(Options{Groups: []Group{g}}); err == nil {
+			t.Errorf("New accepted group %q", g.Name)
+		}
+	}
+	if _, err := New(Options{TrustedProxies: []string{"not-an-ip"}}); err == nil {
+		t.Error("New accepted an invalid trusted proxy")
+	}
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/all-in-one/internal/common"
+	"github.com/all-in-one/internal/openapi"
+	"github.com/sirupsen/logrus"
+)
+
+// HealthOperations describes the health check for the OpenAPI document.
+// Register the handler under the route name "health".
+var HealthOperations = openapi.Operations{
+	"health": {
+		Summary:  "Health check",
+		Tags:     []string{"health"},
+		Response: map[string]interface{}{},
+	},
+}
+
+// HealthCheck returns a health check endpoint reporting that service is
+// running, with message as the response message
+func HealthCheck(service, message string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logrus.WithFields(logrus.Fields{
+			"method": r.Method,
+			"path":   r.URL.Path,
+			"ip":     r.RemoteAddr,
+		}).Info("Health check requested")
+
+		response := common.Response{
+			Success: true,
+			Message: message,
+			Data: map[string]interface{}{
+				"timestamp": time.Now(),
+				"version":   "1.0.0",
+				"service":   service,
+			},
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// LogRequests logs the start and end of every HTTP request
+func LogRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		logrus.WithFields(logrus.Fields{
+			"method": r.Method,
+			"path":   r.URL.Path,
+			"ip":     r.RemoteAddr,
+		}).Info("Request started")
+
+		next.ServeHTTP(w, r)
+
+		logrus.WithFields(logrus.Fields{
+			"method":   r.Method,
+			"path":     r.URL.Path,
+			"ip":       r.RemoteAddr,
+			"duration": time.Since(start),
+		}).Info("Request completed")
+	})
+}
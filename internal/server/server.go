@@ -0,0 +1,290 @@
+// Package server runs an HTTP server until its context is done, then shuts
+// it down in order: stop accepting connections, let in-flight requests
+// finish until the drain deadline, stop background workers, and finally run
+// the shutdown hooks, such as closing storage, in reverse order of
+// registration.
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/all-in-one/internal/config"
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultShutdownTimeout is the drain deadline when Options doesn't say
+const DefaultShutdownTimeout = 20 * time.Second
+
+// Options configures a Server. Zero timeouts mean none, as for http.Server.
+type Options struct {
+	// Addr is the TCP address to listen on, e.g. ":8080"
+	Addr    string
+	Handler http.Handler
+
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+
+	// ShutdownTimeout is how long in-flight requests and workers get to
+	// finish once shutdown starts, and separately how long the hooks get.
+	// Zero means DefaultShutdownTimeout.
+	ShutdownTimeout time.Duration
+
+	// TLS serves HTTPS instead of HTTP. Nil means plain HTTP.
+	TLS *TLSOptions
+
+	// RedirectAddr, with TLS, is an address on which plain HTTP requests
+	// are redirected to HTTPS. Empty means no redirect listener.
+	RedirectAddr string
+}
+
+// named is a worker or shutdown hook with a name for the logs
+type named struct {
+	name string
+	fn   func(context.Context) error
+}
+
+// Server is an HTTP server with ordered shutdown
+type Server struct {
+	http            *http.Server
+	shutdownTimeout time.Duration
+	certs           *certReloader
+
+	// redirect sends plain HTTP clients to HTTPS, if enabled
+	redirect     *http.Server
+	redirectAddr string
+
+	workers []named
+	hooks   []named
+}
+
+// New creates a Server. With TLS, the certificate is loaded now, and
+// reloaded in the background whenever its files change.
+func New(opts Options) (*Server, error) {
+	if opts.ShutdownTimeout <= 0 {
+		opts.ShutdownTimeout = DefaultShutdownTimeout
+	}
+
+	s := &Server{
+		http: &http.Server{
+			Addr:              opts.Addr,
+			Handler:           opts.Handler,
+			ReadTimeout:       opts.ReadTimeout,
+			ReadHeaderTimeout: opts.ReadHeaderTimeout,
+			WriteTimeout:      opts.WriteTimeout,
+			IdleTimeout:       opts.IdleTimeout,
+		},
+		shutdownTimeout: opts.ShutdownTimeout,
+	}
+
+	if opts.TLS == nil {
+		if opts.RedirectAddr != "" {
+			return nil, errors.New("an HTTPS redirect needs TLS")
+		}
+		return s, nil
+	}
+
+	certs, err := newCertReloader(*opts.TLS)
+	if err != nil {
+		return nil, err
+	}
+	s.certs = certs
+	s.http.TLSConfig = certs.tlsConfig()
+	s.Go("tls-reload", certs.watch)
+
+	if opts.RedirectAddr != "" {
+		s.redirectAddr = opts.RedirectAddr
+		s.redirect = &http.Server{
+			Addr:              opts.RedirectAddr,
+			Handler:           redirectToHTTPS(opts.Addr),
+			ReadHeaderTimeout: opts.ReadHeaderTimeout,
+			IdleTimeout:       opts.IdleTimeout,
+		}
+	}
+
+	return s, nil
+}
+
+// FromConfig creates a Server for handler on addr with the timeouts and
+// TLS settings in the server section of the config. The HTTPS redirect
+// listener is only started if redirect is set, since services share the
+// section but can't share the port.
+func FromConfig(cfg config.ServerConfig, addr string, handler http.Handler, redirect bool) (*Server, error) {
+	tlsOpts, err := TLSFromConfig(cfg.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := Options{
+		Addr:              addr,
+		Handler:           handler,
+		ReadTimeout:       cfg.ReadTimeout,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		ShutdownTimeout:   cfg.ShutdownTimeout,
+		TLS:               tlsOpts,
+	}
+	if tlsOpts != nil && redirect {
+		opts.RedirectAddr = cfg.TLS.RedirectAddr
+	}
+	return New(opts)
+}
+
+// TLS reports whether the server serves HTTPS
+func (s *Server) TLS() bool {
+	return s.certs != nil
+}
+
+// Go adds a background worker, started when the server is and stopped,
+// by canceling its context, once requests have drained. It should return
+// soon after its context is done.
+func (s *Server) Go(name string, worker func(ctx context.Context) error) {
+	s.workers = append(s.workers, named{name, worker})
+}
+
+// OnShutdown adds a hook run after the workers have stopped. Hooks run in
+// reverse order of registration, like deferred calls, so register storage
+// before whatever uses it.
+func (s *Server) OnShutdown(name string, hook func(ctx context.Context) error) {
+	s.hooks = append(s.hooks, named{name, hook})
+}
+
+// Run listens on the configured address and serves until ctx is done or
+// serving fails, then shuts down. The hooks run even if listening fails.
+func (s *Server) Run(ctx context.Context) error {
+	ln, err := net.Listen("tcp", s.http.Addr)
+	if err != nil {
+		return errors.Join(err, s.runHooks())
+	}
+	return s.Serve(ctx, ln)
+}
+
+// Serve is Run on an existing listener, which it closes. The redirect
+// listener, if any, is opened here.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	var redirectLn net.Listener
+	if s.redirect != nil {
+		var err error
+		if redirectLn, err = net.Listen("tcp", s.redirectAddr); err != nil {
+			ln.Close()
+			return errors.Join(fmt.Errorf("HTTPS redirect listener: %w", err), s.runHooks())
+		}
+	}
+
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+
+	var wg sync.WaitGroup
+	workerErrs := make([]error, len(s.workers))
+	for i, w := range s.workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := w.fn(workerCtx); err != nil && !errors.Is(err, context.Canceled) {
+				workerErrs[i] = fmt.Errorf("%s: %w", w.name, err)
+				logrus.WithError(err).WithField("worker", w.name).Error("Background worker failed")
+			}
+		}()
+	}
+
+	serveErr := make(chan error, 2)
+	go func() {
+		if s.certs != nil {
+			serveErr <- s.http.ServeTLS(ln, "", "")
+			return
+		}
+		serveErr <- s.http.Serve(ln)
+	}()
+	if redirectLn != nil {
+		go func() {
+			serveErr <- s.redirect.Serve(redirectLn)
+		}()
+	}
+
+	var errs []error
+	select {
+	case <-ctx.Done():
+		logrus.WithField("drain_timeout", s.shutdownTimeout.String()).Info("Shutting down: draining in-flight requests")
+	case err := <-serveErr:
+		logrus.WithError(err).Error("HTTP server failed; shutting down")
+		errs = append(errs, err)
+	}
+
+	// Stop accepting and wait for in-flight requests, then cut off
+	// whatever is left at the deadline
+	drainCtx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+	defer cancel()
+	if s.redirect != nil {
+		s.redirect.Close()
+	}
+	if err := s.http.Shutdown(drainCtx); err != nil {
+		logrus.WithError(err).Warn("Drain deadline passed; closing remaining connections")
+		s.http.Close()
+	}
+
+	stopWorkers()
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		errs = append(errs, workerErrs...)
+	case <-drainCtx.Done():
+		logrus.Warn("Background workers didn't stop before the drain deadline")
+		errs = append(errs, errors.New("background workers didn't stop in time"))
+	}
+
+	errs = append(errs, s.runHooks())
+	return errors.Join(errs...)
+}
+
+// runHooks runs the shutdown hooks in reverse order, each with the
+// shutdown timeout, and returns their errors
+func (s *Server) runHooks() error {
+	var errs []error
+	for i := len(s.hooks) - 1; i >= 0; i-- {
+		h := s.hooks[i]
+		ctx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+		err := h.fn(ctx)
+		cancel()
+		if err != nil {
+			logrus.WithError(err).WithField("hook", h.name).Error("Shutdown step failed")
+			errs = append(errs, fmt.Errorf("%s: %w", h.name, err))
+			continue
+		}
+		logrus.WithField("hook", h.name).Info("Shutdown step done")
+	}
+	return errors.Join(errs...)
+}
+
+// redirectToHTTPS redirects requests to the same URL over HTTPS on the
+// port of tlsAddr
+func redirectToHTTPS(tlsAddr string) http.Handler {
+	_, port, _ := net.SplitHostPort(tlsAddr)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if host == "" {
+			http.Error(w, "Host header required", http.StatusBadRequest)
+			return
+		}
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
@@ -0,0 +1,203 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recorder records the order of shutdown events
+type recorder struct {
+	mutex  sync.Mutex
+	events []string
+}
+
+func (r *recorder) add(event string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *recorder) get() []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]string(nil), r.events...)
+}
+
+// newServer is New for options known to be valid
+func newServer(t *testing.T, opts Options) *Server {
+	t.Helper()
+
+	s, err := New(opts)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return s
+}
+
+// start serves handler on a free port until the returned cancel is called.
+// The returned channel gets Serve's error.
+func start(t *testing.T, s *Server) (string, context.CancelFunc, <-chan error) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Serve(ctx, ln) }()
+	t.Cleanup(cancel)
+
+	return "http://" + ln.Addr().String(), cancel, done
+}
+
+func TestShutdownDrainsRequestsThenStopsInOrder(t *testing.T) {
+	events := &recorder{}
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	s := newServer(t, Options{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-release
+			events.add("request finished")
+			io.WriteString(w, "done")
+		}),
+		ShutdownTimeout: 5 * time.Second,
+	})
+	s.Go("worker", func(ctx context.Context) error {
+		<-ctx.Done()
+		events.add("worker stopped")
+		return ctx.Err()
+	})
+	s.OnShutdown("storage", func(context.Context) error {
+		events.add("storage closed")
+		return nil
+	})
+	s.OnShutdown("exporter", func(context.Context) error {
+		events.add("exporter flushed")
+		return nil
+	})
+
+	url, cancel, done := start(t, s)
+
+	type result struct {
+		body string
+		err  error
+	}
+	got := make(chan result, 1)
+	go func() {
+		resp, err := http.Get(url)
+		if err != nil {
+			got <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		got <- result{string(body), err}
+	}()
+
+	<-started
+	cancel()
+
+	// New connections are refused while the request drains
+	time.Sleep(50 * time.Millisecond)
+	if _, err := http.Get(url); err == nil {
+		t.Error("new request accepted during shutdown")
+	}
+	if len(events.get()) != 0 {
+		t.Errorf("shutdown went ahead before the request finished: %v", events.get())
+	}
+
+	close(release)
+	if r := <-got; r.err != nil || r.body != "done" {
+		t.Errorf("in-flight request = %q, %v", r.body, r.err)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("Serve: %v", err)
+	}
+
+	want := []string{"request finished", "worker stopped", "exporter flushed", "storage closed"}
+	if got := events.get(); !slices.Equal(got, want) {
+		t.Errorf("events = %v, want %v", got, want)
+	}
+}
+
+func TestDrainDeadline(t *testing.T) {
+	closed := make(chan struct{})
+	s := newServer(t, Options{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+		}),
+		ShutdownTimeout: 100 * time.Millisecond,
+	})
+	s.OnShutdown("storage", func(context.Context) error {
+		close(closed)
+		return nil
+	})
+
+	url, cancel, done := start(t, s)
+	go http.Get(url)
+	time.Sleep(50 * time.Millisecond)
+
+	begin := time.Now()
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Serve didn't return after the drain deadline")
+	}
+	if elapsed := time.Since(begin); elapsed < 100*time.Millisecond {
+		t.Errorf("returned after %v, before the drain deadline", elapsed)
+	}
+
+	select {
+	case <-closed:
+	default:
+		t.Error("hooks didn't run after the drain deadline")
+	}
+}
+
+func TestErrorsAreCollected(t *testing.T) {
+	s := newServer(t, Options{Handler: http.NotFoundHandler(), ShutdownTimeout: time.Second})
+	errWorker := errors.New("worker broke")
+	errHook := errors.New("close failed")
+	s.Go("broken", func(ctx context.Context) error { return errWorker })
+	s.OnShutdown("storage", func(context.Context) error { return errHook })
+
+	_, cancel, done := start(t, s)
+	cancel()
+	err := <-done
+	if !errors.Is(err, errWorker) || !errors.Is(err, errHook) {
+		t.Errorf("Serve = %v, want both errors", err)
+	}
+}
+
+func TestRunRunsHooksWhenListenFails(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer ln.Close()
+
+	closed := false
+	s := newServer(t, Options{Addr: ln.Addr().String(), Handler: http.NotFoundHandler()})
+	s.OnShutdown("storage", func(context.Context) error {
+		closed = true
+		return nil
+	})
+
+	if err := s.Run(context.Background()); err == nil {
+		t.Error("Run on a taken address succeeded")
+	}
+	if !closed {
+		t.Error("hooks didn't run")
+	}
+}
@@ -0,0 +1,268 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/all-in-one/internal/config"
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultReloadInterval is how often certificate files are checked for
+// changes when TLSOptions doesn't say
+const DefaultReloadInterval = 30 * time.Second
+
+// TLSOptions configures HTTPS
+type TLSOptions struct {
+	// CertFile and KeyFile are the PEM certificate chain and private key
+	CertFile string
+	KeyFile  string
+
+	// MinVersion is the lowest TLS version accepted. Zero means TLS 1.2.
+	MinVersion uint16
+
+	// CipherSuites restricts the TLS 1.2 cipher suites. Empty means Go's
+	// defaults. TLS 1.3 suites aren't configurable.
+	CipherSuites []uint16
+
+	// ClientCAFile is a PEM bundle of the CAs client certificates must be
+	// signed by. Empty means client certificates aren't asked for.
+	ClientCAFile string
+
+	// ClientAuth is the client certificate policy when ClientCAFile is
+	// set. Zero means tls.RequireAndVerifyClientCert.
+	ClientAuth tls.ClientAuthType
+
+	// ReloadInterval is how often the files are checked for changes. Zero
+	// means DefaultReloadInterval.
+	ReloadInterval time.Duration
+}
+
+// tlsVersions are the accepted server.tls.min_version values
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// clientAuthTypes are the accepted server.tls.client_auth values
+var clientAuthTypes = map[string]tls.ClientAuthType{
+	"require":  tls.RequireAndVerifyClientCert,
+	"optional": tls.VerifyClientCertIfGiven,
+}
+
+// TLSFromConfig returns the TLSOptions of the server.tls config section, or
+// nil if TLS isn't enabled
+func TLSFromConfig(cfg config.TLSConfig) (*TLSOptions, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	if cfg.CertFile == "" || cfg.KeyFile == "" {
+		return nil, errors.New("server.tls needs cert_file and key_file")
+	}
+
+	opts := &TLSOptions{
+		CertFile:       cfg.CertFile,
+		KeyFile:        cfg.KeyFile,
+		ClientCAFile:   cfg.ClientCAFile,
+		ReloadInterval: cfg.ReloadInterval,
+	}
+
+	if cfg.MinVersion != "" {
+		version, ok := tlsVersions[cfg.MinVersion]
+		if !ok {
+			return nil, fmt.Errorf("unknown server.tls.min_version %q. Supported versions: 1.2, 1.3", cfg.MinVersion)
+		}
+		opts.MinVersion = version
+	}
+
+	if len(cfg.CipherSuites) > 0 {
+		if opts.MinVersion == tls.VersionTLS13 {
+			return nil, errors.New("server.tls.cipher_suites only apply to TLS 1.2, but min_version is 1.3")
+		}
+		suites, err := parseCipherSuites(cfg.CipherSuites)
+		if err != nil {
+			return nil, err
+		}
+		opts.CipherSuites = suites
+	}
+
+	if cfg.ClientAuth != "" {
+		if cfg.ClientCAFile == "" {
+			return nil, errors.New("server.tls.client_auth needs client_ca_file")
+		}
+		auth, ok := clientAuthTypes[cfg.ClientAuth]
+		if !ok {
+			return nil, fmt.Errorf("unknown server.tls.client_auth %q. Supported values: require, optional", cfg.ClientAuth)
+		}
+		opts.ClientAuth = auth
+	}
+
+	return opts, nil
+}
+
+// parseCipherSuites returns the IDs of the named cipher suites. Suites Go
+// considers insecure are refused.
+func parseCipherSuites(names []string) ([]uint16, error) {
+	known := map[string]uint16{}
+	for _, s := range tls.CipherSuites() {
+		known[s.Name] = s.ID
+	}
+	insecure := map[string]bool{}
+	for _, s := range tls.InsecureCipherSuites() {
+		insecure[s.Name] = true
+	}
+
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if insecure[name] {
+			return nil, fmt.Errorf("cipher suite %s is insecure", name)
+		}
+		id, ok := known[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown cipher suite %q", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// fileStamp identifies a version of a file
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// certReloader serves the certificate and client CAs from files, reloading
+// them when the files change. A failed reload keeps the previous ones.
+type certReloader struct {
+	opts   TLSOptions
+	config atomic.Pointer[tls.Config]
+	stamps map[string]fileStamp
+}
+
+// newCertReloader loads the files of opts, failing if they don't load
+func newCertReloader(opts TLSOptions) (*certReloader, error) {
+	if opts.MinVersion == 0 {
+		opts.MinVersion = tls.VersionTLS12
+	}
+	if opts.ClientCAFile != "" && opts.ClientAuth == tls.NoClientCert {
+		opts.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	if opts.ReloadInterval <= 0 {
+		opts.ReloadInterval = DefaultReloadInterval
+	}
+
+	r := &certReloader{opts: opts}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// files returns the files the config is loaded from
+func (r *certReloader) files() []string {
+	files := []string{r.opts.CertFile, r.opts.KeyFile}
+	if r.opts.ClientCAFile != "" {
+		files = append(files, r.opts.ClientCAFile)
+	}
+	return files
+}
+
+// load reads the files and makes them the current config
+func (r *certReloader) load() error {
+	// Stat first, so a change made while reading is seen next time
+	stamps := map[string]fileStamp{}
+	for _, f := range r.files() {
+		info, err := os.Stat(f)
+		if err != nil {
+			return err
+		}
+		stamps[f] = fileStamp{info.ModTime(), info.Size()}
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.opts.CertFile, r.opts.KeyFile)
+	if err != nil {
+		return fmt.Errorf("loading TLS certificate: %w", err)
+	}
+
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   r.opts.MinVersion,
+		CipherSuites: r.opts.CipherSuites,
+		NextProtos:   []string{"h2", "http/1.1"},
+	}
+
+	if r.opts.ClientCAFile != "" {
+		pem, err := os.ReadFile(r.opts.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("loading client CAs: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("loading client CAs: no certificates in %s", r.opts.ClientCAFile)
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = r.opts.ClientAuth
+	}
+
+	r.config.Store(cfg)
+	r.stamps = stamps
+	return nil
+}
+
+// changed reports whether any of the files changed since they were loaded
+func (r *certReloader) changed() bool {
+	for _, f := range r.files() {
+		info, err := os.Stat(f)
+		if err != nil {
+			// Mid-replacement, perhaps; look again next time
+			return false
+		}
+		if (fileStamp{info.ModTime(), info.Size()}) != r.stamps[f] {
+			return true
+		}
+	}
+	return false
+}
+
+// watch reloads the files whenever they change, until ctx is done
+func (r *certReloader) watch(ctx context.Context) error {
+	ticker := time.NewTicker(r.opts.ReloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if !r.changed() {
+				continue
+			}
+			if err := r.load(); err != nil {
+				logrus.WithError(err).Error("Reloading TLS certificate failed; keeping the current one")
+				continue
+			}
+			logrus.WithField("cert_file", r.opts.CertFile).Info("Reloaded TLS certificate")
+		}
+	}
+}
+
+// tlsConfig returns the server's TLS config, which picks up reloads for
+// every new connection
+func (r *certReloader) tlsConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: r.opts.MinVersion,
+		NextProtos: []string{"h2", "http/1.1"},
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return r.config.Load(), nil
+		},
+	}
+}
@@ -0,0 +1,389 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/all-in-one/internal/config"
+)
+
+// testCA issues certificates for tests
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns the PEM certificate and key of a leaf with the given
+// serial, for the loopback address or, with client, for client auth
+func (ca *testCA) issue(t *testing.T, serial int64, client bool) (certPEM, keyPEM []byte) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	if client {
+		tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+// writeFile writes data to name in dir, bumping the modification time so
+// a rewrite is always noticed
+func writeFile(t *testing.T, dir, name string, data []byte, mtime time.Time) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// tlsFixture is a CA and a server certificate for it on disk
+type tlsFixture struct {
+	ca                        *testCA
+	dir                       string
+	certFile, keyFile, caFile string
+}
+
+func newTLSFixture(t *testing.T) *tlsFixture {
+	t.Helper()
+
+	f := &tlsFixture{ca: newTestCA(t), dir: t.TempDir()}
+	cert, key := f.ca.issue(t, 100, false)
+	now := time.Now()
+	f.certFile = writeFile(t, f.dir, "cert.pem", cert, now)
+	f.keyFile = writeFile(t, f.dir, "key.pem", key, now)
+	f.caFile = writeFile(t, f.dir, "ca.pem", f.ca.pem, now)
+	return f
+}
+
+// client returns an HTTPS client trusting the fixture's CA, with tweak
+// applied to its TLS config
+func (f *tlsFixture) client(tweak func(*tls.Config)) *http.Client {
+	roots := x509.NewCertPool()
+	roots.AddCert(f.ca.cert)
+	cfg := &tls.Config{RootCAs: roots}
+	if tweak != nil {
+		tweak(cfg)
+	}
+	return &http.Client{Transport: &http.Transport{TLSClientConfig: cfg, ForceAttemptHTTP2: true, DisableKeepAlives: true}}
+}
+
+// startTLS serves a handler reporting the client certificate's subject
+func startTLS(t *testing.T, opts TLSOptions) string {
+	t.Helper()
+
+	s := newServer(t, Options{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if len(r.TLS.PeerCertificates) > 0 {
+				w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+			}
+		}),
+		TLS:             &opts,
+		ShutdownTimeout: time.Second,
+	})
+	url, _, _ := start(t, s)
+	return strings.Replace(url, "http://", "https://", 1)
+}
+
+// serverSerial returns the serial of the certificate the server at url
+// presents
+func serverSerial(t *testing.T, client *http.Client, url string) int64 {
+	t.Helper()
+
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	resp.Body.Close()
+	return resp.TLS.PeerCertificates[0].SerialNumber.Int64()
+}
+
+func TestServesHTTPS(t *testing.T) {
+	f := newTLSFixture(t)
+	url := startTLS(t, TLSOptions{CertFile: f.certFile, KeyFile: f.keyFile})
+
+	resp, err := f.client(nil).Get(url)
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	resp.Body.Close()
+	if resp.TLS == nil || resp.ProtoMajor != 2 {
+		t.Errorf("TLS = %v, protocol = %s; want HTTP/2 over TLS", resp.TLS != nil, resp.Proto)
+	}
+
+	// TLS 1.1 and older are refused
+	if _, err := f.client(func(c *tls.Config) { c.MaxVersion = tls.VersionTLS11 }).Get(url); err == nil {
+		t.Error("TLS 1.1 handshake succeeded")
+	}
+}
+
+func TestMinVersion(t *testing.T) {
+	f := newTLSFixture(t)
+	url := startTLS(t, TLSOptions{CertFile: f.certFile, KeyFile: f.keyFile, MinVersion: tls.VersionTLS13})
+
+	if _, err := f.client(func(c *tls.Config) { c.MaxVersion = tls.VersionTLS12 }).Get(url); err == nil {
+		t.Error("TLS 1.2 handshake succeeded with min_version 1.3")
+	}
+	if _, err := f.client(nil).Get(url); err != nil {
+		t.Errorf("TLS 1.3: %v", err)
+	}
+}
+
+func TestMutualTLS(t *testing.T) {
+	f := newTLSFixture(t)
+	clientCert, clientKey := f.ca.issue(t, 200, true)
+	pair, err := tls.X509KeyPair(clientCert, clientKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	withCert := f.client(func(c *tls.Config) { c.Certificates = []tls.Certificate{pair} })
+
+	// A certificate from another CA
+	otherCert, otherKey := newTestCA(t).issue(t, 300, true)
+	otherPair, _ := tls.X509KeyPair(otherCert, otherKey)
+	withOtherCert := f.client(func(c *tls.Config) { c.Certificates = []tls.Certificate{otherPair} })
+
+	t.Run("require", func(t *testing.T) {
+		url := startTLS(t, TLSOptions{CertFile: f.certFile, KeyFile: f.keyFile, ClientCAFile: f.caFile})
+
+		if _, err := f.client(nil).Get(url); err == nil {
+			t.Error("request without a client certificate succeeded")
+		}
+		if _, err := withOtherCert.Get(url); err == nil {
+			t.Error("request with an untrusted client certificate succeeded")
+		}
+		resp, err := withCert.Get(url)
+		if err != nil {
+			t.Fatalf("request with a client certificate: %v", err)
+		}
+		resp.Body.Close()
+	})
+
+	t.Run("optional", func(t *testing.T) {
+		url := startTLS(t, TLSOptions{CertFile: f.certFile, KeyFile: f.keyFile, ClientCAFile: f.caFile, ClientAuth: tls.VerifyClientCertIfGiven})
+
+		if _, err := f.client(nil).Get(url); err != nil {
+			t.Errorf("request without a client certificate: %v", err)
+		}
+		if _, err := withOtherCert.Get(url); err == nil {
+			t.Error("request with an untrusted client certificate succeeded")
+		}
+	})
+}
+
+func TestCertificateReload(t *testing.T) {
+	f := newTLSFixture(t)
+	url := startTLS(t, TLSOptions{CertFile: f.certFile, KeyFile: f.keyFile, ReloadInterval: 10 * time.Millisecond})
+	client := f.client(nil)
+
+	if serial := serverSerial(t, client, url); serial != 100 {
+		t.Fatalf("serial = %d, want 100", serial)
+	}
+
+	// A broken certificate is ignored
+	later := time.Now().Add(time.Minute)
+	writeFile(t, f.dir, "cert.pem", []byte("garbage"), later)
+	time.Sleep(50 * time.Millisecond)
+	if serial := serverSerial(t, client, url); serial != 100 {
+		t.Errorf("serial after a broken reload = %d, want 100", serial)
+	}
+
+	cert, key := f.ca.issue(t, 101, false)
+	later = later.Add(time.Minute)
+	writeFile(t, f.dir, "key.pem", key, later)
+	writeFile(t, f.dir, "cert.pem", cert, later)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for serverSerial(t, client, url) != 101 {
+		if time.Now().After(deadline) {
+			t.Fatal("new certificate not served")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRedirectToHTTPS(t *testing.T) {
+	tests := []struct {
+		tlsAddr, host, want string
+	}{
+		{":8443", "example.com:8080", "https://example.com:8443/api/v1/items?after=2"},
+		{":443", "example.com", "https://example.com/api/v1/items?after=2"},
+		{"127.0.0.1:8443", "[::1]:8080", "https://[::1]:8443/api/v1/items?after=2"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/api/v1/items?after=2", nil)
+		req.Host = tt.host
+		rec := httptest.NewRecorder()
+		redirectToHTTPS(tt.tlsAddr).ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusPermanentRedirect || rec.Header().Get("Location") != tt.want {
+			t.Errorf("%s via %s = %d %q, want %q", tt.host, tt.tlsAddr, rec.Code, rec.Header().Get("Location"), tt.want)
+		}
+	}
+}
+
+func TestRedirectListener(t *testing.T) {
+	f := newTLSFixture(t)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	redirectLn, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	redirectAddr := redirectLn.Addr().String()
+	redirectLn.Close()
+
+	s := newServer(t, Options{
+		Addr:            ln.Addr().String(),
+		Handler:         http.NotFoundHandler(),
+		TLS:             &TLSOptions{CertFile: f.certFile, KeyFile: f.keyFile},
+		RedirectAddr:    redirectAddr,
+		ShutdownTimeout: time.Second,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Serve(ctx, ln) }()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	noFollow := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	var resp *http.Response
+	for i := 0; i < 50; i++ {
+		if resp, err = noFollow.Get("http://" + redirectAddr + "/x"); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	resp.Body.Close()
+	if want := "https://" + ln.Addr().String() + "/x"; resp.StatusCode != http.StatusPermanentRedirect || resp.Header.Get("Location") != want {
+		t.Errorf("redirect = %d %q, want %q", resp.StatusCode, resp.Header.Get("Location"), want)
+	}
+}
+
+func TestTLSFromConfig(t *testing.T) {
+	base := config.TLSConfig{Enabled: true, CertFile: "cert.pem", KeyFile: "key.pem"}
+
+	if opts, err := TLSFromConfig(config.TLSConfig{}); opts != nil || err != nil {
+		t.Errorf("disabled = %v, %v", opts, err)
+	}
+
+	opts, err := TLSFromConfig(config.TLSConfig{
+		Enabled:      true,
+		CertFile:     "cert.pem",
+		KeyFile:      "key.pem",
+		MinVersion:   "1.2",
+		CipherSuites: []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"},
+		ClientCAFile: "ca.pem",
+		ClientAuth:   "optional",
+	})
+	if err != nil {
+		t.Fatalf("TLSFromConfig: %v", err)
+	}
+	if opts.MinVersion != tls.VersionTLS12 || len(opts.CipherSuites) != 1 || opts.CipherSuites[0] != tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256 || opts.ClientAuth != tls.VerifyClientCertIfGiven {
+		t.Errorf("options = %+v", opts)
+	}
+
+	bad := map[string]func(c *config.TLSConfig){
+		"no key":          func(c *config.TLSConfig) { c.KeyFile = "" },
+		"unknown version": func(c *config.TLSConfig) { c.MinVersion = "1.1" },
+		"suites with 1.3": func(c *config.TLSConfig) {
+			c.MinVersion = "1.3"
+			c.CipherSuites = []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"}
+		},
+		"unknown suite":       func(c *config.TLSConfig) { c.CipherSuites = []string{"TLS_MADE_UP"} },
+		"insecure suite":      func(c *config.TLSConfig) { c.CipherSuites = []string{"TLS_RSA_WITH_RC4_128_SHA"} },
+		"client_auth, no CAs": func(c *config.TLSConfig) { c.ClientAuth = "require" },
+		"unknown client_auth": func(c *config.TLSConfig) { c.ClientCAFile = "ca.pem"; c.ClientAuth = "sometimes" },
+	}
+	for name, change := range bad {
+		cfg := base
+		change(&cfg)
+		if _, err := TLSFromConfig(cfg); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
+
+func TestNewFailsOnBadCertificate(t *testing.T) {
+	dir := t.TempDir()
+	cert := writeFile(t, dir, "cert.pem", []byte("garbage"), time.Now())
+
+	if _, err := New(Options{TLS: &TLSOptions{CertFile: cert, KeyFile: cert}}); err == nil {
+		t.Error("New with a bad certificate succeeded")
+	}
+	if _, err := New(Options{RedirectAddr: ":8000"}); err == nil {
+		t.Error("New with a redirect but no TLS succeeded")
+	}
+}
@@ -0,0 +1,85 @@
+package sqlitedb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Driver names accepted in Options.Driver
+const (
+	// DriverMattn is the cgo-based github.com/mattn/go-sqlite3 driver
+	DriverMattn = "mattn"
+
+	// DriverModernc is the pure-Go modernc.org/sqlite driver
+	DriverModernc = "modernc"
+)
+
+// pragma is a PRAGMA applied to every connection the pool opens
+type pragma struct {
+	name  string
+	value string
+}
+
+// driver describes a database/sql driver registered by a driver_*.go file
+type driver struct {
+	// sqlName is the name the driver registers with database/sql
+	sqlName string
+
+	// dsn builds a data source name that applies the pragmas on connect.
+	// Each driver has its own DSN syntax for this.
+	dsn func(dbPath string, pragmas []pragma) string
+
+	// backup copies the live database into a new file at destPath using
+	// SQLite's online backup API, which each driver exposes differently
+	backup func(ctx context.Context, db *sql.DB, destPath string) error
+}
+
+// drivers maps our driver names to their database/sql drivers. Entries are
+// added by the driver_*.go files, so drivers that are excluded by build
+// constraints (e.g. mattn when CGO_ENABLED=0) are absent.
+var drivers = map[string]driver{}
+
+// DefaultDriver returns the driver used when none is configured: mattn when
+// the binary was built with cgo, modernc otherwise.
+func DefaultDriver() string {
+	if _, ok := drivers[DriverMattn]; ok {
+		return DriverMattn
+	}
+	return DriverModernc
+}
+
+// Drivers returns the names of the drivers compiled into this binary
+func Drivers() []string {
+	names := make([]string, 0, len(drivers))
+	for name := range drivers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// lookupDriver resolves one of our driver names to its database/sql driver
+func lookupDriver(name string) (driver, error) {
+	d, ok := drivers[name]
+	if !ok {
+		return driver{}, fmt.Errorf("sqlite driver %q is not available in this build (available: %v)", name, Drivers())
+	}
+
+	return d, nil
+}
+
+// withQuery appends query parameters to a database path, keeping any that
+// are already present
+func withQuery(dbPath string, params url.Values) string {
+	if len(params) == 0 {
+		return dbPath
+	}
+	if strings.Contains(dbPath, "?") {
+		return dbPath + "&" + params.Encode()
+	}
+	return dbPath + "?" + params.Encode()
+}
@@ -0,0 +1,77 @@
+//go:build cgo
+
+package sqlitedb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+func init() {
+	drivers[DriverMattn] = driver{
+		sqlName: "sqlite3",
+		dsn:     mattnDSN,
+		backup:  mattnBackup,
+	}
+}
+
+// mattnDSN passes pragmas as go-sqlite3's underscore-prefixed parameters,
+// e.g. "_journal_mode=WAL"
+func mattnDSN(dbPath string, pragmas []pragma) string {
+	params := url.Values{}
+	for _, p := range pragmas {
+		params.Set("_"+p.name, p.value)
+	}
+	return withQuery(dbPath, params)
+}
+
+// mattnBackup runs an online backup through go-sqlite3's SQLiteConn.Backup
+func mattnBackup(ctx context.Context, db *sql.DB, destPath string) error {
+	destDB, err := sql.Open("sqlite3", destPath)
+	if err != nil {
+		return err
+	}
+	defer destDB.Close()
+
+	destConn, err := destDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer destConn.Close()
+
+	srcConn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer srcConn.Close()
+
+	return destConn.Raw(func(destRaw any) error {
+		return srcConn.Raw(func(srcRaw any) error {
+			dest, ok := destRaw.(*sqlite3.SQLiteConn)
+			if !ok {
+				return fmt.Errorf("unexpected destination connection type %T", destRaw)
+			}
+			src, ok := srcRaw.(*sqlite3.SQLiteConn)
+			if !ok {
+				return fmt.Errorf("unexpected source connection type %T", srcRaw)
+			}
+
+			b, err := dest.Backup("main", src, "main")
+			if err != nil {
+				return err
+			}
+
+			// A negative step copies every page in one go, holding the
+			// source read lock for the duration so the copy is consistent
+			if _, err := b.Step(-1); err != nil {
+				b.Finish()
+				return err
+			}
+			return b.Finish()
+		})
+	})
+}
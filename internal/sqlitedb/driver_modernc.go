@@ -0,0 +1,70 @@
+package sqlitedb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+
+	moderncsqlite "modernc.org/sqlite"
+)
+
+func init() {
+	drivers[DriverModernc] = driver{
+		sqlName: "sqlite",
+		dsn:     moderncDSN,
+		backup:  moderncBackup,
+	}
+}
+
+// moderncDSN passes pragmas as repeated "_pragma=name(value)" parameters,
+// which modernc.org/sqlite runs in order on every new connection
+func moderncDSN(dbPath string, pragmas []pragma) string {
+	params := url.Values{}
+	for _, p := range pragmas {
+		params.Add("_pragma", p.name+"("+p.value+")")
+	}
+	return withQuery(dbPath, params)
+}
+
+// moderncBackuper is implemented by modernc.org/sqlite's (unexported)
+// connection type
+type moderncBackuper interface {
+	NewBackup(dstURI string) (*moderncsqlite.Backup, error)
+}
+
+// moderncBackup runs an online backup through modernc.org/sqlite's
+// conn.NewBackup
+func moderncBackup(ctx context.Context, db *sql.DB, destPath string) error {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(raw any) error {
+		src, ok := raw.(moderncBackuper)
+		if !ok {
+			return fmt.Errorf("unexpected connection type %T", raw)
+		}
+
+		b, err := src.NewBackup(destPath)
+		if err != nil {
+			return err
+		}
+
+		// A negative step copies every page in one go, holding the source
+		// read lock for the duration so the copy is consistent
+		for {
+			more, err := b.Step(-1)
+			if err != nil {
+				b.Finish()
+				return err
+			}
+			if !more {
+				break
+			}
+		}
+		return b.Finish()
+	})
+}
@@ -0,0 +1,84 @@
+package sqlitedb
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Accepted values for Options.JournalMode and Options.Synchronous
+var (
+	journalModes     = []string{"DELETE", "TRUNCATE", "PERSIST", "MEMORY", "WAL", "OFF"}
+	synchronousModes = []string{"OFF", "NORMAL", "FULL", "EXTRA"}
+)
+
+// pragmas converts the options into the PRAGMAs applied on connect.
+// busy_timeout comes first so the remaining pragmas already wait on locks.
+func (o Options) pragmas() ([]pragma, error) {
+	var pragmas []pragma
+
+	if o.BusyTimeout < 0 {
+		return nil, fmt.Errorf("invalid busy timeout %s", o.BusyTimeout)
+	}
+	if o.BusyTimeout > 0 {
+		pragmas = append(pragmas, pragma{"busy_timeout", strconv.FormatInt(o.BusyTimeout.Milliseconds(), 10)})
+	}
+
+	if o.JournalMode != "" {
+		mode := strings.ToUpper(o.JournalMode)
+		if !contains(journalModes, mode) {
+			return nil, fmt.Errorf("invalid journal mode %q (valid: %s)", o.JournalMode, strings.Join(journalModes, ", "))
+		}
+		pragmas = append(pragmas, pragma{"journal_mode", mode})
+	}
+
+	if o.Synchronous != "" {
+		mode := strings.ToUpper(o.Synchronous)
+		if !contains(synchronousModes, mode) {
+			return nil, fmt.Errorf("invalid synchronous level %q (valid: %s)", o.Synchronous, strings.Join(synchronousModes, ", "))
+		}
+		pragmas = append(pragmas, pragma{"synchronous", mode})
+	}
+
+	if o.ForeignKeys != nil {
+		value := "0"
+		if *o.ForeignKeys {
+			value = "1"
+		}
+		pragmas = append(pragmas, pragma{"foreign_keys", value})
+	}
+
+	return pragmas, nil
+}
+
+// EffectivePragmas reads back the pragmas that are actually in effect on a
+// pooled connection, which can differ from what was requested (e.g. WAL is
+// unavailable for in-memory databases)
+func (db *DB) EffectivePragmas() (map[string]string, error) {
+	effective := make(map[string]string)
+
+	for _, name := range []string{"journal_mode", "synchronous", "busy_timeout", "foreign_keys"} {
+		var value string
+		if err := db.QueryRow("PRAGMA " + name).Scan(&value); err != nil {
+			return nil, fmt.Errorf("reading pragma %s: %w", name, err)
+		}
+		effective[name] = value
+	}
+
+	// synchronous reads back as its numeric level
+	if level, err := strconv.Atoi(effective["synchronous"]); err == nil && level >= 0 && level < len(synchronousModes) {
+		effective["synchronous"] = synchronousModes[level]
+	}
+	effective["journal_mode"] = strings.ToUpper(effective["journal_mode"])
+
+	return effective, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,123 @@
+// Package sqlitedb opens SQLite databases the same way for every domain:
+// the mattn (cgo) or modernc (pure Go) driver, pragmas applied to each
+// pooled connection, and connection pool settings.
+package sqlitedb
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/all-in-one/internal/config"
+)
+
+// Options configures a SQLite connection pool
+type Options struct {
+	// Driver selects the database/sql driver ("mattn" or "modernc").
+	// Empty means DefaultDriver().
+	Driver string
+
+	// JournalMode sets PRAGMA journal_mode (e.g. "WAL"). Empty keeps the
+	// SQLite default.
+	JournalMode string
+
+	// Synchronous sets PRAGMA synchronous ("OFF", "NORMAL", "FULL" or
+	// "EXTRA"). Empty keeps the SQLite default.
+	Synchronous string
+
+	// BusyTimeout is how long a connection waits on a locked database
+	// before failing with "database is locked". Zero keeps the driver default.
+	BusyTimeout time.Duration
+
+	// ForeignKeys sets PRAGMA foreign_keys on or off. Nil keeps the SQLite
+	// default.
+	ForeignKeys *bool
+
+	// MaxOpenConns, MaxIdleConns and ConnMaxIdleTime configure the
+	// database/sql connection pool. Zero keeps the database/sql default.
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxIdleTime time.Duration
+}
+
+// FromConfig returns the Options of the storage config section
+func FromConfig(cfg config.StorageConfig) Options {
+	foreignKeys := cfg.SQLite.ForeignKeys
+	return Options{
+		Driver:          cfg.Driver,
+		JournalMode:     cfg.SQLite.JournalMode,
+		Synchronous:     cfg.SQLite.Synchronous,
+		BusyTimeout:     cfg.SQLite.BusyTimeout,
+		ForeignKeys:     &foreignKeys,
+		MaxOpenConns:    cfg.SQLite.MaxOpenConns,
+		MaxIdleConns:    cfg.SQLite.MaxIdleConns,
+		ConnMaxIdleTime: cfg.SQLite.ConnMaxIdleTime,
+	}
+}
+
+// Bool returns a pointer to b, for Options.ForeignKeys
+func Bool(b bool) *bool {
+	return &b
+}
+
+// DB is a connection pool opened by Open, which remembers its driver
+type DB struct {
+	*sql.DB
+	driverName string
+	driver     driver
+}
+
+// Open opens a connection pool on dbPath with the driver, pragmas and pool
+// settings of opts, leaving the schema alone
+func Open(dbPath string, opts Options) (*DB, error) {
+	if opts.Driver == "" {
+		opts.Driver = DefaultDriver()
+	}
+
+	d, err := lookupDriver(opts.Driver)
+	if err != nil {
+		return nil, err
+	}
+
+	pragmas, err := opts.pragmas()
+	if err != nil {
+		return nil, err
+	}
+
+	// Pragmas go into the DSN rather than a one-off Exec so that every
+	// connection in the pool gets them, not just the first
+	db, err := sql.Open(d.sqlName, d.dsn(dbPath, pragmas))
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(opts.MaxOpenConns)
+	}
+	if opts.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(opts.MaxIdleConns)
+	}
+	if opts.ConnMaxIdleTime > 0 {
+		db.SetConnMaxIdleTime(opts.ConnMaxIdleTime)
+	}
+
+	return &DB{DB: db, driverName: opts.Driver, driver: d}, nil
+}
+
+// Driver returns the name of the driver, e.g. "mattn"
+func (db *DB) Driver() string {
+	return db.driverName
+}
+
+// Backup copies the live database into a new file at destPath with
+// SQLite's online backup API, so the copy is consistent even while writers
+// are active
+func (db *DB) Backup(ctx context.Context, destPath string) error {
+	return db.driver.backup(ctx, db.DB, destPath)
+}
+
+// OpenFile opens another database file, such as a backup, with the same
+// driver but none of the pragmas or pool settings
+func (db *DB) OpenFile(path string) (*sql.DB, error) {
+	return sql.Open(db.driver.sqlName, path)
+}
@@ -0,0 +1,112 @@
+package sqlitedb
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPragmasApplied(t *testing.T) {
+	for _, driver := range Drivers() {
+		t.Run(driver, func(t *testing.T) {
+			db, err := Open(filepath.Join(t.TempDir(), "test.db"), Options{
+				Driver:       driver,
+				JournalMode:  "wal",
+				Synchronous:  "normal",
+				BusyTimeout:  2500 * time.Millisecond,
+				ForeignKeys:  Bool(true),
+				MaxOpenConns: 3,
+			})
+			if err != nil {
+				t.Fatalf("Open: %v", err)
+			}
+			defer db.Close()
+
+			if db.Driver() != driver {
+				t.Errorf("Driver() = %q, want %q", db.Driver(), driver)
+			}
+
+			// Pin several pooled connections at once so the check covers
+			// more than a single connection
+			var wg sync.WaitGroup
+			errs := make(chan error, 3)
+			for i := 0; i < 3; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					tx, err := db.Begin()
+					if err != nil {
+						errs <- err
+						return
+					}
+					defer tx.Rollback()
+
+					var timeout, fk int
+					if err := tx.QueryRow("PRAGMA busy_timeout").Scan(&timeout); err != nil {
+						errs <- err
+						return
+					}
+					if err := tx.QueryRow("PRAGMA foreign_keys").Scan(&fk); err != nil {
+						errs <- err
+						return
+					}
+					if timeout != 2500 || fk != 1 {
+						errs <- fmt.Errorf("busy_timeout=%d foreign_keys=%d, want 2500 and 1", timeout, fk)
+					}
+					time.Sleep(20 * time.Millisecond)
+				}()
+			}
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				t.Error(err)
+			}
+
+			effective, err := db.EffectivePragmas()
+			if err != nil {
+				t.Fatalf("EffectivePragmas: %v", err)
+			}
+			if effective["journal_mode"] != "WAL" || effective["synchronous"] != "NORMAL" {
+				t.Errorf("effective pragmas = %v, want WAL and NORMAL", effective)
+			}
+		})
+	}
+}
+
+func TestForeignKeysOff(t *testing.T) {
+	for _, driver := range Drivers() {
+		t.Run(driver, func(t *testing.T) {
+			db, err := Open(filepath.Join(t.TempDir(), "test.db"), Options{Driver: driver, ForeignKeys: Bool(false)})
+			if err != nil {
+				t.Fatalf("Open: %v", err)
+			}
+			defer db.Close()
+
+			effective, err := db.EffectivePragmas()
+			if err != nil {
+				t.Fatalf("EffectivePragmas: %v", err)
+			}
+			if effective["foreign_keys"] != "0" {
+				t.Errorf("foreign_keys = %q, want 0", effective["foreign_keys"])
+			}
+		})
+	}
+}
+
+func TestInvalidOptions(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	for _, opts := range []Options{
+		{Driver: "nonexistent"},
+		{JournalMode: "wal; DROP TABLE listing_items"},
+		{Synchronous: "sometimes"},
+		{BusyTimeout: -time.Second},
+	} {
+		if db, err := Open(dbPath, opts); err == nil {
+			db.Close()
+			t.Errorf("Open(%+v) succeeded, want error", opts)
+		}
+	}
+}
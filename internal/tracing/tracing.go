@@ -0,0 +1,196 @@
+// Package tracing sets up OpenTelemetry tracing: a tracer provider
+// exporting over OTLP/HTTP or as JSON to stdout or a file, W3C trace
+// context propagation, and server spans for HTTP requests.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/all-in-one/internal/config"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.41.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// InstrumentationName names the tracers of this module
+const InstrumentationName = "github.com/all-in-one"
+
+// Exporters
+const (
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+	ExporterFile   = "file"
+)
+
+// Options configures tracing
+type Options struct {
+	// ServiceName and ServiceVersion describe the service in every span
+	ServiceName    string
+	ServiceVersion string
+
+	// Exporter is ExporterOTLP, ExporterStdout or ExporterFile
+	Exporter string
+
+	// Endpoint is the OTLP/HTTP collector URL, e.g.
+	// "http://localhost:4318". Empty means the OTEL_EXPORTER_OTLP_*
+	// environment variables or the exporter's default.
+	Endpoint string
+
+	// Headers are sent with every OTLP export, e.g. for authentication
+	Headers map[string]string
+
+	// File is where ExporterFile appends spans, one JSON object per line
+	File string
+
+	// SampleRatio is the fraction of new traces recorded. Requests that
+	// arrive with a sampled trace context are always recorded.
+	SampleRatio float64
+}
+
+// FromConfig returns the Options of the tracing config section for service
+func FromConfig(cfg config.TracingConfig, service, version string) Options {
+	return Options{
+		ServiceName:    service,
+		ServiceVersion: version,
+		Exporter:       cfg.Exporter,
+		Endpoint:       cfg.Endpoint,
+		Headers:        cfg.Headers,
+		File:           cfg.File,
+		SampleRatio:    cfg.SampleRatio,
+	}
+}
+
+// Provider is the tracer provider set up by Setup
+type Provider struct {
+	provider   *sdktrace.TracerProvider
+	propagator propagation.TextMapPropagator
+	closer     io.Closer
+}
+
+// Setup creates a tracer provider exporting as opts says and makes it, and
+// W3C trace context and baggage propagation, the global defaults. Spans are
+// exported in batches; call Shutdown to flush them before exiting.
+func Setup(ctx context.Context, opts Options) (*Provider, error) {
+	if opts.SampleRatio < 0 || opts.SampleRatio > 1 {
+		return nil, fmt.Errorf("tracing.sample_ratio must be between 0 and 1, not %v", opts.SampleRatio)
+	}
+
+	exporter, closer, err := newExporter(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := newProvider(exporter, closer, opts)
+	if err != nil {
+		if closer != nil {
+			closer.Close()
+		}
+		return nil, err
+	}
+
+	otel.SetTracerProvider(p.provider)
+	otel.SetTextMapPropagator(p.propagator)
+	return p, nil
+}
+
+// SetupFromConfig sets up tracing for service as configured under tracing
+// and logs where the spans go
+func SetupFromConfig(cfg config.TracingConfig, service string) (*Provider, error) {
+	provider, err := Setup(context.Background(), FromConfig(cfg, service, "1.0.0"))
+	if err != nil {
+		return nil, err
+	}
+
+	fields := logrus.Fields{"service": service, "exporter": cfg.Exporter, "sample_ratio": cfg.SampleRatio}
+	switch cfg.Exporter {
+	case ExporterFile:
+		fields["file"] = cfg.File
+	case ExporterOTLP, "":
+		fields["endpoint"] = cfg.Endpoint
+	}
+	logrus.WithFields(fields).Info("Tracing enabled")
+	return provider, nil
+}
+
+// newProvider creates a provider exporting to exporter
+func newProvider(exporter sdktrace.SpanExporter, closer io.Closer, opts Options) (*Provider, error) {
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName(opts.ServiceName),
+		semconv.ServiceVersion(opts.ServiceVersion),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Provider{
+		provider: sdktrace.NewTracerProvider(
+			sdktrace.WithBatcher(exporter),
+			sdktrace.WithResource(res),
+			sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opts.SampleRatio))),
+		),
+		propagator: propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}),
+		closer:     closer,
+	}, nil
+}
+
+// newExporter creates the span exporter opts asks for, with the file it
+// writes to, if any
+func newExporter(ctx context.Context, opts Options) (sdktrace.SpanExporter, io.Closer, error) {
+	switch opts.Exporter {
+	case ExporterOTLP, "":
+		var options []otlptracehttp.Option
+		if opts.Endpoint != "" {
+			options = append(options, otlptracehttp.WithEndpointURL(opts.Endpoint))
+		}
+		if len(opts.Headers) > 0 {
+			options = append(options, otlptracehttp.WithHeaders(opts.Headers))
+		}
+		exporter, err := otlptracehttp.New(ctx, options...)
+		return exporter, nil, err
+	case ExporterStdout:
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout), stdouttrace.WithPrettyPrint())
+		return exporter, nil, err
+	case ExporterFile:
+		if opts.File == "" {
+			return nil, nil, errors.New("the file exporter needs tracing.file")
+		}
+		f, err := os.OpenFile(opts.File, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, nil, err
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(f))
+		if err != nil {
+			f.Close()
+			return nil, nil, err
+		}
+		return exporter, f, nil
+	}
+
+	return nil, nil, fmt.Errorf("unknown tracing.exporter %q. Supported exporters: %s",
+		opts.Exporter, strings.Join([]string{ExporterOTLP, ExporterStdout, ExporterFile}, ", "))
+}
+
+// Tracer returns a tracer of the provider
+func (p *Provider) Tracer() trace.Tracer {
+	return p.provider.Tracer(InstrumentationName)
+}
+
+// Shutdown exports the spans still buffered and stops the provider
+func (p *Provider) Shutdown(ctx context.Context) error {
+	err := p.provider.Shutdown(ctx)
+	if p.closer != nil {
+		err = errors.Join(err, p.closer.Close())
+	}
+	return err
+}
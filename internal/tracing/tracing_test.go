@@ -0,0 +1,161 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/all-in-one/internal/config"
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// newTestProvider returns a provider recording spans in memory
+func newTestProvider(t *testing.T) (*Provider, *tracetest.InMemoryExporter) {
+	t.Helper()
+
+	exporter := tracetest.NewInMemoryExporter()
+	p, err := newProvider(exporter, nil, Options{ServiceName: "test", SampleRatio: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { p.Shutdown(context.Background()) })
+	return p, exporter
+}
+
+// spans returns the spans ended so far
+func spans(t *testing.T, p *Provider, exporter *tracetest.InMemoryExporter) tracetest.SpanStubs {
+	t.Helper()
+
+	if err := p.provider.ForceFlush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return exporter.GetSpans()
+}
+
+// attr returns the value of the attribute key of span
+func attr(span tracetest.SpanStub, key attribute.Key) attribute.Value {
+	for _, kv := range span.Attributes {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestMiddleware(t *testing.T) {
+	p, exporter := newTestProvider(t)
+
+	var handlerSpan trace.SpanContext
+	r := mux.NewRouter()
+	r.Use(p.Middleware)
+	r.HandleFunc("/api/v1/items/{id}", func(w http.ResponseWriter, r *http.Request) {
+		handlerSpan = trace.SpanContextFromContext(r.Context())
+		switch mux.Vars(r)["id"] {
+		case "404":
+			http.NotFound(w, r)
+		case "500":
+			http.Error(w, "boom", http.StatusInternalServerError)
+		}
+	}).Methods("GET")
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest("GET", "/api/v1/items/1", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/v1/items/404", nil))
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/v1/items/500", nil))
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/unknown", nil))
+
+	got := spans(t, p, exporter)
+	if len(got) != 3 {
+		t.Fatalf("got %d spans, want 3: requests matching no route aren't traced", len(got))
+	}
+
+	// The span continues the caller's trace
+	ok := got[0]
+	if ok.Name != "GET /api/v1/items/{id}" || ok.SpanKind != trace.SpanKindServer {
+		t.Errorf("span = %q of kind %v", ok.Name, ok.SpanKind)
+	}
+	if ok.SpanContext.TraceID().String() != traceID || ok.Parent.SpanID().String() != "00f067aa0ba902b7" || !ok.Parent.IsRemote() {
+		t.Errorf("span isn't a child of the traceparent: trace %s, parent %s", ok.SpanContext.TraceID(), ok.Parent.SpanID())
+	}
+	if handlerSpan.SpanID() != got[2].SpanContext.SpanID() {
+		t.Error("the handler of the last request didn't run within its span")
+	}
+	if v := attr(ok, "http.route").AsString(); v != "/api/v1/items/{id}" {
+		t.Errorf("http.route = %q", v)
+	}
+	if v := attr(ok, "url.path").AsString(); v != "/api/v1/items/1" {
+		t.Errorf("url.path = %q", v)
+	}
+	if v := attr(ok, "http.response.status_code").AsInt64(); v != 200 {
+		t.Errorf("http.response.status_code = %d, want 200", v)
+	}
+
+	// Without a traceparent a new trace starts
+	if got[1].Parent.IsValid() || got[1].SpanContext.TraceID() == ok.SpanContext.TraceID() {
+		t.Error("request without a traceparent joined another trace")
+	}
+
+	// Only server errors fail the span
+	if got[1].Status.Code == codes.Error || attr(got[1], "http.response.status_code").AsInt64() != 404 {
+		t.Errorf("404 span: status %v, code %d", got[1].Status.Code, attr(got[1], "http.response.status_code").AsInt64())
+	}
+	if got[2].Status.Code != codes.Error || attr(got[2], "error.type").AsString() != "500" {
+		t.Errorf("500 span: status %v, error.type %q", got[2].Status.Code, attr(got[2], "error.type").AsString())
+	}
+}
+
+func TestFileExporter(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "traces.jsonl")
+	p, err := Setup(context.Background(), Options{ServiceName: "test", Exporter: ExporterFile, File: file, SampleRatio: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, span := p.Tracer().Start(context.Background(), "work")
+	span.End()
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"Name":"work"`) || strings.Count(string(data), "\n") != 1 {
+		t.Errorf("file = %s, want one JSON line with the span", data)
+	}
+}
+
+func TestSetupFromConfig(t *testing.T) {
+	p, err := SetupFromConfig(config.TracingConfig{Exporter: ExporterStdout, SampleRatio: 1}, "test")
+	if err != nil {
+		t.Fatalf("SetupFromConfig: %v", err)
+	}
+	defer p.Shutdown(context.Background())
+
+	if _, err := SetupFromConfig(config.TracingConfig{Exporter: ExporterFile, SampleRatio: 1}, "test"); err == nil {
+		t.Error("SetupFromConfig without a file succeeded")
+	}
+}
+
+func TestSetupRejectsBadOptions(t *testing.T) {
+	for name, opts := range map[string]Options{
+		"unknown exporter":  {Exporter: "zipkin", SampleRatio: 1},
+		"file without path": {Exporter: ExporterFile, SampleRatio: 1},
+		"sample ratio":      {Exporter: ExporterStdout, SampleRatio: 2},
+	} {
+		if _, err := Setup(context.Background(), opts); err == nil {
+			t.Errorf("%s: Setup succeeded", name)
+		}
+	}
+}
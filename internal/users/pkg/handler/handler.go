@@ -0,0 +1,436 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/all-in-one/internal/common"
+	"github.com/all-in-one/internal/users/pkg/model"
+	"github.com/all-in-one/internal/users/pkg/password"
+	"github.com/all-in-one/internal/users/pkg/repository"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// Password length limits. The upper one keeps hashing cost bounded.
+const (
+	minPasswordLength = 8
+	maxPasswordLength = 256
+)
+
+// maxBodyBytes caps request bodies, which only ever hold a username and
+// passwords
+const maxBodyBytes = 4 << 10
+
+// DefaultSessionTTL is how long a session lasts when Options doesn't say
+const DefaultSessionTTL = 24 * time.Hour
+
+// usernamePattern is what a normalized username must look like
+var usernamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{2,31}$`)
+
+// Options configures the users handler
+type Options struct {
+	// SessionTTL is how long a session lasts after login. Zero means
+	// DefaultSessionTTL.
+	SessionTTL time.Duration
+
+	// SecureCookies marks the session cookie Secure, so browsers only send
+	// it over HTTPS
+	SecureCookies bool
+
+	// Params are the argon2id parameters for new password hashes. Zero
+	// means password.DefaultParams.
+	Params password.Params
+
+	// Clock tells the time for session expiry. Nil means the system clock.
+	Clock common.Clock
+
+	// MaxConcurrentHashes caps the password hashes computed at once, as
+	// each takes Params.Memory. Requests needing one beyond that get a 503.
+	// Zero means GOMAXPROCS.
+	MaxConcurrentHashes int
+}
+
+// Handler manages HTTP requests for the users service
+type Handler struct {
+	storage repository.Storage
+	opts    Options
+
+	// hashSlots holds a token for each password hash being computed
+	hashSlots chan struct{}
+
+	// dummyHash is verified against when a login names an unknown user,
+	// so the response takes as long as for a wrong password
+	dummyHash     string
+	dummyHashOnce sync.Once
+}
+
+// NewHandler creates a new users handler
+func NewHandler(storage repository.Storage, opts Options) *Handler {
+	if opts.SessionTTL <= 0 {
+		opts.SessionTTL = DefaultSessionTTL
+	}
+	if opts.Params == (password.Params{}) {
+		opts.Params = password.DefaultParams
+	}
+	if opts.Clock == nil {
+		opts.Clock = common.SystemClock{}
+	}
+	if opts.MaxConcurrentHashes <= 0 {
+		opts.MaxConcurrentHashes = runtime.GOMAXPROCS(0)
+	}
+
+	return &Handler{
+		storage:   storage,
+		opts:      opts,
+		hashSlots: make(chan struct{}, opts.MaxConcurrentHashes),
+	}
+}
+
+// RegisterRoutes registers the users routes to the given router
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/users/register", h.Register).Methods("POST").Name("register")
+	router.HandleFunc("/users/login", h.Login).Methods("POST").Name("login")
+	router.HandleFunc("/users/logout", h.requireSession(h.Logout)).Methods("POST").Name("logout")
+	router.HandleFunc("/users/me", h.requireSession(h.GetMe)).Methods("GET").Name("getMe")
+	router.HandleFunc("/users/password", h.requireSession(h.ChangePassword)).Methods("PUT").Name("changePassword")
+}
+
+// credentials is the body of register and login requests
+type credentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// passwordChange is the body of password change requests
+type passwordChange struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+// loginResult is the body of a successful login response
+type loginResult struct {
+	User      model.User `json:"user"`
+	ExpiresAt time.Time  `json:"expires_at"`
+}
+
+// passwordChanged is the body of a successful password change response
+type passwordChanged struct {
+	SessionsEnded int `json:"sessions_ended"`
+}
+
+// POST /users/register - Create a new user
+func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
+	var req credentials
+	if err := decodeJSON(w, r, &req); err != nil {
+		common.WriteError(w, r, err)
+		return
+	}
+
+	username := normalizeUsername(req.Username)
+	if err := validateRegistration(username, req.Password); err != nil {
+		common.WriteError(w, r, err)
+		return
+	}
+
+	release, err := h.acquireHashSlot(w)
+	if err != nil {
+		common.WriteError(w, r, err)
+		return
+	}
+	hash, err := password.Hash(req.Password, h.opts.Params)
+	release()
+	if err != nil {
+		common.WriteError(w, r, common.Internal("Failed to create user", err))
+		return
+	}
+
+	user, err := h.storage.Users().Create(model.User{Username: username, PasswordHash: hash})
+	if err != nil {
+		if errors.Is(err, common.ErrConflict) {
+			common.WriteError(w, r, common.Conflict("Username is already taken").WithCode("username_taken").Wrap(err))
+			return
+		}
+		common.WriteError(w, r, common.Internal("Failed to create user", err))
+		return
+	}
+
+	response := common.Response{
+		Success: true,
+		Message: "User registered successfully",
+		Data:    user,
+	}
+
+	sendJSON(w, response, http.StatusCreated)
+}
+
+// POST /users/login - Log in and start a session
+func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
+	var req credentials
+	if err := decodeJSON(w, r, &req); err != nil {
+		common.WriteError(w, r, err)
+		return
+	}
+
+	release, err := h.acquireHashSlot(w)
+	if err != nil {
+		common.WriteError(w, r, err)
+		return
+	}
+	user, err := h.authenticate(normalizeUsername(req.Username), req.Password)
+	release()
+	if err != nil {
+		common.WriteError(w, r, err)
+		return
+	}
+
+	now := h.opts.Clock.Now()
+	if _, err := h.storage.Sessions().DeleteExpired(now); err != nil {
+		logrus.WithError(err).Warn("Failed to delete expired sessions")
+	}
+
+	token, err := newSessionToken()
+	if err != nil {
+		common.WriteError(w, r, common.Internal("Failed to start session", err))
+		return
+	}
+	session, err := h.storage.Sessions().Create(model.Session{
+		TokenHash: hashSessionToken(token),
+		UserID:    user.ID,
+		ExpiresAt: now.Add(h.opts.SessionTTL),
+	})
+	if err != nil {
+		common.WriteError(w, r, common.Internal("Failed to start session", err))
+		return
+	}
+
+	h.setSessionCookie(w, token, session.ExpiresAt)
+
+	response := common.Response{
+		Success: true,
+		Message: "Logged in successfully",
+		Data: loginResult{
+			User:      user,
+			ExpiresAt: session.ExpiresAt,
+		},
+	}
+
+	sendJSON(w, response, http.StatusOK)
+}
+
+// POST /users/logout - End the current session
+func (h *Handler) Logout(w http.ResponseWriter, r *http.Request) {
+	session, _ := sessionFromContext(r.Context())
+
+	if err := h.storage.Sessions().Delete(session.TokenHash); err != nil && !errors.Is(err, common.ErrNotFound) {
+		common.WriteError(w, r, common.Internal("Failed to end session", err))
+		return
+	}
+
+	h.clearSessionCookie(w)
+
+	response := common.Response{
+		Success: true,
+		Message: "Logged out successfully",
+	}
+
+	sendJSON(w, response, http.StatusOK)
+}
+
+// GET /users/me - Get the logged-in user
+func (h *Handler) GetMe(w http.ResponseWriter, r *http.Request) {
+	user, _ := userFromContext(r.Context())
+
+	response := common.Response{
+		Success: true,
+		Data:    user,
+	}
+
+	sendJSON(w, response, http.StatusOK)
+}
+
+// PUT /users/password - Change the logged-in user's password and end their
+// other sessions
+func (h *Handler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	user, _ := userFromContext(r.Context())
+	session, _ := sessionFromContext(r.Context())
+
+	var req passwordChange
+	if err := decodeJSON(w, r, &req); err != nil {
+		common.WriteError(w, r, err)
+		return
+	}
+
+	release, err := h.acquireHashSlot(w)
+	if err != nil {
+		common.WriteError(w, r, err)
+		return
+	}
+	defer release()
+
+	ok, err := password.Verify(req.CurrentPassword, user.PasswordHash)
+	if err != nil {
+		common.WriteError(w, r, common.Internal("Failed to change password", err))
+		return
+	}
+	if !ok {
+		common.WriteError(w, r, common.Forbidden("Current password is incorrect").WithCode("wrong_password"))
+		return
+	}
+	if field := checkPassword("new_password", req.NewPassword); field != nil {
+		common.WriteError(w, r, common.Validation(field.Message, *field))
+		return
+	}
+
+	hash, err := password.Hash(req.NewPassword, h.opts.Params)
+	if err != nil {
+		common.WriteError(w, r, common.Internal("Failed to change password", err))
+		return
+	}
+	if _, err := h.storage.Users().UpdatePassword(user.ID, hash); err != nil {
+		common.WriteError(w, r, common.Internal("Failed to change password", err))
+		return
+	}
+
+	revoked, err := h.storage.Sessions().DeleteByUser(user.ID, session.TokenHash)
+	if err != nil {
+		common.WriteError(w, r, common.Internal("Password changed, but other sessions could not be ended", err))
+		return
+	}
+
+	response := common.Response{
+		Success: true,
+		Message: "Password changed successfully",
+		Data: passwordChanged{
+			SessionsEnded: revoked,
+		},
+	}
+
+	sendJSON(w, response, http.StatusOK)
+}
+
+// Helper Functions
+
+// authenticate returns the user with the given username and password.
+// Hashes made with older parameters are upgraded on the way.
+func (h *Handler) authenticate(username, pass string) (model.User, error) {
+	user, err := h.storage.Users().GetByUsername(username)
+	unknown := errors.Is(err, common.ErrNotFound)
+	if err != nil && !unknown {
+		return model.User{}, common.Internal("Failed to log in", err)
+	}
+
+	hash := user.PasswordHash
+	if unknown {
+		hash = h.getDummyHash()
+	}
+
+	ok, err := password.Verify(pass, hash)
+	if err != nil {
+		return model.User{}, common.Internal("Failed to log in", fmt.Errorf("password hash of user %d: %w", user.ID, err))
+	}
+	if unknown || !ok {
+		return model.User{}, common.Unauthorized("Invalid username or password").WithCode("invalid_credentials")
+	}
+
+	if password.NeedsRehash(user.PasswordHash, h.opts.Params) {
+		if rehashed, err := password.Hash(pass, h.opts.Params); err == nil {
+			if updated, err := h.storage.Users().UpdatePassword(user.ID, rehashed); err == nil {
+				user = updated
+			} else {
+				logrus.WithError(err).WithField("user_id", user.ID).Warn("Failed to upgrade password hash")
+			}
+		}
+	}
+
+	return user, nil
+}
+
+// acquireHashSlot reserves one of the concurrent password hashes, returning
+// the function that frees it. When all are taken it fails at once rather
+// than queueing, and asks the client to retry shortly.
+func (h *Handler) acquireHashSlot(w http.ResponseWriter) (release func(), err error) {
+	select {
+	case h.hashSlots <- struct{}{}:
+		return func() { <-h.hashSlots }, nil
+	default:
+		w.Header().Set("Retry-After", "1")
+		return nil, common.Unavailable("Too many password checks in progress, try again shortly").WithCode("busy")
+	}
+}
+
+// getDummyHash returns a hash of a random password made with the current
+// parameters
+func (h *Handler) getDummyHash() string {
+	h.dummyHashOnce.Do(func() {
+		token, _ := newSessionToken()
+		h.dummyHash, _ = password.Hash(token, h.opts.Params)
+	})
+	return h.dummyHash
+}
+
+// normalizeUsername makes usernames case-insensitive
+func normalizeUsername(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
+
+// validateRegistration checks a normalized username and password for a
+// new user
+func validateRegistration(username, pass string) error {
+	var fields []common.FieldError
+	if !usernamePattern.MatchString(username) {
+		fields = append(fields, common.FieldError{
+			Field:   "username",
+			Code:    "invalid_format",
+			Message: "Username must be 3-32 characters of a-z, 0-9, '.', '_' or '-', starting with a letter or digit",
+		})
+	}
+	if field := checkPassword("password", pass); field != nil {
+		fields = append(fields, *field)
+	}
+
+	if len(fields) > 0 {
+		return common.Validation(fields[0].Message, fields...)
+	}
+	return nil
+}
+
+// checkPassword returns what's wrong with a new password in field, or nil
+// if it's acceptable
+func checkPassword(field, pass string) *common.FieldError {
+	if utf8.RuneCountInString(pass) < minPasswordLength {
+		return &common.FieldError{Field: field, Code: "too_short", Message: "Password must be at least 8 characters"}
+	}
+	if len(pass) > maxPasswordLength {
+		return &common.FieldError{Field: field, Code: "too_long", Message: "Password must be at most 256 bytes"}
+	}
+	return nil
+}
+
+// decodeJSON decodes the body of r, of at most maxBodyBytes, into v
+func decodeJSON(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes)).Decode(v)
+	var tooLarge *http.MaxBytesError
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &tooLarge):
+		return common.TooLarge(fmt.Sprintf("Request body must be at most %d bytes", tooLarge.Limit)).Wrap(err)
+	}
+	return common.Validation("Invalid JSON data").WithCode("invalid_json").Wrap(err)
+}
+
+// sendJSON sends a JSON response
+func sendJSON(w http.ResponseWriter, data interface{}, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(data)
+}
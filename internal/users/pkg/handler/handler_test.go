@@ -0,0 +1,255 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/all-in-one/internal/common"
+	"github.com/all-in-one/internal/openapi"
+	"github.com/all-in-one/internal/users/pkg/model"
+	"github.com/all-in-one/internal/users/pkg/password"
+	"github.com/all-in-one/internal/users/pkg/repository"
+	"github.com/gorilla/mux"
+)
+
+// testParams keep the tests fast; they're far too cheap for real use
+var testParams = password.Params{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+// newTestRouter returns a router serving a handler on memory storage
+func newTestRouter(t *testing.T, clock common.Clock) (*mux.Router, repository.Storage) {
+	t.Helper()
+
+	store, err := repository.NewStorage("memory", "", repository.Options{Clock: clock})
+	if err != nil {
+		t.Fatalf("NewStorage: %v", err)
+	}
+
+	router := mux.NewRouter()
+	NewHandler(store, Options{SessionTTL: time.Hour, Params: testParams, Clock: clock}).RegisterRoutes(router)
+	return router, store
+}
+
+// do sends a request with an optional session cookie and returns the
+// recorded response
+func do(router http.Handler, method, path, body string, cookie *http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+// login logs in and returns the session cookie
+func login(t *testing.T, router http.Handler, username, pass string) *http.Cookie {
+	t.Helper()
+
+	rec := do(router, "POST", "/users/login", `{"username":"`+username+`","password":"`+pass+`"}`, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("login: status %d, body %s", rec.Code, rec.Body)
+	}
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == CookieName {
+			if !c.HttpOnly || c.SameSite != http.SameSiteLaxMode {
+				t.Errorf("session cookie HttpOnly=%v SameSite=%v, want HttpOnly and Lax", c.HttpOnly, c.SameSite)
+			}
+			return c
+		}
+	}
+	t.Fatal("login set no session cookie")
+	return nil
+}
+
+func TestRegister(t *testing.T) {
+	router, store := newTestRouter(t, common.SystemClock{})
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"valid", `{"username":" Alice ","password":"correct horse"}`, http.StatusCreated},
+		{"taken, in another case", `{"username":"ALICE","password":"correct horse"}`, http.StatusConflict},
+		{"short username", `{"username":"al","password":"correct horse"}`, http.StatusBadRequest},
+		{"bad characters", `{"username":"al ice","password":"correct horse"}`, http.StatusBadRequest},
+		{"short password", `{"username":"bob","password":"short"}`, http.StatusBadRequest},
+		{"invalid JSON", `{`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := do(router, "POST", "/users/register", tt.body, nil)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d; body %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+
+	user, err := store.Users().GetByUsername("alice")
+	if err != nil {
+		t.Fatalf("registered user not stored: %v", err)
+	}
+	if ok, _ := password.Verify("correct horse", user.PasswordHash); !ok {
+		t.Error("stored hash doesn't verify the password")
+	}
+	if rec := do(router, "POST", "/users/register", `{"username":"bob","password":"correct horse"}`, nil); strings.Contains(rec.Body.String(), "argon2id") {
+		t.Errorf("response leaks the password hash: %s", rec.Body)
+	}
+}
+
+func TestLoginAndSession(t *testing.T) {
+	clock := common.NewManualClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	router, _ := newTestRouter(t, clock)
+	do(router, "POST", "/users/register", `{"username":"alice","password":"correct horse"}`, nil)
+
+	for _, body := range []string{
+		`{"username":"alice","password":"wrong horse"}`,
+		`{"username":"nobody","password":"correct horse"}`,
+	} {
+		if rec := do(router, "POST", "/users/login", body, nil); rec.Code != http.StatusUnauthorized {
+			t.Errorf("login %s: status %d, want 401", body, rec.Code)
+		}
+	}
+
+	if rec := do(router, "GET", "/users/me", "", nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("me without a session: status %d, want 401", rec.Code)
+	}
+
+	cookie := login(t, router, "Alice", "correct horse")
+	rec := do(router, "GET", "/users/me", "", cookie)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("me: status %d, body %s", rec.Code, rec.Body)
+	}
+	var resp struct {
+		Data struct {
+			Username string `json:"username"`
+		} `json:"data"`
+	}
+	json.NewDecoder(rec.Body).Decode(&resp)
+	if resp.Data.Username != "alice" {
+		t.Errorf("me username = %q, want alice", resp.Data.Username)
+	}
+
+	// Sessions expire after the TTL
+	clock.Advance(time.Hour)
+	if rec := do(router, "GET", "/users/me", "", cookie); rec.Code != http.StatusUnauthorized {
+		t.Errorf("me after expiry: status %d, want 401", rec.Code)
+	}
+
+	// Logging out ends the session
+	cookie = login(t, router, "alice", "correct horse")
+	if rec := do(router, "POST", "/users/logout", "", cookie); rec.Code != http.StatusOK {
+		t.Fatalf("logout: status %d, body %s", rec.Code, rec.Body)
+	}
+	if rec := do(router, "GET", "/users/me", "", cookie); rec.Code != http.StatusUnauthorized {
+		t.Errorf("me after logout: status %d, want 401", rec.Code)
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	router, _ := newTestRouter(t, common.SystemClock{})
+	do(router, "POST", "/users/register", `{"username":"alice","password":"correct horse"}`, nil)
+	current := login(t, router, "alice", "correct horse")
+	other := login(t, router, "alice", "correct horse")
+
+	if rec := do(router, "PUT", "/users/password", `{"current_password":"wrong horse","new_password":"battery staple"}`, current); rec.Code != http.StatusForbidden {
+		t.Errorf("wrong current password: status %d, want 403", rec.Code)
+	}
+	if rec := do(router, "PUT", "/users/password", `{"current_password":"correct horse","new_password":"short"}`, current); rec.Code != http.StatusBadRequest {
+		t.Errorf("short new password: status %d, want 400", rec.Code)
+	}
+
+	rec := do(router, "PUT", "/users/password", `{"current_password":"correct horse","new_password":"battery staple"}`, current)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("change password: status %d, body %s", rec.Code, rec.Body)
+	}
+
+	if rec := do(router, "GET", "/users/me", "", current); rec.Code != http.StatusOK {
+		t.Errorf("me on the changing session: status %d, want 200", rec.Code)
+	}
+	if rec := do(router, "GET", "/users/me", "", other); rec.Code != http.StatusUnauthorized {
+		t.Errorf("me on another session: status %d, want 401", rec.Code)
+	}
+	if rec := do(router, "POST", "/users/login", `{"username":"alice","password":"correct horse"}`, nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("login with the old password: status %d, want 401", rec.Code)
+	}
+	login(t, router, "alice", "battery staple")
+}
+
+func TestLoginUpgradesOldHashes(t *testing.T) {
+	router, store := newTestRouter(t, common.SystemClock{})
+
+	weak := testParams
+	weak.Iterations = 2
+	hash, _ := password.Hash("correct horse", weak)
+	user, _ := store.Users().Create(model.User{Username: "alice", PasswordHash: hash})
+
+	login(t, router, "alice", "correct horse")
+
+	user, _ = store.Users().Get(user.ID)
+	if password.NeedsRehash(user.PasswordHash, testParams) {
+		t.Errorf("hash after login = %q, want it made with the current parameters", user.PasswordHash)
+	}
+}
+
+func TestOversizedBodyIsRejected(t *testing.T) {
+	router, _ := newTestRouter(t, common.SystemClock{})
+
+	body := `{"username":"alice","password":"` + strings.Repeat("x", maxBodyBytes) + `"}`
+	for _, path := range []string{"/users/register", "/users/login"} {
+		rec := do(router, "POST", path, body, nil)
+		if rec.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("%s with a %d-byte body: status %d, want 413", path, len(body), rec.Code)
+		}
+	}
+}
+
+func TestConcurrentHashesAreCapped(t *testing.T) {
+	store, err := repository.NewStorage("memory", "", repository.Options{})
+	if err != nil {
+		t.Fatalf("NewStorage: %v", err)
+	}
+	h := NewHandler(store, Options{Params: testParams, MaxConcurrentHashes: 1})
+	router := mux.NewRouter()
+	h.RegisterRoutes(router)
+	do(router, "POST", "/users/register", `{"username":"alice","password":"correct horse"}`, nil)
+
+	// Hold the only slot, as a login in progress would
+	release, err := h.acquireHashSlot(httptest.NewRecorder())
+	if err != nil {
+		t.Fatalf("acquireHashSlot: %v", err)
+	}
+
+	rec := do(router, "POST", "/users/login", `{"username":"alice","password":"correct horse"}`, nil)
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("login while saturated: status %d, Retry-After %q; want 503 with Retry-After", rec.Code, rec.Header().Get("Retry-After"))
+	}
+
+	release()
+	login(t, router, "alice", "correct horse")
+}
+
+func TestOperationsDescribeEveryRoute(t *testing.T) {
+	store, _ := repository.NewStorage("memory", "", repository.Options{})
+	h := NewHandler(store, Options{Params: testParams})
+	router := mux.NewRouter()
+	h.RegisterRoutes(router)
+
+	endpoints, err := openapi.Endpoints(router)
+	if err != nil {
+		t.Fatalf("Endpoints: %v", err)
+	}
+	ops := h.Operations()
+	for _, e := range endpoints {
+		if _, ok := ops[e.Name]; !ok {
+			t.Errorf("%s %s (route %q) has no Operation", e.Method, e.Path, e.Name)
+		}
+	}
+	if len(endpoints) != len(ops) {
+		t.Errorf("%d routes, %d operations", len(endpoints), len(ops))
+	}
+}
@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/all-in-one/internal/openapi"
+	"github.com/all-in-one/internal/users/pkg/model"
+)
+
+// SessionCookie is the security scheme of the routes that need a session
+const SessionCookie = "sessionCookie"
+
+// SecuritySchemes are the security schemes the users Operations refer to
+var SecuritySchemes = map[string]openapi.SecurityScheme{
+	SessionCookie: {
+		Type:        "apiKey",
+		In:          "cookie",
+		Name:        CookieName,
+		Description: "The session cookie set by POST /users/login",
+	},
+}
+
+// Operations describes the routes registered by RegisterRoutes, by route
+// name
+func (h *Handler) Operations() openapi.Operations {
+	security := []string{SessionCookie}
+	return openapi.Operations{
+		"register": {
+			Summary:  "Create an account",
+			Tags:     []string{"users"},
+			Request:  credentials{},
+			Response: model.User{},
+			Status:   http.StatusCreated,
+			Errors:   []int{http.StatusConflict, http.StatusRequestEntityTooLarge, http.StatusServiceUnavailable},
+		},
+		"login": {
+			Summary:     "Log in",
+			Description: "Sets the session cookie on success.",
+			Tags:        []string{"users"},
+			Request:     credentials{},
+			Response:    loginResult{},
+			Errors:      []int{http.StatusUnauthorized, http.StatusRequestEntityTooLarge, http.StatusServiceUnavailable},
+		},
+		"logout": {
+			Summary:  "Log out",
+			Tags:     []string{"users"},
+			Security: security,
+		},
+		"getMe": {
+			Summary:  "Get the logged-in user",
+			Tags:     []string{"users"},
+			Security: security,
+			Response: model.User{},
+		},
+		"changePassword": {
+			Summary:  "Change password, ending other sessions",
+			Tags:     []string{"users"},
+			Security: security,
+			Request:  passwordChange{},
+			Response: passwordChanged{},
+			Errors:   []int{http.StatusForbidden, http.StatusRequestEntityTooLarge, http.StatusServiceUnavailable},
+		},
+	}
+}
@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/all-in-one/internal/common"
+	"github.com/all-in-one/internal/users/pkg/model"
+)
+
+// CookieName is the name of the session cookie
+const CookieName = "session"
+
+// errNotLoggedIn is sent for requests without a valid session
+var errNotLoggedIn = common.Unauthorized("Not logged in").WithCode("not_logged_in")
+
+// contextKey is the type of the request context keys set by requireSession
+type contextKey int
+
+const (
+	sessionKey contextKey = iota
+	userKey
+)
+
+// requireSession only calls next for requests carrying the cookie of an
+// unexpired session, with the session and its user in the request context
+func (h *Handler) requireSession(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie(CookieName)
+		if err != nil || cookie.Value == "" {
+			common.WriteError(w, r, errNotLoggedIn)
+			return
+		}
+
+		session, err := h.storage.Sessions().Get(hashSessionToken(cookie.Value))
+		if err != nil {
+			if errors.Is(err, common.ErrNotFound) {
+				h.clearSessionCookie(w)
+				common.WriteError(w, r, errNotLoggedIn)
+				return
+			}
+			common.WriteError(w, r, common.Internal("Failed to check session", err))
+			return
+		}
+		if session.Expired(h.opts.Clock.Now()) {
+			h.storage.Sessions().Delete(session.TokenHash)
+			h.clearSessionCookie(w)
+			common.WriteError(w, r, common.Unauthorized("Session expired").WithCode("session_expired"))
+			return
+		}
+
+		user, err := h.storage.Users().Get(session.UserID)
+		if err != nil {
+			if errors.Is(err, common.ErrNotFound) {
+				h.clearSessionCookie(w)
+				common.WriteError(w, r, errNotLoggedIn)
+				return
+			}
+			common.WriteError(w, r, common.Internal("Failed to check session", err))
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), sessionKey, session)
+		ctx = context.WithValue(ctx, userKey, user)
+		next(w, r.WithContext(ctx))
+	}
+}
+
+// setSessionCookie hands the session token to the client. The cookie is
+// HttpOnly, so scripts can't read it, and SameSite=Lax, so other sites
+// can't make the browser send it with their POSTs.
+func (h *Handler) setSessionCookie(w http.ResponseWriter, token string, expiresAt time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     CookieName,
+		Value:    token,
+		Path:     "/",
+		Expires:  expiresAt,
+		MaxAge:   int(h.opts.SessionTTL.Seconds()),
+		Secure:   h.opts.SecureCookies,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// clearSessionCookie tells the client to drop the session cookie
+func (h *Handler) clearSessionCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     CookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		Secure:   h.opts.SecureCookies,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// sessionFromContext returns the session put in the context by
+// requireSession
+func sessionFromContext(ctx context.Context) (model.Session, bool) {
+	session, ok := ctx.Value(sessionKey).(model.Session)
+	return session, ok
+}
+
+// userFromContext returns the user put in the context by requireSession
+func userFromContext(ctx context.Context) (model.User, bool) {
+	user, ok := ctx.Value(userKey).(model.User)
+	return user, ok
+}
+
+// newSessionToken returns a random session token with 256 bits of entropy
+func newSessionToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashSessionToken returns the hex SHA-256 of a session token, the form
+// it's stored in. The token is random, so a fast hash is enough.
+func hashSessionToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,27 @@
+package model
+
+import "time"
+
+// User is an account that can log in with a username and password. Only
+// the argon2id hash of the password is stored.
+type User struct {
+	ID           int       `json:"id" openapi:"readonly"`
+	Username     string    `json:"username"`
+	PasswordHash string    `json:"-"`
+	CreatedAt    time.Time `json:"created_at" openapi:"readonly"`
+	UpdatedAt    time.Time `json:"updated_at" openapi:"readonly"`
+}
+
+// Session is a logged-in user's session. The token itself lives only in
+// the client's cookie; storage keeps its hash.
+type Session struct {
+	TokenHash string    `json:"-"` // hex SHA-256 of the session token
+	UserID    int       `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Expired reports whether the session has expired at now
+func (s Session) Expired(now time.Time) bool {
+	return !now.Before(s.ExpiresAt)
+}
@@ -0,0 +1,72 @@
+package password
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// testParams keep the tests fast; they're far too cheap for real use
+var testParams = Params{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func TestHashAndVerify(t *testing.T) {
+	encoded, err := Hash("correct horse battery staple", testParams)
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+	if !strings.HasPrefix(encoded, "$argon2id$v=19$m=64,t=1,p=1$") {
+		t.Errorf("Hash = %q, want an argon2id PHC string with the given parameters", encoded)
+	}
+
+	ok, err := Verify("correct horse battery staple", encoded)
+	if err != nil || !ok {
+		t.Errorf("Verify(right password) = %v, %v; want true", ok, err)
+	}
+	ok, err = Verify("Correct horse battery staple", encoded)
+	if err != nil || ok {
+		t.Errorf("Verify(wrong password) = %v, %v; want false", ok, err)
+	}
+}
+
+func TestHashUsesFreshSalt(t *testing.T) {
+	a, _ := Hash("secret", testParams)
+	b, _ := Hash("secret", testParams)
+	if a == b {
+		t.Error("two hashes of the same password are equal")
+	}
+}
+
+func TestVerifyRejectsInvalidHashes(t *testing.T) {
+	valid, _ := Hash("secret", testParams)
+	parts := strings.Split(valid, "$")
+
+	for _, encoded := range []string{
+		"",
+		"plaintext",
+		"$argon2i$v=19$m=64,t=1,p=1$" + parts[4] + "$" + parts[5],
+		"$argon2id$v=16$m=64,t=1,p=1$" + parts[4] + "$" + parts[5],
+		"$argon2id$v=19$m=0,t=1,p=1$" + parts[4] + "$" + parts[5],
+		"$argon2id$v=19$m=64,t=1,p=1$!!$" + parts[5],
+		"$argon2id$v=19$m=64,t=1,p=1$" + parts[4] + "$",
+	} {
+		if _, err := Verify("secret", encoded); !errors.Is(err, ErrInvalidHash) {
+			t.Errorf("Verify(%q) error = %v, want ErrInvalidHash", encoded, err)
+		}
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	encoded, _ := Hash("secret", testParams)
+	if NeedsRehash(encoded, testParams) {
+		t.Error("NeedsRehash with the same parameters = true")
+	}
+
+	stronger := testParams
+	stronger.Iterations = 2
+	if !NeedsRehash(encoded, stronger) {
+		t.Error("NeedsRehash with more iterations = false")
+	}
+	if !NeedsRehash("garbage", testParams) {
+		t.Error("NeedsRehash of an invalid hash = false")
+	}
+}
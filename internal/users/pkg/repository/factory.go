@@ -0,0 +1,160 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/all-in-one/internal/common"
+	"github.com/all-in-one/internal/sqlitedb"
+	"github.com/all-in-one/internal/users/pkg/model"
+	"github.com/all-in-one/internal/users/pkg/repository/memory"
+	"github.com/all-in-one/internal/users/pkg/repository/sqlite"
+)
+
+// storageWrapper wraps the different storage implementations
+type storageWrapper struct {
+	storageType string
+	memStorage  memory.Storage
+	sqlStorage  sqlite.Storage
+}
+
+func (s *storageWrapper) Users() UserRepository {
+	if s.storageType == "memory" {
+		return &userRepositoryWrapper{
+			storageType: "memory",
+			memRepo:     s.memStorage.Users(),
+		}
+	}
+	return &userRepositoryWrapper{
+		storageType: "sqlite",
+		sqlRepo:     s.sqlStorage.Users(),
+	}
+}
+
+func (s *storageWrapper) Sessions() SessionRepository {
+	if s.storageType == "memory" {
+		return &sessionRepositoryWrapper{
+			storageType: "memory",
+			memRepo:     s.memStorage.Sessions(),
+		}
+	}
+	return &sessionRepositoryWrapper{
+		storageType: "sqlite",
+		sqlRepo:     s.sqlStorage.Sessions(),
+	}
+}
+
+func (s *storageWrapper) Close() error {
+	if s.storageType == "memory" {
+		return s.memStorage.Close()
+	}
+	return s.sqlStorage.Close()
+}
+
+// userRepositoryWrapper wraps the different user repository implementations
+type userRepositoryWrapper struct {
+	storageType string
+	memRepo     memory.UserRepository
+	sqlRepo     sqlite.UserRepository
+}
+
+func (r *userRepositoryWrapper) Create(user model.User) (model.User, error) {
+	if r.storageType == "memory" {
+		return r.memRepo.Create(user)
+	}
+	return r.sqlRepo.Create(user)
+}
+
+func (r *userRepositoryWrapper) Get(id int) (model.User, error) {
+	if r.storageType == "memory" {
+		return r.memRepo.Get(id)
+	}
+	return r.sqlRepo.Get(id)
+}
+
+func (r *userRepositoryWrapper) GetByUsername(username string) (model.User, error) {
+	if r.storageType == "memory" {
+		return r.memRepo.GetByUsername(username)
+	}
+	return r.sqlRepo.GetByUsername(username)
+}
+
+func (r *userRepositoryWrapper) UpdatePassword(id int, passwordHash string) (model.User, error) {
+	if r.storageType == "memory" {
+		return r.memRepo.UpdatePassword(id, passwordHash)
+	}
+	return r.sqlRepo.UpdatePassword(id, passwordHash)
+}
+
+// sessionRepositoryWrapper wraps the different session repository implementations
+type sessionRepositoryWrapper struct {
+	storageType string
+	memRepo     memory.SessionRepository
+	sqlRepo     sqlite.SessionRepository
+}
+
+func (r *sessionRepositoryWrapper) Create(session model.Session) (model.Session, error) {
+	if r.storageType == "memory" {
+		return r.memRepo.Create(session)
+	}
+	return r.sqlRepo.Create(session)
+}
+
+func (r *sessionRepositoryWrapper) Get(tokenHash string) (model.Session, error) {
+	if r.storageType == "memory" {
+		return r.memRepo.Get(tokenHash)
+	}
+	return r.sqlRepo.Get(tokenHash)
+}
+
+func (r *sessionRepositoryWrapper) Delete(tokenHash string) error {
+	if r.storageType == "memory" {
+		return r.memRepo.Delete(tokenHash)
+	}
+	return r.sqlRepo.Delete(tokenHash)
+}
+
+func (r *sessionRepositoryWrapper) DeleteByUser(userID int, keep string) (int, error) {
+	if r.storageType == "memory" {
+		return r.memRepo.DeleteByUser(userID, keep)
+	}
+	return r.sqlRepo.DeleteByUser(userID, keep)
+}
+
+func (r *sessionRepositoryWrapper) DeleteExpired(now time.Time) (int, error) {
+	if r.storageType == "memory" {
+		return r.memRepo.DeleteExpired(now)
+	}
+	return r.sqlRepo.DeleteExpired(now)
+}
+
+// Options holds storage settings
+type Options struct {
+	// Clock supplies timestamps to every backend. Nil means the system clock.
+	Clock common.Clock
+
+	// SQLite holds the SQLite connection settings
+	SQLite sqlitedb.Options
+}
+
+// NewStorage creates a new storage instance based on the storage type
+func NewStorage(storageType, connectionString string, opts Options) (Storage, error) {
+	switch storageType {
+	case "memory":
+		return &storageWrapper{
+			storageType: "memory",
+			memStorage:  memory.NewStorage(memory.Options{Clock: opts.Clock}),
+		}, nil
+	case "sqlite":
+		sqlStorage, err := sqlite.NewStorage(connectionString, sqlite.Options{Connection: opts.SQLite, Clock: opts.Clock})
+		if err != nil {
+			return nil, err
+		}
+		return &storageWrapper{
+			storageType: "sqlite",
+			sqlStorage:  sqlStorage,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported storage type: %s", storageType)
+	}
+}
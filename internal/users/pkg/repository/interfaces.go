@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/all-in-one/internal/users/pkg/model"
+)
+
+// UserRepository defines the interface for user storage operations
+type UserRepository interface {
+	// Create stores a new user, assigning its ID and timestamps. It returns
+	// common.ErrConflict if the username is taken.
+	Create(user model.User) (model.User, error)
+
+	// Get returns a user by ID
+	Get(id int) (model.User, error)
+
+	// GetByUsername returns a user by username
+	GetByUsername(username string) (model.User, error)
+
+	// UpdatePassword replaces a user's password hash
+	UpdatePassword(id int, passwordHash string) (model.User, error)
+}
+
+// SessionRepository defines the interface for session storage operations
+type SessionRepository interface {
+	// Create stores a new session, setting its creation time. It returns
+	// common.ErrConflict if a session with the same token hash exists.
+	Create(session model.Session) (model.Session, error)
+
+	// Get returns the session with the given token hash, expired or not
+	Get(tokenHash string) (model.Session, error)
+
+	// Delete removes a session
+	Delete(tokenHash string) error
+
+	// DeleteByUser removes all of a user's sessions except the one with
+	// token hash keep, which may be empty, and returns how many it removed
+	DeleteByUser(userID int, keep string) (int, error)
+
+	// DeleteExpired removes sessions that expired at or before now and
+	// returns how many it removed
+	DeleteExpired(now time.Time) (int, error)
+}
+
+// Storage defines the main storage interface that aggregates all repositories
+type Storage interface {
+	// Users returns the user repository
+	Users() UserRepository
+
+	// Sessions returns the session repository
+	Sessions() SessionRepository
+
+	// Close closes the storage connection
+	Close() error
+}
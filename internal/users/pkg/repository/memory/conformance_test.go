@@ -0,0 +1,16 @@
+package memory_test
+
+import (
+	"testing"
+
+	"github.com/all-in-one/internal/common"
+	"github.com/all-in-one/internal/users/pkg/repository"
+	"github.com/all-in-one/internal/users/pkg/repository/repotest"
+)
+
+func TestConformance(t *testing.T) {
+	repotest.RunStorage(t, func(t *testing.T, clock *common.ManualClock) repository.Storage {
+		store, _ := repository.NewStorage("memory", "", repository.Options{Clock: clock})
+		return store
+	})
+}
@@ -0,0 +1,96 @@
+package memory
+
+import (
+	"sync"
+	"time"
+
+	"github.com/all-in-one/internal/common"
+	"github.com/all-in-one/internal/users/pkg/model"
+)
+
+// sessionRepository implements the session repository with in-memory storage
+type sessionRepository struct {
+	sessions map[string]model.Session
+	mutex    sync.RWMutex
+	clock    common.Clock
+}
+
+// newSessionRepository creates a new memory-based session repository
+func newSessionRepository(clock common.Clock) *sessionRepository {
+	return &sessionRepository{
+		sessions: make(map[string]model.Session),
+		clock:    clock,
+	}
+}
+
+// Create stores a new session
+func (r *sessionRepository) Create(session model.Session) (model.Session, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, exists := r.sessions[session.TokenHash]; exists {
+		return model.Session{}, common.ErrConflict
+	}
+
+	session.CreatedAt = r.clock.Now()
+	r.sessions[session.TokenHash] = session
+	return session, nil
+}
+
+// Get returns the session with the given token hash
+func (r *sessionRepository) Get(tokenHash string) (model.Session, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	session, exists := r.sessions[tokenHash]
+	if !exists {
+		return model.Session{}, common.ErrNotFound
+	}
+
+	return session, nil
+}
+
+// Delete removes a session
+func (r *sessionRepository) Delete(tokenHash string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, exists := r.sessions[tokenHash]; !exists {
+		return common.ErrNotFound
+	}
+
+	delete(r.sessions, tokenHash)
+	return nil
+}
+
+// DeleteByUser removes all of a user's sessions except keep
+func (r *sessionRepository) DeleteByUser(userID int, keep string) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	n := 0
+	for hash, session := range r.sessions {
+		if session.UserID == userID && hash != keep {
+			delete(r.sessions, hash)
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+// DeleteExpired removes sessions that have expired at now
+func (r *sessionRepository) DeleteExpired(now time.Time) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	n := 0
+	for hash, session := range r.sessions {
+		if session.Expired(now) {
+			delete(r.sessions, hash)
+			n++
+		}
+	}
+
+	return n, nil
+}
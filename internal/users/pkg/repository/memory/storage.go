@@ -0,0 +1,71 @@
+package memory
+
+import (
+	"time"
+
+	"github.com/all-in-one/internal/common"
+	"github.com/all-in-one/internal/users/pkg/model"
+)
+
+// UserRepository defines the interface for user storage operations (local copy to avoid import cycle)
+type UserRepository interface {
+	Create(user model.User) (model.User, error)
+	Get(id int) (model.User, error)
+	GetByUsername(username string) (model.User, error)
+	UpdatePassword(id int, passwordHash string) (model.User, error)
+}
+
+// SessionRepository defines the interface for session storage operations (local copy to avoid import cycle)
+type SessionRepository interface {
+	Create(session model.Session) (model.Session, error)
+	Get(tokenHash string) (model.Session, error)
+	Delete(tokenHash string) error
+	DeleteByUser(userID int, keep string) (int, error)
+	DeleteExpired(now time.Time) (int, error)
+}
+
+// Storage defines the main storage interface (local copy to avoid import cycle)
+type Storage interface {
+	Users() UserRepository
+	Sessions() SessionRepository
+	Close() error
+}
+
+// Options configures the memory storage
+type Options struct {
+	// Clock supplies timestamps. Nil means the system clock.
+	Clock common.Clock
+}
+
+// storage implements Storage with in-memory storage
+type storage struct {
+	userRepo    *userRepository
+	sessionRepo *sessionRepository
+}
+
+// NewStorage creates a new memory-based storage
+func NewStorage(opts Options) Storage {
+	if opts.Clock == nil {
+		opts.Clock = common.SystemClock{}
+	}
+
+	return &storage{
+		userRepo:    newUserRepository(opts.Clock),
+		sessionRepo: newSessionRepository(opts.Clock),
+	}
+}
+
+// Users returns the user repository
+func (s *storage) Users() UserRepository {
+	return s.userRepo
+}
+
+// Sessions returns the session repository
+func (s *storage) Sessions() SessionRepository {
+	return s.sessionRepo
+}
+
+// Close closes the storage connection (no-op for memory storage)
+func (s *storage) Close() error {
+	return nil
+}
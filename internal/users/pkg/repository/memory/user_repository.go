@@ -0,0 +1,88 @@
+package memory
+
+import (
+	"sync"
+
+	"github.com/all-in-one/internal/common"
+	"github.com/all-in-one/internal/users/pkg/model"
+)
+
+// userRepository implements the user repository with in-memory storage
+type userRepository struct {
+	users  map[int]model.User
+	lastID int
+	mutex  sync.RWMutex
+	clock  common.Clock
+}
+
+// newUserRepository creates a new memory-based user repository
+func newUserRepository(clock common.Clock) *userRepository {
+	return &userRepository{
+		users: make(map[int]model.User),
+		clock: clock,
+	}
+}
+
+// Create stores a new user
+func (r *userRepository) Create(user model.User) (model.User, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, existing := range r.users {
+		if existing.Username == user.Username {
+			return model.User{}, common.ErrConflict
+		}
+	}
+
+	r.lastID++
+	user.ID = r.lastID
+	user.CreatedAt = r.clock.Now()
+	user.UpdatedAt = user.CreatedAt
+
+	r.users[user.ID] = user
+	return user, nil
+}
+
+// Get returns a user by ID
+func (r *userRepository) Get(id int) (model.User, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	user, exists := r.users[id]
+	if !exists {
+		return model.User{}, common.ErrNotFound
+	}
+
+	return user, nil
+}
+
+// GetByUsername returns a user by username
+func (r *userRepository) GetByUsername(username string) (model.User, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for _, user := range r.users {
+		if user.Username == username {
+			return user, nil
+		}
+	}
+
+	return model.User{}, common.ErrNotFound
+}
+
+// UpdatePassword replaces a user's password hash
+func (r *userRepository) UpdatePassword(id int, passwordHash string) (model.User, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	user, exists := r.users[id]
+	if !exists {
+		return model.User{}, common.ErrNotFound
+	}
+
+	user.PasswordHash = passwordHash
+	user.UpdatedAt = r.clock.Now()
+	r.users[id] = user
+
+	return user, nil
+}
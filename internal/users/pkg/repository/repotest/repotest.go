@@ -0,0 +1,229 @@
+// Package repotest provides a conformance suite that every users storage
+// implementation runs from its tests, so all backends behave the same.
+//
+// A backend wires it up from an external test package, opening its storage
+// through the repository factory:
+//
+//	func TestConformance(t *testing.T) {
+//		repotest.RunStorage(t, func(t *testing.T, clock *common.ManualClock) repository.Storage {
+//			store, _ := repository.NewStorage("memory", "", repository.Options{Clock: clock})
+//			return store
+//		})
+//	}
+package repotest
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/all-in-one/internal/common"
+	"github.com/all-in-one/internal/users/pkg/model"
+	"github.com/all-in-one/internal/users/pkg/repository"
+)
+
+// Epoch is the time the suite's clock starts at
+var Epoch = time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC)
+
+// NewStorage returns an empty storage using clock for a single subtest. It
+// should register any cleanup with t.Cleanup.
+type NewStorage func(t *testing.T, clock *common.ManualClock) repository.Storage
+
+// RunStorage runs the conformance suite against the storages returned by
+// newStorage
+func RunStorage(t *testing.T, newStorage NewStorage) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, store repository.Storage, clock *common.ManualClock)
+	}{
+		{"CreateAndGetUser", testCreateAndGetUser},
+		{"DuplicateUsername", testDuplicateUsername},
+		{"UpdatePassword", testUpdatePassword},
+		{"SessionLifecycle", testSessionLifecycle},
+		{"DeleteSessionsByUser", testDeleteSessionsByUser},
+		{"DeleteExpiredSessions", testDeleteExpiredSessions},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := common.NewManualClock(Epoch)
+			tt.fn(t, newStorage(t, clock), clock)
+		})
+	}
+}
+
+func testCreateAndGetUser(t *testing.T, store repository.Storage, _ *common.ManualClock) {
+	created, err := store.Users().Create(model.User{ID: 99, Username: "alice", PasswordHash: "hash"})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if created.ID != 1 {
+		t.Errorf("Create assigned ID %d, want 1", created.ID)
+	}
+	if !created.CreatedAt.Equal(Epoch) || !created.UpdatedAt.Equal(Epoch) {
+		t.Errorf("Create timestamps = %v, %v; want the clock's %v", created.CreatedAt, created.UpdatedAt, Epoch)
+	}
+
+	byID, err := store.Users().Get(created.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	byName, err := store.Users().GetByUsername("alice")
+	if err != nil {
+		t.Fatalf("GetByUsername: %v", err)
+	}
+	for _, got := range []model.User{byID, byName} {
+		if got.ID != created.ID || got.Username != "alice" || got.PasswordHash != "hash" || !got.CreatedAt.Equal(Epoch) {
+			t.Errorf("read back %+v, want %+v", got, created)
+		}
+	}
+
+	if _, err := store.Users().Get(42); !errors.Is(err, common.ErrNotFound) {
+		t.Errorf("Get(missing) error = %v, want ErrNotFound", err)
+	}
+	if _, err := store.Users().GetByUsername("bob"); !errors.Is(err, common.ErrNotFound) {
+		t.Errorf("GetByUsername(missing) error = %v, want ErrNotFound", err)
+	}
+}
+
+func testDuplicateUsername(t *testing.T, store repository.Storage, _ *common.ManualClock) {
+	if _, err := store.Users().Create(model.User{Username: "alice", PasswordHash: "a"}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := store.Users().Create(model.User{Username: "alice", PasswordHash: "b"}); !errors.Is(err, common.ErrConflict) {
+		t.Errorf("Create(duplicate) error = %v, want ErrConflict", err)
+	}
+
+	got, err := store.Users().GetByUsername("alice")
+	if err != nil || got.PasswordHash != "a" {
+		t.Errorf("GetByUsername after duplicate = %+v, %v; want the first user", got, err)
+	}
+}
+
+func testUpdatePassword(t *testing.T, store repository.Storage, clock *common.ManualClock) {
+	user, err := store.Users().Create(model.User{Username: "alice", PasswordHash: "old"})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	clock.Advance(time.Minute)
+	updated, err := store.Users().UpdatePassword(user.ID, "new")
+	if err != nil {
+		t.Fatalf("UpdatePassword: %v", err)
+	}
+	if updated.PasswordHash != "new" || !updated.UpdatedAt.Equal(Epoch.Add(time.Minute)) || !updated.CreatedAt.Equal(Epoch) {
+		t.Errorf("UpdatePassword = %+v, want the new hash, UpdatedAt moved and CreatedAt kept", updated)
+	}
+
+	got, _ := store.Users().Get(user.ID)
+	if got.PasswordHash != "new" {
+		t.Errorf("stored hash = %q, want %q", got.PasswordHash, "new")
+	}
+
+	if _, err := store.Users().UpdatePassword(42, "x"); !errors.Is(err, common.ErrNotFound) {
+		t.Errorf("UpdatePassword(missing) error = %v, want ErrNotFound", err)
+	}
+}
+
+func testSessionLifecycle(t *testing.T, store repository.Storage, _ *common.ManualClock) {
+	user := mustCreateUser(t, store, "alice")
+
+	created, err := store.Sessions().Create(model.Session{
+		TokenHash: "t1",
+		UserID:    user.ID,
+		ExpiresAt: Epoch.Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if !created.CreatedAt.Equal(Epoch) {
+		t.Errorf("Create CreatedAt = %v, want the clock's %v", created.CreatedAt, Epoch)
+	}
+
+	got, err := store.Sessions().Get("t1")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got.UserID != user.ID || !got.ExpiresAt.Equal(Epoch.Add(time.Hour)) || !got.CreatedAt.Equal(Epoch) {
+		t.Errorf("Get = %+v, want %+v", got, created)
+	}
+
+	if _, err := store.Sessions().Create(model.Session{TokenHash: "t1", UserID: user.ID, ExpiresAt: Epoch}); !errors.Is(err, common.ErrConflict) {
+		t.Errorf("Create(duplicate) error = %v, want ErrConflict", err)
+	}
+
+	if err := store.Sessions().Delete("t1"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := store.Sessions().Get("t1"); !errors.Is(err, common.ErrNotFound) {
+		t.Errorf("Get after Delete error = %v, want ErrNotFound", err)
+	}
+	if err := store.Sessions().Delete("t1"); !errors.Is(err, common.ErrNotFound) {
+		t.Errorf("Delete(missing) error = %v, want ErrNotFound", err)
+	}
+}
+
+func testDeleteSessionsByUser(t *testing.T, store repository.Storage, _ *common.ManualClock) {
+	alice := mustCreateUser(t, store, "alice")
+	bob := mustCreateUser(t, store, "bob")
+	for _, s := range []model.Session{
+		{TokenHash: "a1", UserID: alice.ID},
+		{TokenHash: "a2", UserID: alice.ID},
+		{TokenHash: "a3", UserID: alice.ID},
+		{TokenHash: "b1", UserID: bob.ID},
+	} {
+		s.ExpiresAt = Epoch.Add(time.Hour)
+		if _, err := store.Sessions().Create(s); err != nil {
+			t.Fatalf("Create(%s): %v", s.TokenHash, err)
+		}
+	}
+
+	n, err := store.Sessions().DeleteByUser(alice.ID, "a2")
+	if err != nil {
+		t.Fatalf("DeleteByUser: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("DeleteByUser removed %d sessions, want 2", n)
+	}
+	for hash, want := range map[string]bool{"a1": false, "a2": true, "a3": false, "b1": true} {
+		_, err := store.Sessions().Get(hash)
+		if exists := err == nil; exists != want {
+			t.Errorf("session %s exists = %v, want %v", hash, exists, want)
+		}
+	}
+
+	if n, _ := store.Sessions().DeleteByUser(alice.ID, ""); n != 1 {
+		t.Errorf("DeleteByUser with no session kept removed %d sessions, want 1", n)
+	}
+}
+
+func testDeleteExpiredSessions(t *testing.T, store repository.Storage, _ *common.ManualClock) {
+	user := mustCreateUser(t, store, "alice")
+	for hash, ttl := range map[string]time.Duration{"past": -time.Minute, "now": 0, "future": time.Minute} {
+		if _, err := store.Sessions().Create(model.Session{TokenHash: hash, UserID: user.ID, ExpiresAt: Epoch.Add(ttl)}); err != nil {
+			t.Fatalf("Create(%s): %v", hash, err)
+		}
+	}
+
+	n, err := store.Sessions().DeleteExpired(Epoch)
+	if err != nil {
+		t.Fatalf("DeleteExpired: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("DeleteExpired removed %d sessions, want 2", n)
+	}
+	if _, err := store.Sessions().Get("future"); err != nil {
+		t.Errorf("unexpired session was removed: %v", err)
+	}
+}
+
+// mustCreateUser creates a user with the given username or fails the test
+func mustCreateUser(t *testing.T, store repository.Storage, username string) model.User {
+	t.Helper()
+
+	user, err := store.Users().Create(model.User{Username: username, PasswordHash: "hash"})
+	if err != nil {
+		t.Fatalf("Create(%s): %v", username, err)
+	}
+	return user
+}
@@ -0,0 +1,56 @@
+package sqlite_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/all-in-one/internal/common"
+	"github.com/all-in-one/internal/sqlitedb"
+	"github.com/all-in-one/internal/users/pkg/model"
+	"github.com/all-in-one/internal/users/pkg/repository"
+	"github.com/all-in-one/internal/users/pkg/repository/repotest"
+	"github.com/all-in-one/internal/users/pkg/repository/sqlite"
+)
+
+func TestConformance(t *testing.T) {
+	for _, driver := range sqlitedb.Drivers() {
+		t.Run(driver, func(t *testing.T) {
+			repotest.RunStorage(t, func(t *testing.T, clock *common.ManualClock) repository.Storage {
+				store, err := repository.NewStorage("sqlite", filepath.Join(t.TempDir(), "users.db"), repository.Options{
+					Clock:  clock,
+					SQLite: sqlitedb.Options{Driver: driver, ForeignKeys: sqlitedb.Bool(true)},
+				})
+				if err != nil {
+					t.Fatalf("NewStorage(%s): %v", driver, err)
+				}
+				t.Cleanup(func() { store.Close() })
+
+				return store
+			})
+		})
+	}
+}
+
+func TestReopenKeepsData(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.db")
+	clock := common.NewManualClock(repotest.Epoch)
+
+	store, err := sqlite.NewStorage(path, sqlite.Options{Clock: clock})
+	if err != nil {
+		t.Fatalf("NewStorage: %v", err)
+	}
+	if _, err := store.Users().Create(model.User{Username: "alice", PasswordHash: "hash"}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	store.Close()
+
+	// A second open must not rerun the migrations
+	store, err = sqlite.NewStorage(path, sqlite.Options{Clock: clock})
+	if err != nil {
+		t.Fatalf("reopening: %v", err)
+	}
+	defer store.Close()
+	if _, err := store.Users().GetByUsername("alice"); err != nil {
+		t.Errorf("GetByUsername after reopening: %v", err)
+	}
+}
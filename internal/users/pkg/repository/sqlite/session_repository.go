@@ -0,0 +1,105 @@
+package sqlite
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/all-in-one/internal/common"
+	"github.com/all-in-one/internal/users/pkg/model"
+)
+
+// sessionRepository implements the session repository with SQLite storage
+type sessionRepository struct {
+	db    *sql.DB
+	clock common.Clock
+}
+
+// newSessionRepository creates a new SQLite-based session repository
+func newSessionRepository(db *sql.DB, clock common.Clock) *sessionRepository {
+	return &sessionRepository{db: db, clock: clock}
+}
+
+// Create stores a new session
+func (r *sessionRepository) Create(session model.Session) (model.Session, error) {
+	session.CreatedAt = r.clock.Now().UTC()
+	session.ExpiresAt = session.ExpiresAt.UTC()
+
+	_, err := r.db.Exec(`
+		INSERT INTO sessions (token_hash, user_id, created_at, expires_at)
+		VALUES (?, ?, ?, ?)
+	`, session.TokenHash, session.UserID, session.CreatedAt.UnixNano(), session.ExpiresAt.UnixNano())
+	if err != nil {
+		if isUniqueViolation(err) {
+			return model.Session{}, common.ErrConflict
+		}
+		return model.Session{}, err
+	}
+
+	return session, nil
+}
+
+// Get returns the session with the given token hash
+func (r *sessionRepository) Get(tokenHash string) (model.Session, error) {
+	var session model.Session
+	var createdAt, expiresAt int64
+
+	err := r.db.QueryRow(`
+		SELECT token_hash, user_id, created_at, expires_at
+		FROM sessions
+		WHERE token_hash = ?
+	`, tokenHash).Scan(&session.TokenHash, &session.UserID, &createdAt, &expiresAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return model.Session{}, common.ErrNotFound
+		}
+		return model.Session{}, err
+	}
+	session.CreatedAt = time.Unix(0, createdAt).UTC()
+	session.ExpiresAt = time.Unix(0, expiresAt).UTC()
+
+	return session, nil
+}
+
+// Delete removes a session
+func (r *sessionRepository) Delete(tokenHash string) error {
+	result, err := r.db.Exec("DELETE FROM sessions WHERE token_hash = ?", tokenHash)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return common.ErrNotFound
+	}
+
+	return nil
+}
+
+// DeleteByUser removes all of a user's sessions except keep
+func (r *sessionRepository) DeleteByUser(userID int, keep string) (int, error) {
+	return r.deleteWhere("user_id = ? AND token_hash <> ?", userID, keep)
+}
+
+// DeleteExpired removes sessions that have expired at now
+func (r *sessionRepository) DeleteExpired(now time.Time) (int, error) {
+	return r.deleteWhere("expires_at <= ?", now.UnixNano())
+}
+
+// deleteWhere removes the sessions matching a WHERE clause and returns how
+// many it removed
+func (r *sessionRepository) deleteWhere(where string, args ...any) (int, error) {
+	result, err := r.db.Exec("DELETE FROM sessions WHERE "+where, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(rowsAffected), nil
+}
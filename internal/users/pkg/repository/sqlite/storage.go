@@ -0,0 +1,108 @@
+package sqlite
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/all-in-one/internal/common"
+	"github.com/all-in-one/internal/sqlitedb"
+	"github.com/all-in-one/internal/users/pkg/model"
+	"github.com/sirupsen/logrus"
+)
+
+// UserRepository defines the interface for user storage operations (local copy to avoid import cycle)
+type UserRepository interface {
+	Create(user model.User) (model.User, error)
+	Get(id int) (model.User, error)
+	GetByUsername(username string) (model.User, error)
+	UpdatePassword(id int, passwordHash string) (model.User, error)
+}
+
+// SessionRepository defines the interface for session storage operations (local copy to avoid import cycle)
+type SessionRepository interface {
+	Create(session model.Session) (model.Session, error)
+	Get(tokenHash string) (model.Session, error)
+	Delete(tokenHash string) error
+	DeleteByUser(userID int, keep string) (int, error)
+	DeleteExpired(now time.Time) (int, error)
+}
+
+// Storage defines the main storage interface (local copy to avoid import cycle)
+type Storage interface {
+	Users() UserRepository
+	Sessions() SessionRepository
+	Close() error
+}
+
+// Options configures the SQLite storage
+type Options struct {
+	// Connection holds the driver, pragmas and pool settings
+	Connection sqlitedb.Options
+
+	// Clock supplies timestamps. Nil means the system clock.
+	Clock common.Clock
+}
+
+// storage implements Storage with SQLite storage
+type storage struct {
+	db          *sql.DB
+	userRepo    *userRepository
+	sessionRepo *sessionRepository
+}
+
+// NewStorage creates a new SQLite-based storage. The users schema is
+// versioned with PRAGMA user_version, so dbPath must not be the listing
+// database.
+func NewStorage(dbPath string, opts Options) (Storage, error) {
+	if opts.Clock == nil {
+		opts.Clock = common.SystemClock{}
+	}
+
+	conn, err := sqlitedb.Open(dbPath, opts.Connection)
+	if err != nil {
+		return nil, err
+	}
+	db := conn.DB
+
+	// Create or upgrade the schema
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	logrus.WithField("db_path", dbPath).Info("SQLite user storage opened")
+
+	return &storage{
+		db:          db,
+		userRepo:    newUserRepository(db, opts.Clock),
+		sessionRepo: newSessionRepository(db, opts.Clock),
+	}, nil
+}
+
+// Users returns the user repository
+func (s *storage) Users() UserRepository {
+	return s.userRepo
+}
+
+// Sessions returns the session repository
+func (s *storage) Sessions() SessionRepository {
+	return s.sessionRepo
+}
+
+// Close closes the database connection
+func (s *storage) Close() error {
+	return s.db.Close()
+}
+
+// Helper Functions
+
+// isUniqueViolation reports whether err comes from a UNIQUE or PRIMARY KEY
+// constraint. Both drivers report it with the same SQLite message.
+func isUniqueViolation(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "UNIQUE constraint failed")
+}
+
+// scanner is implemented by *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...any) error
+}
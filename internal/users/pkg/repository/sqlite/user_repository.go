@@ -0,0 +1,113 @@
+package sqlite
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/all-in-one/internal/common"
+	"github.com/all-in-one/internal/users/pkg/model"
+)
+
+// userRepository implements the user repository with SQLite storage
+type userRepository struct {
+	db    *sql.DB
+	clock common.Clock
+}
+
+// newUserRepository creates a new SQLite-based user repository
+func newUserRepository(db *sql.DB, clock common.Clock) *userRepository {
+	return &userRepository{db: db, clock: clock}
+}
+
+// Create stores a new user
+func (r *userRepository) Create(user model.User) (model.User, error) {
+	user.CreatedAt = r.clock.Now().UTC()
+	user.UpdatedAt = user.CreatedAt
+
+	result, err := r.db.Exec(`
+		INSERT INTO users (username, password_hash, created_at, updated_at)
+		VALUES (?, ?, ?, ?)
+	`, user.Username, user.PasswordHash, user.CreatedAt.UnixNano(), user.UpdatedAt.UnixNano())
+	if err != nil {
+		if isUniqueViolation(err) {
+			return model.User{}, common.ErrConflict
+		}
+		return model.User{}, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return model.User{}, err
+	}
+	user.ID = int(id)
+
+	return user, nil
+}
+
+// Get returns a user by ID
+func (r *userRepository) Get(id int) (model.User, error) {
+	return r.getOne(`
+		SELECT id, username, password_hash, created_at, updated_at
+		FROM users
+		WHERE id = ?
+	`, id)
+}
+
+// GetByUsername returns a user by username
+func (r *userRepository) GetByUsername(username string) (model.User, error) {
+	return r.getOne(`
+		SELECT id, username, password_hash, created_at, updated_at
+		FROM users
+		WHERE username = ?
+	`, username)
+}
+
+// UpdatePassword replaces a user's password hash
+func (r *userRepository) UpdatePassword(id int, passwordHash string) (model.User, error) {
+	result, err := r.db.Exec(`
+		UPDATE users
+		SET password_hash = ?, updated_at = ?
+		WHERE id = ?
+	`, passwordHash, r.clock.Now().UnixNano(), id)
+	if err != nil {
+		return model.User{}, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return model.User{}, err
+	}
+	if rowsAffected == 0 {
+		return model.User{}, common.ErrNotFound
+	}
+
+	return r.Get(id)
+}
+
+// getOne runs a query selecting a single user
+func (r *userRepository) getOne(query string, args ...any) (model.User, error) {
+	user, err := scanUser(r.db.QueryRow(query, args...))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return model.User{}, common.ErrNotFound
+		}
+		return model.User{}, err
+	}
+
+	return user, nil
+}
+
+// scanUser reads a user from a row selected as id, username,
+// password_hash, created_at, updated_at
+func scanUser(row scanner) (model.User, error) {
+	var user model.User
+	var createdAt, updatedAt int64
+
+	if err := row.Scan(&user.ID, &user.Username, &user.PasswordHash, &createdAt, &updatedAt); err != nil {
+		return model.User{}, err
+	}
+	user.CreatedAt = time.Unix(0, createdAt).UTC()
+	user.UpdatedAt = time.Unix(0, updatedAt).UTC()
+
+	return user, nil
+}
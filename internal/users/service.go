@@ -0,0 +1,43 @@
+package users
+
+import (
+	"github.com/all-in-one/internal/openapi"
+	"github.com/all-in-one/internal/users/pkg/handler"
+	"github.com/all-in-one/internal/users/pkg/repository"
+	"github.com/gorilla/mux"
+)
+
+// Service represents the users service
+type Service struct {
+	Handler *handler.Handler
+	Storage repository.Storage
+}
+
+// NewService creates a new users service on top of an existing storage
+func NewService(store repository.Storage, opts handler.Options) *Service {
+	return &Service{
+		Handler: handler.NewHandler(store, opts),
+		Storage: store,
+	}
+}
+
+// NewMemoryService creates a new users service with in-memory storage
+func NewMemoryService(opts handler.Options) *Service {
+	store, _ := repository.NewStorage("memory", "", repository.Options{Clock: opts.Clock})
+	return NewService(store, opts)
+}
+
+// RegisterRoutes registers the users routes to the given router
+func (s *Service) RegisterRoutes(router *mux.Router) {
+	s.Handler.RegisterRoutes(router)
+}
+
+// Operations describes the users routes for the OpenAPI document
+func (s *Service) Operations() openapi.Operations {
+	return s.Handler.Operations()
+}
+
+// Close closes any resources used by the service
+func (s *Service) Close() error {
+	return s.Storage.Close()
+}
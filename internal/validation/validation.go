@@ -0,0 +1,157 @@
+// Package validation checks and normalizes request payloads against rules
+// declared once per type, reporting every invalid field at once
+package validation
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/all-in-one/internal/common"
+)
+
+// Violation is a broken rule: a stable code and a message that follows the
+// field's label, e.g. "is required"
+type Violation struct {
+	Code    string
+	Message string
+}
+
+// Rule checks a value, possibly normalizing it first. It returns the value
+// to keep and, if the value breaks the rule, the violation.
+type Rule func(value string) (string, *Violation)
+
+// Field declares the rules of one string field of T
+type Field[T any] struct {
+	Name  string           // name in the JSON payload, used in field errors
+	Label string           // name in messages, e.g. "Title"
+	Value func(*T) *string // the field in a T
+	Rules []Rule
+}
+
+// Schema is the set of rules for a payload type
+type Schema[T any] struct {
+	Fields []Field[T]
+}
+
+// Apply normalizes every field of v and checks it, returning a validation
+// error listing all invalid fields, or nil
+func (s Schema[T]) Apply(v *T) error {
+	return s.apply(v, func(string) bool { return true })
+}
+
+// ApplyFields is Apply limited to the named fields, for partial updates
+func (s Schema[T]) ApplyFields(v *T, names ...string) error {
+	return s.apply(v, func(name string) bool {
+		for _, n := range names {
+			if n == name {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// apply runs the rules of the fields selected by include. A field stops at
+// its first broken rule, so each field is reported at most once.
+func (s Schema[T]) apply(v *T, include func(name string) bool) error {
+	var fields []common.FieldError
+	for _, f := range s.Fields {
+		if !include(f.Name) {
+			continue
+		}
+
+		value := f.Value(v)
+		for _, rule := range f.Rules {
+			normalized, violation := rule(*value)
+			*value = normalized
+			if violation != nil {
+				fields = append(fields, common.FieldError{
+					Field:   f.Name,
+					Code:    violation.Code,
+					Message: f.Label + " " + violation.Message,
+				})
+				break
+			}
+		}
+	}
+
+	if len(fields) == 0 {
+		return nil
+	}
+
+	messages := make([]string, len(fields))
+	for i, f := range fields {
+		messages[i] = f.Message
+	}
+	return common.Validation(strings.Join(messages, "; "), fields...)
+}
+
+// Trim removes leading and trailing white space. It never fails, so put it
+// first.
+func Trim() Rule {
+	return func(value string) (string, *Violation) {
+		return strings.TrimSpace(value), nil
+	}
+}
+
+// Required rejects empty values
+func Required() Rule {
+	return func(value string) (string, *Violation) {
+		if value == "" {
+			return value, &Violation{Code: "required", Message: "is required"}
+		}
+		return value, nil
+	}
+}
+
+// MinLength rejects values shorter than n characters
+func MinLength(n int) Rule {
+	return func(value string) (string, *Violation) {
+		if utf8.RuneCountInString(value) < n {
+			return value, &Violation{Code: "too_short", Message: fmt.Sprintf("must be at least %d characters", n)}
+		}
+		return value, nil
+	}
+}
+
+// MaxLength rejects values longer than n characters
+func MaxLength(n int) Rule {
+	return func(value string) (string, *Violation) {
+		if utf8.RuneCountInString(value) > n {
+			return value, &Violation{Code: "too_long", Message: fmt.Sprintf("must be at most %d characters", n)}
+		}
+		return value, nil
+	}
+}
+
+// ForbiddenChars rejects values containing any of chars
+func ForbiddenChars(chars string) Rule {
+	return func(value string) (string, *Violation) {
+		if i := strings.IndexAny(value, chars); i >= 0 {
+			r, _ := utf8.DecodeRuneInString(value[i:])
+			return value, &Violation{Code: "forbidden_character", Message: fmt.Sprintf("must not contain %q", r)}
+		}
+		return value, nil
+	}
+}
+
+// NoControlChars rejects control and invisible formatting characters, and
+// invalid UTF-8. With multiline, newlines and tabs are allowed.
+func NoControlChars(multiline bool) Rule {
+	return func(value string) (string, *Violation) {
+		if !utf8.ValidString(value) {
+			return value, &Violation{Code: "invalid_encoding", Message: "must be valid UTF-8"}
+		}
+		for _, r := range value {
+			if multiline && (r == '\n' || r == '\r' || r == '\t') {
+				continue
+			}
+			if unicode.IsControl(r) || unicode.Is(unicode.Cf, r) {
+				return value, &Violation{Code: "forbidden_character", Message: fmt.Sprintf("must not contain control character %U", r)}
+			}
+		}
+		return value, nil
+	}
+}
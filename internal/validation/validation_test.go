@@ -0,0 +1,115 @@
+package validation
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/all-in-one/internal/common"
+)
+
+type payload struct {
+	Name string
+	Bio  string
+}
+
+var schema = Schema[payload]{
+	Fields: []Field[payload]{
+		{
+			Name:  "name",
+			Label: "Name",
+			Value: func(p *payload) *string { return &p.Name },
+			Rules: []Rule{Trim(), Required(), MinLength(2), MaxLength(5), ForbiddenChars("<>"), NoControlChars(false)},
+		},
+		{
+			Name:  "bio",
+			Label: "Bio",
+			Value: func(p *payload) *string { return &p.Bio },
+			Rules: []Rule{Trim(), MaxLength(10), NoControlChars(true)},
+		},
+	},
+}
+
+// fieldCodes returns the field → code map of a validation error
+func fieldCodes(t *testing.T, err error) map[string]string {
+	t.Helper()
+
+	if err == nil {
+		return nil
+	}
+	var e *common.Error
+	if !errors.As(err, &e) || !errors.Is(err, common.ErrValidation) {
+		t.Fatalf("error %v is not a validation error", err)
+	}
+	codes := map[string]string{}
+	for _, f := range e.Fields {
+		codes[f.Field] = f.Code
+	}
+	return codes
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name string
+		in   payload
+		want map[string]string
+	}{
+		{"valid", payload{Name: "Ann", Bio: "hi"}, nil},
+		{"required after trimming", payload{Name: "   "}, map[string]string{"name": "required"}},
+		{"too short", payload{Name: "A"}, map[string]string{"name": "too_short"}},
+		{"too long in characters", payload{Name: "Ännnnn"}, map[string]string{"name": "too_long"}},
+		{"five multi-byte characters fit", payload{Name: "ÄÖÜäö"}, nil},
+		{"forbidden character", payload{Name: "<b>"}, map[string]string{"name": "forbidden_character"}},
+		{"control character", payload{Name: "a\x00b"}, map[string]string{"name": "forbidden_character"}},
+		{"newline in single-line field", payload{Name: "a\nb"}, map[string]string{"name": "forbidden_character"}},
+		{"newline in multiline field", payload{Name: "Ann", Bio: "a\nb"}, nil},
+		{"zero-width format character", payload{Name: "Ann", Bio: "a\u200bb"}, map[string]string{"bio": "forbidden_character"}},
+		{"invalid UTF-8", payload{Name: "Ann", Bio: "\xff"}, map[string]string{"bio": "invalid_encoding"}},
+		{"every field reported", payload{Name: "", Bio: "this is far too long"}, map[string]string{"name": "required", "bio": "too_long"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := tt.in
+			got := fieldCodes(t, schema.Apply(&p))
+			if len(got) != len(tt.want) {
+				t.Fatalf("field errors = %v, want %v", got, tt.want)
+			}
+			for field, code := range tt.want {
+				if got[field] != code {
+					t.Errorf("%s: code %q, want %q", field, got[field], code)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyTrimsAndFormatsMessages(t *testing.T) {
+	p := payload{Name: "  Ann  ", Bio: " hi\n"}
+	if err := schema.Apply(&p); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if p.Name != "Ann" || p.Bio != "hi" {
+		t.Errorf("normalized to %q, %q; want trimmed values", p.Name, p.Bio)
+	}
+
+	p = payload{Bio: "this is far too long"}
+	err := schema.Apply(&p)
+	want := "Name is required; Bio must be at most 10 characters"
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("error = %v, want message %q", err, want)
+	}
+}
+
+func TestApplyFields(t *testing.T) {
+	p := payload{Name: "", Bio: " ok "}
+	if err := schema.ApplyFields(&p, "bio"); err != nil {
+		t.Errorf("ApplyFields(bio) with an invalid name = %v, want nil", err)
+	}
+	if p.Bio != "ok" {
+		t.Errorf("bio = %q, want it trimmed", p.Bio)
+	}
+	if codes := fieldCodes(t, schema.ApplyFields(&p, "name")); codes["name"] != "required" {
+		t.Errorf("ApplyFields(name) = %v, want name required", codes)
+	}
+}
@@ -3,8 +3,10 @@ package main
 import (
 	"fmt"
 	"os"
+	"strconv"
 
 	listingCmd "github.com/all-in-one/cmd/listing"
+	usersCmd "github.com/all-in-one/cmd/users"
 	"github.com/spf13/cobra"
 )
 
@@ -16,6 +18,8 @@ var rootCmd = &cobra.Command{
 
 A comprehensive service platform that provides multiple microservices
 in a single application for development and deployment convenience.`,
+	// main prints the error itself
+	SilenceErrors: true,
 }
 
 var listingCommand = &cobra.Command{
@@ -28,9 +32,169 @@ var listingCommand = &cobra.Command{
 	},
 }
 
+var usersCommand = &cobra.Command{
+	Use:   "users",
+	Short: "Start the users service",
+	Long:  "👤 Launch the users service for registration, password login and sessions",
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Println("👤 Launching Users Service...")
+		usersCmd.Run()
+	},
+}
+
+var backupCommand = &cobra.Command{
+	Use:          "backup",
+	Short:        "Back up listing data to an archive",
+	Long:         "💾 Write a checksummed backup archive of the configured listing storage",
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out, _ := cmd.Flags().GetString("out")
+		return listingCmd.Backup(out)
+	},
+}
+
+var restoreCommand = &cobra.Command{
+	Use:          "restore",
+	Short:        "Restore listing data from an archive",
+	Long:         "♻️  Replace the contents of the configured listing storage with a backup archive",
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		in, _ := cmd.Flags().GetString("in")
+		return listingCmd.Restore(in)
+	},
+}
+
+var migrateStorageCommand = &cobra.Command{
+	Use:   "migrate-storage",
+	Short: "Copy listing data between storage backends",
+	Long: `🚚 Stream all listing items from one storage backend to another,
+preserving IDs and timestamps.
+
+Storage locations are "memory", "sqlite://<path>" or "bolt://<path>".`,
+	Example:      "  all-in-one listing migrate-storage --from sqlite://./data/listings.db --to bolt://./data/listings.bolt",
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		from, _ := cmd.Flags().GetString("from")
+		to, _ := cmd.Flags().GetString("to")
+		batchSize, _ := cmd.Flags().GetInt("batch-size")
+		resume, _ := cmd.Flags().GetBool("resume")
+		return listingCmd.MigrateStorage(from, to, batchSize, resume)
+	},
+}
+
+var rotateKeysCommand = &cobra.Command{
+	Use:   "rotate-keys",
+	Short: "Re-encrypt listing data with the primary key",
+	Long: `🔐 Re-encrypt every item whose title or description isn't encrypted with
+the primary key of the configured keyfile, including rows written before
+encryption was enabled. IDs and timestamps are kept.
+
+With --generate, a new key is added to the keyfile (creating it if needed)
+and made the primary before re-encrypting.`,
+	Example:      "  all-in-one listing rotate-keys --generate",
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		generate, _ := cmd.Flags().GetBool("generate")
+		batchSize, _ := cmd.Flags().GetInt("batch-size")
+		return listingCmd.RotateKeys(generate, batchSize)
+	},
+}
+
+var apikeyCommand = &cobra.Command{
+	Use:   "apikey",
+	Short: "Manage API keys",
+	Long: `🔑 Create, list and revoke the API keys that authenticate requests to
+/api/v1 when auth.enabled is set. Keys are stored, hashed, in the configured
+storage.
+
+A key's scopes name the roles it has. The built-in roles are read
+(items:read), write (adds items:write and items:delete) and admin (adds the
+admin permission for /api/v1/admin); auth.roles in the config replaces them.`,
+}
+
+var apikeyCreateCommand = &cobra.Command{
+	Use:          "create",
+	Short:        "Create an API key",
+	Example:      "  all-in-one apikey create --name ci --scopes read,write",
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name, _ := cmd.Flags().GetString("name")
+		scopes, _ := cmd.Flags().GetStringSlice("scopes")
+		return listingCmd.CreateAPIKey(name, scopes)
+	},
+}
+
+var apikeyListCommand = &cobra.Command{
+	Use:          "list",
+	Short:        "List API keys",
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return listingCmd.ListAPIKeys()
+	},
+}
+
+var apikeyRevokeCommand = &cobra.Command{
+	Use:          "revoke <id>",
+	Short:        "Revoke an API key",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := strconv.Atoi(args[0])
+		if err != nil {
+			return fmt.Errorf("invalid API key ID %q", args[0])
+		}
+		return listingCmd.RevokeAPIKey(id)
+	},
+}
+
+var openapiCommand = &cobra.Command{
+	Use:   "openapi",
+	Short: "Print the OpenAPI document of a service",
+	Long: `📖 Generate the OpenAPI 3.1 document of a service's HTTP API from its
+route table, as served by the running service at /api/v1/openapi.json.`,
+	Example:      "  all-in-one openapi --service users --out users-openapi.json",
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		service, _ := cmd.Flags().GetString("service")
+		out, _ := cmd.Flags().GetString("out")
+		switch service {
+		case "listing":
+			return listingCmd.OpenAPI(out)
+		case "users":
+			return usersCmd.OpenAPI(out)
+		default:
+			return fmt.Errorf("unknown service %q. Supported services: listing, users", service)
+		}
+	},
+}
+
 func main() {
 	// Setup commands
-	rootCmd.AddCommand(listingCommand)
+	backupCommand.Flags().String("out", "", "path of the backup archive to write")
+	backupCommand.MarkFlagRequired("out")
+	restoreCommand.Flags().String("in", "", "path of the backup archive to restore")
+	restoreCommand.MarkFlagRequired("in")
+
+	migrateStorageCommand.Flags().String("from", "", "source storage (memory, sqlite://path or bolt://path)")
+	migrateStorageCommand.Flags().String("to", "", "target storage (memory, sqlite://path or bolt://path)")
+	migrateStorageCommand.Flags().Int("batch-size", 500, "items read from the source per batch")
+	migrateStorageCommand.Flags().Bool("resume", false, "continue into a non-empty target, skipping items it already has")
+	migrateStorageCommand.MarkFlagRequired("from")
+	migrateStorageCommand.MarkFlagRequired("to")
+
+	rotateKeysCommand.Flags().Bool("generate", false, "add a new primary key to the keyfile first")
+	rotateKeysCommand.Flags().Int("batch-size", 500, "items re-encrypted per transaction")
+
+	listingCommand.AddCommand(backupCommand, restoreCommand, migrateStorageCommand, rotateKeysCommand)
+	apikeyCreateCommand.Flags().String("name", "", "name to recognize the key by")
+	apikeyCreateCommand.Flags().StringSlice("scopes", []string{"read"}, "comma-separated roles the key has, e.g. read,write")
+	apikeyCreateCommand.MarkFlagRequired("name")
+
+	apikeyCommand.AddCommand(apikeyCreateCommand, apikeyListCommand, apikeyRevokeCommand)
+	openapiCommand.Flags().String("service", "listing", "service to describe (listing or users)")
+	openapiCommand.Flags().String("out", "", "path to write the document to instead of stdout")
+
+	rootCmd.AddCommand(listingCommand, usersCommand, apikeyCommand, openapiCommand)
 
 	// Execute the root command
 	if err := rootCmd.Execute(); err != nil {
@@ -0,0 +1,180 @@
+// Package client is a typed Go client for the listing API. It retries
+// transient failures with backoff, takes a context for every call, and
+// turns error responses into *Error.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// Options configures a Client
+type Options struct {
+	// HTTPClient sends the requests. Nil means http.DefaultClient.
+	HTTPClient *http.Client
+
+	// Token is sent as a bearer token: an API key, or a JWT if the server
+	// accepts them. Empty means requests are anonymous.
+	Token string
+
+	// UserAgent is sent with every request. Empty means DefaultUserAgent.
+	UserAgent string
+
+	// Retry says how failed requests are retried
+	Retry RetryPolicy
+}
+
+// DefaultUserAgent is the User-Agent sent when Options doesn't say
+const DefaultUserAgent = "all-in-one-listing-client/1"
+
+// Client calls the listing API
+type Client struct {
+	base string
+	opts Options
+}
+
+// New returns a client for the listing API at baseURL, e.g.
+// "http://localhost:8080". The /api/v1 prefix is added by the client.
+func New(baseURL string, opts Options) (*Client, error) {
+	base, err := url.Parse(strings.TrimRight(baseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid base URL: %w", err)
+	}
+	if base.Scheme != "http" && base.Scheme != "https" {
+		return nil, fmt.Errorf("invalid base URL %q: scheme must be http or https", baseURL)
+	}
+
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = http.DefaultClient
+	}
+	if opts.UserAgent == "" {
+		opts.UserAgent = DefaultUserAgent
+	}
+	opts.Retry = opts.Retry.withDefaults()
+
+	return &Client{base: base.String(), opts: opts}, nil
+}
+
+// request is one API call
+type request struct {
+	method string
+	path   string
+	query  url.Values
+
+	// body is sent with contentType, which defaults to JSON
+	body        []byte
+	contentType string
+}
+
+// envelope is the success response of the API, with Data left encoded
+type envelope struct {
+	Success bool            `json:"success"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// call sends a request with in, if not nil, as the JSON body and decodes
+// the Data of the response into out, if not nil. It returns the response
+// headers.
+func (c *Client) call(ctx context.Context, method, path string, query url.Values, in, out interface{}) (http.Header, error) {
+	req := request{method: method, path: path, query: query}
+	if in != nil {
+		body, err := json.Marshal(in)
+		if err != nil {
+			return nil, fmt.Errorf("encoding request: %w", err)
+		}
+		req.body = body
+	}
+
+	resp, err := c.do(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	return resp.Header, decodeData(resp, out)
+}
+
+// decodeData decodes the Data of a success response into out
+func decodeData(resp *http.Response, out interface{}) error {
+	var env envelope
+	if err := json.NewDecoder(resp.Body).Decode(&env); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+	if out == nil || len(env.Data) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(env.Data, out); err != nil {
+		return fmt.Errorf("decoding response data: %w", err)
+	}
+	return nil
+}
+
+// do sends req, retrying as the policy allows, and returns the response if
+// it succeeded. The caller closes its body. Error responses are returned as
+// *Error.
+func (c *Client) do(ctx context.Context, req request) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		resp, err := c.send(ctx, req)
+		if err == nil && resp.StatusCode < 300 {
+			return resp, nil
+		}
+		if err == nil {
+			err = decodeError(resp)
+			resp.Body.Close()
+		}
+
+		wait, retry := c.opts.Retry.backoff(req.method, attempt, err)
+		if !retry || ctx.Err() != nil {
+			return nil, err
+		}
+		if werr := sleep(ctx, wait); werr != nil {
+			return nil, errors.Join(err, werr)
+		}
+	}
+}
+
+// send sends req once
+func (c *Client) send(ctx context.Context, req request) (*http.Response, error) {
+	// Paths are already escaped
+	target := c.base + "/api/v1" + req.path
+	if len(req.query) > 0 {
+		target += "?" + req.query.Encode()
+	}
+
+	var body io.Reader
+	if req.body != nil {
+		body = bytes.NewReader(req.body)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, target, body)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq.Header.Set("Accept", "application/json")
+	httpReq.Header.Set("User-Agent", c.opts.UserAgent)
+	if req.body != nil {
+		contentType := req.contentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		httpReq.Header.Set("Content-Type", contentType)
+	}
+	if c.opts.Token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+c.opts.Token)
+	}
+	// Continue the caller's trace, if it's tracing with OpenTelemetry
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(httpReq.Header))
+
+	return c.opts.HTTPClient.Do(httpReq)
+}
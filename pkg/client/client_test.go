@@ -0,0 +1,371 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/all-in-one/internal/auth"
+	"github.com/all-in-one/internal/common"
+	"github.com/all-in-one/internal/listing/pkg/handler"
+	"github.com/all-in-one/internal/listing/pkg/model"
+	"github.com/all-in-one/internal/listing/pkg/repository"
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// fastRetries keeps retry tests quick
+var fastRetries = RetryPolicy{MaxAttempts: 3, MinBackoff: time.Millisecond, MaxBackoff: 50 * time.Millisecond}
+
+// newTestServer serves the real listing handler on memory storage, routed
+// as the listing service does. With withAuth, requests need an API key
+// and are checked against the built-in roles.
+func newTestServer(t *testing.T, withAuth bool, middleware ...mux.MiddlewareFunc) (*httptest.Server, repository.Storage) {
+	t.Helper()
+
+	store, err := repository.NewStorage("memory", "", repository.Options{})
+	if err != nil {
+		t.Fatalf("NewStorage: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	h := handler.NewHandler(store)
+	router := mux.NewRouter()
+	router.Use(middleware...)
+	api := router.PathPrefix("/api/v1").Subrouter()
+	if withAuth {
+		policy, err := auth.NewPolicy(auth.DefaultRoles())
+		if err != nil {
+			t.Fatalf("NewPolicy: %v", err)
+		}
+		api.Use(auth.Middleware(auth.APIKeyAuthenticator{Keys: repository.AuthKeys(store)}))
+		h.SetPolicy(policy)
+	}
+	h.RegisterRoutes(api)
+	h.RegisterAdminRoutes(api.PathPrefix("/admin").Subrouter())
+
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return server, store
+}
+
+// newTestClient returns a client for server
+func newTestClient(t *testing.T, server *httptest.Server, token string) *Client {
+	t.Helper()
+
+	c, err := New(server.URL, Options{Token: token, Retry: fastRetries})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return c
+}
+
+// newKey stores an API key with the given roles and returns it
+func newKey(t *testing.T, store repository.Storage, roles ...string) string {
+	t.Helper()
+
+	token, prefix, hash, err := auth.NewAPIKey()
+	if err != nil {
+		t.Fatalf("NewAPIKey: %v", err)
+	}
+	if _, err := store.APIKeys().Create(model.APIKey{Name: "test", Prefix: prefix, Hash: hash, Scopes: roles}); err != nil {
+		t.Fatalf("creating API key: %v", err)
+	}
+	return token
+}
+
+func TestItems(t *testing.T) {
+	server, _ := newTestServer(t, false)
+	c := newTestClient(t, server, "")
+	ctx := context.Background()
+
+	created, err := c.CreateItem(ctx, ItemInput{Title: "Lamp", Description: "Brass desk lamp"})
+	if err != nil {
+		t.Fatalf("CreateItem: %v", err)
+	}
+	if created.UID == "" || created.CreatedAt.IsZero() {
+		t.Errorf("created = %+v", created)
+	}
+
+	byUID, err := c.GetItem(ctx, created.UID)
+	if err != nil || byUID.Title != created.Title {
+		t.Errorf("GetItem(uid) = %+v, %v", byUID, err)
+	}
+
+	id := created.UID
+	updated, err := c.UpdateItem(ctx, id, ItemInput{Title: "Floor lamp", Description: "Tall"})
+	if err != nil || updated.Title != "Floor lamp" {
+		t.Errorf("UpdateItem = %+v, %v", updated, err)
+	}
+
+	description := "Tall, brass"
+	patched, err := c.PatchItem(ctx, id, ItemPatch{Description: &description})
+	if err != nil || patched.Title != "Floor lamp" || patched.Description != description {
+		t.Errorf("PatchItem = %+v, %v", patched, err)
+	}
+
+	items, err := c.ListItems(ctx)
+	if err != nil || len(items) != 1 || items[0].Description != description {
+		t.Errorf("ListItems = %+v, %v", items, err)
+	}
+
+	if err := c.DeleteItem(ctx, id); err != nil {
+		t.Fatalf("DeleteItem: %v", err)
+	}
+	_, err = c.GetItem(ctx, id)
+	var apiErr *Error
+	if !IsNotFound(err) || !errors.As(err, &apiErr) || apiErr.Code != "item_not_found" {
+		t.Errorf("GetItem after delete = %v", err)
+	}
+}
+
+func TestValidationErrors(t *testing.T) {
+	for name, middleware := range map[string][]mux.MiddlewareFunc{
+		"json":    nil,
+		"problem": {common.ProblemJSON},
+	} {
+		t.Run(name, func(t *testing.T) {
+			server, _ := newTestServer(t, false, middleware...)
+			c := newTestClient(t, server, "")
+
+			_, err := c.CreateItem(context.Background(), ItemInput{Title: " ", Description: "x"})
+			var apiErr *Error
+			if !IsValidation(err) || !errors.As(err, &apiErr) {
+				t.Fatalf("CreateItem = %v, want a validation error", err)
+			}
+			if apiErr.Code != "validation_failed" || len(apiErr.Details) != 1 || apiErr.Details[0].Field != "title" || apiErr.Details[0].Code != "required" {
+				t.Errorf("error = %+v", apiErr)
+			}
+		})
+	}
+}
+
+func TestItemsIterator(t *testing.T) {
+	server, store := newTestServer(t, false)
+	c := newTestClient(t, server, "")
+	for i := 0; i < 5; i++ {
+		if _, err := store.Items().Create(model.Item{Title: "Item", Description: "x"}); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+
+	page, err := c.ListItemsPage(context.Background(), ListOptions{Limit: 2})
+	if err != nil || len(page.Items) != 2 || page.Next == nil || *page.Next != (ListOptions{After: page.Items[1].UID, Limit: 2}) {
+		t.Fatalf("first page = %+v, %v", page, err)
+	}
+
+	var uids []string
+	for item, err := range c.Items(context.Background(), 2) {
+		if err != nil {
+			t.Fatalf("Items: %v", err)
+		}
+		uids = append(uids, item.UID)
+	}
+	if len(uids) != 5 || uids[0] != page.Items[0].UID || uids[1] != page.Items[1].UID {
+		t.Errorf("iterated UIDs = %v", uids)
+	}
+
+	// Stopping early doesn't fetch further pages
+	count := 0
+	for range c.Items(context.Background(), 2) {
+		count++
+		break
+	}
+	if count != 1 {
+		t.Errorf("iterated %d items after break", count)
+	}
+}
+
+func TestAuthentication(t *testing.T) {
+	server, store := newTestServer(t, true)
+	ctx := context.Background()
+
+	if _, err := newTestClient(t, server, "").ListItems(ctx); !IsUnauthorized(err) {
+		t.Errorf("anonymous ListItems = %v, want unauthorized", err)
+	}
+
+	reader := newTestClient(t, server, newKey(t, store, "read"))
+	if _, err := reader.ListItems(ctx); err != nil {
+		t.Errorf("reader ListItems: %v", err)
+	}
+	if _, err := reader.CreateItem(ctx, ItemInput{Title: "Lamp", Description: "x"}); !IsForbidden(err) {
+		t.Errorf("reader CreateItem = %v, want forbidden", err)
+	}
+
+	perms, err := reader.MyPermissions(ctx)
+	if err != nil || perms.Method != "apikey" || len(perms.Roles) != 1 || perms.Roles[0] != "read" {
+		t.Errorf("MyPermissions = %+v, %v", perms, err)
+	}
+}
+
+func TestBackupAndRestore(t *testing.T) {
+	server, store := newTestServer(t, true)
+	admin := newTestClient(t, server, newKey(t, store, "admin"))
+	ctx := context.Background()
+
+	item, err := admin.CreateItem(ctx, ItemInput{Title: "Lamp", Description: "x"})
+	if err != nil {
+		t.Fatalf("CreateItem: %v", err)
+	}
+
+	var archive bytes.Buffer
+	if n, err := admin.Backup(ctx, &archive); err != nil || n == 0 {
+		t.Fatalf("Backup = %d, %v", n, err)
+	}
+
+	if err := admin.DeleteItem(ctx, item.UID); err != nil {
+		t.Fatalf("DeleteItem: %v", err)
+	}
+	header, err := admin.Restore(ctx, &archive)
+	if err != nil || header.ItemCount != 1 {
+		t.Fatalf("Restore = %+v, %v", header, err)
+	}
+	if _, err := admin.GetItem(ctx, item.UID); err != nil {
+		t.Errorf("GetItem after restore: %v", err)
+	}
+
+	if _, err := admin.Restore(ctx, bytes.NewReader([]byte("not gzip"))); !IsValidation(err) {
+		t.Errorf("Restore(garbage) = %v, want a validation error", err)
+	}
+}
+
+// flakyServer fails the first failures requests with status, then
+// succeeds without data. It counts the requests.
+func flakyServer(t *testing.T, status, failures int, retryAfter string) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if int(calls.Add(1)) <= failures {
+			if retryAfter != "" {
+				w.Header().Set("Retry-After", retryAfter)
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			w.Write([]byte(`{"success":false,"error":"try later","code":"busy"}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"success":true}`))
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+func TestRetries(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("idempotent request is retried", func(t *testing.T) {
+		server, calls := flakyServer(t, http.StatusServiceUnavailable, 2, "")
+		if _, err := newTestClient(t, server, "").ListItems(ctx); err != nil {
+			t.Fatalf("ListItems: %v", err)
+		}
+		if calls.Load() != 3 {
+			t.Errorf("%d calls, want 3", calls.Load())
+		}
+	})
+
+	t.Run("gives up after MaxAttempts", func(t *testing.T) {
+		server, calls := flakyServer(t, http.StatusBadGateway, 10, "")
+		_, err := newTestClient(t, server, "").ListItems(ctx)
+		var apiErr *Error
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadGateway || apiErr.Code != "busy" || apiErr.Message != "try later" {
+			t.Errorf("ListItems = %v", err)
+		}
+		if calls.Load() != 3 {
+			t.Errorf("%d calls, want 3", calls.Load())
+		}
+	})
+
+	t.Run("POST is not retried on server errors", func(t *testing.T) {
+		server, calls := flakyServer(t, http.StatusServiceUnavailable, 1, "")
+		if _, err := newTestClient(t, server, "").CreateItem(ctx, ItemInput{}); err == nil {
+			t.Error("CreateItem succeeded")
+		}
+		if calls.Load() != 1 {
+			t.Errorf("%d calls, want 1", calls.Load())
+		}
+	})
+
+	t.Run("POST is retried when rate limited", func(t *testing.T) {
+		server, calls := flakyServer(t, http.StatusTooManyRequests, 1, "0")
+		if _, err := newTestClient(t, server, "").CreateItem(ctx, ItemInput{}); err != nil {
+			t.Errorf("CreateItem: %v", err)
+		}
+		if calls.Load() != 2 {
+			t.Errorf("%d calls, want 2", calls.Load())
+		}
+	})
+
+	t.Run("Retry-After beyond MaxBackoff is not waited for", func(t *testing.T) {
+		server, calls := flakyServer(t, http.StatusTooManyRequests, 1, "3600")
+		_, err := newTestClient(t, server, "").ListItems(ctx)
+		var apiErr *Error
+		if !errors.As(err, &apiErr) || apiErr.RetryAfter != time.Hour {
+			t.Errorf("ListItems = %v", err)
+		}
+		if calls.Load() != 1 {
+			t.Errorf("%d calls, want 1", calls.Load())
+		}
+	})
+
+	t.Run("canceled context stops retrying", func(t *testing.T) {
+		server, _ := flakyServer(t, http.StatusServiceUnavailable, 10, "")
+		c, _ := New(server.URL, Options{Retry: RetryPolicy{MaxAttempts: 10, MinBackoff: time.Second, MaxBackoff: time.Second}})
+		ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		_, err := c.ListItems(ctx)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("ListItems = %v, want deadline exceeded", err)
+		}
+		if time.Since(start) > 500*time.Millisecond {
+			t.Errorf("took %v after the deadline", time.Since(start))
+		}
+	})
+}
+
+func TestNewRejectsBadURLs(t *testing.T) {
+	for _, u := range []string{"localhost:8080", "ftp://example.com", "://"} {
+		if _, err := New(u, Options{}); err == nil {
+			t.Errorf("New(%q) succeeded", u)
+		}
+	}
+}
+
+func TestTraceContextPropagation(t *testing.T) {
+	previous := otel.GetTextMapPropagator()
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { otel.SetTextMapPropagator(previous) })
+
+	var traceparent string
+	server, _ := newTestServer(t, false, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			traceparent = r.Header.Get("traceparent")
+			next.ServeHTTP(w, r)
+		})
+	})
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+	}))
+	if _, err := newTestClient(t, server, "").ListItems(ctx); err != nil {
+		t.Fatalf("ListItems: %v", err)
+	}
+
+	if want := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"; traceparent != want {
+		t.Errorf("traceparent = %q, want %q", traceparent, want)
+	}
+}
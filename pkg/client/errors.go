@@ -0,0 +1,149 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// FieldError is what's wrong with one field of a rejected request
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Error is an error response from the API
+type Error struct {
+	// StatusCode is the HTTP status
+	StatusCode int
+
+	// Code is the stable error code, e.g. "item_not_found" or
+	// "validation_failed"
+	Code string
+
+	// Message describes the error for people
+	Message string
+
+	// Details are the invalid fields of a validation error
+	Details []FieldError
+
+	// RetryAfter is how long the server asked to wait before retrying,
+	// if it did
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("listing API: %s (status %d)", e.Message, e.StatusCode)
+	}
+	return fmt.Sprintf("listing API: %s (status %d, %s)", e.Message, e.StatusCode, e.Code)
+}
+
+// IsNotFound reports whether err is an API error saying the item or route
+// doesn't exist
+func IsNotFound(err error) bool {
+	return hasStatus(err, http.StatusNotFound)
+}
+
+// IsValidation reports whether err is an API error rejecting the request
+// as invalid. The fields are in its Details.
+func IsValidation(err error) bool {
+	return hasStatus(err, http.StatusBadRequest)
+}
+
+// IsUnauthorized reports whether err is an API error for a missing or
+// invalid token
+func IsUnauthorized(err error) bool {
+	return hasStatus(err, http.StatusUnauthorized)
+}
+
+// IsForbidden reports whether err is an API error for a token without the
+// needed permission
+func IsForbidden(err error) bool {
+	return hasStatus(err, http.StatusForbidden)
+}
+
+func hasStatus(err error, status int) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.StatusCode == status
+}
+
+// maxErrorBody bounds how much of an error response is read
+const maxErrorBody = 64 << 10
+
+// decodeError returns the *Error for an error response. Both the usual
+// Response and RFC 7807 problem details are understood; anything else
+// keeps the status text.
+func decodeError(resp *http.Response) *Error {
+	e := &Error{
+		StatusCode: resp.StatusCode,
+		Message:    http.StatusText(resp.StatusCode),
+		RetryAfter: retryAfter(resp.Header.Get("Retry-After")),
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	if err != nil || len(body) == 0 {
+		return e
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/problem+json":
+		var problem struct {
+			Title  string       `json:"title"`
+			Detail string       `json:"detail"`
+			Code   string       `json:"code"`
+			Errors []FieldError `json:"errors"`
+		}
+		if json.Unmarshal(body, &problem) == nil {
+			e.Code = problem.Code
+			e.Details = problem.Errors
+			if problem.Detail != "" {
+				e.Message = problem.Detail
+			} else if problem.Title != "" {
+				e.Message = problem.Title
+			}
+		}
+	case "application/json":
+		var r struct {
+			Error   string       `json:"error"`
+			Message string       `json:"message"`
+			Code    string       `json:"code"`
+			Details []FieldError `json:"details"`
+		}
+		if json.Unmarshal(body, &r) == nil {
+			e.Code = r.Code
+			e.Details = r.Details
+			if r.Error != "" {
+				e.Message = r.Error
+			} else if r.Message != "" {
+				e.Message = r.Message
+			}
+		}
+	}
+
+	return e
+}
+
+// retryAfter parses a Retry-After header given in seconds or as an HTTP
+// date. Zero means there's none.
+func retryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
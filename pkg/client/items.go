@@ -0,0 +1,240 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// Item is a listing item
+type Item struct {
+	UID         string    `json:"uid"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// ItemInput is the content of an item to create, or to replace an item's
+// with
+type ItemInput struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+}
+
+// ItemPatch changes some fields of an item. Nil fields keep their value.
+type ItemPatch struct {
+	Title       *string `json:"title,omitempty"`
+	Description *string `json:"description,omitempty"`
+}
+
+// Permissions are the caller's roles and what they allow
+type Permissions struct {
+	Subject     string   `json:"subject"`
+	Name        string   `json:"name"`
+	Method      string   `json:"method"`
+	Roles       []string `json:"roles"`
+	Permissions []string `json:"permissions"`
+}
+
+// BackupHeader describes a backup archive
+type BackupHeader struct {
+	Format    string    `json:"format"`
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	ItemCount int       `json:"item_count"`
+	Checksum  string    `json:"checksum"`
+}
+
+// Health is the status reported by the health check
+type Health struct {
+	Service   string    `json:"service"`
+	Version   string    `json:"version"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// ListOptions selects a page of items
+type ListOptions struct {
+	// After is the UID of the last item already seen; the page starts
+	// after it. Empty means the first page.
+	After string
+
+	// Limit is the page size. Zero means the server's default.
+	Limit int
+}
+
+// ItemPage is one page of items
+type ItemPage struct {
+	Items []Item
+
+	// Next selects the following page, or is nil if this is the last one
+	Next *ListOptions
+}
+
+// Health calls the health check
+func (c *Client) Health(ctx context.Context) (Health, error) {
+	var health Health
+	_, err := c.call(ctx, http.MethodGet, "/health", nil, nil, &health)
+	return health, err
+}
+
+// ListItems returns all items in one response. Use Items or ListItemsPage
+// for large collections.
+func (c *Client) ListItems(ctx context.Context) ([]Item, error) {
+	var items []Item
+	_, err := c.call(ctx, http.MethodGet, "/items", nil, nil, &items)
+	return items, err
+}
+
+// ListItemsPage returns one page of items, in creation order
+func (c *Client) ListItemsPage(ctx context.Context, opts ListOptions) (ItemPage, error) {
+	// An empty after still asks for a page rather than every item
+	query := url.Values{}
+	query.Set("after", opts.After)
+	if opts.Limit > 0 {
+		query.Set("limit", strconv.Itoa(opts.Limit))
+	}
+
+	var page ItemPage
+	header, err := c.call(ctx, http.MethodGet, "/items", query, nil, &page.Items)
+	if err != nil {
+		return ItemPage{}, err
+	}
+	page.Next = nextPage(header)
+	return page, nil
+}
+
+// Items iterates over all items, in creation order, fetching pageSize at a
+// time (zero means the server's default). Iteration stops after the first
+// error, which is yielded with a zero Item.
+func (c *Client) Items(ctx context.Context, pageSize int) iter.Seq2[Item, error] {
+	return func(yield func(Item, error) bool) {
+		opts := &ListOptions{Limit: pageSize}
+		for opts != nil {
+			page, err := c.ListItemsPage(ctx, *opts)
+			if err != nil {
+				yield(Item{}, err)
+				return
+			}
+			for _, item := range page.Items {
+				if !yield(item, nil) {
+					return
+				}
+			}
+			opts = page.Next
+		}
+	}
+}
+
+// GetItem returns the item with the given UID
+func (c *Client) GetItem(ctx context.Context, id string) (Item, error) {
+	var item Item
+	_, err := c.call(ctx, http.MethodGet, itemPath(id), nil, nil, &item)
+	return item, err
+}
+
+// CreateItem creates an item
+func (c *Client) CreateItem(ctx context.Context, in ItemInput) (Item, error) {
+	var item Item
+	_, err := c.call(ctx, http.MethodPost, "/items", nil, in, &item)
+	return item, err
+}
+
+// UpdateItem replaces the title and description of an item
+func (c *Client) UpdateItem(ctx context.Context, id string, in ItemInput) (Item, error) {
+	var item Item
+	_, err := c.call(ctx, http.MethodPut, itemPath(id), nil, in, &item)
+	return item, err
+}
+
+// PatchItem changes the fields of an item that patch sets
+func (c *Client) PatchItem(ctx context.Context, id string, patch ItemPatch) (Item, error) {
+	var item Item
+	_, err := c.call(ctx, http.MethodPatch, itemPath(id), nil, patch, &item)
+	return item, err
+}
+
+// DeleteItem deletes an item
+func (c *Client) DeleteItem(ctx context.Context, id string) error {
+	_, err := c.call(ctx, http.MethodDelete, itemPath(id), nil, nil, nil)
+	return err
+}
+
+// MyPermissions returns the caller's roles and permissions
+func (c *Client) MyPermissions(ctx context.Context) (Permissions, error) {
+	var perms Permissions
+	_, err := c.call(ctx, http.MethodGet, "/me/permissions", nil, nil, &perms)
+	return perms, err
+}
+
+// Backup downloads a backup archive of all items to w and returns its size
+func (c *Client) Backup(ctx context.Context, w io.Writer) (int64, error) {
+	resp, err := c.do(ctx, request{method: http.MethodGet, path: "/admin/backup"})
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	n, err := io.Copy(w, resp.Body)
+	if err != nil {
+		return n, fmt.Errorf("downloading backup: %w", err)
+	}
+	return n, nil
+}
+
+// Restore replaces all items with the contents of the backup archive
+func (c *Client) Restore(ctx context.Context, archive io.Reader) (BackupHeader, error) {
+	// Read it whole, so the request can be resent if rate limited
+	body, err := io.ReadAll(archive)
+	if err != nil {
+		return BackupHeader{}, fmt.Errorf("reading backup: %w", err)
+	}
+
+	resp, err := c.do(ctx, request{
+		method:      http.MethodPost,
+		path:        "/admin/restore",
+		body:        body,
+		contentType: "application/gzip",
+	})
+	if err != nil {
+		return BackupHeader{}, err
+	}
+	defer resp.Body.Close()
+
+	var header BackupHeader
+	return header, decodeData(resp, &header)
+}
+
+// itemPath returns the path of the item with the given UID
+func itemPath(id string) string {
+	return "/items/" + url.PathEscape(id)
+}
+
+// nextLinkPattern finds the target of a Link header's rel="next"
+var nextLinkPattern = regexp.MustCompile(`<([^>]*)>\s*;\s*rel="?next"?`)
+
+// nextPage returns the page the Link header points to, or nil if there's
+// none
+func nextPage(header http.Header) *ListOptions {
+	m := nextLinkPattern.FindStringSubmatch(header.Get("Link"))
+	if m == nil {
+		return nil
+	}
+	u, err := url.Parse(m[1])
+	if err != nil {
+		return nil
+	}
+
+	after := u.Query().Get("after")
+	if after == "" {
+		return nil
+	}
+	limit, _ := strconv.Atoi(u.Query().Get("limit"))
+	return &ListOptions{After: after, Limit: limit}
+}
@@ -0,0 +1,111 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"time"
+)
+
+// RetryPolicy says how failed requests are retried. Requests are retried on
+// network errors and 502, 503 and 504 responses if they're idempotent (GET,
+// PUT and DELETE), and on 429 responses whatever the method, since those
+// were turned away before doing anything.
+type RetryPolicy struct {
+	// MaxAttempts is the most times a request is sent. Zero means 3; 1
+	// turns retries off.
+	MaxAttempts int
+
+	// MinBackoff is the wait before the first retry, doubled for each
+	// one after. Zero means 200ms.
+	MinBackoff time.Duration
+
+	// MaxBackoff caps the wait between retries. A Retry-After longer than
+	// this isn't waited for; the error is returned instead. Zero means 10s.
+	MaxBackoff time.Duration
+}
+
+// Defaults of RetryPolicy
+const (
+	DefaultMaxAttempts = 3
+	DefaultMinBackoff  = 200 * time.Millisecond
+	DefaultMaxBackoff  = 10 * time.Second
+)
+
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = DefaultMaxAttempts
+	}
+	if p.MinBackoff <= 0 {
+		p.MinBackoff = DefaultMinBackoff
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = DefaultMaxBackoff
+	}
+	return p
+}
+
+// backoff returns how long to wait before retrying a request that failed
+// with err on the given attempt, and whether to retry at all
+func (p RetryPolicy) backoff(method string, attempt int, err error) (time.Duration, bool) {
+	if attempt >= p.MaxAttempts || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return 0, false
+	}
+
+	var apiErr *Error
+	switch {
+	case !errors.As(err, &apiErr):
+		// Network error: the request may or may not have been handled
+		if !idempotent(method) {
+			return 0, false
+		}
+	case apiErr.StatusCode == http.StatusTooManyRequests:
+	case apiErr.StatusCode == http.StatusBadGateway,
+		apiErr.StatusCode == http.StatusServiceUnavailable,
+		apiErr.StatusCode == http.StatusGatewayTimeout:
+		if !idempotent(method) {
+			return 0, false
+		}
+	default:
+		return 0, false
+	}
+
+	if apiErr != nil && apiErr.RetryAfter > 0 {
+		if apiErr.RetryAfter > p.MaxBackoff {
+			return 0, false
+		}
+		return apiErr.RetryAfter, true
+	}
+
+	// Exponential backoff with full jitter, so clients that failed
+	// together don't retry together
+	wait := p.MinBackoff << (attempt - 1)
+	if wait > p.MaxBackoff || wait <= 0 {
+		wait = p.MaxBackoff
+	}
+	return rand.N(wait) + 1, true
+}
+
+// idempotent reports whether sending a request with method twice has the
+// same effect as sending it once
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// sleep waits for d or until ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}